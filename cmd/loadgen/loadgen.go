@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchclient"
+)
+
+type config struct {
+	URL         string
+	Token       string
+	QPS         float64
+	Duration    time.Duration
+	Concurrency int
+	Timeout     time.Duration
+	// пул соединений клиента, см. searchclient.TransportConfig
+	Transport searchclient.TransportConfig
+	Rand      *rand.Rand
+}
+
+// interval - пауза между запросами при cfg.QPS, 0 - QPS так велик, что пауза меньше наносекунды
+func (c config) interval() time.Duration {
+	return time.Duration(float64(time.Second) / c.QPS)
+}
+
+// validate проверяет параметры прогона до старта: с нулевым интервалом time.NewTicker паникует
+func (c config) validate() error {
+	if c.QPS <= 0 || c.Concurrency <= 0 {
+		return fmt.Errorf("qps and concurrency must be > 0")
+	}
+	if c.interval() <= 0 {
+		return fmt.Errorf("qps must be at most %g", float64(time.Second))
+	}
+	return nil
+}
+
+var (
+	queryWords  = []string{"", "", "nisi", "Boyd", "ex", "dolor", "velit", "zzz"}
+	orderFields = []string{"", "Id", "Name", "Age"}
+)
+
+// randomQuery генерирует запрос поиска, похожий на живой трафик
+func randomQuery(rnd *rand.Rand) model.SearchRequest {
+	return model.SearchRequest{
+		Limit:      1 + rnd.Intn(25),
+		Offset:     rnd.Intn(3) * 25,
+		Query:      queryWords[rnd.Intn(len(queryWords))],
+		OrderField: orderFields[rnd.Intn(len(orderFields))],
+		OrderBy:    rnd.Intn(3) - 1,
+	}
+}
+
+type result struct {
+	latency time.Duration
+	// пусто - успешный запрос
+	err string
+}
+
+// report - итог прогона
+type report struct {
+	Elapsed   time.Duration
+	Requests  int
+	Dropped   int
+	Latencies []time.Duration
+	ErrorsBy  map[string]int
+}
+
+func (r *report) Errors() int {
+	n := 0
+	for _, c := range r.ErrorsBy {
+		n += c
+	}
+	return n
+}
+
+// failure - почему прогон провален, пусто - хотя бы один запрос прошёл. Прогон, в котором
+// ни один запрос не ушёл, - отдельная беда: сервер тут ни при чём, не хватило слотов или времени
+func (r *report) failure() (string, int) {
+	switch {
+	case r.Requests == 0:
+		return fmt.Sprintf("no requests sent, %d dropped: raise -concurrency or -duration", r.Dropped), 3
+	case r.Errors() == r.Requests:
+		return "all requests failed", 1
+	}
+	return "", 0
+}
+
+func (r *report) percentile(p int) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	rank := (p*len(r.Latencies) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return r.Latencies[rank-1]
+}
+
+func (r *report) print(w io.Writer) {
+	fmt.Fprintf(w, "requests: %d, dropped: %d, errors: %d, elapsed: %s\n", r.Requests, r.Dropped, r.Errors(), r.Elapsed.Round(time.Millisecond))
+	if r.Elapsed > 0 {
+		fmt.Fprintf(w, "throughput: %.1f req/s\n", float64(r.Requests)/r.Elapsed.Seconds())
+	}
+	if len(r.Latencies) > 0 {
+		fmt.Fprintf(w, "latency: p50 %s, p90 %s, p99 %s, max %s\n",
+			r.percentile(50), r.percentile(90), r.percentile(99), r.Latencies[len(r.Latencies)-1])
+	}
+	kinds := make([]string, 0, len(r.ErrorsBy))
+	for kind := range r.ErrorsBy {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "  %6d  %s\n", r.ErrorsBy[kind], kind)
+	}
+}
+
+// run шлёт запросы с постоянной частотой cfg.QPS в течение cfg.Duration.
+// Если все cfg.Concurrency слотов заняты, очередной запрос отбрасывается, а не копится:
+// иначе при медленном сервере генератор сам стал бы узким местом и исказил частоту
+func run(cfg config) *report {
+	client := searchclient.NewSearchClient(cfg.Token, cfg.URL, searchclient.WithTimeout(cfg.Timeout),
+		searchclient.WithConnectionPool(cfg.Transport))
+	results := make(chan result, cfg.Concurrency)
+	slots := make(chan struct{}, cfg.Concurrency)
+
+	rep := &report{ErrorsBy: map[string]int{}}
+	collected := make(chan struct{})
+	go func() {
+		for res := range results {
+			rep.Requests++
+			if res.err != "" {
+				rep.ErrorsBy[res.err]++
+				continue
+			}
+			rep.Latencies = append(rep.Latencies, res.latency)
+		}
+		close(collected)
+	}()
+
+	ticker := time.NewTicker(cfg.interval())
+	defer ticker.Stop()
+	start := time.Now()
+	deadline := time.NewTimer(cfg.Duration)
+	defer deadline.Stop()
+
+	var wg sync.WaitGroup
+loop:
+	for {
+		select {
+		case <-deadline.C:
+			break loop
+		case <-ticker.C:
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			rep.Dropped++
+			continue
+		}
+		req := randomQuery(cfg.Rand)
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results <- search(client, req)
+		}()
+	}
+	wg.Wait()
+	close(results)
+	<-collected
+
+	rep.Elapsed = time.Since(start)
+	sort.Slice(rep.Latencies, func(i, j int) bool { return rep.Latencies[i] < rep.Latencies[j] })
+	return rep
+}
+
+func search(client *searchclient.SearchClient, req model.SearchRequest) result {
+	start := time.Now()
+	if _, err := client.FindUsers(req); err != nil {
+		return result{err: errorKind(err)}
+	}
+	return result{latency: time.Since(start)}
+}
+
+// errorKind сводит ошибки клиента к видам: в тексте таймаута и транспортной ошибки есть подробности запроса
+func errorKind(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "timeout for"):
+		return "timeout"
+	case strings.HasPrefix(msg, "unknown error"):
+		return "transport error"
+	}
+	return msg
+}
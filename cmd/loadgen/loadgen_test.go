@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"final_task_golang/searchtest"
+)
+
+func TestRun(t *testing.T) {
+	srv := searchtest.NewServer([]searchtest.User{{Id: 1, Name: "Boyd Wolf"}, {Id: 2, Name: "Hilda Mayer"}})
+	defer srv.Close()
+
+	rep := run(config{
+		URL:         srv.URL,
+		Token:       srv.Token,
+		QPS:         200,
+		Duration:    200 * time.Millisecond,
+		Concurrency: 8,
+		Timeout:     time.Second,
+		Rand:        rand.New(rand.NewSource(1)),
+	})
+	if rep.Requests < 10 || rep.Errors() != 0 || len(rep.Latencies) != rep.Requests {
+		t.Errorf("Error : unexpected report %+v", rep)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	srv := searchtest.NewServer(nil)
+	defer srv.Close()
+
+	rep := run(config{
+		URL:         srv.URL,
+		Token:       "bad",
+		QPS:         100,
+		Duration:    100 * time.Millisecond,
+		Concurrency: 4,
+		Timeout:     time.Second,
+		Rand:        rand.New(rand.NewSource(1)),
+	})
+	if rep.Requests == 0 || rep.ErrorsBy["Bad AccessToken"] != rep.Requests {
+		t.Errorf("Error : unexpected report %+v", rep)
+	}
+}
+
+// медленный сервер не должен замедлять генератор: лишние запросы отбрасываются
+func TestRunDropsWhenSaturated(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+	time.AfterFunc(300*time.Millisecond, func() { close(release) })
+
+	rep := run(config{
+		URL:         ts.URL,
+		QPS:         100,
+		Duration:    200 * time.Millisecond,
+		Concurrency: 2,
+		Timeout:     time.Second,
+		Rand:        rand.New(rand.NewSource(1)),
+	})
+	if rep.Requests != 2 || rep.Dropped == 0 {
+		t.Errorf("Error : unexpected report %+v", rep)
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+	rep := &report{
+		Elapsed:   time.Second,
+		Requests:  4,
+		Latencies: []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond},
+		ErrorsBy:  map[string]int{"timeout": 1},
+	}
+	var buf bytes.Buffer
+	rep.print(&buf)
+	for _, want := range []string{"requests: 4", "errors: 1", "throughput: 4.0 req/s", "p50 2ms", "max 3ms", "timeout"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Error : %q not in %q", want, buf.String())
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, cfg := range []config{
+		{QPS: 0, Concurrency: 1},
+		{QPS: 10, Concurrency: 0},
+		{QPS: 2e9, Concurrency: 1},
+	} {
+		if cfg.validate() == nil {
+			t.Errorf("Error : %+v accepted", cfg)
+		}
+	}
+	if err := (config{QPS: 1e9, Concurrency: 1}).validate(); err != nil {
+		t.Errorf("Error : %v", err)
+	}
+}
+
+func TestReportFailure(t *testing.T) {
+	for _, c := range []struct {
+		rep  report
+		code int
+	}{
+		{report{Requests: 2, ErrorsBy: map[string]int{"timeout": 1}}, 0},
+		{report{Requests: 2, ErrorsBy: map[string]int{"timeout": 2}}, 1},
+		{report{Dropped: 5, ErrorsBy: map[string]int{}}, 3},
+	} {
+		if msg, code := c.rep.failure(); code != c.code || (code == 0) != (msg == "") {
+			t.Errorf("Error : %+v: %q %d, want %d", c.rep, msg, code, c.code)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Команда loadgen нагружает сервер поиска случайными запросами с заданным QPS
+// и печатает перцентили задержки, разбивку ошибок и фактическую пропускную способность:
+//
+//	go run ./cmd/loadgen -url http://localhost:8080/ -token abc-def -qps 200 -duration 30s
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+)
+
+func main() {
+	cfg := config{}
+	flag.StringVar(&cfg.URL, "url", "http://localhost:8080/", "адрес сервера поиска")
+	flag.StringVar(&cfg.Token, "token", "", "токен доступа")
+	flag.Float64Var(&cfg.QPS, "qps", 50, "запросов в секунду")
+	flag.DurationVar(&cfg.Duration, "duration", 10*time.Second, "длительность нагрузки")
+	flag.IntVar(&cfg.Concurrency, "concurrency", 64, "максимум одновременных запросов, лишние отбрасываются")
+	flag.DurationVar(&cfg.Timeout, "timeout", time.Second, "таймаут одного запроса")
+	flag.IntVar(&cfg.Transport.MaxIdleConnsPerHost, "max-idle-per-host", 0, "простаивающих соединений к серверу, 0 - по умолчанию")
+	flag.DurationVar(&cfg.Transport.IdleConnTimeout, "idle-timeout", 0, "когда закрывать простаивающее соединение, 0 - по умолчанию")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "зерно генератора запросов")
+	flag.Parse()
+
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cfg.Rand = rand.New(rand.NewSource(*seed))
+
+	report := run(cfg)
+	report.print(os.Stdout)
+	if msg, code := report.failure(); code != 0 {
+		fmt.Fprintln(os.Stderr, msg)
+		os.Exit(code)
+	}
+}
@@ -0,0 +1,270 @@
+// Команда searchserver поднимает сервер поиска пользователей над xml-датасетом:
+//
+//	go run ./cmd/searchserver -addr :8080 -dataset dataset.xml -tokens tokens.json
+//
+// Токены перечитываются из файла по SIGHUP, по SIGINT/SIGTERM сервер дожидается текущих запросов
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"plugin"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+	"final_task_golang/pkg/searchserver"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "адрес http-сервера")
+	grpcAddr := flag.String("grpc-addr", "", "адрес gRPC-сервера, пусто - gRPC выключен")
+	dataset := flag.String("dataset", "dataset.xml", "xml- или json-файл с пользователями")
+	fields := flag.String("fields", "", "откуда брать доп. поля пользователя, например Email=mail,Phone=")
+	snapshot := flag.Bool("snapshot", true, "кэшировать разобранный датасет в бинарном снимке рядом с файлом")
+	loadPolicy := flag.String("load-policy", "fail", "строки датасета с ошибками: fail - не запускаться, skip - пропустить, quarantine - пропустить и сложить в файл")
+	duplicates := flag.String("duplicates", "reject", "повторы Id в датасете: reject - не запускаться, keep-first или keep-last")
+	tokensFile := flag.String("tokens", "", `json-файл с токенами {"токен": "search"|"admin"|{"scope": "search", "priority": "low"|"normal"|"high"}}`)
+	computed := flag.String("computed", "", "вычисляемые поля через запятую, например AgeDecade=bucket:Age:10,Initial=initial:Name; виды bucket, initial, words, length")
+	plugins := flag.String("plugins", "", "Go-плагины (.so) через запятую: в init они регистрируют поля searchcore.RegisterField и стратегии RegisterMatcher")
+	synonymsFile := flag.String("synonyms", "", "файл с наборами синонимов query, по набору в строке через запятую: dev, developer")
+	cfg := searchserver.ServerConfig{}
+	flag.IntVar(&cfg.CacheSize, "cache", 0, "размер кэша страниц поиска, 0 - без кэша")
+	flag.BoolVar(&cfg.CompactAbout, "compact-about", false, "хранить About в одной арене, экономит память на больших датасетах")
+	flag.IntVar(&cfg.DefaultLimit, "default-limit", 0, "limit поиска без limit, 0 - max-limit")
+	flag.IntVar(&cfg.MaxLimit, "max-limit", 0, "наибольший limit поиска, 0 - без ограничения")
+	flag.IntVar(&cfg.MaxOffset, "max-offset", 0, "наибольший offset поиска, 0 - без ограничения")
+	flag.StringVar(&cfg.SavedSearchesFile, "saved-searches", "", "json-файл с сохранёнными поисками токенов, без него они живут до перезапуска")
+	flag.DurationVar(&cfg.AlertMinInterval, "alert-min-interval", 0, "самый короткий период алерта, 0 - минута")
+	flag.StringVar(&cfg.AlertMail.Addr, "alert-smtp", "", "SMTP-сервер host:port для алертов с email, пусто - такие алерты не принимаются")
+	flag.StringVar(&cfg.AlertMail.From, "alert-from", "", "адрес отправителя писем алертов")
+	alertHosts := flag.String("alert-webhook-hosts", "", "хосты через запятую, на которые вебхуки алертов могут ставить токены без прав admin; пусто - только admin")
+	flag.DurationVar(&cfg.IdempotencyWindow, "idempotency-window", 0, "сколько помнить ответы правок с Idempotency-Key, 0 - сутки, меньше нуля - не поддерживать")
+	cacheHints := flag.String("cache-hints", "", "Cache-Control ответов по точкам через запятую, например /=1m:5m:public,/users/{id}=10s - max-age, stale-while-revalidate, public; пусто - без заголовков")
+	flag.BoolVar(&cfg.ContentChecksum, "checksum", false, "отдавать sha256 тела ответа в X-Content-SHA256")
+	securityHeaders := flag.Bool("security-headers", true, "ставить X-Content-Type-Options, Content-Security-Policy и Strict-Transport-Security, убирать Server")
+	flag.DurationVar(&cfg.SecurityHeaders.HSTSMaxAge, "hsts-max-age", 0, "max-age Strict-Transport-Security, 0 - год, меньше 0 - без заголовка")
+	flag.BoolVar(&cfg.SecurityHeaders.TrustForwardedProto, "trust-forwarded-proto", false, "считать запрос с X-Forwarded-Proto: https пришедшим по TLS (только за своим прокси)")
+	tlsCert := flag.String("tls-cert", "", "сертификат для https, вместе с -tls-key; пусто - http")
+	tlsKey := flag.String("tls-key", "", "ключ сертификата для https")
+	flag.IntVar(&cfg.MaxQueryCost, "max-query-cost", 0, "наибольшая оценка стоимости поиска (см. Cost в /search/explain), 0 - без ограничения")
+	flag.IntVar(&cfg.MaxMultiSearch, "max-msearch", 0, "сколько запросов принимает POST /msearch, 0 - 50")
+	flag.IntVar(&cfg.MaxBulk, "max-bulk", 0, "сколько правок принимает POST /users/bulk, 0 - 1000")
+	flag.IntVar(&cfg.PlanCacheSize, "plan-cache", 0, "размер кэша разобранных запросов, 0 - по умолчанию, -1 - без кэша")
+	flag.IntVar(&cfg.History, "history", 0, "сколько прежних версий датасета держать для поиска с as_of")
+	flag.BoolVar(&cfg.Maintenance, "maintenance", false, "запуститься в режиме обслуживания: поиск работает, правки получают 503")
+	flag.IntVar(&cfg.SearchParallelism, "parallelism", 0, "число горутин для фильтрации больших датасетов")
+	flag.IntVar(&cfg.MaxInFlight, "max-inflight", 0, "максимум одновременных поисков, 0 - без ограничения")
+	flag.Int64Var(&cfg.DefaultQuota.Requests, "quota-requests", 0, "суточная квота запросов на токен (кроме admin), 0 - без квоты")
+	flag.Int64Var(&cfg.DefaultQuota.Bytes, "quota-bytes", 0, "суточная квота отданных байт на токен (кроме admin), 0 - без квоты")
+	flag.DurationVar(&cfg.SLO.Target, "slo", 0, "цель по времени ответа поиска, выше неё сервер сбрасывает нагрузку, 0 - выключено")
+	flag.IntVar(&cfg.SLO.Percentile, "slo-percentile", 0, "перцентиль времени ответа для -slo, 0 - 99")
+	flag.IntVar(&cfg.SLO.ShedMaxLimit, "shed-max-limit", 0, "наибольший limit поиска при сбросе нагрузки, 0 - max-limit")
+	flag.BoolVar(&cfg.SLO.ShedCacheOnly, "shed-cache-only", false, "при сбросе нагрузки отвечать на поиск только из кэша")
+	shedTokens := flag.String("shed-tokens", "", "токены через запятую, которым отказывать при сбросе нагрузки")
+	flag.DurationVar(&cfg.SearchTimeout, "search-timeout", 0, "дедлайн одного поиска, 0 - без дедлайна")
+	flag.StringVar(&cfg.OrderLocale, "order-locale", "", "локаль сортировки по имени, например de, пусто - побайтово")
+	flag.Float64Var(&cfg.Boosts.Name, "boost-name", 1, "вес совпадения в Name при сортировке по релевантности (order_field=Score)")
+	flag.Float64Var(&cfg.Boosts.About, "boost-about", 1, "вес совпадения в About при сортировке по релевантности")
+	flag.StringVar(&cfg.TextSearch.Language, "text-language", "", "поиск по словам About: english, simple или auto - по языку записи, пусто - подстрокой")
+	stopwords := flag.String("stopwords", "", "стоп-слова через запятую вместо списка по умолчанию для языка")
+	analyzers := flag.String("analyzers", "", `json-файл с цепочками анализа по полям: {"About": {"Tokenizer": "letters", "Filters": ["lowercase", "stopwords", "stem", "synonyms"]}, "Name": {...}}`)
+	flag.DurationVar(&cfg.SlowQueryThreshold, "slow-query", 0, "порог журнала медленных запросов, 0 - журнал выключен")
+	auditPath := flag.String("audit", "", "файл журнала аудита, пусто - аудит не ведётся")
+	redact := flag.String("redact", "", "права через запятую, для которых ответы обезличиваются, например search")
+	flag.StringVar(&cfg.Redact.Salt, "redact-salt", "", "соль псевдонимов обезличивания")
+	downstream := flag.String("downstream", "", "адреса серверов поиска через запятую: режим агрегатора, датасет не загружается")
+	flag.StringVar(&cfg.Aggregate.AccessToken, "downstream-token", "", "токен для нижестоящих серверов")
+	flag.DurationVar(&cfg.Aggregate.Timeout, "downstream-timeout", 0, "таймаут запроса к нижестоящему серверу, 0 - по умолчанию")
+	flag.DurationVar(&cfg.Aggregate.CacheTTL, "cache-ttl", 0, "время жизни страницы в кэше агрегатора, 0 - без ограничения")
+	replicas := flag.String("replicas", "", "адреса реплик через запятую, которым рассылаются датасет и правки")
+	flag.StringVar(&cfg.Replication.AccessToken, "replica-token", "", "admin-токен на репликах")
+	var webhook searchserver.WebhookConfig
+	flag.StringVar(&webhook.URL, "webhook", "", "адрес вебхука об изменениях датасета, пусто - не оповещать")
+	flag.StringVar(&webhook.Secret, "webhook-secret", "", "секрет подписи вебхука (HMAC-SHA256)")
+	webhookEvents := flag.String("webhook-events", "", "события вебхука через запятую, пусто - все")
+	walPath := flag.String("wal", "", "журнал правок датасета, пусто - правки теряются при перезапуске")
+	flag.DurationVar(&cfg.WALCompactInterval, "wal-compact", 0, "как часто сжимать журнал правок, 0 - по умолчанию")
+	flag.Parse()
+
+	if *shedTokens != "" {
+		cfg.SLO.ShedTokens = strings.Split(*shedTokens, ",")
+	}
+	if *alertHosts != "" {
+		cfg.AlertWebhookHosts = strings.Split(*alertHosts, ",")
+	}
+	if *stopwords != "" {
+		cfg.TextSearch.Stopwords = strings.Split(*stopwords, ",")
+	}
+	if *analyzers != "" {
+		loaded, err := searchcore.LoadAnalyzers(*analyzers)
+		if err != nil {
+			log.Fatalf("load analyzers: %v", err)
+		}
+		cfg.TextSearch.Analyzers = loaded
+	}
+	if err := cfg.TextSearch.Validate(); err != nil {
+		log.Fatalf("text search: %v", err)
+	}
+	// поля и стратегии регистрируются до загрузки датасета, чтобы по ним построились индексы
+	if *plugins != "" {
+		for _, path := range strings.Split(*plugins, ",") {
+			if _, err := plugin.Open(strings.TrimSpace(path)); err != nil {
+				log.Fatalf("plugins: %v", err)
+			}
+		}
+	}
+	specs, err := searchcore.ParseFieldSpecs(*computed)
+	if err != nil {
+		log.Fatalf("computed: %v", err)
+	}
+	for _, spec := range specs {
+		field, err := spec.Field()
+		if err == nil {
+			err = searchcore.RegisterField(spec.Name, field)
+		}
+		if err != nil {
+			log.Fatalf("computed: %v", err)
+		}
+	}
+	cfg.SecurityHeaders.Disabled = !*securityHeaders
+	if cfg.CacheHints, err = searchserver.ParseCacheHints(*cacheHints); err != nil {
+		log.Fatalf("cache-hints: %v", err)
+	}
+	mapping, err := searchserver.ParseFieldMapping(*fields)
+	if err != nil {
+		log.Fatalf("fields: %v", err)
+	}
+	var loadOpts searchserver.LoadOptions
+	if loadOpts.Policy, err = searchserver.ParseLoadPolicy(*loadPolicy); err != nil {
+		log.Fatalf("load-policy: %v", err)
+	}
+	if loadOpts.Duplicates, err = searchserver.ParseDuplicatePolicy(*duplicates); err != nil {
+		log.Fatalf("duplicates: %v", err)
+	}
+	cfg.FieldMapping = mapping
+	if *replicas != "" {
+		for _, u := range strings.Split(*replicas, ",") {
+			cfg.Replication.Replicas = append(cfg.Replication.Replicas, strings.TrimSpace(u))
+		}
+	}
+	if webhook.URL != "" {
+		if *webhookEvents != "" {
+			webhook.Events = strings.Split(*webhookEvents, ",")
+		}
+		cfg.Webhooks = append(cfg.Webhooks, webhook)
+	}
+	var users []model.User
+	var loadStats searchserver.LoadStats
+	if *downstream != "" {
+		for _, u := range strings.Split(*downstream, ",") {
+			cfg.Aggregate.URLs = append(cfg.Aggregate.URLs, strings.TrimSpace(u))
+		}
+	} else if *snapshot {
+		var fromSnapshot bool
+		users, loadStats, fromSnapshot, err = searchserver.LoadDatasetSnapshotChecked(*dataset, mapping, loadOpts)
+		if fromSnapshot {
+			log.Printf("dataset loaded from %s", searchserver.SnapshotPath(*dataset))
+		}
+	} else {
+		users, loadStats, err = searchserver.LoadDatasetChecked(*dataset, mapping, loadOpts)
+	}
+	if err != nil {
+		log.Fatalf("load dataset: %v", err)
+	}
+	if *tokensFile != "" {
+		if cfg.Tokens, err = searchserver.LoadTokens(*tokensFile); err != nil {
+			log.Fatalf("load tokens: %v", err)
+		}
+		if cfg.TokenPriorities, err = searchserver.LoadTokenPriorities(*tokensFile); err != nil {
+			log.Fatalf("load tokens: %v", err)
+		}
+		cfg.TokensFile = *tokensFile
+	}
+	if cfg.SavedSearchesFile != "" {
+		if cfg.SavedSearches, err = searchserver.LoadSavedSearches(cfg.SavedSearchesFile); err != nil {
+			log.Fatalf("load saved searches: %v", err)
+		}
+	}
+	if *synonymsFile != "" {
+		if cfg.Synonyms, err = searchcore.LoadSynonyms(*synonymsFile); err != nil {
+			log.Fatalf("load synonyms: %v", err)
+		}
+		cfg.SynonymsFile = *synonymsFile
+	}
+	if *redact != "" {
+		for _, name := range strings.Split(*redact, ",") {
+			scope, err := searchserver.ParseScope(strings.TrimSpace(name))
+			if err != nil {
+				log.Fatalf("redact: %v", err)
+			}
+			cfg.Redact.Scopes = append(cfg.Redact.Scopes, scope)
+		}
+	}
+	if *auditPath != "" {
+		if cfg.AuditLog, err = searchserver.OpenAuditLog(*auditPath); err != nil {
+			log.Fatalf("open audit log: %v", err)
+		}
+		defer cfg.AuditLog.Close()
+	}
+	if *walPath != "" {
+		if cfg.WAL, users, err = searchserver.OpenWAL(*walPath, users); err != nil {
+			log.Fatalf("open wal: %v", err)
+		}
+		defer cfg.WAL.Close()
+	}
+
+	srv := searchserver.NewServer(users, cfg)
+	defer srv.Close()
+	srv.SetLoadStats(loadStats)
+	if cfg.TokensFile != "" {
+		defer srv.ReloadTokensOnSignal()()
+	}
+
+	var gs *grpc.Server
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("grpc listen: %v", err)
+		}
+		gs = grpc.NewServer()
+		srv.RegisterGRPC(gs)
+		go gs.Serve(lis)
+	}
+
+	hs := srv.HTTPServer(*addr)
+	go func() {
+		var err error
+		if *tlsCert != "" || *tlsKey != "" {
+			err = hs.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			err = hs.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("http: %v", err)
+		}
+	}()
+	log.Printf("searchserver: %d users, listening on %s", len(users), *addr)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := hs.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	if gs != nil {
+		gs.GracefulStop()
+	}
+}
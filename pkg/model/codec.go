@@ -0,0 +1,125 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+
+	"final_task_golang/searchpb"
+)
+
+const (
+	FormatJSON = "json"
+	FormatXML  = "xml"
+	FormatCSV  = "csv"
+	// FormatMsgpack - компактный бинарный формат для внутренних клиентов с высоким QPS
+	FormatMsgpack = "msgpack"
+	// FormatProtobuf - сообщение searchpb.SearchResponse, то же, что отдаёт gRPC
+	FormatProtobuf = "protobuf"
+)
+
+// Codec описывает, как список пользователей выглядит в одном из форматов ответа
+type Codec struct {
+	Format      string
+	ContentType string
+	Encode      func(w io.Writer, users []User) error
+	// nil - формат только для выгрузки (csv), клиент его не разбирает
+	Decode func(data []byte) ([]User, error)
+}
+
+// XMLUsers - корневой элемент xml-ответа поиска
+type XMLUsers struct {
+	XMLName xml.Name `xml:"users"`
+	Users   []User   `xml:"user"`
+}
+
+var csvHeader = []string{"Id", "Name", "Age", "About", "Gender", "Email", "Phone", "Company", "Address"}
+
+// Codecs - все поддерживаемые форматы, первый - формат по умолчанию
+var Codecs = []Codec{
+	JSONCodec(StdJSON),
+	{FormatXML, "application/xml", func(w io.Writer, users []User) error {
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		return xml.NewEncoder(w).Encode(XMLUsers{Users: users})
+	}, func(data []byte) ([]User, error) {
+		xmlData := XMLUsers{}
+		err := xml.Unmarshal(data, &xmlData)
+		return xmlData.Users, err
+	}},
+	{FormatCSV, "text/csv", func(w io.Writer, users []User) error {
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		for _, u := range users {
+			cw.Write([]string{strconv.Itoa(u.Id), u.Name, strconv.Itoa(u.Age), u.About, u.Gender, u.Email, u.Phone, u.Company, u.Address})
+		}
+		cw.Flush()
+		return cw.Error()
+	}, nil},
+	{FormatMsgpack, "application/msgpack", msgpackEncodeUsers, msgpackDecodeUsers},
+	{FormatProtobuf, "application/x-protobuf", func(w io.Writer, users []User) error {
+		data, err := proto.Marshal(UsersToProto(users))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}, func(data []byte) ([]User, error) {
+		pbData := &searchpb.SearchResponse{}
+		err := proto.Unmarshal(data, pbData)
+		return UsersFromProto(pbData), err
+	}},
+}
+
+func CodecByFormat(format string) (Codec, bool) {
+	for _, c := range Codecs {
+		if c.Format == format {
+			return c, true
+		}
+	}
+	return Codec{}, false
+}
+
+func UsersToProto(users []User) *searchpb.SearchResponse {
+	resp := &searchpb.SearchResponse{}
+	for _, u := range users {
+		resp.Users = append(resp.Users, &searchpb.User{
+			Id:      int32(u.Id),
+			Name:    u.Name,
+			Age:     int32(u.Age),
+			About:   u.About,
+			Gender:  u.Gender,
+			Email:   u.Email,
+			Phone:   u.Phone,
+			Company: u.Company,
+			Address: u.Address,
+			Deleted: u.Deleted,
+			Version: int32(u.Version),
+		})
+	}
+	return resp
+}
+
+func UsersFromProto(resp *searchpb.SearchResponse) []User {
+	users := []User{}
+	for _, u := range resp.Users {
+		users = append(users, User{
+			Id:      int(u.Id),
+			Name:    u.Name,
+			Age:     int(u.Age),
+			About:   u.About,
+			Gender:  u.Gender,
+			Email:   u.Email,
+			Phone:   u.Phone,
+			Company: u.Company,
+			Address: u.Address,
+			Deleted: u.Deleted,
+			Version: int(u.Version),
+		})
+	}
+	return users
+}
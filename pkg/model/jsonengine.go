@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONEngine - реализация json, через которую клиент и сервер кодируют выдачу поиска.
+// По умолчанию StdJSON (encoding/json). Там, где json упирается в CPU, можно подключить
+// jsoniter или segmentio/encoding адаптером из пары методов
+type JSONEngine interface {
+	// Encode пишет v в w с переводом строки в конце, как json.Encoder
+	Encode(w io.Writer, v interface{}) error
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type stdJSON struct{}
+
+func (stdJSON) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (stdJSON) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// StdJSON - JSONEngine на encoding/json
+var StdJSON JSONEngine = stdJSON{}
+
+// JSONCodec возвращает кодек FormatJSON поверх engine, nil - StdJSON
+func JSONCodec(engine JSONEngine) Codec {
+	if engine == nil {
+		engine = StdJSON
+	}
+	return Codec{FormatJSON, "application/json", func(w io.Writer, users []User) error {
+		return engine.Encode(w, users)
+	}, func(data []byte) ([]User, error) {
+		users := []User{}
+		err := engine.Unmarshal(data, &users)
+		return users, err
+	}}
+}
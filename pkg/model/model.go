@@ -0,0 +1,168 @@
+// Package model - общие для клиента и сервера поиска типы, коды ошибок и форматы ответа
+package model
+
+import "errors"
+
+type User struct {
+	Id     int
+	Name   string
+	Age    int
+	About  string
+	Gender string
+	// контактные поля из датасета, какие элементы строки в них попадают - см. FieldMapping сервера
+	Email   string `json:",omitempty" xml:",omitempty"`
+	Phone   string `json:",omitempty" xml:",omitempty"`
+	Company string `json:",omitempty" xml:",omitempty"`
+	Address string `json:",omitempty" xml:",omitempty"`
+	// выставляется сервером для мягко удалённых записей, видно только с admin-токеном
+	Deleted bool `json:",omitempty" xml:",omitempty"`
+	// версия записи, ведётся сервером: 1 при загрузке, +1 на каждую правку. Её же сервер отдаёт в ETag
+	Version int `json:",omitempty" xml:",omitempty"`
+}
+
+type SearchResponse struct {
+	Users    []User
+	NextPage bool
+	// сервер не успел досмотреть датасет и вернул только найденное к дедлайну (AllowPartial)
+	Partial bool `json:",omitempty"`
+	// идентификатор версии датасета, по которой собрана страница (SnapshotHeader)
+	Snapshot string `json:",omitempty"`
+	// подсказка сервера, сколько страницу можно держать в кэше, nil - не прислал
+	Freshness *Freshness `json:",omitempty"`
+}
+
+type SearchErrorResponse struct {
+	Error string
+	// для ErrorLimitTooLarge - наибольший limit, который примет сервер
+	MaxLimit int `json:",omitempty"`
+	// для ErrorOffsetTooLarge - наибольший offset, который примет сервер
+	MaxOffset int `json:",omitempty"`
+	// для ErrorQueryTooExpensive - оценка запроса и наибольшая, которую примет сервер
+	Cost    int `json:",omitempty"`
+	MaxCost int `json:",omitempty"`
+	// что сделать вместо отклонённого запроса, для людей
+	Hint string `json:",omitempty"`
+}
+
+const (
+	OrderByAsc  = -1
+	OrderByAsIs = 0
+	OrderByDesc = 1
+
+	ErrorBadOrderField = `OrderField invalid`
+)
+
+type SearchRequest struct {
+	Limit      int
+	Offset     int    // Можно учесть после сортировки
+	Query      string // подстрока в 1 из полей
+	OrderField string
+	// -1 по убыванию, 0 как встретилось, 1 по возрастанию
+	OrderBy int
+	// согласиться на неполный результат, если сервер не уложится в свой дедлайн
+	AllowPartial bool
+	// Snapshot из предыдущей страницы: если датасет с тех пор изменился, сервер
+	// ответит ErrSnapshotChanged вместо страницы из другой версии
+	Snapshot string
+	// фильтры по контактным полям, пусто - без фильтра. Email и Company сравниваются
+	// без учёта регистра, Phone - точно, Address - подстрока адреса
+	Email   string
+	Phone   string
+	Company string
+	Address string
+}
+
+// коды ошибок в SearchErrorResponse.Error
+const (
+	ErrorBadUser         = "ErrorBadUser"
+	ErrorUserNotFound    = "ErrorUserNotFound"
+	ErrorVersionMismatch = "ErrorVersionMismatch"
+	ErrorAdminOnly       = "ErrorAdminOnly"
+	ErrorBadFormat       = "ErrorBadFormat"
+	// ErrorOverloaded - все слоты поиска заняты и очередь не успела освободиться
+	ErrorOverloaded = "ErrorOverloaded"
+	// ErrorSnapshotChanged - датасет изменился с момента, когда выдали snapshot
+	ErrorSnapshotChanged = "ErrorSnapshotChanged"
+	// ErrorLimitTooLarge - limit больше максимума сервера, сам максимум - в SearchErrorResponse.MaxLimit
+	ErrorLimitTooLarge = "ErrorLimitTooLarge"
+	// ErrorOffsetTooLarge - offset больше максимума сервера (SearchErrorResponse.MaxOffset),
+	// дальние страницы надо листать курсором, а не offset
+	ErrorOffsetTooLarge = "ErrorOffsetTooLarge"
+	// ErrorQuotaExceeded - суточная квота токена исчерпана, Retry-After - до конца суток по UTC
+	ErrorQuotaExceeded = "ErrorQuotaExceeded"
+	// ErrorRequestNotFound - поиска с таким id нет среди выполняющихся
+	ErrorRequestNotFound = "ErrorRequestNotFound"
+	// ErrorMaintenance - сервер в режиме обслуживания и правок не принимает, поиск работает
+	ErrorMaintenance = "ErrorMaintenance"
+	// ErrorNoDatasetVersion - нет подготовленной версии датасета для переключения или прежней для отката
+	ErrorNoDatasetVersion = "ErrorNoDatasetVersion"
+	// ErrorBadAsOf - as_of не RFC 3339 или сервер - агрегатор без своего датасета
+	ErrorBadAsOf = "ErrorBadAsOf"
+	// ErrorAsOfUnavailable - версии датасета на момент as_of сервер уже не хранит
+	ErrorAsOfUnavailable = "ErrorAsOfUnavailable"
+	// ErrorChangesUnavailable - версии since сервер уже не хранит, датасет надо выгрузить заново
+	ErrorChangesUnavailable = "ErrorChangesUnavailable"
+	// ErrorBadMultiSearch - тело POST /msearch не массив запросов или их больше MaxMultiSearch
+	ErrorBadMultiSearch = "ErrorBadMultiSearch"
+	// ErrorSavedSearchNotFound - у токена нет сохранённого поиска с таким именем
+	ErrorSavedSearchNotFound = "ErrorSavedSearchNotFound"
+	// ErrorBadSavedSearch - тело не объект параметров, имя пустое или сохранённых поисков у токена слишком много
+	ErrorBadSavedSearch = "ErrorBadSavedSearch"
+	// ErrorAlertNotFound - у токена нет алерта с таким именем
+	ErrorAlertNotFound = "ErrorAlertNotFound"
+	// ErrorBadAlert - в алерте нет поиска или получателя, период короче минимального
+	// или алертов у токена слишком много
+	ErrorBadAlert = "ErrorBadAlert"
+	// ErrorQueryTooExpensive - оценка стоимости запроса больше MaxQueryCost сервера,
+	// сама оценка и максимум - в SearchErrorResponse.Cost и MaxCost
+	ErrorQueryTooExpensive = "ErrorQueryTooExpensive"
+	// ErrorBadBulk - тело POST /users/bulk не массив правок, их больше MaxBulk или у правки неизвестный Op
+	ErrorBadBulk = "ErrorBadBulk"
+	// ErrorBadIdempotencyKey - Idempotency-Key длиннее 255 символов
+	ErrorBadIdempotencyKey = "ErrorBadIdempotencyKey"
+	// ErrorIdempotencyKeyReused - с этим Idempotency-Key уже выполнен другой запрос
+	ErrorIdempotencyKeyReused = "ErrorIdempotencyKeyReused"
+	// ErrorIdempotencyInProgress - запрос с этим Idempotency-Key ещё выполняется, повторить позже
+	ErrorIdempotencyInProgress = "ErrorIdempotencyInProgress"
+)
+
+// ошибки проверки параметров поиска, текст ошибки уходит клиенту как код
+var (
+	ErrBadOrderField = errors.New("ErrorBadOrderField")
+	ErrBadLimit      = errors.New("ErrorBadLimit")
+	ErrBadOffset     = errors.New("ErrorBadOffset")
+	// order_locale - не BCP 47 тег
+	ErrBadOrderLocale = errors.New("ErrorBadOrderLocale")
+	// поиск не уложился в дедлайн сервера, а частичный результат не разрешён
+	ErrSearchTimeout = errors.New("ErrorTimeout")
+	// агрегатор не получил ответ от нижестоящего сервера
+	ErrDownstream = errors.New("ErrorDownstream")
+	// запись уже изменили, версия в If-Match устарела
+	ErrVersionMismatch = errors.New(ErrorVersionMismatch)
+	ErrUserNotFound    = errors.New(ErrorUserNotFound)
+	// страница запрошена по snapshot, а датасет уже другой - пагинацию надо начать заново
+	ErrSnapshotChanged = errors.New(ErrorSnapshotChanged)
+	ErrLimitTooLarge   = errors.New(ErrorLimitTooLarge)
+	ErrOffsetTooLarge  = errors.New(ErrorOffsetTooLarge)
+	// запрос дороже бюджета сервера, см. ErrorQueryTooExpensive
+	ErrQueryTooExpensive = errors.New(ErrorQueryTooExpensive)
+	// тело ответа не сошлось с ChecksumHeader - его испортили по дороге
+	ErrChecksumMismatch = errors.New("ErrorChecksumMismatch")
+	// after_id не число или его записи нет, а after_value не задан
+	ErrBadCursor = errors.New("ErrorBadCursor")
+	// matcher не зарегистрирован на сервере, см. searchcore.RegisterMatcher
+	ErrBadMatcher = errors.New("ErrorBadMatcher")
+	// boost_name или boost_about меньше нуля
+	ErrBadBoost = errors.New("ErrorBadBoost")
+	// lang - не код языка из searchcore.DetectLanguage
+	ErrBadLang = errors.New("ErrorBadLang")
+	// в field.<имя> поле не зарегистрировано или у числового поля не число, см. searchcore.RegisterField
+	ErrBadField = errors.New("ErrorBadField")
+	// group_by по полю, по которому не группируют, или вместе с offset и after_id
+	ErrBadGroupBy = errors.New("ErrorBadGroupBy")
+	// фильтр, сортировка или группировка по полю, которое обезличивается для этого токена
+	ErrRedactedField = errors.New("ErrorRedactedField")
+	// сервер уже не помнит версию since в GET /changes, датасет надо выгрузить заново
+	ErrChangesUnavailable  = errors.New(ErrorChangesUnavailable)
+	ErrSavedSearchNotFound = errors.New(ErrorSavedSearchNotFound)
+)
@@ -0,0 +1,343 @@
+package model
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Минимальный кодек MessagePack (https://msgpack.org/) для ответов поиска.
+// Пользователь кодируется map с теми же ключами, что и в json
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+// наибольшая вложенность массивов и map при разборе. Пользователям хватает двух уровней,
+// а без предела глубоко вложенный ввод переполнит стек рекурсивного разбора
+const maxMsgpackDepth = 16
+
+var errMsgpackDepth = fmt.Errorf("msgpack: nesting deeper than %d", maxMsgpackDepth)
+
+type msgpackWriter struct {
+	w   *bufio.Writer
+	buf [9]byte
+}
+
+func (m *msgpackWriter) header(b byte, n uint64, size int) {
+	m.buf[0] = b
+	switch size {
+	case 1:
+		m.buf[1] = byte(n)
+	case 2:
+		binary.BigEndian.PutUint16(m.buf[1:], uint16(n))
+	case 4:
+		binary.BigEndian.PutUint32(m.buf[1:], uint32(n))
+	case 8:
+		binary.BigEndian.PutUint64(m.buf[1:], n)
+	}
+	m.w.Write(m.buf[:1+size])
+}
+
+func (m *msgpackWriter) writeInt(v int64) {
+	switch {
+	case v >= 0 && v < 128:
+		m.w.WriteByte(byte(v))
+	case v < 0 && v >= -32:
+		m.w.WriteByte(byte(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		m.header(0xd2, uint64(uint32(int32(v))), 4)
+	default:
+		m.header(0xd3, uint64(v), 8)
+	}
+}
+
+func (m *msgpackWriter) writeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		m.w.WriteByte(0xa0 | byte(n))
+	case n < 1<<8:
+		m.header(0xd9, uint64(n), 1)
+	case n < 1<<16:
+		m.header(0xda, uint64(n), 2)
+	default:
+		m.header(0xdb, uint64(n), 4)
+	}
+	m.w.WriteString(s)
+}
+
+func (m *msgpackWriter) writeBool(v bool) {
+	if v {
+		m.w.WriteByte(0xc3)
+	} else {
+		m.w.WriteByte(0xc2)
+	}
+}
+
+func (m *msgpackWriter) writeArrayHeader(n int) {
+	switch {
+	case n < 16:
+		m.w.WriteByte(0x90 | byte(n))
+	case n < 1<<16:
+		m.header(0xdc, uint64(n), 2)
+	default:
+		m.header(0xdd, uint64(n), 4)
+	}
+}
+
+func (m *msgpackWriter) writeMapHeader(n int) {
+	switch {
+	case n < 16:
+		m.w.WriteByte(0x80 | byte(n))
+	case n < 1<<16:
+		m.header(0xde, uint64(n), 2)
+	default:
+		m.header(0xdf, uint64(n), 4)
+	}
+}
+
+func msgpackEncodeUsers(w io.Writer, users []User) error {
+	m := &msgpackWriter{w: bufio.NewWriter(w)}
+	m.writeArrayHeader(len(users))
+	for _, u := range users {
+		// необязательные строковые поля пишутся, только если заполнены, как omitempty в json
+		optional := [][2]string{{"Email", u.Email}, {"Phone", u.Phone}, {"Company", u.Company}, {"Address", u.Address}}
+		fields := 5
+		for _, f := range optional {
+			if f[1] != "" {
+				fields++
+			}
+		}
+		if u.Deleted {
+			fields++
+		}
+		if u.Version != 0 {
+			fields++
+		}
+		m.writeMapHeader(fields)
+		m.writeString("Id")
+		m.writeInt(int64(u.Id))
+		m.writeString("Name")
+		m.writeString(u.Name)
+		m.writeString("Age")
+		m.writeInt(int64(u.Age))
+		m.writeString("About")
+		m.writeString(u.About)
+		m.writeString("Gender")
+		m.writeString(u.Gender)
+		for _, f := range optional {
+			if f[1] != "" {
+				m.writeString(f[0])
+				m.writeString(f[1])
+			}
+		}
+		if u.Deleted {
+			m.writeString("Deleted")
+			m.writeBool(true)
+		}
+		if u.Version != 0 {
+			m.writeString("Version")
+			m.writeInt(int64(u.Version))
+		}
+	}
+	return m.w.Flush()
+}
+
+type msgpackReader struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (m *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || m.pos+n > len(m.data) {
+		return nil, errMsgpackShort
+	}
+	b := m.data[m.pos : m.pos+n]
+	m.pos += n
+	return b, nil
+}
+
+func (m *msgpackReader) uint(size int) (uint64, error) {
+	b, err := m.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// value читает одно значение: числа приводятся к int64/float64, строки и bin - к string
+func (m *msgpackReader) value() (interface{}, error) {
+	b, err := m.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return m.mapOf(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return m.arrayOf(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return m.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := m.uint(1 << (c - 0xcc))
+		return int64(n), err
+	case 0xd0:
+		n, err := m.uint(1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := m.uint(2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := m.uint(4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := m.uint(8)
+		return int64(n), err
+	case 0xca:
+		n, err := m.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := m.uint(8)
+		return math.Float64frombits(n), err
+	case 0xd9, 0xda, 0xdb, 0xc4, 0xc5, 0xc6:
+		size := 1
+		switch c {
+		case 0xda, 0xc5:
+			size = 2
+		case 0xdb, 0xc6:
+			size = 4
+		}
+		n, err := m.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		return m.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := m.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return m.arrayOf(int(n))
+	case 0xde, 0xdf:
+		n, err := m.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return m.mapOf(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%x", c)
+}
+
+func (m *msgpackReader) str(n int) (string, error) {
+	b, err := m.next(n)
+	return string(b), err
+}
+
+func (m *msgpackReader) arrayOf(n int) ([]interface{}, error) {
+	if n > len(m.data)-m.pos {
+		return nil, errMsgpackShort
+	}
+	if m.depth >= maxMsgpackDepth {
+		return nil, errMsgpackDepth
+	}
+	m.depth++
+	defer func() { m.depth-- }()
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := m.value()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func (m *msgpackReader) mapOf(n int) (map[string]interface{}, error) {
+	if n > len(m.data)-m.pos {
+		return nil, errMsgpackShort
+	}
+	if m.depth >= maxMsgpackDepth {
+		return nil, errMsgpackDepth
+	}
+	m.depth++
+	defer func() { m.depth-- }()
+	obj := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := m.value()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: map key must be a string")
+		}
+		if obj[key], err = m.value(); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func msgpackDecodeUsers(data []byte) ([]User, error) {
+	m := &msgpackReader{data: data}
+	v, err := m.value()
+	if err != nil {
+		return nil, err
+	}
+	if m.pos != len(data) {
+		return nil, fmt.Errorf("msgpack: trailing data")
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: expected array of users")
+	}
+
+	users := make([]User, 0, len(arr))
+	for _, el := range arr {
+		obj, ok := el.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("msgpack: expected user map")
+		}
+		u := User{}
+		id, _ := obj["Id"].(int64)
+		age, _ := obj["Age"].(int64)
+		version, _ := obj["Version"].(int64)
+		u.Id, u.Age, u.Version = int(id), int(age), int(version)
+		u.Name, _ = obj["Name"].(string)
+		u.About, _ = obj["About"].(string)
+		u.Gender, _ = obj["Gender"].(string)
+		u.Email, _ = obj["Email"].(string)
+		u.Phone, _ = obj["Phone"].(string)
+		u.Company, _ = obj["Company"].(string)
+		u.Address, _ = obj["Address"].(string)
+		u.Deleted, _ = obj["Deleted"].(bool)
+		users = append(users, u)
+	}
+	return users, nil
+}
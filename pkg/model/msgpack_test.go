@@ -0,0 +1,73 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	users := []User{
+		{Id: 1, Name: "Boyd Wolf", Age: 22, About: strings.Repeat("a", 300), Gender: "male", Email: "boyd@hopeli.com", Company: "HOPELI"},
+		{Id: -70000, Name: "", Age: 100000, Gender: "female", Deleted: true},
+	}
+	for i := 0; i < 20; i++ {
+		users = append(users, User{Id: i})
+	}
+
+	var buf bytes.Buffer
+	if err := msgpackEncodeUsers(&buf, users); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	decoded, err := msgpackDecodeUsers(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+
+	if len(decoded) != len(users) {
+		t.Fatalf("Error : %v users decoded", len(decoded))
+	}
+	for i := range users {
+		if decoded[i] != users[i] {
+			t.Errorf("Error : %v != %v", decoded[i], users[i])
+		}
+	}
+}
+
+func TestMsgpackSmallerThanJSON(t *testing.T) {
+	users := []User{}
+	for i := 0; i < 50; i++ {
+		users = append(users, User{Id: i, Name: "Boyd Wolf", Age: 20 + i, About: "Nulla cillum enim voluptate", Gender: "male"})
+	}
+
+	var buf bytes.Buffer
+	msgpackEncodeUsers(&buf, users)
+	data, _ := json.Marshal(users)
+
+	if buf.Len() >= len(data) {
+		t.Errorf("Error : msgpack %v bytes, json %v bytes", buf.Len(), len(data))
+	}
+}
+
+func TestMsgpackTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	msgpackEncodeUsers(&buf, []User{{Id: 1, Name: "Boyd Wolf"}})
+
+	if _, err := msgpackDecodeUsers(buf.Bytes()[:buf.Len()-3]); err == nil {
+		t.Errorf("Error : truncated data decoded")
+	}
+}
+
+func TestMsgpackDeepNesting(t *testing.T) {
+	// массив из одного массива из одного массива... - раньше переполнял стек
+	data := bytes.Repeat([]byte{0x91}, 3000000)
+	if _, err := msgpackDecodeUsers(data); err != errMsgpackDepth {
+		t.Errorf("Error : %v", err)
+	}
+	// то же через map: {"a": {"a": ...}}
+	data = bytes.Repeat([]byte{0x81, 0xa1, 'a'}, 100000)
+	if _, err := msgpackDecodeUsers(data); err != errMsgpackDepth {
+		t.Errorf("Error : %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package model
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// версии схемы json-ответа поиска. Остальные форматы от версии не зависят
+const (
+	// WireV1 - голый массив пользователей, следующую страницу клиент определяет сам по limit+1
+	WireV1 = 1
+	// WireV2 - объект SearchResponseV2: явные NextPage и Partial, место под новые поля
+	WireV2 = 2
+
+	WireLatest = WireV2
+)
+
+// VersionHeader - клиент передаёт в нём старшую версию, которую понимает,
+// сервер отвечает версией, по которой закодирован ответ. Нет заголовка - WireV1
+const VersionHeader = "X-Search-Version"
+
+// SnapshotHeader - идентификатор версии датасета, по которой собрана страница.
+// Клиент возвращает его параметром snapshot, чтобы все страницы были из одной версии
+const SnapshotHeader = "X-Snapshot-Id"
+
+// ChecksumHeader - sha256 тела ответа в hex. У потока ndjson приходит трейлером после тела
+const ChecksumHeader = "X-Content-SHA256"
+
+// Freshness - подсказка сервера в Cache-Control и Age: сколько страница ещё свежая и сколько
+// после этого её можно отдавать, обновляя в фоне
+type Freshness struct {
+	// кэшировать нельзя совсем (no-store) или без перепроверки (no-cache)
+	NoStore bool `json:",omitempty"`
+	// остаток свежести: max-age за вычетом Age
+	MaxAge               time.Duration `json:",omitempty"`
+	StaleWhileRevalidate time.Duration `json:",omitempty"`
+}
+
+// ParseFreshness разбирает Cache-Control и Age ответа, nil - Cache-Control нет
+func ParseFreshness(h http.Header) *Freshness {
+	cc := h.Get("Cache-Control")
+	if cc == "" {
+		return nil
+	}
+	f := &Freshness{}
+	for _, directive := range strings.Split(cc, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds, err := strconv.Atoi(value)
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			f.NoStore = true
+		case "max-age":
+			if err == nil {
+				f.MaxAge = time.Duration(seconds) * time.Second
+			}
+		case "stale-while-revalidate":
+			if err == nil {
+				f.StaleWhileRevalidate = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if age, err := strconv.Atoi(h.Get("Age")); err == nil && age > 0 {
+		f.MaxAge -= time.Duration(age) * time.Second
+		if f.MaxAge < 0 {
+			f.StaleWhileRevalidate += f.MaxAge
+			f.MaxAge = 0
+		}
+		if f.StaleWhileRevalidate < 0 {
+			f.StaleWhileRevalidate = 0
+		}
+	}
+	return f
+}
+
+// UserGroup - записи выдачи с одним значением поля group_by
+type UserGroup struct {
+	Value string
+	// сколько записей выдачи в группе, Users - первые limit из них
+	Total int
+	Users []User
+}
+
+// GroupedResponse - json-ответ поиска с group_by: группы в порядке их лучшей записи в выдаче
+type GroupedResponse struct {
+	GroupBy string
+	Groups  []UserGroup
+	Partial bool `json:",omitempty"`
+}
+
+// MultiSearchResult - ответ на один запрос из POST /msearch
+type MultiSearchResult struct {
+	// статус, с которым на этот запрос ответил бы GET /
+	Status   int
+	Users    []User `json:",omitempty"`
+	NextPage bool   `json:",omitempty"`
+	Partial  bool   `json:",omitempty"`
+	Snapshot string `json:",omitempty"`
+	// ошибка при Status не 200
+	Error *SearchErrorResponse `json:",omitempty"`
+}
+
+// операции BulkOperation.Op
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkOperation - одна правка из POST /users/bulk
+type BulkOperation struct {
+	Op string
+	// запись для update и delete; create выдаёт Id сам - следующий за самым большим
+	Id int `json:",omitempty"`
+	// новая запись для create и полная замена для update
+	User *User `json:",omitempty"`
+	// ожидаемая версия записи, как If-Match; 0 - не проверять
+	Version int `json:",omitempty"`
+}
+
+// BulkResult - итог одной правки из POST /users/bulk
+type BulkResult struct {
+	// статус, с которым ответил бы запрос к /users/{id}: 201 для create, 200 для update, 204 для delete
+	Status int
+	User   *User `json:",omitempty"`
+	// ошибка, правка при этом не применена
+	Error *SearchErrorResponse `json:",omitempty"`
+}
+
+// SavedSearch - именованный поиск, сохранённый на сервере для токена
+type SavedSearch struct {
+	Name string
+	// параметры GET /, при запуске их переопределяют параметры запроса
+	Params  map[string]string
+	Updated time.Time
+}
+
+// Alert - поиск, который сервер выполняет раз в Every и сообщает получателям, когда выдача
+// меняется или число найденных пересекает Threshold
+type Alert struct {
+	Name string
+	// сохранённый поиск токена, пусто - Params
+	Saved  string            `json:",omitempty"`
+	Params map[string]string `json:",omitempty"`
+	// период проверки в формате time.ParseDuration, например 10m
+	Every string
+	// вебхук с подписью по Secret, как у WebhookConfig, и/или email. Вебхук без прав admin - только
+	// на хосты ServerConfig.AlertWebhookHosts
+	Webhook string `json:",omitempty"`
+	Secret  string `json:",omitempty"`
+	Email   string `json:",omitempty"`
+	// больше нуля - сообщать, только когда число найденных переходит через порог в любую сторону,
+	// 0 - при любом изменении выдачи
+	Threshold int `json:",omitempty"`
+
+	// состояние, ведёт сервер: время последней проверки, сколько нашлось и чем она кончилась
+	LastRun   time.Time
+	Count     int
+	LastError string `json:",omitempty"`
+}
+
+// SearchResponseV2 - json-ответ поиска версии WireV2
+type SearchResponseV2 struct {
+	Version  int
+	Users    []User
+	NextPage bool
+	Partial  bool `json:",omitempty"`
+}
+
+// ChangesResponse - ответ GET /changes: чем текущая версия датасета отличается от версии Since
+type ChangesResponse struct {
+	Since string
+	// snapshotID текущей версии, его передают в since в следующий раз
+	Snapshot string
+	Added    []User
+	Updated  []User
+	// Id исчезнувших записей. Для не-admin токенов мягко удалённые записи тоже исчезают
+	Removed []int
+}
+
+// NegotiateVersion выбирает версию ответа по заголовку клиента:
+// старые клиенты без заголовка получают WireV1, слишком новые - WireLatest
+func NegotiateVersion(header string) int {
+	v, err := strconv.Atoi(header)
+	if err != nil || v < WireV1 {
+		return WireV1
+	}
+	if v > WireLatest {
+		return WireLatest
+	}
+	return v
+}
+
+// DecodeSearchJSON разбирает json-ответ поиска любой версии. Версию определяет по самому
+// телу: массив - WireV1, объект - WireV2 и новее. Незнакомые поля пропускаются,
+// так что клиент переживает и новые поля User, и ответы следующих версий
+func DecodeSearchJSON(data []byte) (SearchResponseV2, error) {
+	return DecodeSearchJSONWith(StdJSON, data)
+}
+
+// DecodeSearchJSONWith - DecodeSearchJSON поверх engine
+func DecodeSearchJSONWith(engine JSONEngine, data []byte) (SearchResponseV2, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		resp := SearchResponseV2{Version: WireV1, Users: []User{}}
+		err := engine.Unmarshal(data, &resp.Users)
+		return resp, err
+	}
+	resp := SearchResponseV2{}
+	if err := engine.Unmarshal(data, &resp); err != nil {
+		return resp, err
+	}
+	if resp.Version < WireV2 {
+		resp.Version = WireV2
+	}
+	if resp.Users == nil {
+		resp.Users = []User{}
+	}
+	return resp, nil
+}
+
+// DecodeSearchJSONAs - DecodeSearchJSONWith, но записи разбираются в T вызывающего,
+// например в свою структуру с полями, которых нет в User, или в json.RawMessage
+func DecodeSearchJSONAs[T any](engine JSONEngine, data []byte) ([]T, error) {
+	users := []T{}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		err := engine.Unmarshal(data, &users)
+		return users, err
+	}
+	resp := struct{ Users []T }{Users: users}
+	if err := engine.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Users == nil {
+		resp.Users = []T{}
+	}
+	return resp.Users, nil
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	cases := map[string]int{
+		"":    WireV1,
+		"abc": WireV1,
+		"0":   WireV1,
+		"1":   WireV1,
+		"2":   WireV2,
+		"99":  WireLatest,
+	}
+	for header, expected := range cases {
+		if v := NegotiateVersion(header); v != expected {
+			t.Errorf("Error : %q: %d != %d", header, v, expected)
+		}
+	}
+}
+
+// ответы, которые отдавали и будут отдавать серверы: клиент обязан разбирать их все
+func TestDecodeSearchJSONCompatibility(t *testing.T) {
+	cases := []struct {
+		body     string
+		version  int
+		ids      []int
+		nextPage bool
+		partial  bool
+	}{
+		{`[{"Id":1,"Name":"Boyd"},{"Id":2}]`, WireV1, []int{1, 2}, false, false},
+		{` [] `, WireV1, []int{}, false, false},
+		{`{"Version":2,"Users":[{"Id":3}],"NextPage":true}`, WireV2, []int{3}, true, false},
+		{`{"Version":2,"Users":null,"Partial":true}`, WireV2, []int{}, false, true},
+		// новые поля пользователя и ответа старому клиенту не мешают
+		{`[{"Id":4,"Score":0.5,"Email":"a@b.c"}]`, WireV1, []int{4}, false, false},
+		{`{"Version":3,"Users":[{"Id":5,"Phone":"+1"}],"Total":100}`, 3, []int{5}, false, false},
+	}
+	for _, c := range cases {
+		resp, err := DecodeSearchJSON([]byte(c.body))
+		if err != nil {
+			t.Errorf("Error : %s: %v", c.body, err)
+			continue
+		}
+		if resp.Version != c.version || resp.NextPage != c.nextPage || resp.Partial != c.partial || len(resp.Users) != len(c.ids) {
+			t.Errorf("Error : %s: unexpected %+v", c.body, resp)
+			continue
+		}
+		for i, id := range c.ids {
+			if resp.Users[i].Id != id {
+				t.Errorf("Error : %s: unexpected %+v", c.body, resp.Users)
+			}
+		}
+	}
+}
+
+func TestDecodeSearchJSONError(t *testing.T) {
+	for _, body := range []string{``, `[{"Id":"x"}]`, `{"Users":1}`, `"users"`} {
+		if _, err := DecodeSearchJSON([]byte(body)); err == nil {
+			t.Errorf("Error : %q decoded", body)
+		}
+	}
+}
+
+func TestParseFreshness(t *testing.T) {
+	cases := []struct {
+		cc, age string
+		want    *Freshness
+	}{
+		{"", "", nil},
+		{"no-store", "", &Freshness{NoStore: true}},
+		{"private, max-age=60, stale-while-revalidate=30", "", &Freshness{MaxAge: time.Minute, StaleWhileRevalidate: 30 * time.Second}},
+		{"public, max-age=60", "20", &Freshness{MaxAge: 40 * time.Second}},
+		// устарела на 10 секунд: из окна фонового обновления остаётся 20
+		{"max-age=60, stale-while-revalidate=30", "70", &Freshness{StaleWhileRevalidate: 20 * time.Second}},
+		{"max-age=60", "100", &Freshness{}},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		if c.cc != "" {
+			h.Set("Cache-Control", c.cc)
+		}
+		if c.age != "" {
+			h.Set("Age", c.age)
+		}
+		got := ParseFreshness(h)
+		if (got == nil) != (c.want == nil) || got != nil && *got != *c.want {
+			t.Errorf("Error : %q age %q: %+v, want %+v", c.cc, c.age, got, c.want)
+		}
+	}
+}
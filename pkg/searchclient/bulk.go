@@ -0,0 +1,117 @@
+package searchclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"final_task_golang/pkg/model"
+)
+
+// сколько правок уходит одним POST /users/bulk, если chunk не задан; столько же по умолчанию принимает сервер
+const defaultBulkChunk = 1000
+
+// BulkResult - итог одной правки BulkWrite: запись после неё (для delete - nil) или ошибка, как у UpdateUser
+type BulkResult struct {
+	User *model.User
+	Err  error
+}
+
+// BulkWrite отправляет правки пачками по chunk штук в POST /users/bulk, 0 - по 1000.
+// Каждая правка применяется целиком или никак, ошибка одной не мешает остальным и попадает
+// в её BulkResult. error - ошибка пачки: результаты уже отправленных пачек при этом возвращаются,
+// а правки с этой пачки и дальше могли не дойти до сервера. Как и UpdateUser, требует токен с правами admin
+func (srv *SearchClient) BulkWrite(ops []model.BulkOperation, chunk int) ([]BulkResult, error) {
+	return srv.BulkWriteContext(context.Background(), ops, chunk)
+}
+
+// BulkWriteContext - BulkWrite с контекстом вызывающего
+func (srv *SearchClient) BulkWriteContext(ctx context.Context, ops []model.BulkOperation, chunk int) ([]BulkResult, error) {
+	if chunk <= 0 {
+		chunk = defaultBulkChunk
+	}
+	results := make([]BulkResult, 0, len(ops))
+	for start := 0; start < len(ops); start += chunk {
+		end := start + chunk
+		if end > len(ops) {
+			end = len(ops)
+		}
+		part, err := srv.bulkChunk(ctx, ops[start:end])
+		if err != nil {
+			return results, err
+		}
+		results = append(results, part...)
+	}
+	return results, nil
+}
+
+func (srv *SearchClient) bulkChunk(ctx context.Context, ops []model.BulkOperation) ([]BulkResult, error) {
+	body, err := json.Marshal(ops)
+	if err != nil {
+		return nil, fmt.Errorf("cant pack bulk json: %s", err)
+	}
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		return nil, unknownError(err)
+	}
+	target := base.ResolveReference(&url.URL{Path: "/users/bulk"})
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, unknownError(err)
+	}
+	httpReq.Header.Add("AccessToken", srv.AccessToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	srv.metrics.add("requests")
+	resp, err := srv.httpClient(client).Do(httpReq)
+	if err != nil {
+		return nil, unknownError(err)
+	}
+	defer resp.Body.Close()
+	data, err := srv.readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("access denied")
+	}
+	if resp.StatusCode != http.StatusOK {
+		if err := statusError(resp.StatusCode, data, model.SearchRequest{}); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var items []model.BulkResult
+	if err := json.Unmarshal(data, &items); err != nil || len(items) != len(ops) {
+		return nil, fmt.Errorf("cant unpack bulk json: %v", err)
+	}
+	results := make([]BulkResult, len(items))
+	for i, item := range items {
+		if item.Error != nil {
+			results[i].Err = bulkError(item)
+			continue
+		}
+		if item.User != nil {
+			u := srv.applyUserHooks(*item.User)
+			results[i].User = &u
+		}
+	}
+	return results, nil
+}
+
+// bulkError - ошибка правки из /users/bulk, та же, что вернул бы UpdateUser
+func bulkError(item model.BulkResult) error {
+	switch item.Status {
+	case http.StatusNotFound:
+		return model.ErrUserNotFound
+	case http.StatusConflict:
+		return model.ErrVersionMismatch
+	case http.StatusBadRequest:
+		return fmt.Errorf("bad user: %s", item.Error.Error)
+	}
+	return fmt.Errorf("unexpected status %d: %s", item.Status, item.Error.Error)
+}
@@ -0,0 +1,58 @@
+package searchclient
+
+import (
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestBulkWrite(t *testing.T) {
+	server, client := newTestServer(accessToken)
+	defer server.Close()
+
+	u, err := client.GetUser(1)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	u.Name = "Bulk Write"
+	ops := []model.BulkOperation{
+		{Op: model.BulkCreate, User: &model.User{Name: "Bulk Created", Age: 20, Gender: "male"}},
+		{Op: model.BulkUpdate, Id: u.Id, Version: u.Version, User: &u},
+		{Op: model.BulkUpdate, Id: u.Id, Version: u.Version, User: &u},
+		{Op: model.BulkDelete, Id: 2},
+		{Op: model.BulkCreate, User: &model.User{Gender: "male"}},
+	}
+	// правки - токеном администратора, проверки - обычным: ему удалённые не видны
+	admin := NewSearchClient(adminToken, server.URL)
+	results, err := admin.BulkWrite(ops, 2)
+	if err != nil || len(results) != len(ops) {
+		t.Fatalf("Error : %+v %v", results, err)
+	}
+	if r := results[0]; r.Err != nil || r.User == nil || r.User.Name != "Bulk Created" {
+		t.Errorf("Error : unexpected create %+v", r)
+	}
+	if r := results[1]; r.Err != nil || r.User.Version != u.Version+1 {
+		t.Errorf("Error : unexpected update %+v", r)
+	}
+	if r := results[2]; r.Err != model.ErrVersionMismatch {
+		t.Errorf("Error : unexpected error %v", r.Err)
+	}
+	if r := results[3]; r.Err != nil || r.User != nil {
+		t.Errorf("Error : unexpected delete %+v", r)
+	}
+	if r := results[4]; r.Err == nil || !strings.HasPrefix(r.Err.Error(), "bad user") {
+		t.Errorf("Error : unexpected error %v", r.Err)
+	}
+	if _, err := client.GetUser(2); err != model.ErrUserNotFound {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+
+	if results, err := client.BulkWrite(ops, 2); err == nil || err.Error() != "access denied" || len(results) != 0 {
+		t.Errorf("Error : bulk allowed for search token %+v", results)
+	}
+	bad := SearchClient{AccessToken: "bad", URL: server.URL}
+	if results, err := bad.BulkWrite(ops, 2); err == nil || err.Error() != "Bad AccessToken" || len(results) != 0 {
+		t.Errorf("Error : unexpected result %+v %v", results, err)
+	}
+}
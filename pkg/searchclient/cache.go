@@ -0,0 +1,248 @@
+package searchclient
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+const (
+	defaultCacheSize = 256
+	defaultCacheTTL  = 30 * time.Second
+)
+
+// CacheConfig - кэш страниц FindUsers на стороне клиента, ключ - строка запроса.
+// Неполные ответы (Partial) не кэшируются
+type CacheConfig struct {
+	// сколько страниц держать, 0 - defaultCacheSize
+	Size int
+	// сколько страница считается свежей, 0 - defaultCacheTTL, меньше 0 - кэшируются только пустые страницы
+	TTL time.Duration
+	// stale-while-revalidate: сколько после TTL страницу ещё можно отдать сразу,
+	// обновляя её в фоне. 0 - устаревшая страница запрашивается заново
+	MaxStale time.Duration
+	// сколько живёт пустая страница (запрос ничего не нашёл), 0 - как все, по TTL.
+	// Пустые страницы не отдаются устаревшими: их TTL и так короткий
+	NegativeTTL time.Duration
+	// каталог, в котором страницы переживают перезапуск процесса, пусто - только память.
+	// Каталог можно делить между клиентами и процессами, страницы лежат в searchcache-*.json,
+	// остальные файлы каталога кэш не трогает
+	Dir string
+	// сколько байт страниц держать в Dir, 0 - defaultDiskCacheBytes. Лишние удаляются, начиная со старых
+	MaxDiskBytes int64
+	// брать свежесть страницы из Cache-Control и Age ответа (model.Freshness) вместо TTL, MaxStale
+	// и NegativeTTL. Ответы с no-store и no-cache не кэшируются, ответы без Cache-Control живут по TTL
+	ServerHints bool
+}
+
+// CacheStats - счётчики кэша клиента
+type CacheStats struct {
+	Size   int
+	Hits   uint64
+	Misses uint64
+	// из Hits: отдано устаревших страниц с фоновым обновлением
+	StaleHits uint64
+	// из Hits: отдано пустых страниц
+	NegativeHits uint64
+	// сколько устаревших страниц сервер подтвердил ответом 304 вместо того, чтобы прислать заново
+	NotModified uint64
+}
+
+type clientCache struct {
+	cfg CacheConfig
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+	stats CacheStats
+
+	// страницы на диске, nil - CacheConfig.Dir не задан
+	disk *diskCache
+}
+
+type cachedResult struct {
+	key    string
+	resp   model.SearchResponse
+	stored time.Time
+	// сколько страница свежая и сколько после этого её можно отдавать устаревшей
+	ttl, maxStale time.Duration
+	// фоновое обновление уже идёт, второе не запускаем
+	refreshing bool
+	// ETag страницы для условного запроса, см. validator
+	etag string
+}
+
+// WithCache включает кэш страниц FindUsers
+func WithCache(cfg CacheConfig) ClientOption {
+	return func(c *SearchClient) {
+		cfg.Size = intOr(cfg.Size, defaultCacheSize)
+		if cfg.TTL == 0 {
+			cfg.TTL = defaultCacheTTL
+		}
+		c.cache = &clientCache{cfg: cfg, items: map[string]*list.Element{}, order: list.New()}
+		if cfg.Dir != "" {
+			maxBytes := cfg.MaxDiskBytes
+			if maxBytes <= 0 {
+				maxBytes = defaultDiskCacheBytes
+			}
+			// опции применяются после URL и токена, так что они уже известны
+			c.cache.disk = &diskCache{dir: cfg.Dir, maxBytes: maxBytes, scope: c.URL + "\x00" + c.AccessToken}
+		}
+	}
+}
+
+// CacheStats возвращает счётчики кэша, если он включён
+func (srv *SearchClient) CacheStats() CacheStats {
+	if srv.cache == nil {
+		return CacheStats{}
+	}
+	srv.cache.mu.Lock()
+	defer srv.cache.mu.Unlock()
+	stats := srv.cache.stats
+	stats.Size = srv.cache.order.Len()
+	return stats
+}
+
+// get возвращает копию страницы: хуки клиента меняют Users на месте.
+// revalidate - страница устарела, и обновить её в фоне должен вызывающий
+func (c *clientCache) get(key string) (resp model.SearchResponse, revalidate bool, ok bool) {
+	c.mu.Lock()
+	el, ok := c.items[key]
+	if !ok && c.disk != nil {
+		// файл читается без c.mu: медленный диск не должен держать запросы к другим страницам
+		c.mu.Unlock()
+		entry, found := c.disk.get(key)
+		c.mu.Lock()
+		// пока читали, страницу мог положить другой запрос - его версия новее
+		if el, ok = c.items[key]; !ok && found {
+			el, ok = c.insert(entry), true
+		}
+	}
+	defer c.mu.Unlock()
+
+	if !ok {
+		c.stats.Misses++
+		return resp, false, false
+	}
+	entry := el.Value.(*cachedResult)
+	age := time.Since(entry.stored)
+	if age > entry.ttl+entry.maxStale {
+		// страница с ETag остаётся до вытеснения: её можно подтвердить условным запросом
+		if entry.etag == "" {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+		c.stats.Misses++
+		return resp, false, false
+	}
+	c.stats.Hits++
+	if len(entry.resp.Users) == 0 {
+		c.stats.NegativeHits++
+	}
+	c.order.MoveToFront(el)
+	if age > entry.ttl {
+		c.stats.StaleHits++
+		revalidate = !entry.refreshing
+		entry.refreshing = true
+	}
+	resp = entry.resp
+	resp.Users = append([]model.User{}, entry.resp.Users...)
+	return resp, revalidate, true
+}
+
+func (c *clientCache) put(key string, resp model.SearchResponse, etag string) {
+	entry := &cachedResult{key: key, stored: time.Now(), ttl: c.cfg.TTL, maxStale: c.cfg.MaxStale, etag: etag}
+	if len(resp.Users) == 0 && c.cfg.NegativeTTL > 0 {
+		entry.ttl, entry.maxStale = c.cfg.NegativeTTL, 0
+	}
+	if f := resp.Freshness; c.cfg.ServerHints && f != nil {
+		if f.NoStore {
+			return
+		}
+		entry.ttl, entry.maxStale = f.MaxAge, f.StaleWhileRevalidate
+		if entry.ttl <= 0 && entry.maxStale > 0 {
+			// уже устарела, но ещё может отдаваться с фоновым обновлением
+			entry.ttl = time.Nanosecond
+		}
+	}
+	if resp.Partial || entry.ttl <= 0 {
+		return
+	}
+	entry.resp = resp
+	// подсказка относится к моменту ответа, из кэша страница отдаётся уже без неё
+	entry.resp.Freshness = nil
+	entry.resp.Users = append([]model.User{}, resp.Users...)
+
+	c.mu.Lock()
+	c.insert(entry)
+	c.mu.Unlock()
+	if c.disk != nil {
+		c.disk.put(entry)
+	}
+}
+
+// insert кладёт страницу в память и вытесняет лишние, вызывается под c.mu
+func (c *clientCache) insert(entry *cachedResult) *list.Element {
+	if el, ok := c.items[entry.key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return el
+	}
+	el := c.order.PushFront(entry)
+	c.items[entry.key] = el
+	for c.order.Len() > c.cfg.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cachedResult).key)
+	}
+	return el
+}
+
+// validator возвращает ETag страницы key для условного запроса, пусто - страницы нет или ETag не пришёл
+func (c *clientCache) validator(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		return el.Value.(*cachedResult).etag
+	}
+	return ""
+}
+
+// notModified продлевает страницу key, которую сервер подтвердил ответом 304, и возвращает её копию
+func (c *clientCache) notModified(key string) (resp model.SearchResponse, ok bool) {
+	c.mu.Lock()
+	el, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return resp, false
+	}
+	entry := el.Value.(*cachedResult)
+	entry.stored, entry.refreshing = time.Now(), false
+	c.stats.NotModified++
+	resp = entry.resp
+	resp.Users = append([]model.User{}, entry.resp.Users...)
+	saved := *entry
+	c.mu.Unlock()
+	if c.disk != nil {
+		c.disk.put(&saved)
+	}
+	return resp, true
+}
+
+// revalidateFailed разрешает следующему запросу снова попробовать обновить страницу
+func (c *clientCache) revalidateFailed(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*cachedResult).refreshing = false
+	}
+}
+
+func intOr(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
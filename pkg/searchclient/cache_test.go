@@ -0,0 +1,244 @@
+package searchclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// newCountingServer - тестовый сервер, который считает поисковые запросы
+func newCountingServer() (*httptest.Server, *int64) {
+	var requests int64
+	handler := newTestHandler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		handler.ServeHTTP(w, r)
+	}))
+	return server, &requests
+}
+
+func TestClientCache(t *testing.T) {
+	server, requests := newCountingServer()
+	defer server.Close()
+	client := NewSearchClient(accessToken, server.URL, WithCache(CacheConfig{TTL: time.Hour}),
+		WithUserHook(func(u model.User) model.User {
+			u.Name = "hooked"
+			return u
+		}))
+	req := model.SearchRequest{Limit: 5, OrderField: "Id", OrderBy: model.OrderByAsc}
+
+	first, err := client.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	second, err := client.FindUsers(req)
+	if err != nil || len(second.Users) != 5 || second.Users[0] != first.Users[0] || !second.NextPage {
+		t.Errorf("Error : %v %v", second, err)
+	}
+	// хуки применяются к копии, а не к странице в кэше
+	if second.Users[0].Name != "hooked" {
+		t.Errorf("Error : hooks not applied to cached page")
+	}
+	if n := atomic.LoadInt64(requests); n != 1 {
+		t.Errorf("Error : %d requests, want 1", n)
+	}
+
+	req.Offset = 5
+	client.FindUsers(req)
+	if stats := client.CacheStats(); stats.Hits != 1 || stats.Misses != 2 || stats.Size != 2 {
+		t.Errorf("Error : unexpected stats %+v", stats)
+	}
+}
+
+func TestClientCacheStaleWhileRevalidate(t *testing.T) {
+	server, requests := newCountingServer()
+	defer server.Close()
+	client := NewSearchClient(accessToken, server.URL, WithCache(CacheConfig{TTL: 20 * time.Millisecond, MaxStale: time.Hour}))
+	req := model.SearchRequest{Limit: 5}
+
+	client.FindUsers(req)
+	time.Sleep(30 * time.Millisecond)
+
+	// устаревшая страница отдаётся сразу, обновление - в фоне, и только одно
+	for i := 0; i < 3; i++ {
+		if resp, err := client.FindUsers(req); err != nil || len(resp.Users) != 5 {
+			t.Fatalf("Error : %v %v", resp, err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(requests) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadInt64(requests); n != 2 {
+		t.Errorf("Error : %d requests, want 2", n)
+	}
+	if stats := client.CacheStats(); stats.Hits != 3 || stats.StaleHits == 0 {
+		t.Errorf("Error : unexpected stats %+v", stats)
+	}
+
+	// за пределами MaxStale страница запрашивается заново синхронно
+	client = NewSearchClient(accessToken, server.URL, WithCache(CacheConfig{TTL: time.Millisecond}))
+	client.FindUsers(req)
+	time.Sleep(5 * time.Millisecond)
+	client.FindUsers(req)
+	if stats := client.CacheStats(); stats.Hits != 0 || stats.Misses != 2 {
+		t.Errorf("Error : unexpected stats %+v", stats)
+	}
+}
+
+func TestClientNegativeCache(t *testing.T) {
+	server, requests := newCountingServer()
+	defer server.Close()
+	// кэшируются только пустые страницы
+	client := NewSearchClient(accessToken, server.URL, WithCache(CacheConfig{TTL: -1, NegativeTTL: 20 * time.Millisecond}))
+	empty := model.SearchRequest{Limit: 5, Query: "no such user anywhere"}
+	found := model.SearchRequest{Limit: 5, Query: "nisi"}
+
+	for i := 0; i < 3; i++ {
+		if resp, err := client.FindUsers(empty); err != nil || len(resp.Users) != 0 || resp.Users == nil {
+			t.Fatalf("Error : %v %v", resp, err)
+		}
+		client.FindUsers(found)
+	}
+	if n := atomic.LoadInt64(requests); n != 4 {
+		t.Errorf("Error : %d requests, want 4", n)
+	}
+	if stats := client.CacheStats(); stats.NegativeHits != 2 || stats.Size != 1 {
+		t.Errorf("Error : unexpected stats %+v", stats)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	client.FindUsers(empty)
+	if n := atomic.LoadInt64(requests); n != 5 {
+		t.Errorf("Error : %d requests, want 5", n)
+	}
+}
+
+func TestClientCacheServerHints(t *testing.T) {
+	var requests int64
+	handler := newTestHandler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if r.URL.Query().Get("query") == "nisi" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "private, max-age=3600")
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	// TTL меньше нуля кэширует только пустые страницы, но подсказка сервера важнее
+	client := NewSearchClient(accessToken, server.URL, WithCache(CacheConfig{TTL: -1, ServerHints: true}))
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.FindUsers(model.SearchRequest{Limit: 5})
+		if err != nil || len(resp.Users) != 5 {
+			t.Fatalf("Error : %v %v", resp, err)
+		}
+		if i == 0 && (resp.Freshness == nil || resp.Freshness.MaxAge != time.Hour) {
+			t.Errorf("Error : unexpected freshness %+v", resp.Freshness)
+		}
+		client.FindUsers(model.SearchRequest{Limit: 5, Query: "nisi"})
+	}
+	if n := atomic.LoadInt64(&requests); n != 3 {
+		t.Errorf("Error : %d requests, want 3", n)
+	}
+}
+
+func TestClientCacheNotModified(t *testing.T) {
+	var requests, conditional int64
+	handler := newTestHandler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if r.Header.Get("If-None-Match") != "" {
+			atomic.AddInt64(&conditional, 1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	client := NewSearchClient(accessToken, server.URL, WithCache(CacheConfig{TTL: 20 * time.Millisecond}))
+	req := model.SearchRequest{Limit: 5, OrderField: "Id", OrderBy: model.OrderByAsc}
+
+	first, err := client.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// страница устарела, но датасет не менялся: сервер отвечает 304, клиент отдаёт её из кэша
+	second, err := client.FindUsers(req)
+	if err != nil || len(second.Users) != 5 || second.Users[0] != first.Users[0] || !second.NextPage {
+		t.Fatalf("Error : %v %v", second, err)
+	}
+	if n := atomic.LoadInt64(&conditional); n != 1 {
+		t.Errorf("Error : %d conditional requests, want 1", n)
+	}
+	// 304 продлевает страницу
+	client.FindUsers(req)
+	if n := atomic.LoadInt64(&requests); n != 2 {
+		t.Errorf("Error : %d requests, want 2", n)
+	}
+	if stats := client.CacheStats(); stats.NotModified != 1 || stats.Hits != 1 {
+		t.Errorf("Error : unexpected stats %+v", stats)
+	}
+
+	handler.Reload(nil)
+	time.Sleep(30 * time.Millisecond)
+	if resp, err := client.FindUsers(req); err != nil || len(resp.Users) != 0 {
+		t.Errorf("Error : stale page after reload: %v %v", resp, err)
+	}
+}
+
+func TestClientDiskCache(t *testing.T) {
+	server, requests := newCountingServer()
+	defer server.Close()
+	cfg := CacheConfig{TTL: time.Hour, Dir: t.TempDir()}
+	req := model.SearchRequest{Limit: 5, Query: "nisi"}
+
+	first, err := NewSearchClient(accessToken, server.URL, WithCache(cfg)).FindUsers(req)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	// новый клиент - как после перезапуска процесса
+	restarted := NewSearchClient(accessToken, server.URL, WithCache(cfg))
+	second, err := restarted.FindUsers(req)
+	if err != nil || len(second.Users) != len(first.Users) || second.Users[0] != first.Users[0] || second.NextPage != first.NextPage {
+		t.Errorf("Error : %v %v", second, err)
+	}
+	if n := atomic.LoadInt64(requests); n != 1 {
+		t.Errorf("Error : %d requests, want 1", n)
+	}
+	// у другого токена своя выдача
+	NewSearchClient(adminToken, server.URL, WithCache(cfg)).FindUsers(req)
+	if n := atomic.LoadInt64(requests); n != 2 {
+		t.Errorf("Error : %d requests, want 2", n)
+	}
+
+	if files, _ := filepath.Glob(filepath.Join(cfg.Dir, diskCachePrefix+"*.json")); len(files) != 2 {
+		t.Errorf("Error : %d cache files, want 2", len(files))
+	}
+
+	// лимит меньше любой страницы: на диске ничего не задерживается, но чужие файлы каталога
+	// чистка не трогает и в лимит не считает
+	cfg.Dir, cfg.MaxDiskBytes = t.TempDir(), 1
+	foreign := filepath.Join(cfg.Dir, "settings.json")
+	if err := os.WriteFile(foreign, []byte("{}"), 0600); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	client := NewSearchClient(accessToken, server.URL, WithCache(cfg))
+	client.FindUsers(req)
+	client.FindUsers(model.SearchRequest{Limit: 5, Query: "sunt"})
+	if files, _ := filepath.Glob(filepath.Join(cfg.Dir, diskCachePrefix+"*")); len(files) != 0 {
+		t.Errorf("Error : %d files over the limit", len(files))
+	}
+	if _, err := os.Stat(foreign); err != nil {
+		t.Errorf("Error : foreign file removed: %v", err)
+	}
+}
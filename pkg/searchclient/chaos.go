@@ -0,0 +1,123 @@
+package searchclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errChaosDropped имитирует оборванное соединение
+var errChaosDropped = errors.New("chaos: connection dropped")
+
+// ChaosTransport вносит сбои между SearchClient и сервером, чтобы проверять устойчивость
+// кода вокруг клиента. Вероятности задаются долями от 0 до 1 и проверяются по порядку:
+// обрыв соединения, 429, 5xx, битый json, обрезанное тело
+type ChaosTransport struct {
+	// куда ходить на самом деле, nil - http.DefaultTransport
+	Next http.RoundTripper
+
+	// задержка перед каждым запросом: Latency плюс случайная добавка до LatencyJitter
+	Latency       time.Duration
+	LatencyJitter time.Duration
+
+	DropRate        float64
+	TooManyRate     float64
+	ServerErrorRate float64
+	MalformedRate   float64
+	TruncateRate    float64
+
+	// зерно генератора, одинаковое зерно - одинаковая последовательность сбоев
+	Seed int64
+
+	once sync.Once
+	mu   sync.Mutex
+	rnd  *rand.Rand
+}
+
+func (c *ChaosTransport) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rnd.Float64() < rate
+}
+
+func (c *ChaosTransport) delay() time.Duration {
+	d := c.Latency
+	if c.LatencyJitter > 0 {
+		c.mu.Lock()
+		d += time.Duration(c.rnd.Int63n(int64(c.LatencyJitter)))
+		c.mu.Unlock()
+	}
+	return d
+}
+
+func (c *ChaosTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.once.Do(func() {
+		c.rnd = rand.New(rand.NewSource(c.Seed))
+	})
+
+	if d := c.delay(); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, r.Context().Err()
+		}
+	}
+
+	switch {
+	case c.roll(c.DropRate):
+		return nil, errChaosDropped
+	case c.roll(c.TooManyRate):
+		resp := chaosResponse(r, http.StatusTooManyRequests, nil)
+		resp.Header.Set("Retry-After", "1")
+		return resp, nil
+	case c.roll(c.ServerErrorRate):
+		return chaosResponse(r, http.StatusInternalServerError, nil), nil
+	case c.roll(c.MalformedRate):
+		return chaosResponse(r, http.StatusOK, []byte(`[{"Id": 1, "Name": `)), nil
+	}
+
+	next := c.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(r)
+	if err != nil || !c.roll(c.TruncateRate) {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body = body[:len(body)/2]
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp, nil
+}
+
+func chaosResponse(r *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
@@ -0,0 +1,68 @@
+package searchclient
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+func newChaosClient(t *testing.T, chaos *ChaosTransport) *SearchClient {
+	ts, _ := newTestServer(accessToken)
+	t.Cleanup(ts.Close)
+	return NewSearchClient(accessToken, ts.URL, WithTransport(chaos))
+}
+
+func TestChaosFaults(t *testing.T) {
+	cases := []struct {
+		chaos *ChaosTransport
+		err   string
+	}{
+		{&ChaosTransport{DropRate: 1}, "chaos: connection dropped"},
+		{&ChaosTransport{TooManyRate: 1}, "SearchServer rate limit exceeded"},
+		{&ChaosTransport{ServerErrorRate: 1}, "SearchServer fatal error"},
+		{&ChaosTransport{MalformedRate: 1}, "cant unpack result json"},
+		{&ChaosTransport{TruncateRate: 1}, "cant unpack result json"},
+	}
+	for _, c := range cases {
+		_, err := newChaosClient(t, c.chaos).FindUsers(model.SearchRequest{Limit: 5})
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Error : %+v: unexpected error %v", c.chaos, err)
+		}
+	}
+}
+
+func TestChaosNoFaults(t *testing.T) {
+	r, err := newChaosClient(t, &ChaosTransport{}).FindUsers(model.SearchRequest{Limit: 5})
+	if err != nil || len(r.Users) != 5 {
+		t.Errorf("Error : unexpected response %v %v", r, err)
+	}
+}
+
+func TestChaosLatency(t *testing.T) {
+	_, err := newChaosClient(t, &ChaosTransport{Latency: 2 * time.Second}).FindUsers(model.SearchRequest{Limit: 5})
+	if err == nil || !strings.Contains(err.Error(), "timeout for") {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+}
+
+// одинаковое зерно - одинаковая последовательность сбоев
+func TestChaosSeed(t *testing.T) {
+	run := func() string {
+		client := newChaosClient(t, &ChaosTransport{ServerErrorRate: 0.5, Seed: 42})
+		var outcome strings.Builder
+		for i := 0; i < 20; i++ {
+			if _, err := client.FindUsers(model.SearchRequest{Limit: 1}); err != nil {
+				outcome.WriteByte('x')
+			} else {
+				outcome.WriteByte('.')
+			}
+		}
+		return outcome.String()
+	}
+	first, second := run(), run()
+	if first != second || !strings.Contains(first, "x") || !strings.Contains(first, ".") {
+		t.Errorf("Error : %s != %s", first, second)
+	}
+}
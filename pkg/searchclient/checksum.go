@@ -0,0 +1,68 @@
+package searchclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"final_task_golang/pkg/model"
+)
+
+// WithChecksumVerification сверяет тело каждого ответа с model.ChecksumHeader сервера
+// (ServerConfig.ContentChecksum), у потока StreamUsers - с трейлером по его окончании.
+// Несовпадение - ошибка с model.ErrChecksumMismatch вместо разбора испорченного ответа.
+// Ответы без заголовка (сервер его не отдаёт) не проверяются
+func WithChecksumVerification() ClientOption {
+	return func(c *SearchClient) {
+		c.verifyChecksum = true
+	}
+}
+
+// readBody читает тело ответа целиком и сверяет его с суммой сервера, если клиент проверяет суммы
+func (srv *SearchClient) readBody(resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, unknownError(err)
+	}
+	if srv.verifyChecksum {
+		if err := checkSum(resp.Header.Get(model.ChecksumHeader), sha256.Sum256(body)); err != nil {
+			srv.metrics.add("errors_checksum")
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+func checkSum(expected string, sum [sha256.Size]byte) error {
+	if expected == "" {
+		return nil
+	}
+	if got := hex.EncodeToString(sum[:]); got != expected {
+		return fmt.Errorf("response sha256 %s, server sent %s: %w", got, expected, model.ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// checksumReader считает сумму потока по ходу чтения и на io.EOF сверяет её с трейлером
+type checksumReader struct {
+	resp *http.Response
+	hash hash.Hash
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.resp.Body.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		// трейлеры заполняются, только когда тело дочитано
+		var sum [sha256.Size]byte
+		copy(sum[:], r.hash.Sum(nil))
+		if err := checkSum(r.resp.Trailer.Get(model.ChecksumHeader), sum); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
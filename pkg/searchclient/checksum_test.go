@@ -0,0 +1,84 @@
+package searchclient
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchserver"
+)
+
+// corruptingTransport - прокси, который портит возраст в теле, не ломая json
+type corruptingTransport struct{}
+
+func (corruptingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// тело дочитываем до конца, чтобы у потока пришли трейлеры
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(bytes.Replace(body, []byte(`"Age":`), []byte(`"Age":1`), 1)))
+	resp.ContentLength = -1
+	return resp, nil
+}
+
+func TestChecksumVerification(t *testing.T) {
+	users, _ := searchserver.LoadDataset("../../dataset.xml")
+	cfg := testServerConfig
+	cfg.ContentChecksum = true
+	server := httptest.NewServer(searchserver.NewServer(users, cfg))
+	defer server.Close()
+
+	verified := NewSearchClient(accessToken, server.URL, WithChecksumVerification())
+	if resp, err := verified.FindUsers(model.SearchRequest{Limit: 5}); err != nil || len(resp.Users) != 5 {
+		t.Errorf("Error : unexpected result %v %v", resp, err)
+	}
+
+	// без проверки испорченный ответ разбирается молча
+	corrupted := NewSearchClient(accessToken, server.URL, WithTransport(corruptingTransport{}))
+	resp, err := corrupted.FindUsers(model.SearchRequest{Limit: 5})
+	if err != nil || resp.Users[0].Age < 100 {
+		t.Fatalf("Error : corruption not reproduced %v %v", resp, err)
+	}
+	corrupted = NewSearchClient(accessToken, server.URL, WithTransport(corruptingTransport{}), WithChecksumVerification())
+	if _, err := corrupted.FindUsers(model.SearchRequest{Limit: 5}); !errors.Is(err, model.ErrChecksumMismatch) {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+	if _, err := corrupted.GetUser(1); !errors.Is(err, model.ErrChecksumMismatch) {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+
+	stream, err := corrupted.StreamUsers(model.SearchRequest{})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	defer stream.Close()
+	for {
+		if _, err = stream.Next(); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, model.ErrChecksumMismatch) {
+		t.Errorf("Error : stream ended with %v", err)
+	}
+	stream, _ = verified.StreamUsers(model.SearchRequest{})
+	defer stream.Close()
+	for {
+		if _, err = stream.Next(); err != nil {
+			break
+		}
+	}
+	if err != io.EOF {
+		t.Errorf("Error : verified stream ended with %v", err)
+	}
+}
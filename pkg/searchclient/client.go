@@ -0,0 +1,474 @@
+package searchclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+var (
+	// общий транспорт всех клиентов пакета: соединения переиспользуются между вызовами и клиентами
+	sharedTransport = NewTransport(TransportConfig{})
+	client          = &http.Client{Timeout: time.Second, Transport: sharedTransport}
+	// для потоковых ответов ограничиваем только ожидание заголовков: тело может читаться долго
+	streamClient = &http.Client{Transport: streamTransport()}
+)
+
+func streamTransport() *http.Transport {
+	t := NewTransport(TransportConfig{})
+	t.ResponseHeaderTimeout = time.Second
+	return t
+}
+
+type SearchClient struct {
+	// токен, по которому происходит авторизация на внешней системе, уходит туда через хедер
+	AccessToken string
+	// урл внешней системы, куда идти
+	URL string
+
+	// формат, в котором просим ответ, по умолчанию json
+	format string
+	// транспорт вместо стандартного, например VCRTransport в тестах
+	transport http.RoundTripper
+	// как соединяться с сервером, см. WithDialer и WithResolver, nil - как в транспорте
+	dialer *dialer
+	// таймаут запроса вместо стандартной секунды
+	timeout time.Duration
+	// старшая версия json-ответа, которую просим у сервера, по умолчанию model.WireLatest
+	wireVersion int
+	// реализация json для разбора выдачи, nil - model.StdJSON
+	json model.JSONEngine
+	// уже закодированный параметр локали сортировки по Name ("&order_locale=de"),
+	// пусто - как настроено на сервере
+	orderLocaleParam string
+	// обработка результатов перед возвратом, см. WithUserHook и WithResultHook
+	userHooks   []func(model.User) model.User
+	resultHooks []func([]model.User) []model.User
+	// кэш страниц FindUsers, nil - выключен
+	cache *clientCache
+	// счётчики в expvar, nil - выключены
+	metrics *clientMetrics
+	// сверять тела ответов с суммой сервера, см. WithChecksumVerification
+	verifyChecksum bool
+	// что делает Warmup
+	warmup WarmupConfig
+}
+
+// ClientOption настраивает SearchClient при создании
+type ClientOption func(*SearchClient)
+
+// WithFormat задаёт формат ответа сервера, поддерживаются все форматы, кроме FormatCSV
+func WithFormat(format string) ClientOption {
+	return func(c *SearchClient) {
+		c.format = format
+	}
+}
+
+// WithTransport подменяет http-транспорт клиента, таймауты запросов сохраняются
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *SearchClient) {
+		c.transport = rt
+	}
+}
+
+// WithTimeout задаёт таймаут поискового запроса, на потоковые запросы не влияет
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *SearchClient) {
+		c.timeout = d
+	}
+}
+
+// WithWireVersion фиксирует версию json-ответа, например model.WireV1 для проверки совместимости
+func WithWireVersion(version int) ClientOption {
+	return func(c *SearchClient) {
+		c.wireVersion = version
+	}
+}
+
+// WithOrderLocale просит сервер сравнивать имена по правилам локали (BCP 47, например "de")
+func WithOrderLocale(locale string) ClientOption {
+	return func(c *SearchClient) {
+		c.orderLocaleParam = ""
+		if locale != "" {
+			c.orderLocaleParam = "&order_locale=" + url.QueryEscape(locale)
+		}
+	}
+}
+
+// WithJSON подменяет реализацию json, которой разбирается выдача (например, адаптер jsoniter)
+func WithJSON(engine model.JSONEngine) ClientOption {
+	return func(c *SearchClient) {
+		c.json = engine
+	}
+}
+
+// WithUserHook добавляет обработку каждой записи перед возвратом: в FindUsers, StreamUsers,
+// GetUser и UpdateUser. Хуки применяются в порядке добавления
+func WithUserHook(hook func(model.User) model.User) ClientOption {
+	return func(c *SearchClient) {
+		c.userHooks = append(c.userHooks, hook)
+	}
+}
+
+// WithResultHook добавляет обработку страницы FindUsers целиком, после хуков записей:
+// например, фильтрацию. NextPage считается до хука, так что страница может стать короче Limit
+func WithResultHook(hook func([]model.User) []model.User) ClientOption {
+	return func(c *SearchClient) {
+		c.resultHooks = append(c.resultHooks, hook)
+	}
+}
+
+func NewSearchClient(accessToken, url string, opts ...ClientOption) *SearchClient {
+	c := &SearchClient{
+		AccessToken: accessToken,
+		URL:         url,
+		wireVersion: model.WireLatest,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// FindUsers отправляет запрос во внешнюю систему, которая непосредственно ищет пользоваталей
+func (srv *SearchClient) FindUsers(req model.SearchRequest) (*model.SearchResponse, error) {
+	return srv.FindUsersContext(context.Background(), req)
+}
+
+// FindUsersContext - FindUsers с контекстом вызывающего. Истёкший дедлайн ctx возвращается
+// как *TimeoutError, для которого errors.Is(err, context.DeadlineExceeded)
+func (srv *SearchClient) FindUsersContext(ctx context.Context, req model.SearchRequest) (*model.SearchResponse, error) {
+	req, err := checkRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	//нужно для получения следующей записи, на основе которой мы скажем - можно показать переключатель следующей страницы или нет
+	req.Limit++
+	query := encodeSearchQuery(req, srv.orderLocaleParam, false)
+
+	etag := ""
+	if srv.cache != nil {
+		cached, revalidate, ok := srv.cache.get(query)
+		switch {
+		case revalidate:
+			srv.metrics.add("cache_stale_hits")
+		case ok:
+			srv.metrics.add("cache_hits")
+		default:
+			srv.metrics.add("cache_misses")
+		}
+		if ok {
+			if revalidate {
+				go srv.revalidate(req, query)
+			}
+			cached.Users = srv.applyHooks(cached.Users)
+			return &cached, nil
+		}
+		// устаревшую страницу с ETag сервер подтвердит 304, не присылая её заново
+		etag = srv.cache.validator(query)
+	}
+
+	result, newETag, err := srv.fetchPage(ctx, req, query, etag)
+	if err == errNotModified {
+		if cached, ok := srv.cache.notModified(query); ok {
+			srv.metrics.add("cache_not_modified")
+			cached.Users = srv.applyHooks(cached.Users)
+			return &cached, nil
+		}
+		// страницу вытеснили, пока шёл запрос
+		result, newETag, err = srv.fetchPage(ctx, req, query, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if srv.cache != nil {
+		srv.cache.put(query, *result, newETag)
+	}
+	result.Users = srv.applyHooks(result.Users)
+	return result, nil
+}
+
+// revalidate обновляет устаревшую страницу кэша в фоне
+func (srv *SearchClient) revalidate(req model.SearchRequest, query string) {
+	result, etag, err := srv.fetchPage(context.Background(), req, query, srv.cache.validator(query))
+	if err == errNotModified {
+		if _, ok := srv.cache.notModified(query); ok {
+			srv.metrics.add("cache_not_modified")
+			return
+		}
+	}
+	if err != nil {
+		srv.cache.revalidateFailed(query)
+		return
+	}
+	srv.cache.put(query, *result, etag)
+}
+
+// errNotModified - сервер ответил 304 на условный поиск: страница в кэше всё ещё верна
+var errNotModified = errors.New("not modified")
+
+// fetchPage запрашивает страницу у сервера, req.Limit уже с запасом на одну запись.
+// С etag запрос условный: если выдача не изменилась, вернётся errNotModified.
+// Возвращает страницу и её ETag
+func (srv *SearchClient) fetchPage(ctx context.Context, req model.SearchRequest, query, etag string) (*model.SearchResponse, string, error) {
+	format := srv.format
+	if format == "" {
+		format = model.FormatJSON
+	}
+	codec, ok := model.CodecByFormat(format)
+	if !ok || codec.Decode == nil {
+		return nil, "", fmt.Errorf("unsupported format %s", format)
+	}
+	engine := srv.json
+	if engine == nil {
+		engine = model.StdJSON
+	}
+
+	accept := ""
+	if format != model.FormatJSON {
+		accept = codec.ContentType
+	}
+	resp, err := srv.send(ctx, srv.httpClient(client), query, accept, etag)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := srv.readBody(resp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", errNotModified
+	}
+	if err := statusError(resp.StatusCode, body, req); err != nil {
+		srv.metrics.add("errors_server")
+		return nil, "", err
+	}
+
+	// json разбираем без оглядки на заголовок версии: старый сервер его не присылает
+	var data []model.User
+	partial := resp.Header.Get("X-Partial-Result") == "true"
+	if format == model.FormatJSON {
+		var page model.SearchResponseV2
+		page, err = model.DecodeSearchJSONWith(engine, body)
+		data, partial = page.Users, partial || page.Partial
+	} else {
+		data, err = codec.Decode(body)
+	}
+	if err != nil {
+		srv.metrics.add("errors_decode")
+		return nil, "", fmt.Errorf("cant unpack result %s: %s", format, err)
+	}
+
+	result := model.SearchResponse{Partial: partial, Snapshot: resp.Header.Get(model.SnapshotHeader), Freshness: model.ParseFreshness(resp.Header)}
+	if len(data) == req.Limit {
+		result.NextPage = true
+		result.Users = data[0 : len(data)-1]
+	} else {
+		result.Users = data[0:len(data)]
+	}
+
+	return &result, resp.Header.Get("ETag"), err
+}
+
+// applyHooks прогоняет страницу через хуки записей, затем через хуки страницы
+func (srv *SearchClient) applyHooks(users []model.User) []model.User {
+	for i := range users {
+		users[i] = srv.applyUserHooks(users[i])
+	}
+	for _, hook := range srv.resultHooks {
+		users = hook(users)
+	}
+	return users
+}
+
+func (srv *SearchClient) applyUserHooks(u model.User) model.User {
+	for _, hook := range srv.userHooks {
+		u = hook(u)
+	}
+	return u
+}
+
+// checkRequest проверяет limit и offset и обрезает limit до страницы
+func checkRequest(req model.SearchRequest) (model.SearchRequest, error) {
+	if req.Limit < 0 {
+		return req, fmt.Errorf("limit must be > 0")
+	}
+	if req.Limit > 25 {
+		req.Limit = 25
+	}
+	if req.Offset < 0 {
+		return req, fmt.Errorf("offset must be > 0")
+	}
+	return req, nil
+}
+
+// буферы строки запроса: на высоких QPS FindUsers не должен собирать её через url.Values
+var queryBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// encodeSearchQuery собирает строку запроса так же, как url.Values.Encode (ключи по алфавиту),
+// чтобы адрес не зависел от способа сборки: на него завязаны, например, кассеты VCR.
+// localeParam - уже закодированный "&order_locale=...", stream - добавить stream=true
+func encodeSearchQuery(req model.SearchRequest, localeParam string, stream bool) string {
+	bp := queryBuffers.Get().(*[]byte)
+	b := (*bp)[:0]
+	// параметры идут по алфавиту, как у url.Values.Encode
+	b = appendFilter(b, "address", req.Address)
+	if req.AllowPartial {
+		b = append(b, "allow_partial=true&"...)
+	}
+	b = appendFilter(b, "company", req.Company)
+	b = appendFilter(b, "email", req.Email)
+	b = append(b, "limit="...)
+	b = strconv.AppendInt(b, int64(req.Limit), 10)
+	b = append(b, "&offset="...)
+	b = strconv.AppendInt(b, int64(req.Offset), 10)
+	b = append(b, "&order_by="...)
+	b = strconv.AppendInt(b, int64(req.OrderBy), 10)
+	b = append(b, "&order_field="...)
+	b = append(b, url.QueryEscape(req.OrderField)...)
+	b = append(b, localeParam...)
+	b = append(b, '&')
+	b = appendFilter(b, "phone", req.Phone)
+	b = append(b, "query="...)
+	b = append(b, url.QueryEscape(req.Query)...)
+	if req.Snapshot != "" {
+		b = append(b, "&snapshot="...)
+		b = append(b, url.QueryEscape(req.Snapshot)...)
+	}
+	if stream {
+		b = append(b, "&stream=true"...)
+	}
+	query := string(b)
+	*bp = b
+	queryBuffers.Put(bp)
+	return query
+}
+
+// appendFilter дописывает "param=value&", пустой фильтр не передаётся
+func appendFilter(b []byte, param, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = append(b, param...)
+	b = append(b, '=')
+	b = append(b, url.QueryEscape(value)...)
+	return append(b, '&')
+}
+
+// httpClient возвращает base с транспортом и таймаутом клиента, если они заданы.
+// Таймаут применяется только к base с таймаутом: потоковому клиенту он не нужен
+func (srv *SearchClient) httpClient(base *http.Client) *http.Client {
+	withTimeout := srv.timeout > 0 && base.Timeout > 0
+	if srv.transport == nil && !withTimeout {
+		return base
+	}
+	c := *base
+	if srv.transport != nil {
+		c.Transport = srv.transport
+	}
+	if withTimeout {
+		c.Timeout = srv.timeout
+	}
+	return &c
+}
+
+// send выполняет поисковый запрос со строкой запроса query и переводит транспортные ошибки в понятные
+// ifNoneMatch - ETag прошлого ответа для условного поиска, пусто - запрос безусловный
+func (srv *SearchClient) send(ctx context.Context, httpClient *http.Client, query, accept, ifNoneMatch string) (*http.Response, error) {
+	searcherReq, err := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if err != nil {
+		return nil, unknownError(err)
+	}
+	searcherReq.URL.RawQuery = query
+	searcherReq.Header.Add("AccessToken", srv.AccessToken)
+	if srv.wireVersion > 0 {
+		searcherReq.Header.Add(model.VersionHeader, strconv.Itoa(srv.wireVersion))
+	}
+	if accept != "" {
+		searcherReq.Header.Add("Accept", accept)
+	}
+	if ifNoneMatch != "" {
+		searcherReq.Header.Add("If-None-Match", ifNoneMatch)
+	}
+
+	srv.metrics.add("requests")
+	resp, err := httpClient.Do(searcherReq)
+	if err != nil {
+		// сначала контекст вызывающего: его дедлайн транспорт тоже считает таймаутом
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			srv.metrics.add("errors_timeout")
+			return nil, &TimeoutError{Query: query, Err: context.DeadlineExceeded}
+		case context.Canceled:
+			srv.metrics.add("errors_canceled")
+			return nil, fmt.Errorf("request canceled for %s: %w", model.Redact(query), context.Canceled)
+		}
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			srv.metrics.add("errors_timeout")
+			return nil, &TimeoutError{Query: query, Err: ErrRequestTimeout}
+		}
+		srv.metrics.add("errors_transport")
+		return nil, unknownError(err)
+	}
+	return resp, nil
+}
+
+// statusError разбирает ошибочные статусы ответа, для успешных возвращает nil
+func statusError(status int, body []byte, req model.SearchRequest) error {
+	switch status {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("Bad AccessToken")
+	case http.StatusInternalServerError:
+		return fmt.Errorf("SearchServer fatal error")
+	case http.StatusServiceUnavailable:
+		return fmt.Errorf("SearchServer overloaded")
+	case http.StatusGatewayTimeout:
+		return fmt.Errorf("SearchServer timeout")
+	case http.StatusBadGateway:
+		return fmt.Errorf("SearchServer downstream error")
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("SearchServer rate limit exceeded")
+	case http.StatusConflict:
+		return model.ErrSnapshotChanged
+	case http.StatusBadRequest:
+		errResp := model.SearchErrorResponse{}
+		err := json.Unmarshal(body, &errResp)
+		if err != nil {
+			return fmt.Errorf("cant unpack error json: %s", err)
+		}
+		if errResp.Error == "ErrorBadOrderField" {
+			return fmt.Errorf("OrderFeld %s invalid", req.OrderField)
+		}
+		if errResp.Error == model.ErrBadOrderLocale.Error() {
+			return fmt.Errorf("order locale invalid")
+		}
+		if errResp.Error == model.ErrorLimitTooLarge {
+			return fmt.Errorf("limit above server maximum %d: %w", errResp.MaxLimit, model.ErrLimitTooLarge)
+		}
+		if errResp.Error == model.ErrorOffsetTooLarge {
+			return fmt.Errorf("offset %d above server maximum %d: %w", req.Offset, errResp.MaxOffset, model.ErrOffsetTooLarge)
+		}
+		if errResp.Error == model.ErrorQueryTooExpensive {
+			return fmt.Errorf("query cost %d above server budget %d: %w", errResp.Cost, errResp.MaxCost, model.ErrQueryTooExpensive)
+		}
+		return fmt.Errorf("unknown bad request error: %s", errResp.Error)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package searchclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// семейства адресов DialConfig.Prefer
+const (
+	// IPv4 пробуется первым, IPv6 - запасным
+	PreferIPv4 = "ipv4"
+	// IPv6 пробуется первым, IPv4 - запасным
+	PreferIPv6 = "ipv6"
+	// только IPv4, адреса IPv6 не пробуются вовсе
+	OnlyIPv4 = "ipv4only"
+	// только IPv6
+	OnlyIPv6 = "ipv6only"
+)
+
+const (
+	// как у http.DefaultTransport
+	defaultDialTimeout = 30 * time.Second
+	defaultKeepAlive   = 30 * time.Second
+	// как у net.Dialer
+	defaultFallbackDelay = 300 * time.Millisecond
+)
+
+// DialConfig - как клиент соединяется с сервером, у которого есть адреса IPv4 и IPv6
+type DialConfig struct {
+	// таймаут установки соединения, 0 - defaultDialTimeout
+	Timeout time.Duration
+	// happy eyeballs (RFC 6555): через сколько, не дождавшись соединения по первому семейству адресов,
+	// параллельно пробовать второе. 0 - defaultFallbackDelay, меньше 0 - все адреса по очереди
+	FallbackDelay time.Duration
+	// какое семейство пробовать первым: PreferIPv4, PreferIPv6, OnlyIPv4, OnlyIPv6.
+	// Пусто - семейство первого адреса от резолвера
+	Prefer string
+}
+
+// dialer соединяется по DialConfig с адресами от resolver
+type dialer struct {
+	cfg      DialConfig
+	resolver Resolver
+}
+
+func newDialer(cfg DialConfig, resolver Resolver) *dialer {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &dialer{cfg: cfg, resolver: resolver}
+}
+
+// WithDialer задаёт клиенту настройки соединения cfg. Как и WithProxy, сохраняет заданный
+// раньше *http.Transport и резолвер из WithResolver
+func WithDialer(cfg DialConfig) ClientOption {
+	return func(c *SearchClient) {
+		d := newDialer(cfg, nil)
+		if c.dialer != nil {
+			d.resolver = c.dialer.resolver
+		}
+		c.setDialer(d)
+	}
+}
+
+// setDialer ставит d в собственный транспорт клиента
+func (c *SearchClient) setDialer(d *dialer) {
+	c.dialer = d
+	t := c.ownTransport()
+	t.DialContext = d.dial
+	c.transport = t
+}
+
+// ownTransport возвращает копию транспорта клиента, которую можно менять: заданный раньше
+// *http.Transport сохраняет настройки пула, другой RoundTripper заменяется транспортом по умолчанию
+func (c *SearchClient) ownTransport() *http.Transport {
+	if t, ok := c.transport.(*http.Transport); ok {
+		return t.Clone()
+	}
+	return NewTransport(TransportConfig{})
+}
+
+func (d *dialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	timeout := d.cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs := []string{host}
+	if net.ParseIP(host) == nil {
+		if addrs, err = d.resolver.LookupHost(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+	primary, fallback := d.split(addrs)
+	if len(primary) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address", Name: host, IsNotFound: true}
+	}
+	if len(fallback) == 0 || d.cfg.FallbackDelay < 0 {
+		return dialSerial(ctx, network, append(primary, fallback...), port)
+	}
+	return dialParallel(ctx, network, primary, fallback, port, durationOr(d.cfg.FallbackDelay, defaultFallbackDelay))
+}
+
+// split делит адреса на основное и запасное семейства по DialConfig.Prefer
+func (d *dialer) split(addrs []string) (primary, fallback []string) {
+	var v4, v6 []string
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); ip != nil && ip.To4() == nil {
+			v6 = append(v6, a)
+		} else {
+			v4 = append(v4, a)
+		}
+	}
+	switch d.cfg.Prefer {
+	case PreferIPv4:
+		return v4, v6
+	case PreferIPv6:
+		return v6, v4
+	case OnlyIPv4:
+		return v4, nil
+	case OnlyIPv6:
+		return v6, nil
+	}
+	if len(addrs) > 0 && len(v6) > 0 && v6[0] == addrs[0] {
+		return v6, v4
+	}
+	return v4, v6
+}
+
+// dialSerial пробует адреса по очереди и возвращает первую ошибку, если не ответил ни один
+func dialSerial(ctx context.Context, network string, addrs []string, port string) (net.Conn, error) {
+	nd := &net.Dialer{KeepAlive: defaultKeepAlive}
+	var firstErr error
+	for _, a := range addrs {
+		conn, err := nd.DialContext(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// dialParallel - happy eyeballs: основное семейство сразу, запасное - через delay или сразу
+// после отказа основного. Побеждает первое соединение, проигравшее закрывается
+func dialParallel(ctx context.Context, network string, primary, fallback []string, port string, delay time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan dialResult, 2)
+	race := func(addrs []string) {
+		go func() {
+			conn, err := dialSerial(ctx, network, addrs, port)
+			results <- dialResult{conn, err}
+		}()
+	}
+
+	race(primary)
+	pending, fallbackStarted := 1, false
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if pending > 0 {
+					go func() {
+						if res := <-results; res.conn != nil {
+							res.conn.Close()
+						}
+					}()
+				}
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if fallbackStarted && pending == 0 {
+				return nil, firstErr
+			}
+		}
+		if !fallbackStarted {
+			fallbackStarted = true
+			pending++
+			race(fallback)
+		}
+	}
+}
+
+func durationOr(v, def time.Duration) time.Duration {
+	if v > 0 {
+		return v
+	}
+	return def
+}
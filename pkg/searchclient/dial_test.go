@@ -0,0 +1,58 @@
+package searchclient
+
+import (
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestDialerSplit(t *testing.T) {
+	addrs := []string{"::1", "10.0.0.1", "fe80::2", "10.0.0.2"}
+	cases := []struct {
+		prefer            string
+		primary, fallback []string
+	}{
+		{"", []string{"::1", "fe80::2"}, []string{"10.0.0.1", "10.0.0.2"}},
+		{PreferIPv4, []string{"10.0.0.1", "10.0.0.2"}, []string{"::1", "fe80::2"}},
+		{PreferIPv6, []string{"::1", "fe80::2"}, []string{"10.0.0.1", "10.0.0.2"}},
+		{OnlyIPv4, []string{"10.0.0.1", "10.0.0.2"}, nil},
+		{OnlyIPv6, []string{"::1", "fe80::2"}, nil},
+	}
+	for _, c := range cases {
+		primary, fallback := newDialer(DialConfig{Prefer: c.prefer}, nil).split(addrs)
+		if !reflect.DeepEqual(primary, c.primary) || !reflect.DeepEqual(fallback, c.fallback) {
+			t.Errorf("Error : %q: %v %v", c.prefer, primary, fallback)
+		}
+	}
+	// без предпочтения первым идёт семейство первого адреса
+	if primary, _ := newDialer(DialConfig{}, nil).split([]string{"10.0.0.1", "::1"}); primary[0] != "10.0.0.1" {
+		t.Errorf("Error : unexpected primary %v", primary)
+	}
+}
+
+func TestWithDialer(t *testing.T) {
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	target := "http://search.test:" + port
+	// по IPv6 сервер недоступен: основное семейство отказывает, и сразу пробуется запасное
+	resolver := &fakeResolver{addrs: []string{"::1", "127.0.0.1"}}
+
+	for _, cfg := range []DialConfig{{}, {FallbackDelay: -1}, {Prefer: PreferIPv4}, {Prefer: OnlyIPv4, Timeout: time.Second}} {
+		// WithDialer после WithResolver сохраняет резолвер
+		client := NewSearchClient(accessToken, target, WithResolver(resolver), WithDialer(cfg))
+		if resp, err := client.FindUsers(model.SearchRequest{Limit: 5}); err != nil || len(resp.Users) != 5 {
+			t.Errorf("Error : %+v: %v %v", cfg, resp, err)
+		}
+	}
+
+	client := NewSearchClient(accessToken, target, WithDialer(DialConfig{Prefer: OnlyIPv6}), WithResolver(&fakeResolver{addrs: []string{"127.0.0.1"}}))
+	if _, err := client.FindUsers(model.SearchRequest{Limit: 5}); err == nil || !strings.Contains(err.Error(), "no suitable address") {
+		t.Errorf("Error : %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package searchclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+const defaultDiskCacheBytes = 64 << 20
+
+// diskCachePrefix - начало имён файлов страниц: чистка трогает только их, а не все файлы каталога
+const diskCachePrefix = "searchcache-"
+
+// diskCache - страницы кэша клиента в файлах, по одному json на страницу. Переживает
+// перезапуск процесса, каталог можно делить между процессами: файлы пишутся атомарно
+type diskCache struct {
+	dir      string
+	maxBytes int64
+	// адрес сервера и токен: выдача зависит от обоих, а каталог может быть общим
+	scope string
+
+	mu sync.Mutex
+	// сколько байт страниц в каталоге: последний подсчёт плюс записанное с тех пор.
+	// Каталог перечитывается, только когда оценка выходит за maxBytes
+	used    int64
+	counted bool
+}
+
+// diskEntry - файл страницы
+type diskEntry struct {
+	Stored   time.Time
+	TTL      time.Duration
+	MaxStale time.Duration
+	ETag     string `json:",omitempty"`
+	Resp     model.SearchResponse
+}
+
+func (d *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(d.scope + "\x00" + key))
+	return filepath.Join(d.dir, diskCachePrefix+hex.EncodeToString(sum[:])+".json")
+}
+
+// get читает страницу с диска, просроченную удаляет
+func (d *diskCache) get(key string) (*cachedResult, bool) {
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var e diskEntry
+	// просроченную страницу с ETag оставляем: её подтвердит условный запрос
+	if err := json.Unmarshal(data, &e); err != nil || e.ETag == "" && time.Since(e.Stored) > e.TTL+e.MaxStale {
+		os.Remove(path)
+		return nil, false
+	}
+	if e.Resp.Users == nil {
+		e.Resp.Users = []model.User{}
+	}
+	return &cachedResult{key: key, resp: e.Resp, stored: e.Stored, ttl: e.TTL, maxStale: e.MaxStale, etag: e.ETag}, true
+}
+
+// put пишет страницу во временный файл и переименовывает. Ошибки диска не мешают
+// поиску: страница просто не переживёт перезапуск
+func (d *diskCache) put(entry *cachedResult) {
+	data, err := json.Marshal(diskEntry{Stored: entry.stored, TTL: entry.ttl, MaxStale: entry.maxStale, ETag: entry.etag, Resp: entry.resp})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(d.dir, diskCachePrefix+"*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	path := d.path(entry.key)
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.counted {
+		d.counted = true
+		d.trim()
+		return
+	}
+	d.used += int64(len(data)) - replaced
+	if d.used > d.maxBytes {
+		d.trim()
+	}
+}
+
+// trim пересчитывает страницы в каталоге и удаляет самые старые, пока их больше maxBytes.
+// Вызывается под d.mu
+func (d *diskCache) trim() {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+	type file struct {
+		path string
+		size int64
+		mod  time.Time
+	}
+	var files []file
+	var total int64
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), diskCachePrefix) || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{filepath.Join(d.dir, e.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	defer func() { d.used = total }()
+	if total <= d.maxBytes {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.Before(files[j].mod) })
+	for _, f := range files {
+		if total <= d.maxBytes {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			total -= f.size
+		}
+	}
+}
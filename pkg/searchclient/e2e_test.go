@@ -0,0 +1,58 @@
+package searchclient
+
+import (
+	"net/http"
+	"testing"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchserver"
+)
+
+var scenarioUsers = []model.User{
+	{Id: 1, Name: "Boyd Wolf", Age: 22, About: "likes go", Gender: "male"},
+	{Id: 2, Name: "Hilda Mayer", Age: 30, About: "likes rust", Gender: "female"},
+	{Id: 3, Name: "Anna Smith", Age: 25, About: "likes go", Gender: "female"},
+}
+
+func TestScenarioPaging(t *testing.T) {
+	sc := newScenario(t, searchserver.ServerConfig{}, scenarioUsers...)
+
+	first := sc.find(model.SearchRequest{Limit: 2, OrderField: "Age", OrderBy: model.OrderByAsc})
+	sc.expectIDs(first, 1, 3)
+	if !first.NextPage {
+		t.Errorf("Error : expected next page")
+	}
+	last := sc.find(model.SearchRequest{Limit: 2, Offset: 2, OrderField: "Age", OrderBy: model.OrderByAsc})
+	sc.expectIDs(last, 2)
+	if last.NextPage {
+		t.Errorf("Error : unexpected next page")
+	}
+	sc.findErr(model.SearchRequest{OrderField: "Gender", OrderBy: model.OrderByAsc}, "OrderFeld Gender invalid")
+}
+
+func TestScenarioSeed(t *testing.T) {
+	sc := newScenario(t, searchserver.ServerConfig{CacheSize: 10}, scenarioUsers...)
+	sc.expectIDs(sc.find(model.SearchRequest{Limit: 5, Query: "go"}), 1, 3)
+
+	// после перезагрузки датасета кэш не должен отдавать старую выдачу
+	sc.seed(model.User{Id: 10, Name: "New User", About: "likes go"})
+	sc.expectIDs(sc.find(model.SearchRequest{Limit: 5, Query: "go"}), 10)
+}
+
+func TestScenarioSoftDelete(t *testing.T) {
+	sc := newScenario(t, searchserver.ServerConfig{}, scenarioUsers...)
+
+	if resp := sc.do(http.MethodDelete, "/users/1", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Error : unexpected status %d", resp.StatusCode)
+	}
+	sc.expectIDs(sc.find(model.SearchRequest{Limit: 5, Query: "go"}), 3)
+}
+
+func TestScenarioPatch(t *testing.T) {
+	sc := newScenario(t, searchserver.ServerConfig{}, scenarioUsers...)
+
+	if resp := sc.do(http.MethodPatch, "/users/2", `{"Age": 18}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d", resp.StatusCode)
+	}
+	sc.expectIDs(sc.find(model.SearchRequest{Limit: 1, OrderField: "Age", OrderBy: model.OrderByAsc}), 2)
+}
@@ -0,0 +1,52 @@
+package searchclient
+
+import (
+	"testing"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchserver"
+)
+
+var _ Searcher = (*searchserver.EmbeddedSearcher)(nil)
+
+func TestEmbeddedMatchesHTTP(t *testing.T) {
+	server, httpClient := newTestServer(accessToken)
+	defer server.Close()
+	embedded, err := searchserver.NewEmbeddedSearcher("../../dataset.xml", testServerConfig)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	defer embedded.Close()
+
+	req := model.SearchRequest{Limit: 5, Offset: 3, Query: "nisi", OrderField: "Name", OrderBy: model.OrderByDesc}
+	expected, err := httpClient.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	r, err := embedded.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if len(r.Users) != len(expected.Users) || r.NextPage != expected.NextPage || r.Snapshot == "" {
+		t.Fatalf("Error : %v != %v", r, expected)
+	}
+	for i := range r.Users {
+		if r.Users[i] != expected.Users[i] {
+			t.Errorf("Error : %v != %v", r.Users[i], expected.Users[i])
+		}
+	}
+
+	// limit 0 - выдача целиком, а не пустая страница с NextPage
+	if r, err := embedded.FindUsers(model.SearchRequest{Query: "nisi"}); err != nil || len(r.Users) == 0 || r.NextPage {
+		t.Errorf("Error : unexpected response %v %v", r, err)
+	}
+	if _, err := embedded.FindUsers(model.SearchRequest{Limit: 1, OrderField: "Gender", OrderBy: model.OrderByAsc}); err != model.ErrBadOrderField {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+	if _, err := embedded.FindUsers(model.SearchRequest{Limit: 1, Snapshot: "stale"}); err != model.ErrSnapshotChanged {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+	if _, err := searchserver.NewEmbeddedSearcher("missing.xml", testServerConfig); err == nil {
+		t.Errorf("Error : missing dataset loaded")
+	}
+}
@@ -0,0 +1,96 @@
+package searchclient
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchserver"
+)
+
+func TestFindUsersProtobuf(t *testing.T) {
+	server, _ := newTestServer(accessToken)
+	defer server.Close()
+	client := NewSearchClient(accessToken, server.URL, WithFormat(model.FormatProtobuf))
+
+	r, err := client.FindUsers(model.SearchRequest{Limit: 2, OrderField: "Id", OrderBy: model.OrderByDesc})
+
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if len(r.Users) != 2 || !r.NextPage || r.Users[0].Id != 34 {
+		t.Errorf("Error : unexpected response %v", r)
+	}
+}
+
+func TestFindUsersMsgpack(t *testing.T) {
+	server, _ := newTestServer(accessToken)
+	defer server.Close()
+	client := NewSearchClient(accessToken, server.URL, WithFormat(model.FormatMsgpack))
+
+	r, err := client.FindUsers(model.SearchRequest{Limit: 3, OrderField: "Id", OrderBy: model.OrderByAsc})
+
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if len(r.Users) != 3 || !r.NextPage || r.Users[0].Name != "Boyd Wolf" {
+		t.Errorf("Error : unexpected response %v", r)
+	}
+}
+
+// дополнительные поля пользователя доходят до клиента во всех форматах
+func TestFindUsersExtendedFields(t *testing.T) {
+	server, _ := newTestServer(accessToken)
+	defer server.Close()
+
+	for _, format := range []string{model.FormatJSON, model.FormatXML, model.FormatMsgpack, model.FormatProtobuf} {
+		client := NewSearchClient(accessToken, server.URL, WithFormat(format))
+		r, err := client.FindUsers(model.SearchRequest{Limit: 1, Query: "Boyd"})
+		if err != nil || len(r.Users) != 1 {
+			t.Fatalf("Error : %s: %v %v", format, r, err)
+		}
+		if u := r.Users[0]; u.Email != "boydwolf@hopeli.com" || u.Company != "HOPELI" || u.Phone == "" || u.Address == "" {
+			t.Errorf("Error : %s: unexpected user %+v", format, u)
+		}
+	}
+}
+
+// countingJSON - подменная реализация json, считает вызовы
+type countingJSON struct {
+	encodes, unmarshals int
+}
+
+func (c *countingJSON) Encode(w io.Writer, v interface{}) error {
+	c.encodes++
+	return model.StdJSON.Encode(w, v)
+}
+
+func (c *countingJSON) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshals++
+	return model.StdJSON.Unmarshal(data, v)
+}
+
+func TestFindUsersJSONEngine(t *testing.T) {
+	users, _ := searchserver.LoadDataset("../../dataset.xml")
+	serverJSON := &countingJSON{}
+	cfg := testServerConfig
+	cfg.JSON = serverJSON
+	server := httptest.NewServer(searchserver.NewServer(users, cfg))
+	defer server.Close()
+
+	for _, version := range []int{model.WireV1, model.WireV2} {
+		clientJSON := &countingJSON{}
+		client := NewSearchClient(accessToken, server.URL, WithJSON(clientJSON), WithWireVersion(version))
+		r, err := client.FindUsers(model.SearchRequest{Limit: 2, OrderField: "Id", OrderBy: model.OrderByAsc})
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		if len(r.Users) != 2 || r.Users[0].Name != "Boyd Wolf" || clientJSON.unmarshals != 1 {
+			t.Errorf("Error : unexpected response %v %+v", r, clientJSON)
+		}
+	}
+	if serverJSON.encodes != 2 {
+		t.Errorf("Error : unexpected server calls %+v", serverJSON)
+	}
+}
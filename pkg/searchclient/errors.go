@@ -0,0 +1,34 @@
+package searchclient
+
+import (
+	"errors"
+	"fmt"
+
+	"final_task_golang/pkg/model"
+)
+
+// ErrRequestTimeout - запрос не уложился в таймаут самого клиента (WithTimeout), то есть сервер
+// отвечал слишком долго. Истёкший дедлайн контекста вызывающего - context.DeadlineExceeded
+var ErrRequestTimeout = errors.New("request timeout")
+
+// TimeoutError - запрос не уложился во время. Причину различают через errors.Is:
+// context.DeadlineExceeded - истёк дедлайн вызывающего, ErrRequestTimeout - таймаут клиента
+type TimeoutError struct {
+	// строка запроса
+	Query string
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	return "timeout for " + model.Redact(e.Query)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// unknownError - транспортная ошибка без разбора. Текст ошибок net/http содержит адрес запроса,
+// поэтому учётные данные из него вычищаются
+func unknownError(err error) error {
+	return fmt.Errorf("unknown error %s", model.Redact(err.Error()))
+}
@@ -0,0 +1,47 @@
+package searchclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+// FuzzFindUsersResponse подсовывает клиенту произвольные ответы сервера во всех форматах.
+// Клиент не должен паниковать, а при успехе - не отдавать больше записей, чем просили
+func FuzzFindUsersResponse(f *testing.F) {
+	f.Add(200, 0, []byte(`[{"Id": 1, "Name": "Boyd"}]`))
+	f.Add(200, 0, []byte(`[{"Id": 1`))
+	f.Add(400, 0, []byte(`{"Error": "ErrorBadOrderField"}`))
+	f.Add(400, 0, []byte(`{"Error": 1}`))
+	f.Add(200, 1, []byte(`<users><user><Id>1</Id></user></users>`))
+	f.Add(200, 2, []byte{0x91, 0x81, 0xa2, 'I', 'd', 0x01})
+	f.Add(200, 2, []byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	f.Add(200, 3, []byte{0x0a, 0x02, 0x08, 0x01})
+	f.Add(500, 0, []byte(``))
+
+	formats := []string{model.FormatJSON, model.FormatXML, model.FormatMsgpack, model.FormatProtobuf}
+	var status int
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	f.Fuzz(func(t *testing.T, fuzzStatus int, format int, fuzzBody []byte) {
+		if fuzzStatus < 200 || fuzzStatus > 599 {
+			return
+		}
+		if format < 0 {
+			format = -format
+		}
+		status, body = fuzzStatus, fuzzBody
+		srv := NewSearchClient(accessToken, ts.URL, WithFormat(formats[format%len(formats)]))
+		resp, err := srv.FindUsers(model.SearchRequest{Limit: 3})
+		if err == nil && len(resp.Users) > 3 {
+			t.Errorf("Error : too many users %d", len(resp.Users))
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package searchclient
+
+import (
+	"context"
+	"fmt"
+
+	"final_task_golang/pkg/model"
+)
+
+// FindUsersAs ищет как FindUsers, но разбирает записи в T: свою структуру, если нужны поля,
+// которых нет в model.User, или json.RawMessage, чтобы получить записи как есть.
+//
+// Ответ всегда запрашивается в json, формат клиента и хуки (они работают с model.User)
+// не применяются. Limit обрезается до страницы, признака следующей страницы нет:
+// если записей меньше Limit, страница последняя
+func FindUsersAs[T any](ctx context.Context, srv *SearchClient, req model.SearchRequest) ([]T, error) {
+	req, err := checkRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	engine := srv.json
+	if engine == nil {
+		engine = model.StdJSON
+	}
+
+	resp, err := srv.send(ctx, srv.httpClient(client), encodeSearchQuery(req, srv.orderLocaleParam, false), "", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := srv.readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := statusError(resp.StatusCode, body, req); err != nil {
+		srv.metrics.add("errors_server")
+		return nil, err
+	}
+
+	users, err := model.DecodeSearchJSONAs[T](engine, body)
+	if err != nil {
+		srv.metrics.add("errors_decode")
+		return nil, fmt.Errorf("cant unpack result json: %s", err)
+	}
+	return users, nil
+}
@@ -0,0 +1,85 @@
+package searchclient
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/searchpb"
+)
+
+// Searcher - общий интерфейс поиска пользователей, независимо от транспорта
+type Searcher interface {
+	FindUsers(req model.SearchRequest) (*model.SearchResponse, error)
+}
+
+var (
+	_ Searcher = (*SearchClient)(nil)
+	_ Searcher = (*GRPCSearchClient)(nil)
+)
+
+// GRPCSearchClient - реализация Searcher поверх gRPC, для внутренних сервисов без накладных расходов на json
+type GRPCSearchClient struct {
+	// токен, по которому происходит авторизация на внешней системе, уходит туда через метаданные
+	AccessToken string
+
+	client searchpb.SearchServiceClient
+}
+
+func NewGRPCSearchClient(accessToken string, conn grpc.ClientConnInterface) *GRPCSearchClient {
+	return &GRPCSearchClient{
+		AccessToken: accessToken,
+		client:      searchpb.NewSearchServiceClient(conn),
+	}
+}
+
+func (c *GRPCSearchClient) FindUsers(req model.SearchRequest) (*model.SearchResponse, error) {
+	if req.Limit < 0 {
+		return nil, fmt.Errorf("limit must be > 0")
+	}
+	if req.Limit > 25 {
+		req.Limit = 25
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("offset must be > 0")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "accesstoken", c.AccessToken)
+
+	resp, err := c.client.FindUsers(ctx, &searchpb.SearchRequest{
+		Limit:        int32(req.Limit),
+		Offset:       int32(req.Offset),
+		Query:        req.Query,
+		OrderField:   req.OrderField,
+		OrderBy:      int32(req.OrderBy),
+		AllowPartial: req.AllowPartial,
+		Email:        req.Email,
+		Phone:        req.Phone,
+		Company:      req.Company,
+		Address:      req.Address,
+	})
+	if err != nil {
+		st := status.Convert(err)
+		switch st.Code() {
+		case codes.Unauthenticated:
+			return nil, fmt.Errorf("Bad AccessToken")
+		case codes.DeadlineExceeded:
+			return nil, &TimeoutError{Query: encodeSearchQuery(req, "", false), Err: ErrRequestTimeout}
+		case codes.InvalidArgument:
+			if st.Message() == model.ErrBadOrderField.Error() {
+				return nil, fmt.Errorf("OrderFeld %s invalid", req.OrderField)
+			}
+			return nil, fmt.Errorf("unknown bad request error: %s", st.Message())
+		}
+		return nil, unknownError(err)
+	}
+
+	return &model.SearchResponse{NextPage: resp.NextPage, Partial: resp.Partial, Users: model.UsersFromProto(resp)}, nil
+}
@@ -0,0 +1,129 @@
+package searchclient
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"final_task_golang/pkg/model"
+)
+
+func newTestGRPCClient(t *testing.T, token string) *GRPCSearchClient {
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	newTestHandler().RegisterGRPC(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewGRPCSearchClient(token, conn)
+}
+
+func TestGRPCFindUsers(t *testing.T) {
+	client := newTestGRPCClient(t, accessToken)
+
+	r, err := client.FindUsers(model.SearchRequest{Limit: 2, OrderField: "Age", OrderBy: model.OrderByAsc})
+
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if len(r.Users) != 2 || !r.NextPage || r.Users[0].Age > r.Users[1].Age {
+		t.Errorf("Error : unexpected response %v", r)
+	}
+}
+
+func TestGRPCNoLimit(t *testing.T) {
+	client := newTestGRPCClient(t, accessToken)
+
+	// без limit и без лимитов в конфиге сервер отдаёт выдачу целиком, как GET /
+	r, err := client.FindUsers(model.SearchRequest{Query: "nisi"})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if len(r.Users) == 0 || r.NextPage {
+		t.Errorf("Error : unexpected response %d users, next page %v", len(r.Users), r.NextPage)
+	}
+}
+
+func TestGRPCMatchesHTTP(t *testing.T) {
+	server, httpClient := newTestServer(accessToken)
+	defer server.Close()
+	grpcClient := newTestGRPCClient(t, accessToken)
+
+	req := model.SearchRequest{Limit: 5, Offset: 3, Query: "nisi", OrderField: "Name", OrderBy: model.OrderByDesc}
+	expected, err := httpClient.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	r, err := grpcClient.FindUsers(req)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+
+	if len(r.Users) != len(expected.Users) || r.NextPage != expected.NextPage {
+		t.Fatalf("Error : %v != %v", r, expected)
+	}
+	for i := range r.Users {
+		if r.Users[i] != expected.Users[i] {
+			t.Errorf("Error : %v != %v", r.Users[i], expected.Users[i])
+		}
+	}
+}
+
+func TestGRPCContactFilters(t *testing.T) {
+	server, httpClient := newTestServer(accessToken)
+	defer server.Close()
+	grpcClient := newTestGRPCClient(t, accessToken)
+
+	for _, req := range []model.SearchRequest{
+		{Limit: 25, Email: "BOYDWOLF@hopeli.com"},
+		{Limit: 25, Phone: "+1 (956) 593-2402"},
+		{Limit: 25, Company: "hopeli"},
+		{Limit: 25, Address: "Winthrop Street"},
+	} {
+		expected, err := httpClient.FindUsers(req)
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		r, err := grpcClient.FindUsers(req)
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		if len(r.Users) != 1 || r.Users[0].Id != 0 || len(expected.Users) != 1 || r.Users[0] != expected.Users[0] {
+			t.Errorf("Error : %+v: %v != %v", req, r.Users, expected.Users)
+		}
+	}
+}
+
+func TestGRPCInvalidAccessToken(t *testing.T) {
+	client := newTestGRPCClient(t, "")
+
+	_, err := client.FindUsers(model.SearchRequest{})
+
+	if err == nil || err.Error() != "Bad AccessToken" {
+		t.Errorf("Error : %v", err)
+	}
+}
+
+func TestGRPCInvalidOrderField(t *testing.T) {
+	client := newTestGRPCClient(t, accessToken)
+
+	_, err := client.FindUsers(model.SearchRequest{OrderBy: model.OrderByAsc, OrderField: "invalid"})
+
+	if err == nil || err.Error() != "OrderFeld invalid invalid" {
+		t.Errorf("Error : %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package searchclient
+
+import (
+	"errors"
+	"io"
+
+	"final_task_golang/pkg/model"
+)
+
+// сколько раз итератор с WithDedup начинает выдачу заново, прежде чем вернуть ErrSnapshotChanged
+const maxIteratorRestarts = 3
+
+// UserIterator обходит выдачу запроса целиком, страница за страницей через FindUsers
+// любого Searcher. Limit запроса - размер страницы (0 - maxPageSize), Offset - откуда начать.
+//
+// Если сервер вернул Snapshot с первой страницей, остальные запрашиваются по нему: изменился
+// датасет посреди обхода - Next вернёт model.ErrSnapshotChanged, а с WithDedup начнёт заново
+type UserIterator struct {
+	searcher Searcher
+	req      model.SearchRequest
+	page     []model.User
+	last     bool
+	// Offset исходного запроса, с него обход начинается заново
+	start    int
+	restarts int
+	// Id уже отданных записей, nil - без дедупликации
+	seen map[int]bool
+}
+
+// IteratorOption настраивает UserIterator
+type IteratorOption func(*UserIterator)
+
+// WithDedup пропускает записи, Id которых уже были отданы. Если датасет меняется между
+// страницами, сдвиг offset может повторить запись на следующей странице - с этой опцией
+// каждый Id встречается один раз. Обратный сдвиг (запись пропущена) лечится только
+// перезапуском по Snapshot, без него - никак
+func WithDedup() IteratorOption {
+	return func(it *UserIterator) {
+		it.seen = map[int]bool{}
+	}
+}
+
+func NewUserIterator(searcher Searcher, req model.SearchRequest, opts ...IteratorOption) *UserIterator {
+	if req.Limit <= 0 || req.Limit > maxPageSize {
+		req.Limit = maxPageSize
+	}
+	it := &UserIterator{searcher: searcher, req: req, start: req.Offset}
+	for _, opt := range opts {
+		opt(it)
+	}
+	return it
+}
+
+// Next возвращает следующего пользователя, по окончании выдачи - io.EOF
+func (it *UserIterator) Next() (model.User, error) {
+	for {
+		for len(it.page) > 0 {
+			u := it.page[0]
+			it.page = it.page[1:]
+			if it.seen != nil {
+				if it.seen[u.Id] {
+					continue
+				}
+				it.seen[u.Id] = true
+			}
+			return u, nil
+		}
+		if it.last {
+			return model.User{}, io.EOF
+		}
+		resp, err := it.searcher.FindUsers(it.req)
+		if errors.Is(err, model.ErrSnapshotChanged) && it.seen != nil && it.restarts < maxIteratorRestarts {
+			// уже отданные записи отсеет seen
+			it.restarts++
+			it.req.Offset, it.req.Snapshot = it.start, ""
+			continue
+		}
+		if err != nil {
+			return model.User{}, err
+		}
+		if it.req.Snapshot == "" {
+			it.req.Snapshot = resp.Snapshot
+		}
+		it.page = resp.Users
+		it.req.Offset += len(resp.Users)
+		it.last = !resp.NextPage || len(resp.Users) == 0
+	}
+}
@@ -0,0 +1,136 @@
+package searchclient
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+// churnSearcher после каждой страницы добавляет запись в начало выдачи,
+// так что следующая страница повторяет последнюю запись предыдущей
+type churnSearcher struct {
+	users []model.User
+	next  int
+}
+
+func (s *churnSearcher) FindUsers(req model.SearchRequest) (*model.SearchResponse, error) {
+	end := req.Offset + req.Limit
+	if end > len(s.users) {
+		end = len(s.users)
+	}
+	resp := &model.SearchResponse{
+		Users:    append([]model.User{}, s.users[req.Offset:end]...),
+		NextPage: end < len(s.users),
+	}
+	s.next--
+	s.users = append([]model.User{{Id: s.next}}, s.users...)
+	return resp, nil
+}
+
+func collectIds(t *testing.T, it *UserIterator) []int {
+	var ids []int
+	for {
+		u, err := it.Next()
+		if err == io.EOF {
+			return ids
+		}
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		ids = append(ids, u.Id)
+	}
+}
+
+func TestUserIteratorDedup(t *testing.T) {
+	newSearcher := func() *churnSearcher {
+		s := &churnSearcher{}
+		for i := 0; i < 10; i++ {
+			s.users = append(s.users, model.User{Id: i})
+		}
+		return s
+	}
+
+	ids := collectIds(t, NewUserIterator(newSearcher(), model.SearchRequest{Limit: 3}))
+	if len(ids) != 14 {
+		t.Errorf("Error : without dedup expected repeats, got %v", ids)
+	}
+
+	ids = collectIds(t, NewUserIterator(newSearcher(), model.SearchRequest{Limit: 3}, WithDedup()))
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("Error : id %d repeated in %v", id, ids)
+		}
+		seen[id] = true
+	}
+	if len(ids) != 10 {
+		t.Errorf("Error : expected 10 unique users, got %v", ids)
+	}
+}
+
+func TestUserIteratorServer(t *testing.T) {
+	server, client := newTestServer(accessToken)
+	defer server.Close()
+
+	ids := collectIds(t, NewUserIterator(&client, model.SearchRequest{Limit: 10}, WithDedup()))
+	if len(ids) != 35 {
+		t.Errorf("Error : expected 35 users, got %d", len(ids))
+	}
+}
+
+// reloadingSearcher после первой страницы перезагружает датасет сервера с новой записью в начале
+type reloadingSearcher struct {
+	Searcher
+	reload func()
+}
+
+func (s *reloadingSearcher) FindUsers(req model.SearchRequest) (*model.SearchResponse, error) {
+	resp, err := s.Searcher.FindUsers(req)
+	if s.reload != nil {
+		s.reload()
+		s.reload = nil
+	}
+	return resp, err
+}
+
+func TestUserIteratorSnapshot(t *testing.T) {
+	for _, dedup := range []bool{false, true} {
+		handler := newTestHandler()
+		server := httptest.NewServer(handler)
+		client := NewSearchClient(accessToken, server.URL)
+		searcher := &reloadingSearcher{Searcher: client, reload: func() {
+			resp, _ := client.FindUsers(model.SearchRequest{Limit: 25})
+			more, _ := client.FindUsers(model.SearchRequest{Limit: 25, Offset: 25})
+			handler.Reload(append(append([]model.User{{Id: 100, Name: "New"}}, resp.Users...), more.Users...))
+		}}
+
+		var opts []IteratorOption
+		if dedup {
+			opts = append(opts, WithDedup())
+		}
+		it := NewUserIterator(searcher, model.SearchRequest{Limit: 10}, opts...)
+		var ids []int
+		var err error
+		for {
+			var u model.User
+			if u, err = it.Next(); err != nil {
+				break
+			}
+			ids = append(ids, u.Id)
+		}
+		server.Close()
+
+		if !dedup {
+			if !errors.Is(err, model.ErrSnapshotChanged) {
+				t.Errorf("Error : expected ErrSnapshotChanged, got %v after %v", err, ids)
+			}
+			continue
+		}
+		if err != io.EOF || len(ids) != 36 {
+			t.Errorf("Error : expected 36 users after restart, got %d %v", len(ids), err)
+		}
+	}
+}
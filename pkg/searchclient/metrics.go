@@ -0,0 +1,48 @@
+package searchclient
+
+import (
+	"expvar"
+	"sync"
+)
+
+// expvarMu - expvar.NewMap паникует на повторном имени, а клиенты с одним name
+// могут создаваться одновременно
+var expvarMu sync.Mutex
+
+// clientMetrics - счётчики клиента в expvar, их отдаёт /debug/vars вместе с остальными.
+// nil - счётчики выключены, все методы тогда ничего не делают
+type clientMetrics struct {
+	vars *expvar.Map
+}
+
+// WithExpvar публикует счётчики клиента в expvar под именем name:
+//
+//	requests                      - запросы к серверу
+//	errors_timeout                - таймаут клиента или дедлайн вызывающего
+//	errors_canceled               - контекст вызывающего отменён
+//	errors_transport              - сервер недоступен
+//	errors_server                 - сервер ответил ошибкой
+//	errors_decode                 - ответ не разобрался
+//	errors_checksum               - тело не сошлось с суммой сервера, см. WithChecksumVerification
+//	cache_hits, cache_misses, cache_stale_hits - кэш страниц, см. WithCache
+//	cache_not_modified - устаревшая страница подтверждена ответом 304 на условный запрос
+//	watch_not_modified - опрос WatchQuery, на который сервер ответил 304
+//
+// Клиенты с одним name пишут в одни счётчики
+func WithExpvar(name string) ClientOption {
+	return func(c *SearchClient) {
+		expvarMu.Lock()
+		vars, ok := expvar.Get(name).(*expvar.Map)
+		if !ok {
+			vars = expvar.NewMap(name)
+		}
+		expvarMu.Unlock()
+		c.metrics = &clientMetrics{vars: vars}
+	}
+}
+
+func (m *clientMetrics) add(key string) {
+	if m != nil {
+		m.vars.Add(key, 1)
+	}
+}
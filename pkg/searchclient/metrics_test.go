@@ -0,0 +1,71 @@
+package searchclient
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// expvarValue - счётчик key из expvar-карты name, 0 - карты или счётчика ещё нет
+func expvarValue(name, key string) int64 {
+	vars, _ := expvar.Get(name).(*expvar.Map)
+	if vars == nil {
+		return 0
+	}
+	v, _ := vars.Get(key).(*expvar.Int)
+	if v == nil {
+		return 0
+	}
+	return v.Value()
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+	// expvar общий на процесс: с -count=N счётчики копятся, поэтому сравниваются приращения
+	keys := []string{"requests", "cache_hits", "cache_misses", "errors_server", "errors_transport"}
+	before := map[string]int64{}
+	for _, key := range keys {
+		before[key] = expvarValue("searchclient_test", key)
+	}
+	client := NewSearchClient(accessToken, server.URL, WithExpvar("searchclient_test"), WithCache(CacheConfig{TTL: time.Hour}))
+
+	client.FindUsers(model.SearchRequest{Limit: 5})
+	client.FindUsers(model.SearchRequest{Limit: 5})
+	client.FindUsers(model.SearchRequest{Limit: 5, OrderField: "bad", OrderBy: model.OrderByAsc})
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	// второй клиент с тем же именем пишет в те же счётчики
+	NewSearchClient(accessToken, down.URL, WithExpvar("searchclient_test")).FindUsers(model.SearchRequest{})
+
+	for key, expected := range map[string]int64{
+		"requests":         3,
+		"cache_hits":       1,
+		"cache_misses":     2,
+		"errors_server":    1,
+		"errors_transport": 1,
+	} {
+		if got := expvarValue("searchclient_test", key) - before[key]; got != expected {
+			t.Errorf("Error : %s grew by %d, want %d", key, got, expected)
+		}
+	}
+}
+
+func TestExpvarConcurrent(t *testing.T) {
+	// одно новое имя из нескольких горутин сразу: expvar.NewMap не должен вызваться дважды
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			NewSearchClient(accessToken, "http://127.0.0.1:1", WithExpvar("searchclient_concurrent_test"))
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+}
@@ -0,0 +1,113 @@
+package searchclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"final_task_golang/pkg/model"
+)
+
+// MultiSearchResult - ответ на один запрос MultiSearch: страница или ошибка, как у FindUsers
+type MultiSearchResult struct {
+	Response *model.SearchResponse
+	Err      error
+}
+
+// MultiSearch выполняет запросы одним POST /msearch, сервер ищет их параллельно.
+// Ошибка запроса попадает в его MultiSearchResult, error - ошибка всего вызова.
+// Кэш клиента не используется
+func (srv *SearchClient) MultiSearch(reqs []model.SearchRequest) ([]MultiSearchResult, error) {
+	return srv.MultiSearchContext(context.Background(), reqs)
+}
+
+// MultiSearchContext - MultiSearch с контекстом вызывающего
+func (srv *SearchClient) MultiSearchContext(ctx context.Context, reqs []model.SearchRequest) ([]MultiSearchResult, error) {
+	// запросы после checkRequest, для ошибок в результатах
+	checked := make([]model.SearchRequest, len(reqs))
+	items := make([]map[string]string, len(reqs))
+	for i, req := range reqs {
+		req, err := checkRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		checked[i] = req
+		params, _ := url.ParseQuery(encodeSearchQuery(req, srv.orderLocaleParam, false))
+		items[i] = map[string]string{}
+		for name := range params {
+			items[i][name] = params.Get(name)
+		}
+	}
+	body, err := json.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("cant pack msearch json: %s", err)
+	}
+
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		return nil, unknownError(err)
+	}
+	target := base.ResolveReference(&url.URL{Path: "/msearch"})
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, unknownError(err)
+	}
+	httpReq.Header.Add("AccessToken", srv.AccessToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	srv.metrics.add("requests")
+	resp, err := srv.httpClient(client).Do(httpReq)
+	if err != nil {
+		return nil, unknownError(err)
+	}
+	defer resp.Body.Close()
+	data, err := srv.readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if err := statusError(resp.StatusCode, data, model.SearchRequest{}); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var pages []model.MultiSearchResult
+	if err := json.Unmarshal(data, &pages); err != nil || len(pages) != len(reqs) {
+		return nil, fmt.Errorf("cant unpack msearch json: %v", err)
+	}
+	results := make([]MultiSearchResult, len(pages))
+	for i, page := range pages {
+		if page.Status != http.StatusOK {
+			results[i].Err = multiSearchError(page, checked[i])
+			continue
+		}
+		users := page.Users
+		if users == nil {
+			users = []model.User{}
+		}
+		results[i].Response = &model.SearchResponse{
+			Users:    srv.applyHooks(users),
+			NextPage: page.NextPage,
+			Partial:  page.Partial,
+			Snapshot: page.Snapshot,
+		}
+	}
+	return results, nil
+}
+
+// multiSearchError - ошибка запроса из /msearch, та же, что вернул бы FindUsers
+func multiSearchError(page model.MultiSearchResult, req model.SearchRequest) error {
+	errResp := model.SearchErrorResponse{}
+	if page.Error != nil {
+		errResp = *page.Error
+	}
+	body, _ := json.Marshal(errResp)
+	if err := statusError(page.Status, body, req); err != nil {
+		return err
+	}
+	return fmt.Errorf("unexpected status %d: %s", page.Status, errResp.Error)
+}
@@ -0,0 +1,46 @@
+package searchclient
+
+import (
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestMultiSearch(t *testing.T) {
+	server, client := newTestServer(accessToken)
+	defer server.Close()
+
+	reqs := []model.SearchRequest{
+		{Limit: 3, Query: "nisi", OrderField: "Age", OrderBy: model.OrderByAsc},
+		{Limit: 25, OrderField: "Id", OrderBy: model.OrderByDesc},
+		{Limit: 2, OrderField: "About", OrderBy: model.OrderByAsc},
+	}
+	results, err := client.MultiSearch(reqs)
+	if err != nil || len(results) != len(reqs) {
+		t.Fatalf("Error : %v %v", results, err)
+	}
+	for i, req := range reqs[:2] {
+		expected, err := client.FindUsers(req)
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		got := results[i].Response
+		if results[i].Err != nil || len(got.Users) != len(expected.Users) || got.NextPage != expected.NextPage {
+			t.Fatalf("Error : request %d: %+v %v != %+v", i, got, results[i].Err, expected)
+		}
+		for k := range got.Users {
+			if got.Users[k] != expected.Users[k] {
+				t.Errorf("Error : request %d: %v != %v", i, got.Users[k], expected.Users[k])
+			}
+		}
+	}
+	if err := results[2].Err; err == nil || !strings.Contains(err.Error(), "OrderFeld About invalid") {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+
+	bad := SearchClient{AccessToken: "bad", URL: server.URL}
+	if _, err := bad.MultiSearch(reqs); err == nil || err.Error() != "Bad AccessToken" {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+}
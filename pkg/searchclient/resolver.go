@@ -0,0 +1,112 @@
+package searchclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDNSTTL      = 30 * time.Second
+	defaultDNSMaxStale = 10 * time.Minute
+)
+
+// Resolver находит адреса сервера по имени, например *net.Resolver
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// TTLResolver - Resolver, который знает TTL записей. CachingResolver держит его ответы
+// столько, сколько сказано в записи, а не ResolverCacheConfig.TTL
+type TTLResolver interface {
+	Resolver
+	LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error)
+}
+
+// ResolverCacheConfig - настройки CachingResolver
+type ResolverCacheConfig struct {
+	// откуда брать адреса, nil - net.DefaultResolver
+	Resolver Resolver
+	// сколько адреса свежие, если Resolver не TTLResolver, 0 - defaultDNSTTL.
+	// Стандартный резолвер TTL записей не сообщает
+	TTL time.Duration
+	// сколько после TTL отдавать прежние адреса, если Resolver ошибается, 0 - defaultDNSMaxStale,
+	// меньше 0 - не отдавать
+	MaxStale time.Duration
+}
+
+// CachingResolver кэширует адреса по именам: сервер не резолвится на каждое соединение,
+// а сбой DNS не мешает ходить по недавно известным адресам. Безопасен из любых горутин
+type CachingResolver struct {
+	cfg ResolverCacheConfig
+
+	mu    sync.Mutex
+	hosts map[string]resolvedHost
+}
+
+type resolvedHost struct {
+	addrs   []string
+	expires time.Time
+}
+
+// NewCachingResolver возвращает кэширующий резолвер, см. ResolverCacheConfig
+func NewCachingResolver(cfg ResolverCacheConfig) *CachingResolver {
+	if cfg.Resolver == nil {
+		cfg.Resolver = net.DefaultResolver
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultDNSTTL
+	}
+	if cfg.MaxStale == 0 {
+		cfg.MaxStale = defaultDNSMaxStale
+	}
+	return &CachingResolver{cfg: cfg, hosts: map[string]resolvedHost{}}
+}
+
+// LookupHost отдаёт свежие адреса из кэша, иначе спрашивает Resolver. Если он ошибся,
+// а адреса устарели не больше чем на MaxStale, отдаются они
+func (r *CachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	now := time.Now()
+	r.mu.Lock()
+	cached, ok := r.hosts[host]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return append([]string(nil), cached.addrs...), nil
+	}
+
+	ttl := r.cfg.TTL
+	var addrs []string
+	var err error
+	if tr, isTTL := r.cfg.Resolver.(TTLResolver); isTTL {
+		addrs, ttl, err = tr.LookupHostTTL(ctx, host)
+	} else {
+		addrs, err = r.cfg.Resolver.LookupHost(ctx, host)
+	}
+	if err != nil || len(addrs) == 0 {
+		if ok && r.cfg.MaxStale > 0 && now.Before(cached.expires.Add(r.cfg.MaxStale)) {
+			return append([]string(nil), cached.addrs...), nil
+		}
+		if err == nil {
+			err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.hosts[host] = resolvedHost{addrs: addrs, expires: time.Now().Add(ttl)}
+	r.mu.Unlock()
+	return append([]string(nil), addrs...), nil
+}
+
+// WithResolver резолвит имя сервера (и прокси, см. WithProxy) через resolver, например
+// NewCachingResolver. Как и WithProxy, сохраняет заданный раньше *http.Transport и настройки WithDialer
+func WithResolver(resolver Resolver) ClientOption {
+	return func(c *SearchClient) {
+		d := newDialer(DialConfig{}, resolver)
+		if c.dialer != nil {
+			d.cfg = c.dialer.cfg
+		}
+		c.setDialer(d)
+	}
+}
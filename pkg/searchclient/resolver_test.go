@@ -0,0 +1,111 @@
+package searchclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// fakeResolver отдаёт addrs или, пока fail, ошибку и считает запросы
+type fakeResolver struct {
+	addrs   []string
+	fail    atomic.Bool
+	lookups int32
+}
+
+func (f *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	atomic.AddInt32(&f.lookups, 1)
+	if f.fail.Load() {
+		return nil, errors.New("dns blip")
+	}
+	return f.addrs, nil
+}
+
+type fakeTTLResolver struct {
+	*fakeResolver
+	ttl time.Duration
+}
+
+func (f fakeTTLResolver) LookupHostTTL(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addrs, err := f.LookupHost(ctx, host)
+	return addrs, f.ttl, err
+}
+
+func TestCachingResolver(t *testing.T) {
+	fake := &fakeResolver{addrs: []string{"10.0.0.1"}}
+	r := NewCachingResolver(ResolverCacheConfig{Resolver: fake, TTL: 20 * time.Millisecond, MaxStale: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		if addrs, err := r.LookupHost(context.Background(), "search.test"); err != nil || addrs[0] != "10.0.0.1" {
+			t.Fatalf("Error : %v %v", addrs, err)
+		}
+	}
+	if n := atomic.LoadInt32(&fake.lookups); n != 1 {
+		t.Errorf("Error : %d lookups, want 1", n)
+	}
+
+	// DNS не отвечает - отдаются устаревшие адреса
+	time.Sleep(30 * time.Millisecond)
+	fake.fail.Store(true)
+	if addrs, err := r.LookupHost(context.Background(), "search.test"); err != nil || addrs[0] != "10.0.0.1" {
+		t.Errorf("Error : no stale fallback: %v %v", addrs, err)
+	}
+	if _, err := r.LookupHost(context.Background(), "other.test"); err == nil {
+		t.Errorf("Error : unknown host resolved")
+	}
+	if n := atomic.LoadInt32(&fake.lookups); n != 3 {
+		t.Errorf("Error : %d lookups, want 3", n)
+	}
+
+	noStale := NewCachingResolver(ResolverCacheConfig{Resolver: fake, TTL: time.Nanosecond, MaxStale: -1})
+	fake.fail.Store(false)
+	if _, err := noStale.LookupHost(context.Background(), "search.test"); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	fake.fail.Store(true)
+	if _, err := noStale.LookupHost(context.Background(), "search.test"); err == nil {
+		t.Errorf("Error : stale addresses with MaxStale < 0")
+	}
+}
+
+func TestCachingResolverRecordTTL(t *testing.T) {
+	fake := &fakeResolver{addrs: []string{"10.0.0.1"}}
+	// TTL записи важнее TTL настроек
+	r := NewCachingResolver(ResolverCacheConfig{Resolver: fakeTTLResolver{fake, time.Nanosecond}, TTL: time.Hour})
+	r.LookupHost(context.Background(), "search.test")
+	time.Sleep(time.Millisecond)
+	r.LookupHost(context.Background(), "search.test")
+	if n := atomic.LoadInt32(&fake.lookups); n != 2 {
+		t.Errorf("Error : %d lookups, want 2", n)
+	}
+}
+
+func TestWithResolver(t *testing.T) {
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// первый адрес не отвечает, клиент переходит ко второму
+	fake := &fakeResolver{addrs: []string{"127.0.0.2", "127.0.0.1"}}
+	if ln, err := net.Listen("tcp", "127.0.0.2:"+port); err == nil {
+		ln.Close()
+	} else {
+		fake.addrs = fake.addrs[1:]
+	}
+	client := NewSearchClient(accessToken, "http://search.test:"+port, WithResolver(NewCachingResolver(ResolverCacheConfig{Resolver: fake})))
+	for i := 0; i < 3; i++ {
+		if resp, err := client.FindUsers(model.SearchRequest{Limit: 5}); err != nil || len(resp.Users) != 5 {
+			t.Fatalf("Error : %v %v", resp, err)
+		}
+	}
+	if n := atomic.LoadInt32(&fake.lookups); n != 1 {
+		t.Errorf("Error : %d lookups, want 1", n)
+	}
+}
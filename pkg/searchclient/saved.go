@@ -0,0 +1,104 @@
+package searchclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"final_task_golang/pkg/model"
+)
+
+// SaveSearch сохраняет на сервере поиск name с параметрами GET / (например "query", "limit"),
+// у каждого токена свои сохранённые поиски
+func (srv *SearchClient) SaveSearch(name string, params map[string]string) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("cant pack params json: %s", err)
+	}
+	resp, data, err := srv.doSaved(context.Background(), http.MethodPut, "/saved/"+url.PathEscape(name), nil, body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return savedError(resp.StatusCode, data)
+	}
+	return nil
+}
+
+// RunSaved выполняет сохранённый поиск name, overrides переопределяют его параметры.
+// Нет такого поиска - model.ErrSavedSearchNotFound
+func (srv *SearchClient) RunSaved(name string, overrides map[string]string) (*model.SearchResponse, error) {
+	query := url.Values{}
+	for key, value := range overrides {
+		query.Set(key, value)
+	}
+	// сохранённый limit клиенту неизвестен, поэтому просим ответ с явным NextPage
+	header := http.Header{model.VersionHeader: {strconv.Itoa(model.WireV2)}}
+	resp, data, err := srv.doSaved(context.Background(), http.MethodGet, "/saved/"+url.PathEscape(name)+"/run?"+query.Encode(), header, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, savedError(resp.StatusCode, data)
+	}
+	engine := srv.json
+	if engine == nil {
+		engine = model.StdJSON
+	}
+	page, err := model.DecodeSearchJSONWith(engine, data)
+	if err != nil {
+		return nil, fmt.Errorf("cant unpack result json: %s", err)
+	}
+	return &model.SearchResponse{
+		Users:    srv.applyHooks(page.Users),
+		NextPage: page.NextPage,
+		Partial:  page.Partial || resp.Header.Get("X-Partial-Result") == "true",
+		Snapshot: resp.Header.Get(model.SnapshotHeader),
+	}, nil
+}
+
+// doSaved отправляет запрос к /saved и читает ответ целиком
+func (srv *SearchClient) doSaved(ctx context.Context, method, target string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		return nil, nil, unknownError(err)
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return nil, nil, unknownError(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base.ResolveReference(ref).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, unknownError(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Add("AccessToken", srv.AccessToken)
+
+	srv.metrics.add("requests")
+	resp, err := srv.httpClient(client).Do(req)
+	if err != nil {
+		return nil, nil, unknownError(err)
+	}
+	defer resp.Body.Close()
+	data, err := srv.readBody(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
+
+func savedError(status int, data []byte) error {
+	if status == http.StatusNotFound {
+		return model.ErrSavedSearchNotFound
+	}
+	if err := statusError(status, data, model.SearchRequest{}); err != nil {
+		return err
+	}
+	return fmt.Errorf("unexpected status %d", status)
+}
@@ -0,0 +1,34 @@
+package searchclient
+
+import (
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestRunSaved(t *testing.T) {
+	server, client := newTestServer(accessToken)
+	defer server.Close()
+
+	if err := client.SaveSearch("by-age", map[string]string{"order_field": "Age", "order_by": "1", "limit": "2"}); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	expected, err := client.FindUsers(model.SearchRequest{Limit: 2, OrderField: "Age", OrderBy: model.OrderByDesc})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	page, err := client.RunSaved("by-age", nil)
+	if err != nil || len(page.Users) != 2 || !page.NextPage || page.Users[1] != expected.Users[1] || page.Snapshot == "" {
+		t.Fatalf("Error : %+v %v", page, err)
+	}
+	if page, err := client.RunSaved("by-age", map[string]string{"limit": "1", "offset": "1"}); err != nil || len(page.Users) != 1 || page.Users[0] != expected.Users[1] {
+		t.Errorf("Error : overrides ignored, %+v %v", page, err)
+	}
+
+	if _, err := client.RunSaved("missing", nil); err != model.ErrSavedSearchNotFound {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+	if err := client.SaveSearch("bad", map[string]string{"order_field": "About", "order_by": "1"}); err == nil {
+		t.Errorf("Error : invalid search saved")
+	}
+}
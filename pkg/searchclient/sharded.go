@@ -0,0 +1,143 @@
+package searchclient
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"final_task_golang/pkg/model"
+)
+
+// размер страницы, которую отдаёт FindUsers любого клиента
+const maxPageSize = 25
+
+// ShardedClient - Searcher поверх нескольких серверов, каждый из которых держит свою часть датасета.
+// Запрос уходит во все шарды параллельно, выдачи сливаются и заново сортируются,
+// limit и offset применяются к общей выдаче.
+//
+// Каждый шард должен отдать offset+limit+1 записей, так что далёкие страницы стоят
+// нескольких запросов к каждому шарду. Сортировка по Name при слиянии побайтовая,
+// локаль (WithOrderLocale) у шардов ей не поможет. Без сортировки шарды идут по порядку
+// их перечисления, как если бы датасет был склеен из частей
+type ShardedClient struct {
+	shards []Searcher
+}
+
+var _ Searcher = (*ShardedClient)(nil)
+
+func NewShardedClient(shards ...Searcher) *ShardedClient {
+	return &ShardedClient{shards: shards}
+}
+
+func (c *ShardedClient) FindUsers(req model.SearchRequest) (*model.SearchResponse, error) {
+	if req.Limit < 0 {
+		return nil, fmt.Errorf("limit must be > 0")
+	}
+	if req.Limit > maxPageSize {
+		req.Limit = maxPageSize
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("offset must be > 0")
+	}
+	var less func(lhs, rhs model.User) bool
+	if req.OrderBy != model.OrderByAsIs {
+		var ok bool
+		if less, ok = userLess(req.OrderField); !ok {
+			return nil, fmt.Errorf("OrderFeld %s invalid", req.OrderField)
+		}
+	}
+
+	// +1 - чтобы узнать, есть ли следующая страница
+	need := req.Offset + req.Limit + 1
+	type shardResult struct {
+		users   []model.User
+		partial bool
+		err     error
+	}
+	results := make([]shardResult, len(c.shards))
+	var wg sync.WaitGroup
+	for i, shard := range c.shards {
+		wg.Add(1)
+		go func(i int, shard Searcher) {
+			defer wg.Done()
+			r := &results[i]
+			r.users, r.partial, r.err = fetchTop(shard, req, need)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var merged []model.User
+	result := &model.SearchResponse{}
+	for _, r := range results {
+		if r.err != nil {
+			return nil, r.err
+		}
+		merged = append(merged, r.users...)
+		result.Partial = result.Partial || r.partial
+	}
+	// stable: при равных ключах порядок шардов и порядок внутри шарда сохраняются
+	if less != nil {
+		sort.SliceStable(merged, func(i, j int) bool {
+			if req.OrderBy == model.OrderByDesc {
+				return less(merged[j], merged[i])
+			}
+			return less(merged[i], merged[j])
+		})
+	}
+
+	if len(merged) > req.Offset+req.Limit {
+		merged, result.NextPage = merged[:req.Offset+req.Limit], true
+	}
+	if len(merged) > req.Offset {
+		result.Users = merged[req.Offset:]
+	} else {
+		result.Users = []model.User{}
+	}
+	return result, nil
+}
+
+// fetchTop забирает у шарда первые need записей выдачи req постранично
+func fetchTop(shard Searcher, req model.SearchRequest, need int) ([]model.User, bool, error) {
+	var users []model.User
+	partial := false
+	page := req
+	page.Offset = 0
+	for len(users) < need {
+		page.Limit = need - len(users)
+		if page.Limit > maxPageSize {
+			page.Limit = maxPageSize
+		}
+		resp, err := shard.FindUsers(page)
+		if err != nil {
+			return nil, false, err
+		}
+		users = append(users, resp.Users...)
+		partial = partial || resp.Partial
+		if !resp.NextPage || len(resp.Users) == 0 {
+			break
+		}
+		page.Offset += len(resp.Users)
+	}
+	return users, partial, nil
+}
+
+// userLess - сравнение пользователей по полю сортировки, как на сервере
+func userLess(field string) (func(lhs, rhs model.User) bool, bool) {
+	switch field {
+	case "Id":
+		return func(lhs, rhs model.User) bool { return lhs.Id < rhs.Id }, true
+	case "Name", "":
+		return func(lhs, rhs model.User) bool { return lhs.Name < rhs.Name }, true
+	case "Age":
+		return func(lhs, rhs model.User) bool { return lhs.Age < rhs.Age }, true
+	case "Email":
+		return func(lhs, rhs model.User) bool { return lhs.Email < rhs.Email }, true
+	case "Phone":
+		return func(lhs, rhs model.User) bool { return lhs.Phone < rhs.Phone }, true
+	case "Company":
+		return func(lhs, rhs model.User) bool { return lhs.Company < rhs.Company }, true
+	case "Address":
+		return func(lhs, rhs model.User) bool { return lhs.Address < rhs.Address }, true
+	}
+	return nil, false
+}
@@ -0,0 +1,92 @@
+package searchclient
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchserver"
+)
+
+// датасет, разложенный по трём шардам, ищется так же, как целиком на одном сервере
+func TestShardedClientMatchesSingleServer(t *testing.T) {
+	users, _ := searchserver.LoadDataset("../../dataset.xml")
+	parts := make([][]model.User, 3)
+	for i, u := range users {
+		parts[i%3] = append(parts[i%3], u)
+	}
+	var shards []Searcher
+	for _, part := range parts {
+		server := httptest.NewServer(searchserver.NewServer(part, testServerConfig))
+		defer server.Close()
+		shards = append(shards, NewSearchClient(accessToken, server.URL))
+	}
+	sharded := NewShardedClient(shards...)
+
+	server := httptest.NewServer(searchserver.NewServer(users, testServerConfig))
+	defer server.Close()
+	single := NewSearchClient(accessToken, server.URL)
+
+	for _, field := range []string{"Id", "Name", "Age"} {
+		for _, orderBy := range []int{model.OrderByAsc, model.OrderByDesc} {
+			for _, query := range []string{"", "nisi", "Boyd"} {
+				for _, offset := range []int{0, 7, 30} {
+					req := model.SearchRequest{Limit: 10, Offset: offset, Query: query, OrderField: field, OrderBy: orderBy}
+					expected, err := single.FindUsers(req)
+					if err != nil {
+						t.Fatalf("Error : %v", err)
+					}
+					got, err := sharded.FindUsers(req)
+					if err != nil {
+						t.Fatalf("Error : %v", err)
+					}
+					if len(got.Users) != len(expected.Users) || got.NextPage != expected.NextPage {
+						t.Errorf("Error : %+v: %d users, next %v; want %d, next %v", req, len(got.Users), got.NextPage, len(expected.Users), expected.NextPage)
+						continue
+					}
+					for i := range got.Users {
+						// у равных возрастов порядок между шардами свой, сравниваем сам ключ
+						if field == "Age" && got.Users[i].Age != expected.Users[i].Age ||
+							field != "Age" && got.Users[i].Id != expected.Users[i].Id {
+							t.Errorf("Error : %+v: user %d is %v, want %v", req, i, got.Users[i], expected.Users[i])
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestShardedClientErrors(t *testing.T) {
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+	sharded := NewShardedClient(NewSearchClient(accessToken, server.URL), NewSearchClient("bad", server.URL))
+
+	if _, err := sharded.FindUsers(model.SearchRequest{OrderField: "Salary", OrderBy: model.OrderByAsc}); err == nil || err.Error() != "OrderFeld Salary invalid" {
+		t.Errorf("Error : %v", err)
+	}
+	if _, err := sharded.FindUsers(model.SearchRequest{Limit: 5}); err == nil || err.Error() != "Bad AccessToken" {
+		t.Errorf("Error : %v", err)
+	}
+	if _, err := sharded.FindUsers(model.SearchRequest{Limit: -1}); err == nil {
+		t.Errorf("Error : negative limit accepted")
+	}
+}
+
+// без сортировки шарды идут друг за другом
+func TestShardedClientAsIs(t *testing.T) {
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+	c := NewSearchClient(accessToken, server.URL)
+	sharded := NewShardedClient(c, c)
+
+	r, err := sharded.FindUsers(model.SearchRequest{Limit: 25, Offset: 30})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	// в датасете 35 записей: 5 хвостовых из первого шарда и 20 начальных из второго
+	if len(r.Users) != 25 || !r.NextPage || r.Users[0].Id != 30 || r.Users[5].Id != 0 {
+		t.Errorf("Error : unexpected response %d %v %v", len(r.Users), r.NextPage, r.Users[0])
+	}
+}
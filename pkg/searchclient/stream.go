@@ -0,0 +1,73 @@
+package searchclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"final_task_golang/pkg/model"
+)
+
+// UserStream читает потоковый (NDJSON) ответ сервера по одному пользователю,
+// не загружая тело ответа в память целиком
+type UserStream struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+	// хуки записей клиента, хуки страницы к потоку не применяются
+	hook func(model.User) model.User
+}
+
+// StreamUsers запрашивает у сервера поток пользователей (stream=true).
+// В отличие от FindUsers лимит не обрезается до страницы, Limit == 0 означает "все записи"
+func (srv *SearchClient) StreamUsers(req model.SearchRequest) (*UserStream, error) {
+	if req.Limit < 0 {
+		return nil, fmt.Errorf("limit must be > 0")
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("offset must be > 0")
+	}
+
+	query := encodeSearchQuery(req, srv.orderLocaleParam, true)
+	resp, err := srv.send(context.Background(), srv.httpClient(streamClient), query, "application/x-ndjson", "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		if err := statusError(resp.StatusCode, body, req); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var body io.Reader = resp.Body
+	if srv.verifyChecksum {
+		body = &checksumReader{resp: resp, hash: sha256.New()}
+	}
+	return &UserStream{body: resp.Body, dec: json.NewDecoder(body), hook: srv.applyUserHooks}, nil
+}
+
+// Next возвращает следующего пользователя, по окончании потока - io.EOF
+func (s *UserStream) Next() (model.User, error) {
+	u := model.User{}
+	if err := s.dec.Decode(&u); err != nil {
+		if err == io.EOF {
+			return u, io.EOF
+		}
+		if errors.Is(err, model.ErrChecksumMismatch) {
+			return u, err
+		}
+		return u, fmt.Errorf("cant unpack stream json: %s", err)
+	}
+	return s.hook(u), nil
+}
+
+func (s *UserStream) Close() error {
+	return s.body.Close()
+}
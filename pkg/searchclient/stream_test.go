@@ -0,0 +1,102 @@
+package searchclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func readStream(t *testing.T, s *UserStream) []model.User {
+	defer s.Close()
+	var users []model.User
+	for {
+		u, err := s.Next()
+		if err == io.EOF {
+			return users
+		}
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		users = append(users, u)
+	}
+}
+
+func TestStreamUsers(t *testing.T) {
+	server, client := newTestServer(accessToken)
+	defer server.Close()
+
+	s, err := client.StreamUsers(model.SearchRequest{})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+
+	if users := readStream(t, s); len(users) != 35 || users[0].Id != 0 || users[34].Id != 34 {
+		t.Errorf("Error : unexpected users %v", users)
+	}
+}
+
+func TestStreamUsersPaged(t *testing.T) {
+	server, client := newTestServer(accessToken)
+	defer server.Close()
+
+	s, err := client.StreamUsers(model.SearchRequest{Limit: 3, Offset: 2, OrderField: "Id", OrderBy: model.OrderByDesc})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+
+	users := readStream(t, s)
+	if len(users) != 3 || users[0].Id != 32 || users[2].Id != 30 {
+		t.Errorf("Error : unexpected users %v", users)
+	}
+}
+
+func TestStreamUsersAsIsPaged(t *testing.T) {
+	server, client := newTestServer(accessToken)
+	defer server.Close()
+
+	s, err := client.StreamUsers(model.SearchRequest{Limit: 2, Offset: 5})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+
+	if users := readStream(t, s); len(users) != 2 || users[0].Id != 5 || users[1].Id != 6 {
+		t.Errorf("Error : unexpected users %v", users)
+	}
+}
+
+func TestStreamUsersBadOrderField(t *testing.T) {
+	server, client := newTestServer(accessToken)
+	defer server.Close()
+
+	_, err := client.StreamUsers(model.SearchRequest{OrderBy: model.OrderByAsc, OrderField: "invalid"})
+
+	if err == nil || err.Error() != "OrderFeld invalid invalid" {
+		t.Errorf("Error : %v", err)
+	}
+}
+
+func TestStreamUsersBrokenLine(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"Id\": 1}\n{\"Id\": "))
+	}))
+	defer server.Close()
+	client := SearchClient{AccessToken: accessToken, URL: server.URL}
+
+	s, err := client.StreamUsers(model.SearchRequest{})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	defer s.Close()
+
+	if u, err := s.Next(); err != nil || u.Id != 1 {
+		t.Errorf("Error : %v %v", u, err)
+	}
+	if _, err := s.Next(); err == nil || !strings.HasPrefix(err.Error(), "cant unpack stream json") {
+		t.Errorf("Error : %v", err)
+	}
+}
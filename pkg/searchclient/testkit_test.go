@@ -0,0 +1,144 @@
+package searchclient
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchserver"
+)
+
+// scenario - обвязка для e2e-тестов: настоящий Server на временном датасете и настоящий
+// SearchClient, который ходит в него по http. Новые возможности протокола удобно
+// проверять как чёрный ящик:
+//
+//	sc := newScenario(t, ServerConfig{}, User{Id: 1, Name: "Boyd Wolf", Age: 22})
+//	sc.expectIDs(sc.find(SearchRequest{Limit: 5}), 1)
+type scenario struct {
+	t       *testing.T
+	dataset string
+	server  *searchserver.Server
+	http    *httptest.Server
+	client  *SearchClient
+}
+
+// newScenario поднимает сервер с токенами из testServerConfig, если в cfg их нет
+func newScenario(t *testing.T, cfg searchserver.ServerConfig, users ...model.User) *scenario {
+	t.Helper()
+	if cfg.Tokens == nil {
+		cfg.Tokens = testServerConfig.Tokens
+	}
+	sc := &scenario{t: t, dataset: filepath.Join(t.TempDir(), "dataset.xml")}
+	sc.writeDataset(users)
+	loaded, err := searchserver.LoadDataset(sc.dataset)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+
+	sc.server = searchserver.NewServer(loaded, cfg)
+	sc.http = httptest.NewServer(sc.server)
+	sc.client = NewSearchClient(accessToken, sc.http.URL)
+	t.Cleanup(func() {
+		sc.http.Close()
+		sc.server.Close()
+	})
+	return sc
+}
+
+// writeDataset пишет пользователей в формате dataset.xml, Name делится на имя и фамилию по первому пробелу
+func (sc *scenario) writeDataset(users []model.User) {
+	sc.t.Helper()
+	root := searchserver.XMLRoot{}
+	for _, u := range users {
+		first, last := u.Name, ""
+		if i := strings.Index(u.Name, " "); i >= 0 {
+			first, last = u.Name[:i], u.Name[i+1:]
+		}
+		root.Rows = append(root.Rows, searchserver.XMLRow{
+			Id:        u.Id,
+			FirstName: first,
+			LastName:  last,
+			Age:       u.Age,
+			About:     u.About,
+			Gender:    u.Gender,
+		})
+	}
+	data, err := xml.Marshal(root)
+	if err != nil {
+		sc.t.Fatalf("Error : %v", err)
+	}
+	if err := ioutil.WriteFile(sc.dataset, data, 0644); err != nil {
+		sc.t.Fatalf("Error : %v", err)
+	}
+}
+
+// seed заменяет датасет и перечитывает его с диска, как при перезагрузке в проде
+func (sc *scenario) seed(users ...model.User) {
+	sc.t.Helper()
+	sc.writeDataset(users)
+	loaded, err := searchserver.LoadDataset(sc.dataset)
+	if err != nil {
+		sc.t.Fatalf("Error : %v", err)
+	}
+	sc.server.Reload(loaded)
+}
+
+// find выполняет поиск клиентом, ошибка валит тест
+func (sc *scenario) find(req model.SearchRequest) *model.SearchResponse {
+	sc.t.Helper()
+	resp, err := sc.client.FindUsers(req)
+	if err != nil {
+		sc.t.Fatalf("Error : %+v: %v", req, err)
+	}
+	return resp
+}
+
+// findErr проверяет, что поиск завершился ошибкой с текстом msg
+func (sc *scenario) findErr(req model.SearchRequest, msg string) {
+	sc.t.Helper()
+	_, err := sc.client.FindUsers(req)
+	if err == nil || err.Error() != msg {
+		sc.t.Errorf("Error : %+v: expected %q, got %v", req, msg, err)
+	}
+}
+
+// do выполняет произвольный http-запрос к серверу с токеном администратора: правки пользователей
+// другим токенам запрещены
+func (sc *scenario) do(method, path, body string) *http.Response {
+	sc.t.Helper()
+	req, err := http.NewRequest(method, sc.http.URL+path, strings.NewReader(body))
+	if err != nil {
+		sc.t.Fatalf("Error : %v", err)
+	}
+	req.Header.Set("AccessToken", adminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		sc.t.Fatalf("Error : %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// expectIDs сверяет Id пользователей в ответе по порядку
+func (sc *scenario) expectIDs(resp *model.SearchResponse, ids ...int) {
+	sc.t.Helper()
+	got := make([]int, 0, len(resp.Users))
+	for _, u := range resp.Users {
+		got = append(got, u.Id)
+	}
+	if len(got) != len(ids) {
+		sc.t.Errorf("Error : expected ids %v, got %v", ids, got)
+		return
+	}
+	for i := range ids {
+		if got[i] != ids[i] {
+			sc.t.Errorf("Error : expected ids %v, got %v", ids, got)
+			return
+		}
+	}
+}
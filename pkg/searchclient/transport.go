@@ -0,0 +1,106 @@
+package searchclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// значения по умолчанию для транспорта: стандартные 2 простаивающих соединения на хост
+// при сотнях параллельных запросов означают постоянные переподключения
+const (
+	defaultMaxIdleConnsPerHost = 64
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// TransportConfig - настройки пула соединений, 0 - значение по умолчанию
+type TransportConfig struct {
+	// сколько простаивающих соединений держать к одному серверу
+	MaxIdleConnsPerHost int
+	// через сколько закрывать простаивающее соединение
+	IdleConnTimeout time.Duration
+	// прокси всех запросов: http://, https://, socks5:// или socks5h:// (имя сервера резолвит прокси),
+	// при необходимости с user:password@. Пусто - из HTTP_PROXY, HTTPS_PROXY и NO_PROXY,
+	// ProxyDirect - без прокси, даже если он задан в окружении
+	Proxy string
+	// как находить адреса серверов, nil - системный резолвер на каждое соединение, см. NewCachingResolver
+	Resolver Resolver
+	// таймаут соединения и выбор между IPv4 и IPv6
+	Dial DialConfig
+}
+
+// ProxyDirect - TransportConfig.Proxy, при котором переменные окружения прокси не действуют
+const ProxyDirect = "direct"
+
+// NewTransport возвращает транспорт с пулом соединений по cfg. HTTP/2 включается сам,
+// если сервер поддерживает его по TLS. Транспорт стоит создавать один раз на процесс
+// и раздавать клиентам: пул соединений живёт в нём
+func NewTransport(cfg TransportConfig) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ForceAttemptHTTP2 = true
+	t.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if t.MaxIdleConns > 0 && t.MaxIdleConns < t.MaxIdleConnsPerHost {
+		t.MaxIdleConns = t.MaxIdleConnsPerHost
+	}
+	t.IdleConnTimeout = defaultIdleConnTimeout
+	if cfg.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	t.Proxy = proxyFunc(cfg.Proxy)
+	if cfg.Resolver != nil || cfg.Dial != (DialConfig{}) {
+		t.DialContext = newDialer(cfg.Dial, cfg.Resolver).dial
+	}
+	return t
+}
+
+// proxyFunc - Transport.Proxy по TransportConfig.Proxy. Неправильный адрес прокси
+// возвращается ошибкой каждого запроса: идти в обход прокси нельзя
+func proxyFunc(proxy string) func(*http.Request) (*url.URL, error) {
+	switch proxy {
+	case "":
+		return http.ProxyFromEnvironment
+	case ProxyDirect:
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err == nil {
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+			if u.Host == "" {
+				err = fmt.Errorf("no host")
+			}
+		default:
+			err = fmt.Errorf("unsupported scheme %q", u.Scheme)
+		}
+	}
+	if err != nil {
+		err = errors.New(model.Redact(fmt.Sprintf("bad proxy %q: %v", proxy, err)))
+		return func(*http.Request) (*url.URL, error) { return nil, err }
+	}
+	return http.ProxyURL(u)
+}
+
+// WithProxy направляет запросы клиента, и потоковые тоже, через прокси proxy, см. TransportConfig.Proxy.
+// Транспорт, заданный раньше через WithTransport или WithConnectionPool, сохраняет свой пул, если это
+// *http.Transport; другой RoundTripper заменяется транспортом по умолчанию. Без WithProxy клиент
+// берёт прокси из HTTP_PROXY, HTTPS_PROXY и NO_PROXY
+func WithProxy(proxy string) ClientOption {
+	return func(c *SearchClient) {
+		t := c.ownTransport()
+		t.Proxy = proxyFunc(proxy)
+		c.transport = t
+	}
+}
+
+// WithConnectionPool даёт клиенту собственный транспорт с настройками пула cfg
+// вместо общего на пакет. Как и WithTransport, действует и на потоковые запросы
+func WithConnectionPool(cfg TransportConfig) ClientOption {
+	return WithTransport(NewTransport(cfg))
+}
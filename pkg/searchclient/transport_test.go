@@ -0,0 +1,213 @@
+package searchclient
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// последовательные и параллельные запросы идут по уже открытым соединениям
+func TestFindUsersReusesConnections(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(newTestHandler())
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := NewSearchClient(accessToken, server.URL, WithConnectionPool(TransportConfig{MaxIdleConnsPerHost: 8}))
+	for i := 0; i < 10; i++ {
+		if _, err := client.FindUsers(model.SearchRequest{Limit: 5}); err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("Error : %d connections for sequential requests", n)
+	}
+
+	for round := 0; round < 3; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client.FindUsers(model.SearchRequest{Limit: 5})
+			}()
+		}
+		wg.Wait()
+	}
+	// пул вмещает все параллельные соединения, повторные раунды новых не открывают
+	if n := atomic.LoadInt32(&conns); n > 8 {
+		t.Errorf("Error : %d connections for 8 parallel requests", n)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	tr := NewTransport(TransportConfig{})
+	if tr.MaxIdleConnsPerHost != defaultMaxIdleConnsPerHost || tr.IdleConnTimeout != defaultIdleConnTimeout || !tr.ForceAttemptHTTP2 {
+		t.Errorf("Error : unexpected defaults %v %v %v", tr.MaxIdleConnsPerHost, tr.IdleConnTimeout, tr.ForceAttemptHTTP2)
+	}
+	tr = NewTransport(TransportConfig{MaxIdleConnsPerHost: 500, IdleConnTimeout: time.Second})
+	if tr.MaxIdleConnsPerHost != 500 || tr.MaxIdleConns < 500 || tr.IdleConnTimeout != time.Second {
+		t.Errorf("Error : unexpected transport %v %v %v", tr.MaxIdleConnsPerHost, tr.MaxIdleConns, tr.IdleConnTimeout)
+	}
+}
+
+func TestFindUsersHTTP2(t *testing.T) {
+	var proto int32
+	handler := newTestHandler()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.StoreInt32(&proto, int32(r.ProtoMajor))
+		handler.ServeHTTP(w, r)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	tr := NewTransport(TransportConfig{})
+	tr.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	client := NewSearchClient(accessToken, server.URL, WithTransport(tr))
+	if _, err := client.FindUsers(model.SearchRequest{Limit: 5}); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if atomic.LoadInt32(&proto) != 2 {
+		t.Errorf("Error : HTTP/%d instead of HTTP/2", proto)
+	}
+}
+
+func TestWithProxyHTTP(t *testing.T) {
+	var proxied int32
+	handler := newTestHandler()
+	// http-прокси получает запрос с полным адресом и отвечает за сервер сам
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "search.invalid" {
+			atomic.AddInt32(&proxied, 1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	client := NewSearchClient(accessToken, "http://search.invalid/", WithProxy(proxy.URL))
+	if resp, err := client.FindUsers(model.SearchRequest{Limit: 5}); err != nil || len(resp.Users) != 5 {
+		t.Fatalf("Error : %v %v", resp, err)
+	}
+	if n := atomic.LoadInt32(&proxied); n != 1 {
+		t.Errorf("Error : %d requests through proxy", n)
+	}
+}
+
+func TestWithProxySOCKS5(t *testing.T) {
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+	socks, connects := startSOCKS5(t)
+
+	// пул из WithConnectionPool сохраняется, прокси добавляется к нему
+	client := NewSearchClient(accessToken, server.URL, WithConnectionPool(TransportConfig{MaxIdleConnsPerHost: 2}), WithProxy("socks5://"+socks))
+	for i := 0; i < 3; i++ {
+		if resp, err := client.FindUsers(model.SearchRequest{Limit: 5}); err != nil || len(resp.Users) != 5 {
+			t.Fatalf("Error : %v %v", resp, err)
+		}
+	}
+	if n := atomic.LoadInt32(connects); n != 1 {
+		t.Errorf("Error : %d connections through socks5, want 1", n)
+	}
+	if client.transport.(*http.Transport).MaxIdleConnsPerHost != 2 {
+		t.Errorf("Error : connection pool settings lost")
+	}
+}
+
+func TestWithProxyBad(t *testing.T) {
+	server := httptest.NewServer(newTestHandler())
+	defer server.Close()
+	for _, proxy := range []string{"ftp://proxy:21", "http://", "::"} {
+		client := NewSearchClient(accessToken, server.URL, WithProxy(proxy))
+		if _, err := client.FindUsers(model.SearchRequest{Limit: 5}); err == nil || !strings.Contains(err.Error(), "bad proxy") {
+			t.Errorf("Error : %q: %v", proxy, err)
+		}
+	}
+	client := NewSearchClient(accessToken, server.URL, WithProxy(ProxyDirect))
+	if _, err := client.FindUsers(model.SearchRequest{Limit: 5}); err != nil {
+		t.Errorf("Error : %v", err)
+	}
+}
+
+// startSOCKS5 запускает socks5-прокси без авторизации, который умеет только CONNECT
+func startSOCKS5(t *testing.T) (addr string, connects *int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	connects = new(int32)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn, connects)
+		}
+	}()
+	return ln.Addr().String(), connects
+}
+
+func serveSOCKS5(conn net.Conn, connects *int32) {
+	defer conn.Close()
+	buf := make([]byte, 262)
+	// приветствие: версия, число методов, методы; выбираем "без авторизации"
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil || buf[0] != 5 {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0})
+	// запрос: версия, команда, 0, тип адреса, адрес, порт
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil || buf[1] != 1 {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return
+		}
+		host = net.IP(buf[:4]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return
+		}
+		host = string(buf[:n])
+	default:
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(buf[0])<<8|int(buf[1]))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	atomic.AddInt32(connects, 1)
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
@@ -0,0 +1,86 @@
+package searchclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"final_task_golang/pkg/model"
+)
+
+// GetUser возвращает запись по Id вместе с её версией (User.Version)
+func (srv *SearchClient) GetUser(id int) (model.User, error) {
+	return srv.doUser(http.MethodGet, id, nil, "")
+}
+
+// UpdateUser целиком заменяет запись u.Id и возвращает её с новой версией. Если u.Version задана,
+// запись меняется, только пока её версия на сервере та же, иначе - model.ErrVersionMismatch:
+// запись успели изменить, её нужно перечитать через GetUser. Version 0 - заменить без проверки.
+// Править может только токен с правами admin, остальным - "access denied"
+func (srv *SearchClient) UpdateUser(u model.User) (model.User, error) {
+	body, err := json.Marshal(u)
+	if err != nil {
+		return model.User{}, err
+	}
+	ifMatch := ""
+	if u.Version > 0 {
+		ifMatch = `"` + strconv.Itoa(u.Version) + `"`
+	}
+	return srv.doUser(http.MethodPut, u.Id, body, ifMatch)
+}
+
+func (srv *SearchClient) doUser(method string, id int, body []byte, ifMatch string) (model.User, error) {
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		return model.User{}, unknownError(err)
+	}
+	target := base.ResolveReference(&url.URL{Path: "/users/" + strconv.Itoa(id)})
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return model.User{}, unknownError(err)
+	}
+	req.Header.Add("AccessToken", srv.AccessToken)
+	if ifMatch != "" {
+		req.Header.Add("If-Match", ifMatch)
+	}
+
+	resp, err := srv.httpClient(client).Do(req)
+	if err != nil {
+		return model.User{}, unknownError(err)
+	}
+	defer resp.Body.Close()
+	data, err := srv.readBody(resp)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return model.User{}, model.ErrUserNotFound
+	case http.StatusConflict:
+		return model.User{}, model.ErrVersionMismatch
+	case http.StatusForbidden:
+		return model.User{}, fmt.Errorf("access denied")
+	case http.StatusBadRequest:
+		errResp := model.SearchErrorResponse{}
+		if err := json.Unmarshal(data, &errResp); err != nil {
+			return model.User{}, fmt.Errorf("cant unpack error json: %s", err)
+		}
+		return model.User{}, fmt.Errorf("bad user: %s", errResp.Error)
+	default:
+		if err := statusError(resp.StatusCode, data, model.SearchRequest{}); err != nil {
+			return model.User{}, err
+		}
+		return model.User{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var u model.User
+	if err := json.Unmarshal(data, &u); err != nil {
+		return model.User{}, fmt.Errorf("cant unpack user json: %s", err)
+	}
+	return srv.applyUserHooks(u), nil
+}
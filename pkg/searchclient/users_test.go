@@ -0,0 +1,36 @@
+package searchclient
+
+import (
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestUpdateUserVersion(t *testing.T) {
+	server, client := newTestServer(adminToken)
+	defer server.Close()
+
+	u, err := client.GetUser(3)
+	if err != nil || u.Id != 3 || u.Version != 1 {
+		t.Fatalf("Error : %v %v", u, err)
+	}
+
+	u.Age = 77
+	updated, err := client.UpdateUser(u)
+	if err != nil || updated.Age != 77 || updated.Version != 2 {
+		t.Fatalf("Error : %v %v", updated, err)
+	}
+
+	// u.Version устарела после первого обновления
+	u.Age = 78
+	if _, err := client.UpdateUser(u); err != model.ErrVersionMismatch {
+		t.Errorf("Error : stale update accepted, %v", err)
+	}
+	if got, _ := client.GetUser(3); got != updated {
+		t.Errorf("Error : %v != %v", got, updated)
+	}
+
+	if _, err := client.GetUser(100500); err != model.ErrUserNotFound {
+		t.Errorf("Error : %v", err)
+	}
+}
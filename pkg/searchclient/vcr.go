@@ -0,0 +1,142 @@
+package searchclient
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"final_task_golang/pkg/model"
+)
+
+// VCRTransport записывает пары запрос/ответ в файлы (кассеты) и умеет отдавать их обратно
+// без сервера, так что тесты сервисов поверх SearchClient идут герметично:
+//
+//	rec := NewVCRRecorder("testdata/cassettes", nil)   // один раз против живого сервера
+//	play := NewVCRReplayer("testdata/cassettes")       // дальше в CI
+//	client := NewSearchClient(token, url, WithTransport(play))
+//
+// Кассета ищется по методу, url, Accept и телу запроса. Токен в кассету не пишется
+type VCRTransport struct {
+	dir    string
+	replay bool
+	// куда ходить в режиме записи
+	next http.RoundTripper
+}
+
+// NewVCRRecorder проксирует запросы в next (nil - http.DefaultTransport) и сохраняет ответы в dir
+func NewVCRRecorder(dir string, next http.RoundTripper) *VCRTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &VCRTransport{dir: dir, next: next}
+}
+
+// NewVCRReplayer отдаёт ответы из dir, запрос без кассеты завершается ошибкой
+func NewVCRReplayer(dir string) *VCRTransport {
+	return &VCRTransport{dir: dir, replay: true}
+}
+
+type cassette struct {
+	Method string
+	URL    string
+	Accept string `json:",omitempty"`
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// path - файл кассеты. Тело входит в ключ хэшем: у POST /msearch и /users/bulk разные
+// запросы отличаются только им. Запросы без тела сохраняют прежние имена кассет
+func (v *VCRTransport) path(r *http.Request, body []byte) string {
+	key := r.Method + " " + r.URL.String() + " " + r.Header.Get("Accept")
+	if len(body) > 0 {
+		bodySum := sha1.Sum(body)
+		key += " " + hex.EncodeToString(bodySum[:])
+	}
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(v.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (v *VCRTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// тело уже прочитано, дальше уходит копия запроса с тем же телом
+		r = r.Clone(r.Context())
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	path := v.path(r, body)
+	if v.replay {
+		return v.play(r, path)
+	}
+	return v.record(r, path)
+}
+
+func (v *VCRTransport) play(r *http.Request, path string) (*http.Response, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("vcr: no recording for %s %s", r.Method, model.Redact(r.URL.String()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := cassette{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("vcr: broken cassette %s: %s", path, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.Status, http.StatusText(c.Status)),
+		StatusCode:    c.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       r,
+	}, nil
+}
+
+func (v *VCRTransport) record(r *http.Request, path string) (*http.Response, error) {
+	resp, err := v.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	data, err := json.MarshalIndent(cassette{
+		Method: r.Method,
+		// кассеты коммитят в репозиторий, а сопоставляются они по хэшу, так что секреты из адреса не нужны
+		URL:    model.Redact(r.URL.String()),
+		Accept: r.Header.Get("Accept"),
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   body,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(v.dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
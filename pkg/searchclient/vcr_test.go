@@ -0,0 +1,143 @@
+package searchclient
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestVCRRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	ts, _ := newTestServer(accessToken)
+
+	recorder := NewSearchClient(accessToken, ts.URL, WithTransport(NewVCRRecorder(dir, nil)))
+	requests := []model.SearchRequest{
+		{Limit: 5, Query: "Boyd"},
+		{Limit: 3, OrderField: "Age", OrderBy: model.OrderByDesc},
+		{OrderField: "bad", OrderBy: model.OrderByAsc},
+	}
+	var recorded []*model.SearchResponse
+	var recordedErr []error
+	for _, req := range requests {
+		r, err := recorder.FindUsers(req)
+		recorded = append(recorded, r)
+		recordedErr = append(recordedErr, err)
+	}
+	// дальше сервер не нужен
+	ts.Close()
+
+	player := NewSearchClient(accessToken, ts.URL, WithTransport(NewVCRReplayer(dir)))
+	for i, req := range requests {
+		r, err := player.FindUsers(req)
+		if (err == nil) != (recordedErr[i] == nil) || err != nil && err.Error() != recordedErr[i].Error() {
+			t.Errorf("Error : %v != %v", err, recordedErr[i])
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(r.Users) != len(recorded[i].Users) || r.NextPage != recorded[i].NextPage {
+			t.Errorf("Error : %v != %v", r, recorded[i])
+			continue
+		}
+		for j := range r.Users {
+			if r.Users[j] != recorded[i].Users[j] {
+				t.Errorf("Error : %v != %v", r.Users[j], recorded[i].Users[j])
+			}
+		}
+	}
+}
+
+func TestVCRRequestBody(t *testing.T) {
+	dir := t.TempDir()
+	ts, _ := newTestServer(accessToken)
+
+	// оба запроса - POST /msearch на один адрес, отличаются только телом
+	first := []model.SearchRequest{{Limit: 1, Query: "Boyd"}}
+	second := []model.SearchRequest{{Limit: 2, OrderField: "Age", OrderBy: model.OrderByDesc}}
+	recorder := NewSearchClient(accessToken, ts.URL, WithTransport(NewVCRRecorder(dir, nil)))
+	if _, err := recorder.MultiSearch(first); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	expected, err := recorder.MultiSearch(second)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	ts.Close()
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 2 {
+		t.Errorf("Error : %d cassettes, want 2", len(files))
+	}
+
+	player := NewSearchClient(accessToken, ts.URL, WithTransport(NewVCRReplayer(dir)))
+	r, err := player.MultiSearch(first)
+	if err != nil || len(r) != 1 || r[0].Err != nil || len(r[0].Response.Users) != 1 || r[0].Response.Users[0].Id != 0 {
+		t.Errorf("Error : unexpected replay %v %v", r, err)
+	}
+	r, err = player.MultiSearch(second)
+	if err != nil || len(r) != 1 || r[0].Err != nil || len(r[0].Response.Users) != 2 || r[0].Response.Users[0] != expected[0].Response.Users[0] {
+		t.Errorf("Error : unexpected replay %v %v", r, err)
+	}
+	if _, err := player.MultiSearch([]model.SearchRequest{{Limit: 3}}); err == nil || !strings.Contains(err.Error(), "vcr: no recording") {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+}
+
+func TestVCRReplayMissing(t *testing.T) {
+	player := NewSearchClient(accessToken, "http://127.0.0.1:1", WithTransport(NewVCRReplayer(t.TempDir())))
+	_, err := player.FindUsers(model.SearchRequest{Limit: 1})
+	if err == nil || !strings.Contains(err.Error(), "vcr: no recording") {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+}
+
+func TestVCRStream(t *testing.T) {
+	dir := t.TempDir()
+	ts, _ := newTestServer(accessToken)
+	recorder := NewSearchClient(accessToken, ts.URL, WithTransport(NewVCRRecorder(dir, nil)))
+	stream, err := recorder.StreamUsers(model.SearchRequest{Limit: 3})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	stream.Close()
+	ts.Close()
+
+	player := NewSearchClient(accessToken, ts.URL, WithTransport(NewVCRReplayer(dir)))
+	stream, err = player.StreamUsers(model.SearchRequest{Limit: 3})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	defer stream.Close()
+	n := 0
+	for {
+		if _, err := stream.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("Error : unexpected count %d", n)
+	}
+}
+
+// токен не должен попадать в кассеты, они коммитятся в репозиторий
+func TestVCRDoesNotRecordToken(t *testing.T) {
+	dir := t.TempDir()
+	ts, _ := newTestServer(accessToken)
+	defer ts.Close()
+	NewSearchClient(accessToken, ts.URL, WithTransport(NewVCRRecorder(dir, nil))).FindUsers(model.SearchRequest{Limit: 1})
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Error : unexpected cassettes %v", files)
+	}
+	data, _ := ioutil.ReadFile(files[0])
+	if strings.Contains(string(data), accessToken) {
+		t.Errorf("Error : token recorded")
+	}
+}
@@ -0,0 +1,75 @@
+package searchclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"final_task_golang/pkg/model"
+)
+
+// WarmupConfig - что делает Warmup
+type WarmupConfig struct {
+	// сколько соединений открыть заранее, 0 - одно. Больше MaxIdleConnsPerHost транспорта
+	// (см. TransportConfig) открывать бессмысленно: лишние закроются сразу после прогрева
+	Connections int
+	// поиски, страницы которых положить в кэш (WithCache). Без кэша они просто прогревают сервер
+	Queries []model.SearchRequest
+}
+
+// WithWarmup задаёт, что делает Warmup
+func WithWarmup(cfg WarmupConfig) ClientOption {
+	return func(c *SearchClient) {
+		c.warmup = cfg
+	}
+}
+
+// Warmup заранее открывает соединения с сервером (TCP и TLS) и выполняет поиски из WarmupConfig,
+// чтобы первый настоящий запрос не платил за них. Соединения открываются параллельно лёгкими
+// запросами HEAD. Возвращает первую ошибку, но доделывает остальное
+func (srv *SearchClient) Warmup(ctx context.Context) error {
+	connections := intOr(srv.warmup.Connections, 1)
+	errs := make(chan error, connections+len(srv.warmup.Queries))
+	var wg sync.WaitGroup
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- srv.warmConnection(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, req := range srv.warmup.Queries {
+		_, err := srv.FindUsersContext(ctx, req)
+		errs <- err
+	}
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warmConnection - HEAD поиска с limit=1: ответ без тела, соединение остаётся в пуле транспорта
+func (srv *SearchClient) warmConnection(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, srv.URL, nil)
+	if err != nil {
+		return unknownError(err)
+	}
+	req.URL.RawQuery = "limit=1"
+	req.Header.Add("AccessToken", srv.AccessToken)
+	resp, err := srv.httpClient(client).Do(req)
+	if err != nil {
+		return fmt.Errorf("warmup: %s", model.Redact(err.Error()))
+	}
+	resp.Body.Close()
+	// прочие статусы соединению не мешают, а неверный токен помешает и настоящим запросам
+	if resp.StatusCode == http.StatusUnauthorized {
+		return statusError(resp.StatusCode, nil, model.SearchRequest{})
+	}
+	return nil
+}
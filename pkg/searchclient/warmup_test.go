@@ -0,0 +1,66 @@
+package searchclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestWarmup(t *testing.T) {
+	var conns, searches int32
+	handler := newTestHandler()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&searches, 1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	query := model.SearchRequest{Limit: 5, Query: "nisi"}
+	client := NewSearchClient(accessToken, server.URL,
+		WithConnectionPool(TransportConfig{MaxIdleConnsPerHost: 4}),
+		WithCache(CacheConfig{}),
+		WithWarmup(WarmupConfig{Connections: 4, Queries: []model.SearchRequest{query}}))
+	if err := client.Warmup(context.Background()); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	opened := atomic.LoadInt32(&conns)
+	if opened < 2 {
+		t.Errorf("Error : %d connections opened", opened)
+	}
+
+	// прогретый поиск приходит из кэша, остальные идут по открытым соединениям
+	if _, err := client.FindUsers(query); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		client.FindUsers(model.SearchRequest{Limit: 5, Offset: i})
+	}
+	if n := atomic.LoadInt32(&searches); n != 4 {
+		t.Errorf("Error : %d searches, want 4", n)
+	}
+	if n := atomic.LoadInt32(&conns); n != opened {
+		t.Errorf("Error : %d new connections after warmup", n-opened)
+	}
+}
+
+func TestWarmupBadToken(t *testing.T) {
+	server, _ := newTestServer(accessToken)
+	defer server.Close()
+	client := NewSearchClient("bad token", server.URL)
+	if err := client.Warmup(context.Background()); err == nil {
+		t.Errorf("Error : no error for bad token")
+	}
+}
@@ -0,0 +1,278 @@
+package searchclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// как часто ChangesWatcher спрашивает правки, если не задано
+const defaultWatchInterval = 5 * time.Second
+
+// ChangesWatcher держит локальное зеркало пользователей сервера: сначала выгружает весь
+// датасет через GET /changes, потом периодически забирает правки с последней версии.
+// Если сервер эту версию уже не помнит, зеркало выгружается заново. Чтение из зеркала
+// не ходит в сеть и безопасно из любых горутин
+type ChangesWatcher struct {
+	srv      *SearchClient
+	interval time.Duration
+
+	mu       sync.RWMutex
+	users    map[int]model.User
+	snapshot string
+	// ошибка последней синхронизации, nil - удалась
+	err error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// WatchChanges выгружает датасет в зеркало и начинает раз в interval (0 - defaultWatchInterval)
+// забирать правки. Ошибка первой выгрузки возвращается сразу, последующих - через Err.
+// Остановить - Close
+func (srv *SearchClient) WatchChanges(interval time.Duration) (*ChangesWatcher, error) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	w := &ChangesWatcher{
+		srv:      srv,
+		interval: interval,
+		users:    map[int]model.User{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := w.Sync(context.Background()); err != nil {
+		return nil, err
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *ChangesWatcher) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.Sync(context.Background())
+		}
+	}
+}
+
+// Close останавливает синхронизацию, зеркало остаётся доступным для чтения
+func (w *ChangesWatcher) Close() {
+	close(w.stop)
+	<-w.done
+}
+
+// Sync сразу забирает правки с последней версии зеркала, не дожидаясь таймера
+func (w *ChangesWatcher) Sync(ctx context.Context) error {
+	w.mu.RLock()
+	since := w.snapshot
+	w.mu.RUnlock()
+
+	changes, err := w.srv.fetchChanges(ctx, since)
+	if err == model.ErrChangesUnavailable && since != "" {
+		since = ""
+		changes, err = w.srv.fetchChanges(ctx, since)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+	if err != nil {
+		return err
+	}
+	if since == "" {
+		w.users = make(map[int]model.User, len(changes.Added))
+	} else if since != w.snapshot {
+		// пока шёл запрос, зеркало обновили параллельным Sync
+		return nil
+	}
+	for _, list := range [][]model.User{changes.Added, changes.Updated} {
+		for _, u := range list {
+			w.users[u.Id] = w.srv.applyUserHooks(u)
+		}
+	}
+	for _, id := range changes.Removed {
+		delete(w.users, id)
+	}
+	w.snapshot = changes.Snapshot
+	return nil
+}
+
+// Get возвращает пользователя из зеркала
+func (w *ChangesWatcher) Get(id int) (model.User, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	u, ok := w.users[id]
+	return u, ok
+}
+
+// Users возвращает копию зеркала в порядке Id
+func (w *ChangesWatcher) Users() []model.User {
+	w.mu.RLock()
+	users := make([]model.User, 0, len(w.users))
+	for _, u := range w.users {
+		users = append(users, u)
+	}
+	w.mu.RUnlock()
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+	return users
+}
+
+// Len - сколько пользователей в зеркале
+func (w *ChangesWatcher) Len() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.users)
+}
+
+// Snapshot - версия датасета на сервере, до которой зеркало синхронизировано
+func (w *ChangesWatcher) Snapshot() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.snapshot
+}
+
+// Err возвращает ошибку последней синхронизации, зеркало при этом остаётся прежним
+func (w *ChangesWatcher) Err() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.err
+}
+
+// во сколько раз пауза WatchQuery может вырасти после ошибок подряд
+const maxWatchBackoff = 32
+
+// WatchQuery опрашивает поиск req раз в interval (0 - defaultWatchInterval) и вызывает onChange,
+// только когда страница изменилась, первый раз - с первой страницей. Запросы условные (If-None-Match):
+// пока датасет не менялся, сервер отвечает 304 без поиска. Правка, которая страницу не задела,
+// onChange не вызывает. После ошибки пауза удваивается, но не больше maxWatchBackoff интервалов.
+// Ошибка первого запроса возвращается сразу, иначе WatchQuery работает до отмены ctx и возвращает ctx.Err()
+func (srv *SearchClient) WatchQuery(ctx context.Context, req model.SearchRequest, interval time.Duration, onChange func(*model.SearchResponse)) error {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	req, err := checkRequest(req)
+	if err != nil {
+		return err
+	}
+	req.Limit++
+	query := encodeSearchQuery(req, srv.orderLocaleParam, false)
+
+	var last *model.SearchResponse
+	etag := ""
+	poll := func() error {
+		page, pageETag, err := srv.fetchPage(ctx, req, query, etag)
+		if err == errNotModified {
+			srv.metrics.add("watch_not_modified")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// неполная страница может отличаться от прошлой только из-за дедлайна, и ETag её не запоминаем:
+		// иначе полную страницу этой версии сервер уже не пришлёт
+		if page.Partial {
+			return nil
+		}
+		etag = pageETag
+		if last != nil && samePage(last, page) {
+			return nil
+		}
+		last = page
+		// хуки меняют записи на месте, а last нужен для сравнения как пришёл
+		changed := *page
+		changed.Users = srv.applyHooks(append([]model.User{}, page.Users...))
+		onChange(&changed)
+		return nil
+	}
+	if err := poll(); err != nil {
+		return err
+	}
+
+	delay := interval
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if err := poll(); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if delay < interval*maxWatchBackoff {
+				delay *= 2
+			}
+		} else {
+			delay = interval
+		}
+		timer.Reset(delay)
+	}
+}
+
+func samePage(a, b *model.SearchResponse) bool {
+	if a.NextPage != b.NextPage || len(a.Users) != len(b.Users) {
+		return false
+	}
+	for i := range a.Users {
+		if a.Users[i] != b.Users[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchChanges - GET /changes?since=, без since - весь датасет
+func (srv *SearchClient) fetchChanges(ctx context.Context, since string) (model.ChangesResponse, error) {
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		return model.ChangesResponse{}, unknownError(err)
+	}
+	target := base.ResolveReference(&url.URL{Path: "/changes", RawQuery: url.Values{"since": {since}}.Encode()})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return model.ChangesResponse{}, unknownError(err)
+	}
+	req.Header.Add("AccessToken", srv.AccessToken)
+
+	resp, err := srv.httpClient(client).Do(req)
+	if err != nil {
+		return model.ChangesResponse{}, unknownError(err)
+	}
+	defer resp.Body.Close()
+	data, err := srv.readBody(resp)
+	if err != nil {
+		return model.ChangesResponse{}, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusGone:
+		return model.ChangesResponse{}, model.ErrChangesUnavailable
+	default:
+		if err := statusError(resp.StatusCode, data, model.SearchRequest{}); err != nil {
+			return model.ChangesResponse{}, err
+		}
+		return model.ChangesResponse{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	changes := model.ChangesResponse{}
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return model.ChangesResponse{}, fmt.Errorf("cant unpack changes json: %s", err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,134 @@
+package searchclient
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchserver"
+)
+
+func TestChangesWatcher(t *testing.T) {
+	users, _ := searchserver.LoadDataset("../../dataset.xml")
+	cfg := testServerConfig
+	cfg.History = 1
+	server := httptest.NewServer(searchserver.NewServer(users, cfg))
+	defer server.Close()
+	client := SearchClient{AccessToken: adminToken, URL: server.URL}
+
+	w, err := client.WatchChanges(time.Hour)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	defer w.Close()
+	if w.Len() != len(users) || w.Snapshot() == "" {
+		t.Fatalf("Error : unexpected mirror, %d users, snapshot %q", w.Len(), w.Snapshot())
+	}
+
+	u, _ := w.Get(3)
+	u.Age = 77
+	if _, err := client.UpdateUser(u); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if err := w.Sync(context.Background()); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if got, ok := w.Get(3); !ok || got.Age != 77 {
+		t.Errorf("Error : update not mirrored, %v", got)
+	}
+
+	// две правки подряд вытесняют версию зеркала, и оно выгружается заново
+	for _, age := range []int{78, 79} {
+		u, _ = client.GetUser(4)
+		u.Age = age
+		client.UpdateUser(u)
+	}
+	if err := w.Sync(context.Background()); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if got, _ := w.Get(4); got.Age != 79 || w.Len() != len(users) {
+		t.Errorf("Error : mirror not reloaded, %v, %d users", got, w.Len())
+	}
+	if list := w.Users(); len(list) != len(users) || list[0].Id > list[1].Id {
+		t.Errorf("Error : unexpected users %v", list[:2])
+	}
+}
+
+func TestWatchQuery(t *testing.T) {
+	var failures int64
+	handler := newTestHandler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" && atomic.LoadInt64(&failures) > 0 {
+			atomic.AddInt64(&failures, -1)
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	admin := NewSearchClient(adminToken, server.URL)
+	client := NewSearchClient(accessToken, server.URL, WithExpvar("watch_query_test"))
+
+	changes := make(chan *model.SearchResponse, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	req := model.SearchRequest{Limit: 3, OrderField: "Id", OrderBy: model.OrderByAsc}
+	go func() {
+		done <- client.WatchQuery(ctx, req, 5*time.Millisecond, func(resp *model.SearchResponse) { changes <- resp })
+	}()
+	first := <-changes
+	if len(first.Users) != 3 || !first.NextPage {
+		t.Fatalf("Error : unexpected first page %v", first)
+	}
+
+	// правка за пределами страницы меняет версию датасета, но не страницу
+	u, _ := admin.GetUser(20)
+	u.Age++
+	if _, err := admin.UpdateUser(u); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	// ошибки поиска не прерывают опрос
+	atomic.StoreInt64(&failures, 2)
+	u, _ = admin.GetUser(1)
+	u.Age = 99
+	if _, err := admin.UpdateUser(u); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	select {
+	case resp := <-changes:
+		if resp.Users[1].Age != 99 {
+			t.Errorf("Error : unexpected page %v", resp.Users)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Error : change not reported")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Error : %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Error : unchanged page reported")
+	}
+	if n := expvar.Get("watch_query_test").(*expvar.Map).Get("watch_not_modified"); n == nil {
+		t.Errorf("Error : no conditional polls")
+	}
+}
+
+func TestWatchQueryFirstError(t *testing.T) {
+	server, _ := newTestServer(accessToken)
+	defer server.Close()
+	client := NewSearchClient("bad token", server.URL)
+	err := client.WatchQuery(context.Background(), model.SearchRequest{Limit: 3}, time.Millisecond, func(*model.SearchResponse) {
+		t.Errorf("Error : callback on error")
+	})
+	if err == nil {
+		t.Errorf("Error : no error for bad token")
+	}
+}
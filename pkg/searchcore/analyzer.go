@@ -0,0 +1,157 @@
+package searchcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// токенизаторы AnalyzerConfig
+const (
+	// TokenizerLetters - слова из букв и цифр, остальное - разделители
+	TokenizerLetters = "letters"
+	// TokenizerWhitespace - слова между пробельными символами, пунктуация остаётся в словах
+	TokenizerWhitespace = "whitespace"
+)
+
+// фильтры слов AnalyzerConfig
+const (
+	FilterLowercase = "lowercase"
+	// FilterASCIIFold убирает диакритику: "café" - "cafe"
+	FilterASCIIFold = "asciifold"
+	// FilterStopwords выкидывает AnalyzerConfig.Stopwords, без них - английские стоп-слова
+	FilterStopwords = "stopwords"
+	// FilterStem приводит слово к основе стеммером Портера для английского
+	FilterStem = "stem"
+	// FilterSynonyms - термины query расширяются синонимами Config.Synonyms, только для About
+	FilterSynonyms = "synonyms"
+)
+
+// поля, для которых настраивается анализ текста
+const (
+	FieldAbout = "About"
+	FieldName  = "Name"
+)
+
+// AnalyzerConfig - цепочка анализа текста поля: токенизатор режет текст на слова, затем
+// фильтры по порядку меняют или выкидывают каждое слово, например
+// {"Tokenizer": "letters", "Filters": ["lowercase", "asciifold", "stopwords", "stem", "synonyms"]}.
+// Термины query получаются той же цепочкой, что и термины поля
+type AnalyzerConfig struct {
+	// TokenizerLetters (по умолчанию) или TokenizerWhitespace
+	Tokenizer string
+	Filters   []string
+	// стоп-слова фильтра stopwords, nil - английские
+	Stopwords []string
+}
+
+// Validate проверяет токенизатор и фильтры цепочки поля field
+func (c AnalyzerConfig) Validate(field string) error {
+	if field != FieldAbout && field != FieldName {
+		return fmt.Errorf("analyzer for unknown field %q", field)
+	}
+	switch c.Tokenizer {
+	case "", TokenizerLetters, TokenizerWhitespace:
+	default:
+		return fmt.Errorf("%s: unknown tokenizer %q", field, c.Tokenizer)
+	}
+	for _, f := range c.Filters {
+		switch f {
+		case FilterLowercase, FilterASCIIFold, FilterStopwords, FilterStem:
+		case FilterSynonyms:
+			if field != FieldAbout {
+				return fmt.Errorf("%s: synonyms are supported only for %s", field, FieldAbout)
+			}
+		default:
+			return fmt.Errorf("%s: unknown filter %q", field, f)
+		}
+	}
+	return nil
+}
+
+// LoadAnalyzers читает цепочки анализа по полям из json-файла {"About": {...}, "Name": {...}}
+func LoadAnalyzers(path string) (map[string]AnalyzerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	analyzers := map[string]AnalyzerConfig{}
+	if err := json.Unmarshal(data, &analyzers); err != nil {
+		return nil, err
+	}
+	for field, c := range analyzers {
+		if err := c.Validate(field); err != nil {
+			return nil, err
+		}
+	}
+	return analyzers, nil
+}
+
+// wordFilter - звено цепочки анализа: слово для следующего звена, false - слово выкидывается
+type wordFilter func(word string) (string, bool)
+
+func lowercaseFilter(word string) (string, bool) {
+	return strings.ToLower(word), true
+}
+
+func stemFilter(word string) (string, bool) {
+	return porterStem(word), true
+}
+
+func stopwordsFilter(words []string) wordFilter {
+	stop := map[string]bool{}
+	for _, w := range words {
+		stop[strings.ToLower(strings.TrimSpace(w))] = true
+	}
+	return func(word string) (string, bool) {
+		return word, !stop[word]
+	}
+}
+
+func asciiFoldFilter(word string) (string, bool) {
+	// transform.Chain не потокобезопасен, поэтому цепочка своя на каждое слово
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), word)
+	if err != nil {
+		return word, true
+	}
+	return folded, true
+}
+
+func letterTokens(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// newChainAnalyzer собирает анализатор по проверенной Validate цепочке
+func newChainAnalyzer(c AnalyzerConfig) *textAnalyzer {
+	a := &textAnalyzer{tokenize: letterTokens}
+	if c.Tokenizer == TokenizerWhitespace {
+		a.tokenize = strings.Fields
+	}
+	for _, name := range c.Filters {
+		switch name {
+		case FilterLowercase:
+			a.filters = append(a.filters, lowercaseFilter)
+		case FilterASCIIFold:
+			a.filters = append(a.filters, asciiFoldFilter)
+		case FilterStopwords:
+			stopwords := c.Stopwords
+			if stopwords == nil {
+				stopwords = englishStopwords
+			}
+			a.filters = append(a.filters, stopwordsFilter(stopwords))
+		case FilterStem:
+			a.filters = append(a.filters, stemFilter)
+		case FilterSynonyms:
+			a.synonyms = true
+		}
+	}
+	return a
+}
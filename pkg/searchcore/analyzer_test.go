@@ -0,0 +1,84 @@
+package searchcore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestAnalyzerChain(t *testing.T) {
+	a := newChainAnalyzer(AnalyzerConfig{Tokenizer: TokenizerWhitespace, Filters: []string{FilterLowercase, FilterASCIIFold, FilterStopwords, FilterStem}})
+	// слова с дефисом стеммер Портера не трогает
+	if terms := a.terms("The Café-owners serve crêpes"); !equalStrings(terms, []string{"cafe-owners", "serv", "crepe"}) {
+		t.Errorf("Error : unexpected terms %v", terms)
+	}
+	// без lowercase регистр сохраняется, стоп-слова сравниваются как есть
+	a = newChainAnalyzer(AnalyzerConfig{Filters: []string{FilterStopwords}, Stopwords: []string{"the"}})
+	if terms := a.terms("The the, end"); !equalStrings(terms, []string{"The", "end"}) {
+		t.Errorf("Error : unexpected terms %v", terms)
+	}
+
+	for _, bad := range []TextSearchConfig{
+		{Analyzers: map[string]AnalyzerConfig{"Email": {}}},
+		{Analyzers: map[string]AnalyzerConfig{FieldAbout: {Tokenizer: "ngram"}}},
+		{Analyzers: map[string]AnalyzerConfig{FieldAbout: {Filters: []string{"upper"}}}},
+		{Analyzers: map[string]AnalyzerConfig{FieldName: {Filters: []string{FilterSynonyms}}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Error : %+v accepted", bad)
+		}
+	}
+}
+
+func TestFieldAnalyzers(t *testing.T) {
+	users := []model.User{
+		{Id: 1, Name: "José Álvarez", About: "Backend developers"},
+		{Id: 2, Name: "Joseph", About: "dev team"},
+		{Id: 3, Name: "Ann", About: "Senior developer"},
+	}
+	cfg := TextSearchConfig{Analyzers: map[string]AnalyzerConfig{
+		FieldAbout: {Filters: []string{FilterLowercase, FilterStem}},
+		FieldName:  {Filters: []string{FilterLowercase, FilterASCIIFold}},
+	}}
+	e := newTestEngine(users, Config{TextSearch: cfg, Synonyms: &Synonyms{Sets: [][]string{{"dev", "developer"}}}})
+	defer e.Close()
+	cases := []struct {
+		query    string
+		expected []int
+	}{
+		// Name по словам без диакритики, а не подстрокой
+		{"jose", []int{1}},
+		{`"jose alvarez"`, []int{1}},
+		{"Jose", []int{1}},
+		// About по основам, без фильтра synonyms синонимы не подставляются
+		{"developer", []int{1, 3}},
+		{"dev", []int{2}},
+	}
+	for _, c := range cases {
+		found, err := e.Search(context.Background(), Query{Query: c.query})
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		var ids []int
+		for _, u := range found.Users {
+			ids = append(ids, u.Id)
+		}
+		if !equalInts(ids, c.expected) {
+			t.Errorf("Error : query %q found %v, want %v", c.query, ids, c.expected)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "analyzers.json")
+	os.WriteFile(path, []byte(`{"About": {"Tokenizer": "whitespace", "Filters": ["lowercase", "synonyms"]}}`), 0o644)
+	analyzers, err := LoadAnalyzers(path)
+	if err != nil || analyzers[FieldAbout].Tokenizer != TokenizerWhitespace || len(analyzers[FieldAbout].Filters) != 2 {
+		t.Errorf("Error : %+v %v", analyzers, err)
+	}
+	os.WriteFile(path, []byte(`{"About": {"Filters": ["soundex"]}}`), 0o644)
+	if _, err := LoadAnalyzers(path); err == nil {
+		t.Errorf("Error : unknown filter loaded")
+	}
+}
@@ -0,0 +1,66 @@
+package searchcore
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+
+	"final_task_golang/pkg/model"
+)
+
+// collatedIndexes - индексы сортировки по Name с учётом правил локали.
+// Строятся лениво при первом запросе с локалью и живут, пока не поменяется датасет
+type collatedIndexes struct {
+	mu sync.Mutex
+	// датасет, по которому построены индексы: после SetUsers это уже другой срез
+	users   []model.User
+	indexes map[SortKey][]int
+}
+
+// parseOrderLocale проверяет локаль сортировки и приводит её к каноническому виду
+func parseOrderLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", model.ErrBadOrderLocale
+	}
+	return tag.String(), nil
+}
+
+// get возвращает индекс для key.Locale по users, при необходимости строит его
+func (c *collatedIndexes) get(users []model.User, key SortKey) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !sameSlice(c.users, users) {
+		c.users, c.indexes = users, map[SortKey][]int{}
+	}
+	if positions, ok := c.indexes[key]; ok {
+		return positions
+	}
+
+	// Collator не потокобезопасен, поэтому свой на каждое построение
+	col := collate.New(language.Make(key.Locale))
+	var buf collate.Buffer
+	keys := make([][]byte, len(users))
+	for i, u := range users {
+		keys[i] = col.KeyFromString(&buf, u.Name)
+	}
+	positions := make([]int, len(users))
+	for i := range positions {
+		positions[i] = i
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		if key.Desc {
+			return bytes.Compare(keys[positions[j]], keys[positions[i]]) < 0
+		}
+		return bytes.Compare(keys[positions[i]], keys[positions[j]]) < 0
+	})
+	c.indexes[key] = positions
+	return positions
+}
+
+func sameSlice(a, b []model.User) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
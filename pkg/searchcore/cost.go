@@ -0,0 +1,63 @@
+package searchcore
+
+import "math/bits"
+
+// веса проверки одной записи в оценке стоимости запроса
+const (
+	// фильтры по полям
+	costFilter = 1
+	// подстрока в Name и About, за каждый вариант с синонимами
+	costSubstring = 4
+	// термин или фраза текстового индекса
+	costTerm = 2
+	// Query.Matcher - чужой код, его стоимость неизвестна
+	costMatcher = 8
+)
+
+// Cost оценивает работу по запросу в условных проверках записей: сколько записей обойдёт план,
+// умножить на сложность проверки каждой, плюс сортировка кандидатов, если выдачу не отдаёт индекс.
+// Оценка берётся без выполнения, по плану из кэша. q должен быть уже проверен Validate
+func (e *Engine) Cost(q Query) int {
+	d := e.data.Load()
+	plan := e.plan(q)
+	access := d.filters.estimate(plan, q, len(d.users))
+	return queryCost(plan, access, sortMethod(plan, access))
+}
+
+// sortMethod - как упорядочится выдача плана при выбранном обходе, см. Explanation.Sort
+func sortMethod(plan *queryPlan, access AccessEstimate) string {
+	switch {
+	case !plan.sorted:
+		return SortNone
+	case plan.scorer != nil:
+		return SortScore
+	case access.Method != AccessScan && !access.ordered:
+		return SortCandidates
+	case plan.sort.Locale != "":
+		return SortCollated
+	}
+	return SortIndex
+}
+
+func queryCost(plan *queryPlan, access AccessEstimate, sort string) int {
+	examined := access.ScanCost
+	if access.Method != AccessScan {
+		examined = access.IndexCost
+	}
+	weight := costFilter
+	switch {
+	case plan.custom != nil:
+		weight += costMatcher
+	case len(plan.terms) > 0:
+		weight += costTerm * len(plan.terms)
+	case plan.text.rest != "":
+		weight += costSubstring * (1 + len(plan.restSynonyms))
+	}
+	weight += costTerm * len(plan.phrases)
+	cost := examined * weight
+	if sort == SortScore {
+		// оценка каждой подходящей записи и сортировка всех, кандидатов индекса IndexCost уже учёл
+		cost += examined*costSubstring + examined*bits.Len(uint(examined))
+	}
+	return cost
+}
@@ -0,0 +1,28 @@
+package searchcore
+
+import (
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestCost(t *testing.T) {
+	e := newTestEngine(testUsers(1000), Config{})
+	defer e.Close()
+
+	all := e.Cost(Query{})
+	page := e.Cost(Query{Gender: "female", AgeMin: 30, AgeMax: 31, Limit: 10})
+	text := e.Cost(Query{Query: "nisi"})
+	score := e.Cost(Query{Query: "nisi", OrderField: ScoreField, OrderBy: model.OrderByDesc})
+	if !(page < all && all < text && text < score) {
+		t.Errorf("Error : unexpected costs: page %d, all %d, text %d, score %d", page, all, text, score)
+	}
+	if all != 1000 {
+		t.Errorf("Error : full scan without query costs %d", all)
+	}
+
+	q := Query{Query: "nisi", OrderField: "Name", OrderBy: model.OrderByAsc, Limit: 10}
+	if exp := e.Explain(q); exp.Cost != e.Cost(q) {
+		t.Errorf("Error : explain cost %d != %d", exp.Cost, e.Cost(q))
+	}
+}
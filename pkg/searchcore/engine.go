@@ -0,0 +1,434 @@
+// Package searchcore - фильтрация, сортировка и пагинация пользователей без транспорта:
+// индексы, планы запросов и параллельный обход. Им пользуются http, grpc, graphql и json-rpc
+// сервера, а бенчмарки и фаззинг гоняют его напрямую
+package searchcore
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// как часто обход, оценка и сортировка кандидатов проверяют, не завершился ли ctx запроса
+const deadlineCheckEvery = 256
+
+// ctxCheck проверяет ctx раз в deadlineCheckEvery вызовов: ctx.Err берёт мьютекс,
+// дёргать его на каждой записи или сравнении дорого
+type ctxCheck struct {
+	ctx  context.Context
+	n    int
+	done bool
+}
+
+// canceled сообщает, что ctx уже завершился (дедлайн или клиент ушёл), и дальше всегда true
+func (c *ctxCheck) canceled() bool {
+	if !c.done {
+		if c.n%deadlineCheckEvery == 0 && c.ctx.Err() != nil {
+			c.done = true
+		}
+		c.n++
+	}
+	return c.done
+}
+
+// как упорядочивается выдача в Explanation.Sort
+const (
+	SortNone       = "none"
+	SortIndex      = "index"
+	SortCollated   = "collated"
+	SortCandidates = "candidates"
+	SortScore      = "score"
+)
+
+// Config - настройки Engine, не меняются после New
+type Config struct {
+	// локаль сортировки по Name (BCP 47, например "de" или "sv"), пусто - побайтово
+	OrderLocale string
+	// поиск query по словам About со стеммингом и стоп-словами, по умолчанию - подстрокой
+	TextSearch TextSearchConfig
+	// размер кэша планов, 0 - defaultPlanCacheSize, меньше нуля - без кэша
+	PlanCacheSize int
+	// сколько горутин фильтруют большие датасеты параллельно, 0 или 1 - в одной горутине
+	Parallelism int
+	// синонимы query, nil - без синонимов. Подменяются на ходу через SetSynonyms
+	Synonyms *Synonyms
+	// веса полей во встроенной оценке для order_field=Score, запрос может их переопределить
+	Boosts Boosts
+}
+
+// Engine ищет по текущему датасету. Датасет с индексами подменяется целиком в SetUsers,
+// поиск идёт по снимку и блокировок не берёт
+type Engine struct {
+	cfg      Config
+	analyzer *textAnalyzer
+	// анализатор Name, nil - Name ищется подстрокой
+	nameAnalyzer *textAnalyzer
+	plans        *planCache
+	pool         *workerPool
+	data         atomic.Pointer[dataset]
+	// индексы по Name с учётом локали, см. collatedIndexes
+	collated collatedIndexes
+	synonyms atomic.Pointer[synonymIndex]
+}
+
+// dataset - срез пользователей вместе с индексами, построенными по нему
+type dataset struct {
+	users []model.User
+	// позиции в users, отсортированные по каждому из полей
+	indexes map[SortKey][]int
+	// индексы под фильтры возраста и пола, см. filterIndex.choose
+	filters *filterIndex
+	// термины About, nil - текстовый поиск подстрокой
+	text *textIndex
+	// термины Name, nil - Name ищется подстрокой
+	nameText *textIndex
+	// язык About каждой записи, см. DetectLanguage
+	langs []string
+	// значения вычисляемых полей, посчитанные при загрузке, см. RegisterField
+	fields map[string]fieldValues
+	// сколько записей помечены удалёнными, без них offset можно пропускать по индексу
+	deleted int
+	// позиция записи по Id, для курсора keyset-пагинации
+	byID map[int]int
+}
+
+// New возвращает Engine с пустым датасетом
+func New(cfg Config) *Engine {
+	e := &Engine{cfg: cfg, analyzer: newTextAnalyzer(cfg.TextSearch), nameAnalyzer: newNameAnalyzer(cfg.TextSearch)}
+	if cfg.PlanCacheSize >= 0 {
+		size := cfg.PlanCacheSize
+		if size == 0 {
+			size = defaultPlanCacheSize
+		}
+		e.plans = newPlanCache(size)
+	}
+	if cfg.Parallelism > 1 {
+		e.pool = newWorkerPool(cfg.Parallelism)
+	}
+	e.data.Store(&dataset{})
+	e.SetSynonyms(cfg.Synonyms)
+	return e
+}
+
+// SetSynonyms подменяет синонимы query, nil - без синонимов. Планы из кэша пересобираются
+// при следующем запросе
+func (e *Engine) SetSynonyms(s *Synonyms) {
+	e.synonyms.Store(buildSynonymIndex(s, e.analyzer))
+}
+
+// Close останавливает пул горутин фильтрации
+func (e *Engine) Close() {
+	if e.pool != nil {
+		e.pool.close()
+	}
+}
+
+// SetUsers строит индексы по users и подменяет ими датасет. users дальше не должен меняться на месте
+func (e *Engine) SetUsers(users []model.User) {
+	d := &dataset{users: users, indexes: buildSortIndexes(users), byID: make(map[int]int, len(users))}
+	for i, u := range users {
+		if u.Deleted {
+			d.deleted++
+		}
+		if _, ok := d.byID[u.Id]; !ok {
+			d.byID[u.Id] = i
+		}
+	}
+	d.fields = computeFields(users, d.indexes)
+	d.filters = buildFilterIndex(users, d.indexes)
+	d.langs = detectLanguages(users)
+	if e.analyzer != nil {
+		d.text = buildTextIndex(e.analyzer, users, func(u model.User) string { return u.About }, d.langs)
+	}
+	if e.nameAnalyzer != nil {
+		d.nameText = buildTextIndex(e.nameAnalyzer, users, func(u model.User) string { return u.Name }, nil)
+	}
+	e.data.Store(d)
+}
+
+// Languages возвращает, у скольких записей About на каждом языке, см. DetectLanguage
+func (e *Engine) Languages() map[string]int {
+	counts := map[string]int{}
+	for _, lang := range e.data.Load().langs {
+		counts[lang]++
+	}
+	return counts
+}
+
+// Users возвращает текущий датасет, срез только для чтения
+func (e *Engine) Users() []model.User {
+	return e.data.Load().users
+}
+
+// Stats - как прошёл обход датасета, для трассировки и журнала медленных запросов
+type Stats struct {
+	// AccessScan или индекс фильтра
+	Access string
+	// сколько записей проверено и сколько подошло, включая пропущенные offset
+	Scanned int
+	Matched int
+	// выбор порядка обхода и сама фильтрация
+	Sort   time.Duration
+	Filter time.Duration
+}
+
+// Result - страница выдачи Search
+type Result struct {
+	Users []model.User
+	// за страницей есть ещё записи
+	NextPage bool
+	// ctx истёк посреди обхода, выдача - корректный префикс, см. Query.AllowPartial
+	Partial bool
+	Stats   Stats
+}
+
+// Search ищет страницу из q.Limit записей (без лимита - всю выдачу) и сообщает, есть ли за ней ещё.
+// Если ctx истёк посреди обхода, при q.AllowPartial возвращает найденное с Partial,
+// иначе model.ErrSearchTimeout
+func (e *Engine) Search(ctx context.Context, q Query) (Result, error) {
+	if err := q.Validate(); err != nil {
+		return Result{}, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		// без лимита offset не учитывается
+		q.Offset = 0
+	} else {
+		q.Limit++
+	}
+
+	res := Result{Users: []model.User{}}
+	var err error
+	res.Stats, err = e.Each(ctx, q, func(u model.User) bool {
+		res.Users = append(res.Users, u)
+		return true
+	})
+	if err != nil {
+		if !q.AllowPartial {
+			return Result{Stats: res.Stats}, err
+		}
+		res.Partial = true
+	}
+	if limit > 0 && len(res.Users) > limit {
+		res.Users, res.NextPage = res.Users[:limit], true
+	}
+	return res, nil
+}
+
+// plan возвращает план для q из кэша или разбирает q заново. q должен быть уже проверен Validate
+func (e *Engine) plan(q Query) *queryPlan {
+	if e.plans == nil {
+		return e.newPlan(q)
+	}
+	key := q.planKey()
+	if p, ok := e.plans.get(key); ok && p.synonyms == e.synonyms.Load() {
+		return p
+	}
+	p := e.newPlan(key)
+	e.plans.put(key, p)
+	return p
+}
+
+// newPlan разбирает q с текущими синонимами
+func (e *Engine) newPlan(q Query) *queryPlan {
+	p := newQueryPlan(q, e.analyzer, e.nameAnalyzer, e.cfg.OrderLocale)
+	p.expand(e.synonyms.Load())
+	if p.sorted && p.sort.Field == ScoreField && p.custom == nil {
+		p.scorer = newTextScorer(p, q.boosts(e.cfg.Boosts))
+	}
+	return p
+}
+
+// PlanCacheStats возвращает счётчики кэша планов запросов
+func (e *Engine) PlanCacheStats() CacheStats {
+	if e.plans == nil {
+		return CacheStats{}
+	}
+	return e.plans.stats()
+}
+
+// Each вызывает fn для подходящих пользователей в порядке выдачи, учитывая limit и offset.
+// При сортировке обход идёт по заранее построенному индексу, поэтому работа
+// пропорциональна offset+limit, а не размеру выдачи. Если же запрос не отсеивает ни одной
+// записи обхода, offset пропускается срезом и работа пропорциональна limit. q должен быть
+// уже проверен Validate.
+//
+// На больших датасетах, если включён пул, совпадения проверяются окнами: окно делится
+// на шарды, которые фильтруются параллельно, а затем обходится по порядку - так результат
+// детерминирован и обход всё так же останавливается, набрав limit записей.
+//
+// Если ctx завершился посреди обхода, возвращает model.ErrSearchTimeout: всё, что успело
+// попасть в fn, - корректный префикс выдачи. Завершённый ctx (дедлайн или отмена, когда клиент
+// закрыл соединение) останавливает и сортировку кандидатов с оценкой Scorer до обхода
+func (e *Engine) Each(ctx context.Context, q Query, fn func(model.User) bool) (stats Stats, err error) {
+	plan := e.plan(q)
+	d := e.data.Load()
+	users := d.users
+	match := plan.matcher(d)
+
+	start := time.Now()
+	check := &ctxCheck{ctx: ctx}
+	// фильтр по индексу сужает обход до кандидатов, уже упорядоченных для выдачи
+	access, order := d.filters.choose(plan, q, users, check)
+	if order == nil && plan.sorted {
+		if plan.sort.Locale != "" {
+			order = e.collated.get(users, plan.sort)
+		} else {
+			order = d.indexes[plan.sort]
+		}
+		if order == nil && plan.scorer == nil {
+			// поле зарегистрировали после загрузки датасета, индекса по нему нет
+			order = make([]int, len(users))
+			for i := range order {
+				order[i] = i
+			}
+			orderPositions(order, users, plan, check)
+		}
+	}
+	if plan.scorer != nil {
+		order = scoreOrder(plan.scorer, plan.sort.Desc, users, order, match, check)
+	}
+	if check.done {
+		stats.Access, stats.Sort = access, time.Since(start)
+		return stats, model.ErrSearchTimeout
+	}
+	total := len(users)
+	if order != nil {
+		total = len(order)
+	}
+	// с курсором обход начинается сразу за ним
+	begin := 0
+	if q.AfterID != "" {
+		if begin, err = keysetStart(plan, q, d, order); err != nil {
+			return stats, err
+		}
+	}
+	// если под запрос подходит всё, что идёт в обход, первые offset записей пропускаются без проверки
+	first := begin
+	if q.Offset > 0 && skipsOffset(plan, q, access, d) {
+		first += q.Offset
+		if first > total {
+			first = total
+		}
+	}
+	stats.Access, stats.Sort = access, time.Since(start)
+	defer func() {
+		stats.Filter = time.Since(start) - stats.Sort
+	}()
+	at := func(k int) int {
+		if order == nil {
+			return k
+		}
+		return order[k]
+	}
+
+	skipped, sent := first-begin, 0
+	stats.Matched = first - begin
+	emit := func(el model.User) bool {
+		stats.Matched++
+		if skipped < q.Offset {
+			skipped++
+			return true
+		}
+		if !fn(el) {
+			return false
+		}
+		sent++
+		return q.Limit <= 0 || sent < q.Limit
+	}
+
+	if e.pool == nil || total < parallelMinRows {
+		for k := first; k < total; k++ {
+			if (k-first)%deadlineCheckEvery == 0 && ctx.Err() != nil {
+				return stats, model.ErrSearchTimeout
+			}
+			stats.Scanned++
+			if i := at(k); match(i) && !emit(users[i]) {
+				return stats, nil
+			}
+		}
+		return stats, nil
+	}
+
+	window := e.pool.size * parallelShardSize
+	matched := make([]bool, window)
+	for from := first; from < total; from += window {
+		if ctx.Err() != nil {
+			return stats, model.ErrSearchTimeout
+		}
+		to := from + window
+		if to > total {
+			to = total
+		}
+		// шарды бросают проверку на завершённом ctx, неполное окно отдавать нельзя
+		if !e.pool.matchParallel(ctx, match, at, from, to, matched) {
+			return stats, model.ErrSearchTimeout
+		}
+		for k := from; k < to; k++ {
+			stats.Scanned++
+			if matched[k-from] && !emit(users[at(k)]) {
+				return stats, nil
+			}
+		}
+	}
+	return stats, nil
+}
+
+// skipsOffset сообщает, что обход access для q не отсеет ни одной записи: тогда offset
+// можно пропустить по индексу сортировки или кандидатам, как срез, не проверяя записи
+func skipsOffset(plan *queryPlan, q Query, access string, d *dataset) bool {
+	return plan.covers(access) && (q.IncludeDeleted || d.deleted == 0)
+}
+
+// Explanation - как Engine выполнил бы поиск q. Сам поиск не выполняется, кэш планов не меняется
+type Explanation struct {
+	// размер датасета
+	Rows int
+	// обход датасета: полный проход или индекс фильтра и оценки обоих
+	Access AccessEstimate
+	// none - порядок датасета, index - индекс сортировки, collated - индекс с локалью,
+	// candidates - сортировка кандидатов индекса фильтра, score - по оценке Scorer
+	Sort    string
+	SortKey *SortKey `json:",omitempty"`
+	// как ищется query: none, substring, index (термины текстового индекса) или matcher (Query.Matcher)
+	Text string
+	// offset пропускается срезом, без проверки записей - работа пропорциональна limit
+	SkipOffset bool `json:",omitempty"`
+	// выдача начинается за курсором after_id, найденным без обхода
+	Keyset bool `json:",omitempty"`
+	// план запроса уже разобран и лежит в кэше планов
+	PlanCached bool
+	// оценка работы в условных проверках записей, см. Engine.Cost
+	Cost int
+}
+
+// Explain разбирает q заново, не трогая кэш планов. q должен быть уже проверен Validate
+func (e *Engine) Explain(q Query) Explanation {
+	d := e.data.Load()
+	key := q.planKey()
+	exp := Explanation{Rows: len(d.users), PlanCached: e.plans != nil && e.plans.contains(key)}
+	plan := e.newPlan(key)
+	if plan.sorted {
+		exp.SortKey = &plan.sort
+	}
+
+	switch {
+	case plan.custom != nil:
+		exp.Text = "matcher"
+	case plan.text.rest == "" && len(plan.phrases) == 0:
+		exp.Text = "none"
+	case d.text != nil && (len(plan.terms) > 0 || len(plan.phrases) > 0):
+		exp.Text = "index"
+	default:
+		exp.Text = "substring"
+	}
+
+	exp.Access = d.filters.estimate(plan, q, len(d.users))
+	exp.Sort = sortMethod(plan, exp.Access)
+	exp.Cost = queryCost(plan, exp.Access, exp.Sort)
+	exp.SkipOffset = q.Offset > 0 && skipsOffset(plan, q, exp.Access.Method, d)
+	exp.Keyset = q.AfterID != ""
+	return exp
+}
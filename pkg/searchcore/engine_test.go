@@ -0,0 +1,314 @@
+package searchcore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+var testWords = []string{"nisi", "dolor", "developer", "golang", "lorem", "remote", "senior", "team"}
+
+// testUsers - n детерминированных пользователей с повторами имён и возрастов, чтобы сортировка была стабильной не случайно
+func testUsers(n int) []model.User {
+	users := make([]model.User, n)
+	for i := range users {
+		gender := "male"
+		if i%3 == 0 {
+			gender = "female"
+		}
+		users[i] = model.User{
+			Id:      i,
+			Name:    fmt.Sprintf("User%03d", (i*7919)%97),
+			Age:     20 + (i*31)%40,
+			Gender:  gender,
+			Company: fmt.Sprintf("Company%d", i%5),
+			About:   testWords[i%len(testWords)] + " " + testWords[(i/3)%len(testWords)],
+			Deleted: i%50 == 49,
+		}
+	}
+	return users
+}
+
+func newTestEngine(users []model.User, cfg Config) *Engine {
+	e := New(cfg)
+	e.SetUsers(users)
+	return e
+}
+
+// naiveSearch - выдача без индексов и планов: Match, стабильная сортировка, срез страницы
+func naiveSearch(users []model.User, q Query) []model.User {
+	found := []model.User{}
+	for _, u := range users {
+		if q.Match(u) {
+			found = append(found, u)
+		}
+	}
+	if key, ok := q.SortKey(); ok {
+		less, _ := OrderLess(key.Field)
+		sort.SliceStable(found, func(i, j int) bool {
+			if key.Desc {
+				return less(found[j], found[i])
+			}
+			return less(found[i], found[j])
+		})
+	}
+	if q.Limit <= 0 {
+		return found
+	}
+	if q.Offset > len(found) {
+		return []model.User{}
+	}
+	found = found[q.Offset:]
+	if len(found) > q.Limit {
+		found = found[:q.Limit]
+	}
+	return found
+}
+
+func TestSearch(t *testing.T) {
+	users := testUsers(500)
+	e := newTestEngine(users, Config{})
+
+	for _, q := range []Query{
+		{},
+		{Query: "nisi", Limit: 10},
+		{Query: "nisi", Limit: 10, Offset: 40},
+		{Query: "golang", OrderField: "Age", OrderBy: model.OrderByDesc, Limit: 7, Offset: 3},
+		{Gender: "female", AgeMin: 30, AgeMax: 35, OrderField: "Name", OrderBy: model.OrderByAsc},
+		{Company: "company2", IncludeDeleted: true, OrderField: "Id", OrderBy: model.OrderByDesc, Limit: 5},
+		{Query: "no such user", Limit: 1},
+		// offset пропускается срезом только без удалённых или с include_deleted
+		{OrderField: "Name", OrderBy: model.OrderByAsc, Limit: 5, Offset: 300},
+		{OrderField: "Name", OrderBy: model.OrderByAsc, Limit: 5, Offset: 300, IncludeDeleted: true},
+		{Gender: "female", IncludeDeleted: true, Limit: 5, Offset: 100},
+		{AgeMin: 30, OrderField: "Age", OrderBy: model.OrderByDesc, IncludeDeleted: true, Limit: 5, Offset: 100},
+		{IncludeDeleted: true, Limit: 5, Offset: 1000},
+	} {
+		res, err := e.Search(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		expected := naiveSearch(users, q)
+		if len(res.Users) != len(expected) {
+			t.Fatalf("Error : %+v: %d users != %d", q, len(res.Users), len(expected))
+		}
+		for i := range expected {
+			if res.Users[i] != expected[i] {
+				t.Errorf("Error : %+v: %v != %v", q, res.Users[i], expected[i])
+				break
+			}
+		}
+		next := q
+		next.Offset, next.Limit = q.Offset+q.Limit, 1
+		if q.Limit > 0 && res.NextPage != (len(naiveSearch(users, next)) > 0) {
+			t.Errorf("Error : %+v: wrong NextPage %v", q, res.NextPage)
+		}
+		if res.Stats.Scanned == 0 && len(expected) > 0 || res.Partial {
+			t.Errorf("Error : %+v: unexpected stats %+v", q, res)
+		}
+	}
+
+	if _, err := e.Search(context.Background(), Query{OrderField: "About", OrderBy: model.OrderByAsc}); err != model.ErrBadOrderField {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+}
+
+func TestSearchSkipOffset(t *testing.T) {
+	users := testUsers(500)
+	for i := range users {
+		users[i].Deleted = false
+	}
+	e := newTestEngine(users, Config{})
+
+	for _, q := range []Query{
+		{OrderField: "Name", OrderBy: model.OrderByDesc, Limit: 5, Offset: 400},
+		{Gender: "male", Limit: 5, Offset: 300},
+		{AgeMin: 25, AgeMax: 50, OrderField: "Age", OrderBy: model.OrderByAsc, Limit: 5, Offset: 300},
+	} {
+		res, err := e.Search(context.Background(), q)
+		if err != nil || res.Stats.Scanned != 6 || !e.Explain(q).SkipOffset {
+			t.Errorf("Error : %+v: offset not skipped, %+v %v", q, res.Stats, err)
+		}
+		expected := naiveSearch(users, q)
+		if len(res.Users) != len(expected) || res.Users[0] != expected[0] {
+			t.Errorf("Error : %+v: %v != %v", q, res.Users, expected)
+		}
+	}
+
+	// фильтр без индекса обхода - offset честно проверяется
+	q := Query{Company: "company1", Limit: 5, Offset: 50}
+	if res, _ := e.Search(context.Background(), q); res.Stats.Scanned <= 6 || e.Explain(q).SkipOffset {
+		t.Errorf("Error : offset skipped under filter, %+v", res.Stats)
+	}
+}
+
+func TestSearchTimeout(t *testing.T) {
+	e := newTestEngine(testUsers(100), Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := e.Search(ctx, Query{}); err != model.ErrSearchTimeout {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+	res, err := e.Search(ctx, Query{AllowPartial: true})
+	if err != nil || !res.Partial || len(res.Users) != 0 {
+		t.Errorf("Error : unexpected result %+v %v", res, err)
+	}
+}
+
+// cancelingScorer отменяет ctx запроса на after-й проверке, как клиент, закрывший соединение
+type cancelingScorer struct {
+	calls  *int64
+	after  int64
+	cancel context.CancelFunc
+}
+
+func (s cancelingScorer) Match(u model.User) bool {
+	if atomic.AddInt64(s.calls, 1) == s.after {
+		s.cancel()
+	}
+	return true
+}
+
+func (s cancelingScorer) Score(u model.User) float64 {
+	return float64(u.Age)
+}
+
+// отменённый посреди работы запрос бросает и оценку перед сортировкой, и параллельный обход
+func TestEachCanceled(t *testing.T) {
+	users := testUsers(3 * parallelMinRows)
+	var calls int64
+	ctx, cancel := context.WithCancel(context.Background())
+	RegisterMatcher("test-canceling", func(query string) Matcher {
+		// cancel подменяется на каждый запрос, а план со стратегией живёт в кэше
+		return cancelingScorer{calls: &calls, after: 1000, cancel: func() { cancel() }}
+	})
+
+	for _, e := range []*Engine{newTestEngine(users, Config{}), newTestEngine(users, Config{Parallelism: 4})} {
+		for _, q := range []Query{
+			{Matcher: "test-canceling", OrderField: ScoreField, OrderBy: model.OrderByDesc},
+			{Matcher: "test-canceling"},
+		} {
+			atomic.StoreInt64(&calls, 0)
+			ctx, cancel = context.WithCancel(context.Background())
+			_, err := e.Each(ctx, q, func(model.User) bool { return true })
+			if err != model.ErrSearchTimeout {
+				t.Errorf("Error : %+v: unexpected error %v", q, err)
+			}
+			if n := atomic.LoadInt64(&calls); n >= int64(len(users))/2 {
+				t.Errorf("Error : %+v: %d of %d rows checked after cancel", q, n, len(users))
+			}
+		}
+		e.Close()
+	}
+	cancel()
+}
+
+// параллельная фильтрация должна давать ровно ту же выдачу, что и последовательная
+func TestSearchParallel(t *testing.T) {
+	users := testUsers(3 * parallelMinRows)
+	serial := newTestEngine(users, Config{})
+	parallel := newTestEngine(users, Config{Parallelism: 4})
+	defer parallel.Close()
+
+	for _, q := range []Query{
+		{Query: "nisi"},
+		{Query: "senior", OrderField: "Age", OrderBy: model.OrderByDesc, Limit: 25, Offset: 2000},
+		{Query: "no such user"},
+	} {
+		expected, _ := serial.Search(context.Background(), q)
+		got, err := parallel.Search(context.Background(), q)
+		if err != nil || len(got.Users) != len(expected.Users) {
+			t.Fatalf("Error : %+v: %d != %d, %v", q, len(got.Users), len(expected.Users), err)
+		}
+		for i := range got.Users {
+			if got.Users[i] != expected.Users[i] {
+				t.Errorf("Error : %+v: %v != %v", q, got.Users[i], expected.Users[i])
+				break
+			}
+		}
+	}
+}
+
+// поиск после Close и во время него не паникует, а проверяет шарды сам
+func TestSearchAfterClose(t *testing.T) {
+	users := testUsers(3 * parallelMinRows)
+	serial := newTestEngine(users, Config{})
+	parallel := newTestEngine(users, Config{Parallelism: 4})
+	q := Query{Query: "nisi"}
+	expected, _ := serial.Search(context.Background(), q)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			parallel.Search(context.Background(), q)
+		}
+	}()
+	parallel.Close()
+	<-done
+
+	got, err := parallel.Search(context.Background(), q)
+	if err != nil || len(got.Users) != len(expected.Users) {
+		t.Errorf("Error : %d != %d, %v", len(got.Users), len(expected.Users), err)
+	}
+}
+
+// FuzzSearch гоняет произвольные запросы через Engine и сверяет выдачу с naiveSearch
+func FuzzSearch(f *testing.F) {
+	f.Add("nisi", "Age", 1, 5, 3, "female", 25, 40, false)
+	f.Add(`"senior developer" team`, "Name", -1, 0, 0, "", 0, 0, false)
+	f.Add("", "About", 1, 1, 0, "", 0, 0, false)
+	f.Add("", "", 7, -1, -5, "male", 50, 10, false)
+	f.Add("", "Age", -1, 5, 120, "female", 30, 0, true)
+
+	users := testUsers(300)
+	e := newTestEngine(users, Config{TextSearch: TextSearchConfig{Language: TextLanguageSimple}})
+	plain := newTestEngine(users, Config{})
+	f.Fuzz(func(t *testing.T, query, field string, orderBy, limit, offset int, gender string, ageMin, ageMax int, deleted bool) {
+		q := Query{Query: query, OrderField: field, OrderBy: orderBy, Limit: limit, Offset: offset,
+			Gender: gender, AgeMin: ageMin, AgeMax: ageMax, IncludeDeleted: deleted}
+		res, err := plain.Search(context.Background(), q)
+		if err != nil {
+			if q.Validate() == nil {
+				t.Errorf("Error : %+v: unexpected error %v", q, err)
+			}
+			return
+		}
+		if q.Limit <= 0 {
+			q.Offset = 0
+		}
+		if expected := naiveSearch(users, q); len(res.Users) != len(expected) ||
+			len(expected) > 0 && res.Users[0] != expected[0] {
+			t.Errorf("Error : %+v: %d users != %d", q, len(res.Users), len(expected))
+		}
+		// текстовый индекс меняет совпадения, но не должен паниковать
+		if _, err := e.Search(context.Background(), q); err != nil {
+			t.Errorf("Error : %+v: %v", q, err)
+		}
+	})
+}
+
+func BenchmarkSearch(b *testing.B) {
+	e := newTestEngine(testUsers(10000), Config{})
+	q := Query{Query: "nisi", OrderField: "Name", OrderBy: model.OrderByDesc, Limit: 25, Offset: 100}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Search(context.Background(), q)
+	}
+}
+
+func BenchmarkSearchFilterIndex(b *testing.B) {
+	e := newTestEngine(testUsers(10000), Config{})
+	q := Query{AgeMin: 30, AgeMax: 30, OrderField: "Name", OrderBy: model.OrderByAsc, Limit: 25}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.Search(context.Background(), q)
+	}
+}
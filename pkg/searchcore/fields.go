@@ -0,0 +1,310 @@
+package searchcore
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"final_task_golang/pkg/model"
+)
+
+// ComputedField - поле, которого нет в датасете: значение вычисляется из записи.
+// По нему фильтруют (Query.Fields), сортируют (OrderField) и группируют (GroupBy), как по своим полям.
+// Функция должна зависеть только от записи: значения считаются при загрузке датасета
+// и пересчитываются на лету там, где посчитанных нет
+type ComputedField struct {
+	// ровно одна из функций: Int - числовое поле, String - строковое
+	Int    func(u model.User) int
+	String func(u model.User) string
+}
+
+// виды полей FieldSpec
+const (
+	FieldBucket  = "bucket"
+	FieldInitial = "initial"
+	FieldWords   = "words"
+	FieldLength  = "length"
+)
+
+// FieldSpec - вычисляемое поле, заданное конфигурацией, а не кодом
+type FieldSpec struct {
+	Name string
+	// bucket - числовое Source, округлённое вниз до кратного Width; initial - первая буква Source
+	// в верхнем регистре; words - число слов в Source; length - длина Source в символах
+	Kind   string
+	Source string
+	Width  int
+}
+
+// Field строит ComputedField по описанию
+func (s FieldSpec) Field() (ComputedField, error) {
+	if s.Kind == FieldBucket {
+		num, ok := numericSource(s.Source)
+		if !ok {
+			return ComputedField{}, fmt.Errorf("field %s: %q is not a numeric field", s.Name, s.Source)
+		}
+		if s.Width <= 0 {
+			return ComputedField{}, fmt.Errorf("field %s: bucket width must be > 0", s.Name)
+		}
+		width := s.Width
+		return ComputedField{Int: func(u model.User) int {
+			v := num(u)
+			if v < 0 {
+				// вниз и для отрицательных: -1 попадает в [-width, 0)
+				return (v - width + 1) / width * width
+			}
+			return v / width * width
+		}}, nil
+	}
+	text, ok := textSource(s.Source)
+	if !ok {
+		return ComputedField{}, fmt.Errorf("field %s: %q is not a text field", s.Name, s.Source)
+	}
+	switch s.Kind {
+	case FieldInitial:
+		return ComputedField{String: func(u model.User) string {
+			r, _ := utf8.DecodeRuneInString(strings.TrimSpace(text(u)))
+			if r == utf8.RuneError {
+				return ""
+			}
+			return string(unicode.ToUpper(r))
+		}}, nil
+	case FieldWords:
+		return ComputedField{Int: func(u model.User) int {
+			return len(strings.FieldsFunc(text(u), func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			}))
+		}}, nil
+	case FieldLength:
+		return ComputedField{Int: func(u model.User) int { return utf8.RuneCountInString(text(u)) }}, nil
+	}
+	return ComputedField{}, fmt.Errorf("field %s: unknown kind %q", s.Name, s.Kind)
+}
+
+// ParseFieldSpecs разбирает описания полей вида "AgeDecade=bucket:Age:10,Initial=initial:Name"
+func ParseFieldSpecs(s string) ([]FieldSpec, error) {
+	var specs []FieldSpec
+	if strings.TrimSpace(s) == "" {
+		return specs, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		name, def, ok := strings.Cut(strings.TrimSpace(item), "=")
+		parts := strings.Split(def, ":")
+		if !ok || name == "" || len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("bad field %q, want Name=kind:Source[:width]", item)
+		}
+		spec := FieldSpec{Name: name, Kind: parts[0], Source: parts[1]}
+		if len(parts) == 3 {
+			width, err := strconv.Atoi(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("bad field %q: %v", item, err)
+			}
+			spec.Width = width
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+func numericSource(field string) (func(model.User) int, bool) {
+	switch field {
+	case "Id":
+		return func(u model.User) int { return u.Id }, true
+	case "Age":
+		return func(u model.User) int { return u.Age }, true
+	}
+	return nil, false
+}
+
+func textSource(field string) (func(model.User) string, bool) {
+	if field == "About" {
+		return func(u model.User) string { return u.About }, true
+	}
+	if _, ok := GroupValue(model.User{}, field); !ok || field == "Age" {
+		return nil, false
+	}
+	return func(u model.User) string {
+		v, _ := GroupValue(u, field)
+		return v
+	}, true
+}
+
+var fields = struct {
+	sync.RWMutex
+	byName map[string]ComputedField
+}{byName: map[string]ComputedField{}}
+
+func init() {
+	for _, spec := range []FieldSpec{
+		{Name: "AgeBucket", Kind: FieldBucket, Source: "Age", Width: 10},
+		{Name: "NameInitial", Kind: FieldInitial, Source: "Name"},
+		{Name: "AboutWordCount", Kind: FieldWords, Source: "About"},
+	} {
+		f, _ := spec.Field()
+		RegisterField(spec.Name, f)
+	}
+}
+
+// RegisterField регистрирует вычисляемое поле name. Поля общие для всех Engine процесса,
+// регистрировать их надо до загрузки датасета: позже зарегистрированные считаются на лету, без индекса.
+// Курсор after_value по вычисляемому полю не поддерживается, только after_id
+func RegisterField(name string, f ComputedField) error {
+	if (f.Int == nil) == (f.String == nil) {
+		return fmt.Errorf("field %s: exactly one of Int and String must be set", name)
+	}
+	if _, ok := nativeOrderLess(name); ok || name == "" || name == ScoreField || name == "Gender" || name == "About" {
+		return fmt.Errorf("field %s: name is taken", name)
+	}
+	fields.Lock()
+	defer fields.Unlock()
+	fields.byName[name] = f
+	return nil
+}
+
+// Fields возвращает имена вычисляемых полей по алфавиту
+func Fields() []string {
+	fields.RLock()
+	defer fields.RUnlock()
+	names := make([]string, 0, len(fields.byName))
+	for name := range fields.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupField(name string) (ComputedField, bool) {
+	fields.RLock()
+	defer fields.RUnlock()
+	f, ok := fields.byName[name]
+	return f, ok
+}
+
+// format - значение поля записи так, как оно приходит в параметрах и уходит в группы
+func (f ComputedField) format(u model.User) string {
+	if f.Int != nil {
+		return strconv.Itoa(f.Int(u))
+	}
+	return f.String(u)
+}
+
+func (f ComputedField) less() func(lhs, rhs model.User) bool {
+	if f.Int != nil {
+		return func(lhs, rhs model.User) bool { return f.Int(lhs) < f.Int(rhs) }
+	}
+	return func(lhs, rhs model.User) bool { return f.String(lhs) < f.String(rhs) }
+}
+
+// fieldValues - значения вычисляемого поля по позициям датасета
+type fieldValues struct {
+	ints []int
+	strs []string
+}
+
+func computeField(f ComputedField, users []model.User) fieldValues {
+	var v fieldValues
+	if f.Int != nil {
+		v.ints = make([]int, len(users))
+		for i, u := range users {
+			v.ints[i] = f.Int(u)
+		}
+		return v
+	}
+	v.strs = make([]string, len(users))
+	for i, u := range users {
+		v.strs[i] = f.String(u)
+	}
+	return v
+}
+
+func (v fieldValues) less(i, j int) bool {
+	if v.ints != nil {
+		return v.ints[i] < v.ints[j]
+	}
+	return v.strs[i] < v.strs[j]
+}
+
+// computeFields считает все зарегистрированные поля по users и строит по ним индексы сортировки
+func computeFields(users []model.User, indexes map[SortKey][]int) map[string]fieldValues {
+	fields.RLock()
+	registered := make(map[string]ComputedField, len(fields.byName))
+	for name, f := range fields.byName {
+		registered[name] = f
+	}
+	fields.RUnlock()
+
+	computed := make(map[string]fieldValues, len(registered))
+	for name, f := range registered {
+		values := computeField(f, users)
+		computed[name] = values
+		for _, desc := range []bool{false, true} {
+			positions := make([]int, len(users))
+			for i := range positions {
+				positions[i] = i
+			}
+			sort.SliceStable(positions, func(i, j int) bool {
+				if desc {
+					return values.less(positions[j], positions[i])
+				}
+				return values.less(positions[i], positions[j])
+			})
+			indexes[SortKey{Field: name, Desc: desc}] = positions
+		}
+	}
+	return computed
+}
+
+// fieldFilter - условие Query.Fields: значение поля равно value, у числового поля - num
+type fieldFilter struct {
+	name  string
+	field ComputedField
+	value string
+	num   int
+}
+
+// parseFieldFilters разбирает Query.Fields, false - поле не зарегистрировано или значение
+// числового поля не число
+func parseFieldFilters(encoded string) ([]fieldFilter, bool) {
+	if encoded == "" {
+		return nil, true
+	}
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		return nil, false
+	}
+	filters := make([]fieldFilter, 0, len(values))
+	for name := range values {
+		f, ok := lookupField(name)
+		if !ok {
+			return nil, false
+		}
+		ff := fieldFilter{name: name, field: f, value: values.Get(name)}
+		if f.Int != nil {
+			if ff.num, err = strconv.Atoi(ff.value); err != nil {
+				return nil, false
+			}
+		}
+		filters = append(filters, ff)
+	}
+	sort.Slice(filters, func(a, b int) bool { return filters[a].name < filters[b].name })
+	return filters, true
+}
+
+// match проверяет запись на позиции i: по посчитанным при загрузке значениям, если они есть
+func (ff fieldFilter) match(computed map[string]fieldValues, users []model.User, i int) bool {
+	values, ok := computed[ff.name]
+	switch {
+	case ok && ff.field.Int != nil && values.ints != nil:
+		return values.ints[i] == ff.num
+	case ok && ff.field.String != nil && values.strs != nil:
+		return values.strs[i] == ff.value
+	case ff.field.Int != nil:
+		return ff.field.Int(users[i]) == ff.num
+	}
+	return ff.field.String(users[i]) == ff.value
+}
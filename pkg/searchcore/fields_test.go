@@ -0,0 +1,95 @@
+package searchcore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestComputedFields(t *testing.T) {
+	users := testUsers(200)
+	e := newTestEngine(users, Config{Parallelism: 4})
+	defer e.Close()
+
+	// фильтр и сортировка по полям, посчитанным при загрузке, совпадают с вычислением на ходу
+	q := Query{Fields: "AgeBucket=30", OrderField: "NameInitial", OrderBy: model.OrderByDesc}
+	found, err := e.Search(context.Background(), q)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if expected := naiveSearch(users, q); !sameUsers(found.Users, expected) || len(expected) == 0 {
+		t.Errorf("Error : found %d users, want %d", len(found.Users), len(expected))
+	}
+	for _, u := range found.Users {
+		if u.Age < 30 || u.Age >= 40 {
+			t.Errorf("Error : %+v is not in AgeBucket 30", u)
+		}
+	}
+	if value, ok := GroupValue(users[0], "AboutWordCount"); !ok || value != "2" {
+		t.Errorf("Error : unexpected group value %q %v", value, ok)
+	}
+
+	for _, bad := range []Query{{Fields: "Unknown=1"}, {Fields: "AgeBucket=thirty"}, {OrderField: "Unknown", OrderBy: model.OrderByAsc}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Error : %+v accepted", bad)
+		}
+	}
+	if err := RegisterField("Age", ComputedField{Int: func(u model.User) int { return 0 }}); err == nil {
+		t.Errorf("Error : native field replaced")
+	}
+	if err := RegisterField("Both", ComputedField{}); err == nil {
+		t.Errorf("Error : field without function registered")
+	}
+
+	// поле, зарегистрированное после загрузки, сортируется без индекса
+	if err := RegisterField("test-name-length", ComputedField{Int: func(u model.User) int { return -len(u.Name) }}); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	q = Query{OrderField: "test-name-length", OrderBy: model.OrderByAsc, Limit: 20}
+	found, _ = e.Search(context.Background(), q)
+	if expected := naiveSearch(users, q); !sameUsers(found.Users, expected) {
+		t.Errorf("Error : unexpected order %v", found.Users)
+	}
+	if names := Fields(); sort.SearchStrings(names, "AgeBucket") == len(names) {
+		t.Errorf("Error : unexpected fields %v", names)
+	}
+}
+
+func TestParseFieldSpecs(t *testing.T) {
+	specs, err := ParseFieldSpecs("AgeDecade=bucket:Age:10, Initial=initial:Company")
+	if err != nil || len(specs) != 2 || specs[0] != (FieldSpec{Name: "AgeDecade", Kind: FieldBucket, Source: "Age", Width: 10}) {
+		t.Fatalf("Error : %+v %v", specs, err)
+	}
+	f, err := specs[1].Field()
+	if err != nil || f.String(model.User{Company: " acme"}) != "A" {
+		t.Errorf("Error : unexpected field %v", err)
+	}
+	f, _ = FieldSpec{Kind: FieldBucket, Source: "Age", Width: 10}.Field()
+	if f.Int(model.User{Age: -1}) != -10 || f.Int(model.User{Age: 19}) != 10 {
+		t.Errorf("Error : unexpected buckets")
+	}
+	for _, bad := range []string{"A=bucket:Name:10", "A=bucket:Age", "A=initial", "A=upper:Name", "=words:About"} {
+		specs, err := ParseFieldSpecs(bad)
+		if err == nil {
+			_, err = specs[0].Field()
+		}
+		if err == nil || !strings.Contains(err.Error(), "field") {
+			t.Errorf("Error : %s: unexpected error %v", bad, err)
+		}
+	}
+}
+
+func sameUsers(a, b []model.User) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package searchcore
+
+import (
+	"math/bits"
+	"sort"
+
+	"final_task_golang/pkg/model"
+)
+
+// способы обхода датасета, попадают в трассировку и журнал медленных запросов
+const (
+	AccessScan   = "scan"
+	AccessAge    = "age"
+	AccessGender = "gender"
+)
+
+// filterIndex - индексы под фильтры age_min/age_max и gender, строятся вместе с индексами сортировки
+type filterIndex struct {
+	// позиции по возрасту - тот же срез, что индекс сортировки Age по возрастанию
+	byAge []int
+	// ages[k] - возраст users[byAge[k]], для бинарного поиска границ
+	ages []int
+	// позиции каждого пола в порядке датасета
+	genders map[string][]int
+}
+
+func buildFilterIndex(users []model.User, indexes map[SortKey][]int) *filterIndex {
+	f := &filterIndex{
+		byAge:   indexes[SortKey{Field: "Age"}],
+		genders: map[string][]int{},
+	}
+	f.ages = make([]int, len(f.byAge))
+	for k, i := range f.byAge {
+		f.ages[k] = users[i].Age
+	}
+	for i, u := range users {
+		f.genders[u.Gender] = append(f.genders[u.Gender], i)
+	}
+	return f
+}
+
+// ageRange - границы выдачи фильтра по возрасту в byAge
+func (f *filterIndex) ageRange(q Query) (lo, hi int) {
+	lo, hi = 0, len(f.ages)
+	if q.AgeMin > 0 {
+		lo = sort.SearchInts(f.ages, q.AgeMin)
+	}
+	if q.AgeMax > 0 {
+		hi = sort.SearchInts(f.ages, q.AgeMax+1)
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi
+}
+
+// AccessEstimate - оценка обхода датасета для плана, её же показывает /search/explain
+type AccessEstimate struct {
+	// выбранный способ: AccessScan или индекс фильтра
+	Method string
+	// лучший из индексов фильтров, даже если выбран полный проход, пусто - фильтров нет
+	Index string `json:",omitempty"`
+	// сколько кандидатов даёт Index, без него - весь датасет
+	Candidates int
+	// оценки в проверенных записях
+	ScanCost  int
+	IndexCost int `json:",omitempty"`
+
+	// кандидаты индекса уже в порядке выдачи
+	ordered bool
+	// границы кандидатов в byAge
+	lo, hi int
+}
+
+// estimate выбирает, как обойти датасет для плана: полный проход в порядке выдачи или только
+// кандидаты из индекса фильтра. Стоимость считается в проверенных записях. Полный проход
+// останавливается, набрав offset+limit, так что при частых совпадениях он дешевле индекса,
+// которому кандидатов ещё надо упорядочить (c*log c), если индекс не отдаёт их сразу в нужном порядке
+func (f *filterIndex) estimate(plan *queryPlan, q Query, n int) AccessEstimate {
+	e := AccessEstimate{Method: AccessScan, Candidates: n, ScanCost: n}
+	if f == nil || n == 0 {
+		return e
+	}
+	if q.AgeMin > 0 || q.AgeMax > 0 {
+		e.lo, e.hi = f.ageRange(q)
+		e.Index, e.Candidates = AccessAge, e.hi-e.lo
+	}
+	if q.Gender != "" && len(f.genders[q.Gender]) < e.Candidates {
+		e.Index, e.Candidates = AccessGender, len(f.genders[q.Gender])
+	}
+	if e.Index == "" {
+		return e
+	}
+
+	// нужно записей до конца страницы, 0 - вся выдача
+	want := 0
+	if q.Limit > 0 {
+		want = q.Offset + q.Limit
+	}
+	count := e.Candidates
+	if want > 0 && count > 0 && want*n/count < n {
+		e.ScanCost = want * n / count
+	}
+	e.ordered = e.Index == AccessGender && !plan.sorted ||
+		e.Index == AccessAge && plan.sorted && plan.sort == SortKey{Field: "Age"}
+	e.IndexCost = count
+	if e.ordered {
+		if want > 0 && want < count {
+			e.IndexCost = want
+		}
+	} else {
+		e.IndexCost += count * bits.Len(uint(count))
+	}
+	// порядок с локалью есть только у collatedIndexes, кандидатов по нему не упорядочить
+	if plan.sorted && plan.sort.Locale != "" {
+		return e
+	}
+	if e.IndexCost < e.ScanCost {
+		e.Method = e.Index
+	}
+	return e
+}
+
+// choose - estimate вместе с кандидатами. Кандидаты - надмножество выдачи, matcher их всё
+// равно проверяет. Возвращает способ и позиции в порядке выдачи, nil - полный проход.
+// Если check сообщит о завершённом ctx, сортировка кандидатов бросается и порядок не определён
+func (f *filterIndex) choose(plan *queryPlan, q Query, users []model.User, check *ctxCheck) (string, []int) {
+	e := f.estimate(plan, q, len(users))
+	if e.Method == AccessScan {
+		return AccessScan, nil
+	}
+
+	var positions []int
+	if e.Method == AccessAge {
+		positions = f.byAge[e.lo:e.hi]
+	} else {
+		positions = f.genders[q.Gender]
+	}
+	if e.ordered {
+		return e.Method, positions
+	}
+	// срезы индексов общие, сортируем копию
+	positions = append([]int(nil), positions...)
+	orderPositions(positions, users, plan, check)
+	return e.Method, positions
+}
+
+// orderPositions упорядочивает позиции так же, как индекс сортировки плана: по полю,
+// при равенстве - по позиции в датасете, как стабильная сортировка в buildSortIndexes
+func orderPositions(positions []int, users []model.User, plan *queryPlan, check *ctxCheck) {
+	if !plan.sorted || plan.scorer != nil {
+		// по оценке кандидатов упорядочивает scoreOrder
+		sort.Ints(positions)
+		return
+	}
+	less, _ := OrderLess(plan.sort.Field)
+	sort.Slice(positions, func(a, b int) bool {
+		// после отмены сравнения ничего не стоят и сортировка быстро доходит до конца
+		if check.canceled() {
+			return false
+		}
+		lhs, rhs := users[positions[a]], users[positions[b]]
+		if plan.sort.Desc {
+			lhs, rhs = rhs, lhs
+		}
+		if less(lhs, rhs) {
+			return true
+		}
+		if less(rhs, lhs) {
+			return false
+		}
+		return positions[a] < positions[b]
+	})
+}
@@ -0,0 +1,68 @@
+package searchcore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+// выдача через индексы фильтров должна совпадать с полным проходом
+func TestFilterIndexMatchesScan(t *testing.T) {
+	users := testUsers(700)
+	indexed := newTestEngine(users, Config{})
+	scan := newTestEngine(users, Config{})
+	d := *scan.data.Load()
+	d.filters = nil
+	scan.data.Store(&d)
+
+	for _, gender := range []string{"", "male", "female", "none"} {
+		for _, ages := range [][2]int{{0, 0}, {30, 0}, {0, 25}, {25, 27}, {40, 30}} {
+			for _, order := range []Query{{}, {OrderField: "Age", OrderBy: model.OrderByAsc},
+				{OrderField: "Age", OrderBy: model.OrderByDesc}, {OrderField: "Name", OrderBy: model.OrderByAsc}} {
+				for _, page := range [][2]int{{0, 0}, {5, 0}, {10, 30}} {
+					q := order
+					q.Gender, q.AgeMin, q.AgeMax = gender, ages[0], ages[1]
+					q.Limit, q.Offset = page[0], page[1]
+
+					expected, err := scan.Search(context.Background(), q)
+					if err != nil {
+						t.Fatalf("Error : %v", err)
+					}
+					got, _ := indexed.Search(context.Background(), q)
+					if !reflect.DeepEqual(got.Users, expected.Users) {
+						t.Errorf("Error : %+v: %d users != %d", q, len(got.Users), len(expected.Users))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestFilterIndexChoose(t *testing.T) {
+	d := newTestEngine(testUsers(700), Config{}).data.Load()
+
+	for _, c := range []struct {
+		q      Query
+		access string
+	}{
+		// узкий диапазон возраста - по индексу, даже с сортировкой по имени
+		{Query{AgeMin: 25, AgeMax: 25}, AccessAge},
+		{Query{AgeMin: 25, AgeMax: 25, OrderField: "Name", OrderBy: model.OrderByAsc}, AccessAge},
+		// пол без сортировки - корзина уже в порядке датасета
+		{Query{Gender: "female"}, AccessGender},
+		// половина датасета под сортировкой, а нужна одна запись - проход по индексу сортировки быстрее
+		{Query{Gender: "female", OrderField: "Name", OrderBy: model.OrderByAsc, Limit: 1}, AccessScan},
+		{Query{}, AccessScan},
+	} {
+		q := c.q.Normalize()
+		access, positions := d.filters.choose(newQueryPlan(q, nil, nil, ""), q, d.users, &ctxCheck{ctx: context.Background()})
+		if access != c.access {
+			t.Errorf("Error : %+v: access %s, want %s", c.q, access, c.access)
+		}
+		if access != AccessScan && len(positions) == 0 {
+			t.Errorf("Error : %+v: no candidates", c.q)
+		}
+	}
+}
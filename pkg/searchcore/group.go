@@ -0,0 +1,32 @@
+package searchcore
+
+import (
+	"strconv"
+
+	"final_task_golang/pkg/model"
+)
+
+// GroupValue - значение поля field записи u для group_by, в том числе вычисляемого;
+// false - по полю не группируют
+func GroupValue(u model.User, field string) (string, bool) {
+	switch field {
+	case "Gender":
+		return u.Gender, true
+	case "Age":
+		return strconv.Itoa(u.Age), true
+	case "Company":
+		return u.Company, true
+	case "Name":
+		return u.Name, true
+	case "Email":
+		return u.Email, true
+	case "Phone":
+		return u.Phone, true
+	case "Address":
+		return u.Address, true
+	}
+	if f, ok := lookupField(field); ok {
+		return f.format(u), true
+	}
+	return "", false
+}
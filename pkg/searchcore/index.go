@@ -0,0 +1,58 @@
+package searchcore
+
+import (
+	"sort"
+
+	"final_task_golang/pkg/model"
+)
+
+// SortKey - поле и направление сортировки, для каждого строится свой индекс
+type SortKey struct {
+	Field string
+	Desc  bool
+	// локаль сравнения имён, пусто - побайтово. Индексы с локалью строит collatedIndexes
+	Locale string
+}
+
+var sortFields = []string{"Id", "Name", "Age", "Email", "Phone", "Company", "Address"}
+
+// buildSortIndexes строит заранее отсортированные позиции пользователей по всем полям и направлениям.
+// Индексы пересчитываются при каждом изменении датасета, зато поиск с сортировкой
+// не сортирует совпадения на каждый запрос, а просто идёт по нужному индексу
+func buildSortIndexes(users []model.User) map[SortKey][]int {
+	indexes := make(map[SortKey][]int, 2*len(sortFields))
+	for _, field := range sortFields {
+		less, _ := OrderLess(field)
+		for _, desc := range []bool{false, true} {
+			positions := make([]int, len(users))
+			for i := range positions {
+				positions[i] = i
+			}
+			// stable - при равенстве ключей сохраняется порядок датасета, как и при сортировке на лету
+			sort.SliceStable(positions, func(i, j int) bool {
+				if desc {
+					return less(users[positions[j]], users[positions[i]])
+				}
+				return less(users[positions[i]], users[positions[j]])
+			})
+			indexes[SortKey{Field: field, Desc: desc}] = positions
+		}
+	}
+	return indexes
+}
+
+// SortKey возвращает индекс, нужный запросу; false - сортировать не нужно
+func (q Query) SortKey() (SortKey, bool) {
+	if q.OrderBy == model.OrderByAsIs {
+		return SortKey{}, false
+	}
+	field := q.OrderField
+	if field == "" {
+		field = "Name"
+	}
+	key := SortKey{Field: field, Desc: q.OrderBy == model.OrderByDesc}
+	if field == "Name" {
+		key.Locale = q.OrderLocale
+	}
+	return key, true
+}
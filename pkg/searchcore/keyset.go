@@ -0,0 +1,111 @@
+package searchcore
+
+import (
+	"sort"
+	"strconv"
+
+	"final_task_golang/pkg/model"
+)
+
+// validateCursor проверяет after_id и after_value: Id - число, значение числового поля - тоже
+func (q Query) validateCursor() error {
+	if q.AfterID == "" {
+		if q.AfterValue != "" {
+			return model.ErrBadCursor
+		}
+		return nil
+	}
+	if _, err := strconv.Atoi(q.AfterID); err != nil {
+		return model.ErrBadCursor
+	}
+	if key, ok := q.SortKey(); ok && q.AfterValue != "" {
+		if _, ok := setSortField(model.User{}, key.Field, q.AfterValue); !ok {
+			return model.ErrBadCursor
+		}
+	}
+	return nil
+}
+
+// setSortField возвращает u с полем сортировки field, разобранным из value
+func setSortField(u model.User, field, value string) (model.User, bool) {
+	var err error
+	switch field {
+	case "Id":
+		u.Id, err = strconv.Atoi(value)
+	case "Age":
+		u.Age, err = strconv.Atoi(value)
+	case "Name", "":
+		u.Name = value
+	case "Email":
+		u.Email = value
+	case "Phone":
+		u.Phone = value
+	case "Company":
+		u.Company = value
+	case "Address":
+		u.Address = value
+	default:
+		return u, false
+	}
+	return u, err == nil
+}
+
+// keysetStart - с какого места order (nil - датасет по порядку) начинается выдача за курсором q.
+// Порядок выдачи - значение поля сортировки, при равенстве - позиция в датасете, так что
+// место курсора ищется бинарным поиском и обход стоит O(limit), а не O(offset+limit).
+// Запись курсора, которой уже нет, заменяет AfterValue: тогда записи с тем же значением
+// могут повториться, но не пропадут. Без неё и без AfterValue - model.ErrBadCursor
+func keysetStart(plan *queryPlan, q Query, d *dataset, order []int) (int, error) {
+	id, _ := strconv.Atoi(q.AfterID)
+	pos, found := d.byID[id]
+	if !found {
+		pos = -1
+	}
+
+	if !plan.sorted {
+		if !found {
+			return 0, model.ErrBadCursor
+		}
+		if order == nil {
+			return pos + 1, nil
+		}
+		return sort.Search(len(order), func(k int) bool { return order[k] > pos }), nil
+	}
+
+	if plan.sort.Locale != "" || plan.scorer != nil {
+		// у индекса с локалью и оценок свой порядок, курсор ищем по позиции записи
+		if !found {
+			return 0, model.ErrBadCursor
+		}
+		for k, i := range order {
+			if i == pos {
+				return k + 1, nil
+			}
+		}
+		return 0, model.ErrBadCursor
+	}
+
+	var cursor model.User
+	switch {
+	case q.AfterValue != "":
+		cursor, _ = setSortField(model.User{}, plan.sort.Field, q.AfterValue)
+	case found:
+		cursor = d.users[pos]
+	default:
+		return 0, model.ErrBadCursor
+	}
+	less, _ := OrderLess(plan.sort.Field)
+	return sort.Search(len(order), func(k int) bool {
+		lhs, rhs := cursor, d.users[order[k]]
+		if plan.sort.Desc {
+			lhs, rhs = rhs, lhs
+		}
+		if less(lhs, rhs) {
+			return true
+		}
+		if less(rhs, lhs) {
+			return false
+		}
+		return order[k] > pos
+	}), nil
+}
@@ -0,0 +1,114 @@
+package searchcore
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+// sortValue - значение поля сортировки u в виде after_value
+func sortValue(u model.User, field string) string {
+	switch field {
+	case "Id":
+		return strconv.Itoa(u.Id)
+	case "Age":
+		return strconv.Itoa(u.Age)
+	case "Company":
+		return u.Company
+	}
+	return u.Name
+}
+
+// листание курсором должно дать ровно ту же выдачу, что и один запрос без лимита
+func TestKeysetMatchesFullResult(t *testing.T) {
+	users := testUsers(300)
+	for _, cfg := range []Config{{}, {OrderLocale: "sv"}} {
+		e := newTestEngine(users, cfg)
+		for _, base := range []Query{
+			{},
+			{Query: "nisi"},
+			{OrderField: "Age", OrderBy: model.OrderByAsc},
+			{OrderField: "Age", OrderBy: model.OrderByDesc, Gender: "female"},
+			{OrderField: "Name", OrderBy: model.OrderByAsc, AgeMin: 30, AgeMax: 40},
+			{OrderField: "Company", OrderBy: model.OrderByDesc, IncludeDeleted: true},
+			{Gender: "male"},
+		} {
+			full, err := e.Search(context.Background(), base)
+			if err != nil {
+				t.Fatalf("Error : %v", err)
+			}
+			key, _ := base.SortKey()
+
+			var paged []model.User
+			q := base
+			q.Limit = 7
+			for {
+				page, err := e.Search(context.Background(), q)
+				if err != nil {
+					t.Fatalf("Error : %+v: %v", q, err)
+				}
+				paged = append(paged, page.Users...)
+				if !page.NextPage {
+					break
+				}
+				last := page.Users[len(page.Users)-1]
+				q.AfterID, q.AfterValue = strconv.Itoa(last.Id), sortValue(last, key.Field)
+				if !e.Explain(q).Keyset {
+					t.Fatalf("Error : %+v: keyset not explained", q)
+				}
+			}
+			if len(paged) != len(full.Users) {
+				t.Fatalf("Error : %s %+v: %d users != %d", cfg.OrderLocale, base, len(paged), len(full.Users))
+			}
+			for i := range paged {
+				if paged[i] != full.Users[i] {
+					t.Errorf("Error : %s %+v: %v != %v", cfg.OrderLocale, base, paged[i], full.Users[i])
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestKeysetStartCost(t *testing.T) {
+	users := testUsers(1000)
+	e := newTestEngine(users, Config{})
+	q := Query{OrderField: "Age", OrderBy: model.OrderByAsc, IncludeDeleted: true, Limit: 5}
+	full, _ := e.Search(context.Background(), Query{OrderField: "Age", OrderBy: model.OrderByAsc, IncludeDeleted: true})
+	cursor := full.Users[900]
+
+	q.AfterID, q.AfterValue = strconv.Itoa(cursor.Id), strconv.Itoa(cursor.Age)
+	res, err := e.Search(context.Background(), q)
+	if err != nil || res.Stats.Scanned != 6 || res.Users[0] != full.Users[901] {
+		t.Errorf("Error : unexpected result %+v %v", res, err)
+	}
+}
+
+func TestKeysetMissingRow(t *testing.T) {
+	users := testUsers(100)
+	e := newTestEngine(users, Config{})
+	q := Query{OrderField: "Age", OrderBy: model.OrderByAsc, Limit: 5, AfterID: "1000"}
+
+	if _, err := e.Search(context.Background(), q); err != model.ErrBadCursor {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+	// записи нет, но значение поля сортировки известно - выдача с первой записи с возрастом больше 30
+	// или равным ему: записи с тем же значением могут повториться
+	q.AfterValue = "30"
+	res, err := e.Search(context.Background(), q)
+	if err != nil || len(res.Users) != 5 || res.Users[0].Age != 30 {
+		t.Errorf("Error : unexpected result %+v %v", res, err)
+	}
+
+	for _, bad := range []Query{
+		{AfterID: "x"},
+		{AfterValue: "30"},
+		{OrderField: "Age", OrderBy: model.OrderByAsc, AfterID: "1", AfterValue: "old"},
+	} {
+		if err := bad.Validate(); err != model.ErrBadCursor {
+			t.Errorf("Error : %+v: unexpected error %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package searchcore
+
+import (
+	"strings"
+	"unicode"
+
+	"final_task_golang/pkg/model"
+)
+
+// языки About, которые различает DetectLanguage, коды ISO 639-1
+const (
+	LanguageEnglish = "en"
+	LanguageGerman  = "de"
+	LanguageFrench  = "fr"
+	LanguageSpanish = "es"
+	LanguageRussian = "ru"
+	// латынь - на ней lorem ipsum, а им часто заполняют тестовые датасеты
+	LanguageLatin = "la"
+	// LanguageUnknown - язык не определился: текст пустой, короткий или на языке не из списка
+	LanguageUnknown = "und"
+)
+
+// служебные слова языков: по их частоте DetectLanguage выбирает язык
+var languageProfiles = map[string]map[string]bool{
+	LanguageEnglish: wordSet("the", "and", "of", "to", "is", "in", "that", "it", "with", "for", "was", "on", "are", "as", "be", "this", "by", "at", "from", "have", "not", "or", "an", "he", "she", "they"),
+	LanguageGerman:  wordSet("der", "die", "und", "das", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "sich", "auf", "für", "ich", "von", "dem", "des", "auch", "im", "es", "sie", "wir", "er"),
+	LanguageFrench:  wordSet("le", "la", "les", "et", "est", "des", "une", "un", "du", "de", "en", "que", "qui", "pour", "dans", "pas", "sur", "au", "avec", "ce", "il", "elle", "nous", "sont"),
+	LanguageSpanish: wordSet("el", "la", "los", "las", "de", "que", "y", "en", "un", "una", "es", "por", "con", "para", "del", "se", "no", "al", "lo", "como", "más", "pero", "son", "su"),
+	LanguageLatin:   wordSet("et", "in", "est", "non", "ad", "ut", "cum", "sed", "ex", "qui", "quae", "quod", "sunt", "esse", "enim", "ab", "nec", "atque", "id"),
+}
+
+// стеммеры языков для TextLanguageAuto, остальные языки ищутся по словам без основ
+var languageStemmers = map[string]func(string) string{
+	LanguageEnglish: porterStem,
+}
+
+// сколько служебных слов нужно найти, чтобы поверить в язык
+const minLanguageEvidence = 2
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// KnownLanguage сообщает, что lang - код из DetectLanguage, включая LanguageUnknown
+func KnownLanguage(lang string) bool {
+	_, ok := languageProfiles[lang]
+	return ok || lang == LanguageRussian || lang == LanguageUnknown
+}
+
+// DetectLanguage определяет язык текста: кириллица - русский, иначе язык, служебных слов
+// которого в тексте больше всего. При ничьей или слишком малом их числе - LanguageUnknown
+func DetectLanguage(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	cyrillic := 0
+	hits := map[string]int{}
+	for _, w := range words {
+		if strings.IndexFunc(w, func(r rune) bool { return unicode.Is(unicode.Cyrillic, r) }) >= 0 {
+			cyrillic++
+			continue
+		}
+		for lang, profile := range languageProfiles {
+			if profile[w] {
+				hits[lang]++
+			}
+		}
+	}
+	if cyrillic > 0 && cyrillic*2 >= len(words) {
+		return LanguageRussian
+	}
+	best, bestHits, second := LanguageUnknown, 0, 0
+	for lang, n := range hits {
+		switch {
+		case n > bestHits:
+			best, bestHits, second = lang, n, bestHits
+		case n > second:
+			second = n
+		}
+	}
+	if bestHits < minLanguageEvidence || bestHits == second {
+		return LanguageUnknown
+	}
+	return best
+}
+
+// detectLanguages - язык About каждой записи, позиции совпадают с позициями в users
+func detectLanguages(users []model.User) []string {
+	langs := make([]string, len(users))
+	for i, u := range users {
+		langs[i] = DetectLanguage(u.About)
+	}
+	return langs
+}
@@ -0,0 +1,93 @@
+package searchcore
+
+import (
+	"context"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := []struct {
+		text, expected string
+	}{
+		{"He is a developer and the author of this library", LanguageEnglish},
+		{"Er ist nicht der Entwickler und die Bibliothek ist auch nicht von ihm", LanguageGerman},
+		{"Elle est développeuse dans une banque et elle aime les chats", LanguageFrench},
+		{"Los desarrolladores trabajan con el equipo para los clientes", LanguageSpanish},
+		{"Он работает разработчиком в банке", LanguageRussian},
+		{"Sit commodo consectetur minim amet ex. Eu nisi in exercitation culpa sint ut labore et dolore", LanguageLatin},
+		{"", LanguageUnknown},
+		{"Gardener", LanguageUnknown},
+		// по одному слову английского и латыни - ничья
+		{"the cat et canis", LanguageUnknown},
+	}
+	for _, c := range cases {
+		if lang := DetectLanguage(c.text); lang != c.expected {
+			t.Errorf("Error : %q detected as %q, want %q", c.text, lang, c.expected)
+		}
+	}
+	if !KnownLanguage(LanguageUnknown) || !KnownLanguage(LanguageRussian) || KnownLanguage("klingon") {
+		t.Errorf("Error : unexpected KnownLanguage")
+	}
+}
+
+func TestLanguageFilter(t *testing.T) {
+	users := []model.User{
+		{Id: 1, Name: "Ann", About: "She is one of the developers at the bank"},
+		{Id: 2, Name: "Bob", About: "Er ist einer der developers in der Bank"},
+		{Id: 3, Name: "Dan", About: "Gardener"},
+		{Id: 4, Name: "Eve", About: "Ut enim ad minim veniam, quis nostrud developers"},
+	}
+	cases := []struct {
+		q        Query
+		expected []int
+	}{
+		{Query{Lang: LanguageEnglish}, []int{1}},
+		{Query{Lang: LanguageUnknown}, []int{3}},
+		{Query{Lang: LanguageLatin, Query: "developers"}, []int{4}},
+		// английская запись приведена к основам, остальные - нет
+		{Query{Query: "developer"}, []int{1}},
+		{Query{Query: "developers"}, []int{1, 2, 4}},
+		{Query{Query: `"der developers"`}, []int{2}},
+	}
+	e := newTestEngine(users, Config{TextSearch: TextSearchConfig{Language: TextLanguageAuto}})
+	defer e.Close()
+	for _, c := range cases {
+		found, err := e.Search(context.Background(), c.q)
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		var ids []int
+		for _, u := range found.Users {
+			ids = append(ids, u.Id)
+		}
+		if !equalInts(ids, c.expected) {
+			t.Errorf("Error : %+v found %v, want %v", c.q, ids, c.expected)
+		}
+		// Match определяет язык на ходу и без индекса должен согласиться с фильтром
+		if c.q.Query == "" {
+			for _, u := range users {
+				if c.q.Match(u) != containsInt(c.expected, u.Id) {
+					t.Errorf("Error : Match %+v disagrees on %d", c.q, u.Id)
+				}
+			}
+		}
+	}
+
+	if langs := e.Languages(); langs[LanguageEnglish] != 1 || langs[LanguageGerman] != 1 || langs[LanguageLatin] != 1 || langs[LanguageUnknown] != 1 {
+		t.Errorf("Error : unexpected languages %v", langs)
+	}
+	if _, err := e.Search(context.Background(), Query{Lang: "klingon"}); err != model.ErrBadLang {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package searchcore
+
+import (
+	"sort"
+	"sync"
+
+	"final_task_golang/pkg/model"
+)
+
+// ScoreField - поле сортировки по оценке Scorer, см. Query.Matcher
+const ScoreField = "Score"
+
+// Matcher - своя логика поиска query, например с синонимами предметной области.
+// Заменяет встроенный поиск подстрокой и по терминам, фильтры по полям применяются как обычно
+type Matcher interface {
+	Match(u model.User) bool
+}
+
+// Scorer - Matcher, который ещё и оценивает совпадение: по оценке сортирует order_field=Score.
+// Без Query.Matcher оценивает встроенный textScorer
+type Scorer interface {
+	Matcher
+	Score(u model.User) float64
+}
+
+// MatcherFactory строит Matcher под текст query. Вызывается один раз на план запроса,
+// результат переиспользуется конкурентно и не должен меняться
+type MatcherFactory func(query string) Matcher
+
+// MatcherFunc - Matcher из функции
+type MatcherFunc func(u model.User) bool
+
+func (f MatcherFunc) Match(u model.User) bool {
+	return f(u)
+}
+
+var matchers = struct {
+	sync.RWMutex
+	byName map[string]MatcherFactory
+}{byName: map[string]MatcherFactory{}}
+
+// RegisterMatcher регистрирует стратегию поиска под именем name, запрос выбирает её в Query.Matcher.
+// Регистрировать надо до первого поиска: планы с прежней стратегией живут в кэше планов
+func RegisterMatcher(name string, factory MatcherFactory) {
+	matchers.Lock()
+	defer matchers.Unlock()
+	matchers.byName[name] = factory
+}
+
+// Matchers возвращает имена зарегистрированных стратегий по алфавиту
+func Matchers() []string {
+	matchers.RLock()
+	defer matchers.RUnlock()
+	names := make([]string, 0, len(matchers.byName))
+	for name := range matchers.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupMatcher(name string) (MatcherFactory, bool) {
+	matchers.RLock()
+	defer matchers.RUnlock()
+	factory, ok := matchers.byName[name]
+	return factory, ok
+}
+
+// newMatcher строит стратегию q.Matcher под q.Query, nil - стратегия не задана или не найдена
+func (q Query) newMatcher() Matcher {
+	if q.Matcher == "" {
+		return nil
+	}
+	factory, ok := lookupMatcher(q.Matcher)
+	if !ok {
+		return nil
+	}
+	return factory(q.Query)
+}
+
+// validateMatcher проверяет, что стратегия зарегистрирована, а сортировка по Score - что она умеет оценивать
+func (q Query) validateMatcher() error {
+	if q.Matcher != "" {
+		if _, ok := lookupMatcher(q.Matcher); !ok {
+			return model.ErrBadMatcher
+		}
+	}
+	if key, ok := q.SortKey(); ok && key.Field == ScoreField {
+		if _, ok := q.newMatcher().(Scorer); !ok && q.Matcher != "" {
+			return model.ErrBadOrderField
+		}
+		if q.AfterValue != "" {
+			return model.ErrBadCursor
+		}
+	}
+	return nil
+}
+
+// scoreOrder - позиции подходящих под match записей из order (nil - весь датасет),
+// упорядоченные по оценке, при равенстве - в порядке обхода. На завершённом ctx (check)
+// бросает оценку, тогда результат неполный
+func scoreOrder(scorer Scorer, desc bool, users []model.User, order []int, match func(i int) bool, check *ctxCheck) []int {
+	positions := []int{}
+	scores := map[int]float64{}
+	add := func(i int) bool {
+		if check.canceled() {
+			return false
+		}
+		if match(i) {
+			positions = append(positions, i)
+			scores[i] = scorer.Score(users[i])
+		}
+		return true
+	}
+	if order == nil {
+		for i := range users {
+			if !add(i) {
+				return nil
+			}
+		}
+	} else {
+		for _, i := range order {
+			if !add(i) {
+				return nil
+			}
+		}
+	}
+	sort.SliceStable(positions, func(a, b int) bool {
+		if check.canceled() {
+			return false
+		}
+		if desc {
+			return scores[positions[a]] > scores[positions[b]]
+		}
+		return scores[positions[a]] < scores[positions[b]]
+	})
+	return positions
+}
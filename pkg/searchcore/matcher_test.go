@@ -0,0 +1,88 @@
+package searchcore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+// synonymMatcher ищет query или любой из его синонимов в About, оценка - сколько их нашлось
+type synonymMatcher []string
+
+func (m synonymMatcher) Match(u model.User) bool {
+	return m.Score(u) > 0
+}
+
+func (m synonymMatcher) Score(u model.User) float64 {
+	score := 0.0
+	for _, word := range m {
+		if strings.Contains(u.About, word) {
+			score++
+		}
+	}
+	return score
+}
+
+func init() {
+	synonyms := map[string][]string{"nisi": {"golang"}}
+	RegisterMatcher("test-synonyms", func(query string) Matcher {
+		return synonymMatcher(append([]string{query}, synonyms[query]...))
+	})
+	// без оценки: сортировать по Score с ним нельзя
+	RegisterMatcher("test-plain", func(query string) Matcher {
+		return MatcherFunc(func(u model.User) bool { return true })
+	})
+}
+
+func TestMatcherRegistry(t *testing.T) {
+	users := testUsers(300)
+	e := newTestEngine(users, Config{})
+	ctx := context.Background()
+
+	plain, _ := e.Search(ctx, Query{Query: "nisi"})
+	custom, err := e.Search(ctx, Query{Query: "nisi", Matcher: "test-synonyms"})
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if len(custom.Users) <= len(plain.Users) {
+		t.Errorf("Error : synonyms found %d, plain %d", len(custom.Users), len(plain.Users))
+	}
+
+	// сортировка по оценке: сначала записи с обоими словами, фильтры и курсор работают как обычно
+	q := Query{Query: "nisi", Matcher: "test-synonyms", OrderField: ScoreField, OrderBy: model.OrderByDesc, Gender: "female"}
+	full, err := e.Search(ctx, q)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	m := synonymMatcher{"nisi", "golang"}
+	for i, u := range full.Users {
+		if u.Gender != "female" || i > 0 && m.Score(full.Users[i-1]) < m.Score(u) {
+			t.Fatalf("Error : unexpected order at %d: %v", i, full.Users)
+		}
+	}
+	q.Limit = 5
+	page, _ := e.Search(ctx, q)
+	q.AfterID = sortValue(page.Users[4], "Id")
+	next, _ := e.Search(ctx, q)
+	if len(full.Users) < 10 || next.Users[0] != full.Users[5] {
+		t.Errorf("Error : cursor page differs from full result")
+	}
+	if exp := e.Explain(q); exp.Sort != SortScore || exp.Text != "matcher" {
+		t.Errorf("Error : unexpected explanation %+v", exp)
+	}
+
+	if _, err := e.Search(ctx, Query{Matcher: "unknown"}); err != model.ErrBadMatcher {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+	if _, err := e.Search(ctx, Query{Matcher: "test-plain", OrderField: ScoreField, OrderBy: model.OrderByAsc}); err != model.ErrBadOrderField {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+	// стратегии регистрируют и другие тесты пакета
+	if names := Matchers(); !sort.StringsAreSorted(names) || sort.SearchStrings(names, "test-plain") == len(names) ||
+		names[sort.SearchStrings(names, "test-plain")] != "test-plain" {
+		t.Errorf("Error : unexpected matchers %v", names)
+	}
+}
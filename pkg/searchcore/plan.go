@@ -0,0 +1,284 @@
+package searchcore
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// сколько планов держит кэш, если Config.PlanCacheSize не задан
+const defaultPlanCacheSize = 1024
+
+// queryPlan - запрос, разобранный один раз: сортировка, фразы и термины query.
+// От датасета не зависит, поэтому переживает Reload и правки записей
+type queryPlan struct {
+	q      Query
+	sort   SortKey
+	sorted bool
+
+	text     textQuery
+	analyzer *textAnalyzer
+	// phrases[n] - термины text.phrases[n]
+	phrases [][]string
+	// термины text.rest, пусто - text.rest ищется подстрокой
+	terms []string
+	// термины text.rest и фраз анализатором Name, пусто - в Name ищется подстрока
+	nameTerms   []string
+	namePhrases [][]string
+	// стратегия Query.Matcher вместо поиска text, nil - встроенный поиск
+	custom Matcher
+	// оценка для сортировки по ScoreField: Scorer стратегии или textScorer, nil - сортировка по индексу
+	scorer Scorer
+
+	// синонимы, с которыми собран план, см. expand
+	synonyms *synonymIndex
+	// alternatives[k] - термины, любой из которых заменяет terms[k]
+	alternatives [][]string
+	// синонимы text.rest для поиска подстрокой
+	restSynonyms []string
+	// разобранный Query.Fields
+	fields []fieldFilter
+}
+
+// newQueryPlan разбирает q. analyzer и nameAnalyzer - анализаторы текстовых индексов About и Name,
+// nil - индекса нет; defaultLocale подставляется в сортировку по Name без своей локали
+func newQueryPlan(q Query, analyzer, nameAnalyzer *textAnalyzer, defaultLocale string) *queryPlan {
+	p := &queryPlan{q: q, text: parseTextQuery(q.Query), analyzer: literalAnalyzer}
+	if p.sort, p.sorted = q.SortKey(); p.sorted && p.sort.Field == "Name" && p.sort.Locale == "" {
+		p.sort.Locale = defaultLocale
+	}
+	if analyzer != nil {
+		p.analyzer = analyzer
+		if p.text.rest != "" {
+			p.terms = analyzer.terms(p.text.rest)
+		}
+	}
+	if p.custom = q.newMatcher(); p.sorted && p.sort.Field == ScoreField {
+		p.scorer, _ = p.custom.(Scorer)
+	}
+	p.fields, _ = parseFieldFilters(q.Fields)
+	p.phrases = make([][]string, len(p.text.phrases))
+	for n, phrase := range p.text.phrases {
+		p.phrases[n] = p.analyzer.terms(phrase)
+	}
+	if nameAnalyzer != nil {
+		p.nameTerms = nameAnalyzer.terms(p.text.rest)
+		p.namePhrases = make([][]string, len(p.text.phrases))
+		for n, phrase := range p.text.phrases {
+			p.namePhrases[n] = nameAnalyzer.terms(phrase)
+		}
+	}
+	return p
+}
+
+// covers сообщает, что matcher пропустит всех кандидатов обхода access, кроме удалённых:
+// текста нет, а фильтры, если есть, целиком отвечает индекс access
+func (p *queryPlan) covers(access string) bool {
+	q := p.q
+	if p.custom != nil || p.text.rest != "" || len(p.phrases) > 0 || q.Lang != "" || q.Fields != "" || q.Email != "" || q.Phone != "" || q.Company != "" || q.Address != "" {
+		return false
+	}
+	if q.Gender != "" && access != AccessGender {
+		return false
+	}
+	return q.AgeMin <= 0 && q.AgeMax <= 0 || access == AccessAge
+}
+
+// matcher возвращает проверку пользователя на позиции i в d.users. Чего нет в d - языков
+// или значений вычисляемых полей, - считается на ходу.
+//
+// Фразы в кавычках ищутся в Name или About как слова, идущие подряд. Остальной текст -
+// подстрокой в Name или About; с текстовым индексом - по терминам About
+// (если там одни стоп-слова, то всё же подстрокой)
+func (p *queryPlan) matcher(d *dataset) func(i int) bool {
+	users, langs, text := d.users, d.langs, d.text
+	rest := p.text.rest
+	// остаток query в Name: по терминам, если у Name свой анализатор, иначе подстрокой
+	matchName := func(i int) bool {
+		if d.nameText != nil && len(p.nameTerms) > 0 {
+			return d.nameText.containsAll(i, p.nameTerms)
+		}
+		return strings.Contains(users[i].Name, rest)
+	}
+	// термины query на языке каждой записи, см. textIndex.localize
+	terms := text.localize(p.terms)
+	phrases := make([]func(int) []string, len(p.phrases))
+	for n, phrase := range p.phrases {
+		phrases[n] = text.localize(phrase)
+	}
+	alternatives := make([]func(int) []string, len(p.alternatives))
+	for k, alts := range p.alternatives {
+		alternatives[k] = text.localize(alts)
+	}
+	matchPhrase := func(i, n int) bool {
+		el := users[i]
+		phrase := p.phrases[n]
+		if len(phrase) == 0 {
+			// фраза из одних стоп-слов
+			return strings.Contains(el.About, p.text.phrases[n]) || strings.Contains(el.Name, p.text.phrases[n])
+		}
+		if d.nameText != nil && len(p.namePhrases[n]) > 0 {
+			if d.nameText.containsPhrase(i, p.namePhrases[n]) {
+				return true
+			}
+		} else if containsSequence(p.analyzer.terms(el.Name), phrase) {
+			return true
+		}
+		if text != nil {
+			return text.containsPhrase(i, phrases[n](i))
+		}
+		return containsSequence(p.analyzer.terms(el.About), phrase)
+	}
+
+	return func(i int) bool {
+		el := users[i]
+		if !p.q.matchFilters(el) {
+			return false
+		}
+		if p.q.Lang != "" {
+			lang := ""
+			if langs != nil {
+				lang = langs[i]
+			} else {
+				lang = DetectLanguage(el.About)
+			}
+			if lang != p.q.Lang {
+				return false
+			}
+		}
+		for _, ff := range p.fields {
+			if !ff.match(d.fields, users, i) {
+				return false
+			}
+		}
+		if p.custom != nil {
+			return p.custom.Match(el)
+		}
+		for n := range p.phrases {
+			if !matchPhrase(i, n) {
+				return false
+			}
+		}
+		switch {
+		case rest == "":
+			return true
+		case text != nil && len(p.alternatives) > 0:
+			if matchName(i) {
+				return true
+			}
+			for _, alts := range alternatives {
+				if !text.containsAny(i, alts(i)) {
+					return false
+				}
+			}
+			return true
+		case text != nil && len(p.terms) > 0:
+			return matchName(i) || text.containsAll(i, terms(i))
+		}
+		if strings.Contains(el.About, rest) || matchName(i) {
+			return true
+		}
+		for _, word := range p.restSynonyms {
+			if strings.Contains(el.About, word) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// planKey - q без параметров, не влияющих на план: страница и обезличивание применяются при обходе
+func (q Query) planKey() Query {
+	q = q.Normalize()
+	q.Limit, q.Offset = 0, 0
+	q.AllowPartial, q.Redact = false, false
+	q.AfterID, q.AfterValue = "", ""
+	return q
+}
+
+// planCache - LRU разобранных запросов. В отличие от resultCache при смене датасета не сбрасывается
+type planCache struct {
+	mu    sync.Mutex
+	size  int
+	items map[Query]*list.Element
+	order *list.List
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// CacheStats - счётчики кэша планов
+type CacheStats struct {
+	Size      int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+type cachedPlan struct {
+	key  Query
+	plan *queryPlan
+}
+
+func newPlanCache(size int) *planCache {
+	return &planCache{
+		size:  size,
+		items: map[Query]*list.Element{},
+		order: list.New(),
+	}
+}
+
+func (c *planCache) get(key Query) (*queryPlan, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.order.MoveToFront(el)
+	return el.Value.(cachedPlan).plan, true
+}
+
+func (c *planCache) put(key Query, plan *queryPlan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		// план пересобран, например под новые синонимы
+		el.Value = cachedPlan{key: key, plan: plan}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(cachedPlan{key: key, plan: plan})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(cachedPlan).key)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// contains проверяет, есть ли план, не трогая счётчики и порядок вытеснения
+func (c *planCache) contains(key Query) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.items[key]
+	return ok
+}
+
+func (c *planCache) stats() CacheStats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Size:      size,
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+	}
+}
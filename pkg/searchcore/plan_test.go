@@ -0,0 +1,35 @@
+package searchcore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPlanCache(t *testing.T) {
+	e := newTestEngine(testUsers(100), Config{PlanCacheSize: 2})
+
+	p := e.plan(Query{Query: `"nisi"`, OrderField: "Age", OrderBy: 1, Limit: 5})
+	// страница и обезличивание на план не влияют
+	if e.plan(Query{Query: `"nisi"`, OrderField: "Age", OrderBy: 1, Limit: 10, Offset: 3, Redact: true}) != p {
+		t.Errorf("Error : plan not reused")
+	}
+	if e.plan(Query{Query: `"nisi"`, OrderField: "Age", OrderBy: -1}) == p {
+		t.Errorf("Error : plan reused for another order")
+	}
+	e.plan(Query{Query: "other"})
+	if st := e.PlanCacheStats(); st.Size != 2 || st.Hits != 1 || st.Misses != 3 || st.Evictions != 1 {
+		t.Errorf("Error : unexpected stats %+v", st)
+	}
+
+	// план переживает смену датасета
+	e.SetUsers(testUsers(200))
+	res, err := e.Search(context.Background(), Query{Query: "other"})
+	if err != nil || len(res.Users) != 0 || e.PlanCacheStats().Hits != 2 {
+		t.Errorf("Error : unexpected result %v %v %+v", len(res.Users), err, e.PlanCacheStats())
+	}
+
+	e = newTestEngine(testUsers(100), Config{PlanCacheSize: -1})
+	if _, err := e.Search(context.Background(), Query{Query: "nisi"}); err != nil || e.PlanCacheStats().Misses != 0 {
+		t.Errorf("Error : unexpected result %v %+v", err, e.PlanCacheStats())
+	}
+}
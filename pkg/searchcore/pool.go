@@ -0,0 +1,88 @@
+package searchcore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// меньшие датасеты быстрее отфильтровать в одной горутине
+	parallelMinRows   = 4096
+	parallelShardSize = 1024
+)
+
+// workerPool - общий на сервер пул горутин фильтрации, ограничивает параллелизм
+// суммарно по всем одновременным запросам
+type workerPool struct {
+	size  int
+	tasks chan func()
+	// закрывается в close. Сам tasks не закрывается никогда: поиск, начавшийся до close,
+	// ещё может отправлять в него шарды
+	done chan struct{}
+	once sync.Once
+}
+
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{
+		size:  size,
+		tasks: make(chan func()),
+		done:  make(chan struct{}),
+	}
+	for i := 0; i < size; i++ {
+		go func() {
+			for {
+				select {
+				case task := <-p.tasks:
+					task()
+				case <-p.done:
+					return
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// close останавливает горутины пула. Шарды, отправленные после него, проверяются в горутине поиска
+func (p *workerPool) close() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+}
+
+// submit отдаёт task свободной горутине пула, а если пул закрыт - выполняет сам
+func (p *workerPool) submit(task func()) {
+	select {
+	case p.tasks <- task:
+	case <-p.done:
+		task()
+	}
+}
+
+// matchParallel проверяет match(at(k)) для k из [from, to) шардами на пуле и пишет результат в matched[k-from].
+// false - ctx завершился и шарды проверены не до конца
+func (p *workerPool) matchParallel(ctx context.Context, match func(int) bool, at func(int) int, from, to int, matched []bool) bool {
+	var wg sync.WaitGroup
+	var canceled atomic.Bool
+	for shard := from; shard < to; shard += parallelShardSize {
+		shardEnd := shard + parallelShardSize
+		if shardEnd > to {
+			shardEnd = to
+		}
+		wg.Add(1)
+		start := shard
+		p.submit(func() {
+			defer wg.Done()
+			for k := start; k < shardEnd; k++ {
+				if (k-start)%deadlineCheckEvery == 0 && ctx.Err() != nil {
+					canceled.Store(true)
+					return
+				}
+				matched[k-from] = match(at(k))
+			}
+		})
+	}
+	wg.Wait()
+	return !canceled.Load()
+}
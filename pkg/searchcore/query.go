@@ -0,0 +1,224 @@
+package searchcore
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+// Query - разобранные параметры поиска, не зависят от транспорта (http, grpc, graphql)
+type Query struct {
+	Query      string
+	OrderField string
+	OrderBy    int
+	// локаль сортировки по Name (BCP 47), пусто - Config.OrderLocale
+	OrderLocale    string
+	Limit          int
+	Offset         int
+	IncludeDeleted bool
+	// при истечении дедлайна вернуть найденное к этому моменту вместо ошибки
+	AllowPartial bool
+	// обезличить выдачу, выставляется транспортом по правам токена. Ядро выдачу не обезличивает:
+	// поле входит в ключи кэшей выдачи, но не в план
+	Redact bool
+
+	// фильтры по точным полям, пустые значения не фильтруют
+	Gender string
+	AgeMin int
+	AgeMax int
+	// Email и Company сравниваются без учёта регистра, Address - вхождение подстроки
+	Email   string
+	Phone   string
+	Company string
+	Address string
+	// язык About, см. DetectLanguage; LanguageUnknown - записи, язык которых не определился
+	Lang string
+	// фильтры по вычисляемым полям (RegisterField) как url-запрос "AgeBucket=30&NameInitial=A":
+	// значение поля должно совпасть. Строка, а не map, чтобы Query оставался ключом кэшей
+	Fields string
+
+	// стратегия поиска query из RegisterMatcher вместо встроенной, пусто - встроенная
+	Matcher string
+	// веса полей во встроенной оценке для order_field=Score, 0 - Config.Boosts
+	BoostName  float64
+	BoostAbout float64
+	// поле, по которому выдача делится на группы по limit записей в каждой, см. GroupValue
+	GroupBy string
+
+	// keyset-пагинация: выдача начинается сразу за записью с Id AfterID в порядке выдачи,
+	// пусто - с начала. AfterValue - значение поля сортировки этой записи на прошлой странице:
+	// по нему курсор переживает правку и удаление записи. Offset отсчитывается от курсора
+	AfterID    string
+	AfterValue string
+}
+
+// Match проверяет одного пользователя, без индексов и кэша планов
+func (q Query) Match(el model.User) bool {
+	return newQueryPlan(q, nil, nil, "").matcher(&dataset{users: []model.User{el}})(0)
+}
+
+// matchFilters проверяет всё, кроме query
+func (q Query) matchFilters(el model.User) bool {
+	if el.Deleted && !q.IncludeDeleted {
+		return false
+	}
+	if q.Gender != "" && el.Gender != q.Gender {
+		return false
+	}
+	if q.AgeMin > 0 && el.Age < q.AgeMin || q.AgeMax > 0 && el.Age > q.AgeMax {
+		return false
+	}
+	if q.Email != "" && !strings.EqualFold(el.Email, q.Email) ||
+		q.Company != "" && !strings.EqualFold(el.Company, q.Company) ||
+		q.Phone != "" && el.Phone != q.Phone ||
+		q.Address != "" && !strings.Contains(el.Address, q.Address) {
+		return false
+	}
+	return true
+}
+
+// Params возвращает непустые параметры запроса в том виде, в каком они приходят в url
+func (q Query) Params() map[string]string {
+	params := map[string]string{}
+	add := func(name, value string) {
+		if value != "" && value != "0" && value != "false" {
+			params[name] = value
+		}
+	}
+	add("query", q.Query)
+	add("order_field", q.OrderField)
+	add("order_by", strconv.Itoa(q.OrderBy))
+	add("order_locale", q.OrderLocale)
+	add("limit", strconv.Itoa(q.Limit))
+	add("offset", strconv.Itoa(q.Offset))
+	add("include_deleted", strconv.FormatBool(q.IncludeDeleted))
+	add("allow_partial", strconv.FormatBool(q.AllowPartial))
+	add("gender", q.Gender)
+	add("lang", q.Lang)
+	fields, _ := url.ParseQuery(q.Fields)
+	for name := range fields {
+		add("field."+name, fields.Get(name))
+	}
+	add("age_min", strconv.Itoa(q.AgeMin))
+	add("age_max", strconv.Itoa(q.AgeMax))
+	add("email", q.Email)
+	add("phone", q.Phone)
+	add("company", q.Company)
+	add("address", q.Address)
+	add("matcher", q.Matcher)
+	add("boost_name", strconv.FormatFloat(q.BoostName, 'g', -1, 64))
+	add("boost_about", strconv.FormatFloat(q.BoostAbout, 'g', -1, 64))
+	add("group_by", q.GroupBy)
+	add("after_id", q.AfterID)
+	add("after_value", q.AfterValue)
+	return params
+}
+
+// Normalize приводит эквивалентные запросы к одному виду, используется как ключ кэша
+func (q Query) Normalize() Query {
+	if q.OrderBy == model.OrderByAsIs {
+		q.OrderField = ""
+	} else if q.OrderField == "" {
+		q.OrderField = "Name"
+	}
+	if q.OrderBy != model.OrderByAsIs && q.OrderBy != model.OrderByDesc {
+		q.OrderBy = model.OrderByAsc
+	}
+	if q.Limit <= 0 {
+		q.Limit, q.Offset = 0, 0
+	}
+	if q.AfterID == "" || q.OrderBy == model.OrderByAsIs {
+		q.AfterValue = ""
+	}
+	if q.OrderField != "Name" {
+		q.OrderLocale = ""
+	} else if locale, err := parseOrderLocale(q.OrderLocale); err == nil && q.OrderLocale != "" {
+		q.OrderLocale = locale
+	}
+	return q
+}
+
+// Validate - общая для всех транспортов проверка параметров поиска
+func (q Query) Validate() error {
+	if q.Limit < 0 {
+		return model.ErrBadLimit
+	}
+	if q.Offset < 0 {
+		return model.ErrBadOffset
+	}
+	if q.BoostName < 0 || q.BoostAbout < 0 {
+		return model.ErrBadBoost
+	}
+	if q.Lang != "" && !KnownLanguage(q.Lang) {
+		return model.ErrBadLang
+	}
+	if _, ok := parseFieldFilters(q.Fields); !ok {
+		return model.ErrBadField
+	}
+	if q.GroupBy != "" {
+		if _, ok := GroupValue(model.User{}, q.GroupBy); !ok || q.Offset > 0 || q.AfterID != "" {
+			return model.ErrBadGroupBy
+		}
+	}
+	if err := q.validateMatcher(); err != nil {
+		return err
+	}
+	if key, ok := q.SortKey(); ok && key.Field != ScoreField {
+		if _, ok := OrderLess(key.Field); !ok {
+			return model.ErrBadOrderField
+		}
+	}
+	if q.OrderLocale != "" {
+		if _, err := parseOrderLocale(q.OrderLocale); err != nil {
+			return err
+		}
+	}
+	return q.validateCursor()
+}
+
+// OrderLess - сравнение пользователей по полю сортировки, своему или вычисляемому; false - поля нет
+func OrderLess(field string) (func(lhs model.User, rhs model.User) bool, bool) {
+	if less, ok := nativeOrderLess(field); ok {
+		return less, true
+	}
+	if f, ok := lookupField(field); ok {
+		return f.less(), true
+	}
+	return nil, false
+}
+
+func nativeOrderLess(field string) (func(lhs model.User, rhs model.User) bool, bool) {
+	switch field {
+	case "Id":
+		return func(lhs model.User, rhs model.User) bool {
+			return lhs.Id < rhs.Id
+		}, true
+	case "Name", "":
+		return func(lhs model.User, rhs model.User) bool {
+			return lhs.Name < rhs.Name
+		}, true
+	case "Age":
+		return func(lhs model.User, rhs model.User) bool {
+			return lhs.Age < rhs.Age
+		}, true
+	case "Email":
+		return func(lhs model.User, rhs model.User) bool {
+			return lhs.Email < rhs.Email
+		}, true
+	case "Phone":
+		return func(lhs model.User, rhs model.User) bool {
+			return lhs.Phone < rhs.Phone
+		}, true
+	case "Company":
+		return func(lhs model.User, rhs model.User) bool {
+			return lhs.Company < rhs.Company
+		}, true
+	case "Address":
+		return func(lhs model.User, rhs model.User) bool {
+			return lhs.Address < rhs.Address
+		}, true
+	}
+	return nil, false
+}
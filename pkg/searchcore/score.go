@@ -0,0 +1,78 @@
+package searchcore
+
+import (
+	"final_task_golang/pkg/model"
+)
+
+// Boosts - во сколько раз совпадение слова query в поле весит больше совпадения в About
+// при сортировке по ScoreField без своего Matcher, 0 - 1. Например Name: 3
+type Boosts struct {
+	Name  float64
+	About float64
+}
+
+// boosts - веса q поверх весов по умолчанию
+func (q Query) boosts(defaults Boosts) Boosts {
+	b := defaults
+	if q.BoostName > 0 {
+		b.Name = q.BoostName
+	}
+	if q.BoostAbout > 0 {
+		b.About = q.BoostAbout
+	}
+	if b.Name <= 0 {
+		b.Name = 1
+	}
+	if b.About <= 0 {
+		b.About = 1
+	}
+	return b
+}
+
+// textScorer - встроенная оценка: сколько раз слова query (или их синонимы) встречаются
+// в Name и About, с весами полей
+type textScorer struct {
+	analyzer *textAnalyzer
+	// groups[k] - слово query и его синонимы
+	groups [][]string
+	boosts Boosts
+}
+
+func newTextScorer(p *queryPlan, boosts Boosts) *textScorer {
+	s := &textScorer{analyzer: p.analyzer, boosts: boosts}
+	words := p.analyzer.terms(p.text.rest)
+	for _, phrase := range p.phrases {
+		words = append(words, phrase...)
+	}
+	for _, word := range words {
+		group := []string{word}
+		if p.synonyms != nil {
+			if alts, ok := p.synonyms.terms[word]; ok {
+				group = alts
+			}
+		}
+		s.groups = append(s.groups, group)
+	}
+	return s
+}
+
+func (s *textScorer) Match(u model.User) bool {
+	return s.Score(u) > 0
+}
+
+func (s *textScorer) Score(u model.User) float64 {
+	count := func(text string) float64 {
+		n := 0
+		for _, term := range s.analyzer.terms(text) {
+			for _, group := range s.groups {
+				for _, word := range group {
+					if word == term {
+						n++
+					}
+				}
+			}
+		}
+		return float64(n)
+	}
+	return s.boosts.Name*count(u.Name) + s.boosts.About*count(u.About)
+}
@@ -0,0 +1,58 @@
+package searchcore
+
+import (
+	"context"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestScoreBoosts(t *testing.T) {
+	users := []model.User{
+		{Id: 1, Name: "Ann", About: "go go go"},
+		{Id: 2, Name: "go Bob", About: "rust"},
+		{Id: 3, Name: "Kate", About: "go"},
+		{Id: 4, Name: "Tom", About: "java"},
+	}
+	ids := func(e *Engine, q Query) []int {
+		q.OrderField, q.OrderBy = ScoreField, model.OrderByDesc
+		res, err := e.Search(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		found := []int{}
+		for _, u := range res.Users {
+			found = append(found, u.Id)
+		}
+		return found
+	}
+	expect := func(got []int, want ...int) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("Error : %v != %v", got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("Error : %v != %v", got, want)
+				return
+			}
+		}
+	}
+
+	e := newTestEngine(users, Config{})
+	// без весов три совпадения в About важнее одного в Name, при равенстве - порядок датасета
+	expect(ids(e, Query{Query: "go"}), 1, 2, 3)
+	expect(ids(e, Query{Query: "go", BoostName: 5}), 2, 1, 3)
+
+	// веса из конфига, запрос их переопределяет
+	e = newTestEngine(users, Config{Boosts: Boosts{Name: 5}})
+	expect(ids(e, Query{Query: "go"}), 2, 1, 3)
+	expect(ids(e, Query{Query: "go", BoostName: 1, BoostAbout: 2}), 1, 3, 2)
+
+	if exp := e.Explain(Query{Query: "go", OrderField: ScoreField, OrderBy: model.OrderByDesc}); exp.Sort != SortScore {
+		t.Errorf("Error : unexpected sort %s", exp.Sort)
+	}
+	if _, err := e.Search(context.Background(), Query{Query: "go", BoostName: -1}); err != model.ErrBadBoost {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+}
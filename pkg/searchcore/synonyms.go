@@ -0,0 +1,118 @@
+package searchcore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Synonyms - наборы синонимов, например "dev, developer". Слово query находит в About
+// любое слово своего набора. С TextSearch сравниваются термины (после стемминга и стоп-слов),
+// без него - query целиком ищется подстрокой вместе с синонимами
+type Synonyms struct {
+	Sets [][]string
+}
+
+// ParseSynonyms читает наборы синонимов: по набору в строке, слова через запятую,
+// пустые строки и строки с # пропускаются
+func ParseSynonyms(r io.Reader) (*Synonyms, error) {
+	s := &Synonyms{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var set []string
+		for _, word := range strings.Split(line, ",") {
+			if word = strings.TrimSpace(word); word != "" {
+				set = append(set, word)
+			}
+		}
+		if len(set) < 2 {
+			return nil, fmt.Errorf("line %d: synonym set needs at least two words", n)
+		}
+		s.Sets = append(s.Sets, set)
+	}
+	return s, scanner.Err()
+}
+
+// LoadSynonyms читает наборы синонимов из файла, см. ParseSynonyms
+func LoadSynonyms(path string) (*Synonyms, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSynonyms(f)
+}
+
+// synonymIndex - Synonyms, разобранные анализатором Engine
+type synonymIndex struct {
+	// термин - все термины его наборов, включая его самого
+	terms map[string][]string
+	// слово в нижнем регистре - все слова его наборов как записаны, для поиска подстрокой
+	words map[string][]string
+}
+
+func buildSynonymIndex(s *Synonyms, analyzer *textAnalyzer) *synonymIndex {
+	idx := &synonymIndex{terms: map[string][]string{}, words: map[string][]string{}}
+	if s == nil {
+		return idx
+	}
+	addUniq := func(m map[string][]string, key, value string) {
+		for _, have := range m[key] {
+			if have == value {
+				return
+			}
+		}
+		m[key] = append(m[key], value)
+	}
+	for _, set := range s.Sets {
+		var terms []string
+		for _, word := range set {
+			for _, other := range set {
+				addUniq(idx.words, strings.ToLower(word), other)
+			}
+			// слова из нескольких терминов и из одних стоп-слов ищутся только подстрокой
+			if analyzer != nil {
+				if t := analyzer.terms(word); len(t) == 1 {
+					terms = append(terms, t[0])
+				}
+			}
+		}
+		for _, term := range terms {
+			for _, other := range terms {
+				addUniq(idx.terms, term, other)
+			}
+		}
+	}
+	return idx
+}
+
+// expand подставляет синонимы в план: каждому термину - его набор, тексту без терминов - слова набора
+func (p *queryPlan) expand(idx *synonymIndex) {
+	p.synonyms = idx
+	if idx == nil {
+		return
+	}
+	if !p.analyzer.synonyms {
+		// в цепочке About нет фильтра synonyms
+		return
+	}
+	alternatives := make([][]string, len(p.terms))
+	expanded := false
+	for k, term := range p.terms {
+		alternatives[k] = []string{term}
+		if alts, ok := idx.terms[term]; ok {
+			alternatives[k], expanded = alts, true
+		}
+	}
+	// без синонимов у терминов план ищет как раньше, через containsAll
+	if expanded {
+		p.alternatives = alternatives
+	}
+	p.restSynonyms = idx.words[strings.ToLower(strings.TrimSpace(p.text.rest))]
+}
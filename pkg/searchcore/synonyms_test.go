@@ -0,0 +1,75 @@
+package searchcore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestParseSynonyms(t *testing.T) {
+	s, err := ParseSynonyms(strings.NewReader("# комментарий\n\ndev, developer , programmer\njs,javascript\n"))
+	if err != nil || len(s.Sets) != 2 || len(s.Sets[0]) != 3 || s.Sets[0][1] != "developer" {
+		t.Fatalf("Error : unexpected synonyms %v %v", s, err)
+	}
+	if _, err := ParseSynonyms(strings.NewReader("dev\n")); err == nil {
+		t.Errorf("Error : set of one word accepted")
+	}
+}
+
+func TestSynonymsExpandQuery(t *testing.T) {
+	users := []model.User{
+		{Id: 1, About: "senior developers wanted"},
+		{Id: 2, About: "js and go"},
+		{Id: 3, About: "javascript developer"},
+		{Id: 4, About: "lorem ipsum"},
+	}
+	synonyms := &Synonyms{Sets: [][]string{{"dev", "developer"}, {"js", "javascript"}}}
+	ids := func(e *Engine, query string) []int {
+		res, err := e.Search(context.Background(), Query{Query: query})
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		found := []int{}
+		for _, u := range res.Users {
+			found = append(found, u.Id)
+		}
+		return found
+	}
+	same := func(got []int, want ...int) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	// по словам: термины запроса заменяются наборами, "dev" находит и "developers"
+	e := newTestEngine(users, Config{TextSearch: TextSearchConfig{Language: TextLanguageEnglish}, Synonyms: synonyms})
+	if got := ids(e, "dev"); !same(got, 1, 3) {
+		t.Errorf("Error : unexpected result %v", got)
+	}
+	if got := ids(e, "dev javascript"); !same(got, 3) {
+		t.Errorf("Error : unexpected result %v", got)
+	}
+	if got := ids(e, "js"); !same(got, 2, 3) {
+		t.Errorf("Error : unexpected result %v", got)
+	}
+
+	// подстрокой: query целиком или любой его синоним
+	e = newTestEngine(users, Config{Synonyms: synonyms})
+	if got := ids(e, "JS"); !same(got, 2, 3) {
+		t.Errorf("Error : unexpected result %v", got)
+	}
+
+	// план из кэша пересобирается под новые синонимы
+	e.SetSynonyms(nil)
+	if got := ids(e, "JS"); len(got) != 0 {
+		t.Errorf("Error : stale synonyms %v", got)
+	}
+}
@@ -0,0 +1,258 @@
+package searchcore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+// языки текстового поиска, см. TextSearchConfig
+const (
+	TextLanguageEnglish = "english"
+	TextLanguageSimple  = "simple"
+	// TextLanguageAuto - About каждой записи приводится к основам своего языка, см. DetectLanguage
+	TextLanguageAuto = "auto"
+)
+
+// TextSearchConfig - как query ищется в About. По умолчанию (пустой Language) это вхождение
+// подстроки, как и раньше. С языком About разбивается на слова, из которых выкидываются
+// стоп-слова, а остальные приводятся к основе: "developers" находит "developer".
+// Name ищется вхождением подстроки, если для него не задана цепочка в Analyzers
+type TextSearchConfig struct {
+	// "english" - стемминг Портера и английские стоп-слова, "simple" - только разбиение на слова,
+	// "auto" - стемминг по языку записи, для языков без стеммера как "simple"
+	Language string
+	// стоп-слова вместо списка по умолчанию для языка, у "simple" и "auto" списка по умолчанию нет
+	Stopwords []string
+	// свои цепочки анализа по полям FieldAbout и FieldName. Цепочка About заменяет Language
+	Analyzers map[string]AnalyzerConfig
+}
+
+// Validate проверяет, что язык известен, а цепочки анализа собраны из известных звеньев
+func (c TextSearchConfig) Validate() error {
+	for field, a := range c.Analyzers {
+		if err := a.Validate(field); err != nil {
+			return err
+		}
+	}
+	switch c.Language {
+	case "", TextLanguageEnglish, TextLanguageSimple, TextLanguageAuto:
+		return nil
+	}
+	return fmt.Errorf("unknown text search language %q", c.Language)
+}
+
+// стоп-слова английского по умолчанию (тот же список, что у Lucene)
+var englishStopwords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in", "into",
+	"is", "it", "no", "not", "of", "on", "or", "such", "that", "the", "their", "then",
+	"there", "these", "they", "this", "to", "was", "will", "with",
+}
+
+// textAnalyzer превращает текст в набор терминов: токенизатор режет его на слова,
+// фильтры по порядку приводят их к нижнему регистру, выкидывают стоп-слова, приводят к основе
+type textAnalyzer struct {
+	tokenize func(text string) []string
+	filters  []wordFilter
+	// TextLanguageAuto: основы берутся стеммером языка записи при индексации
+	perLanguage bool
+	// термины query расширяются синонимами, см. queryPlan.expand
+	synonyms bool
+}
+
+// literalAnalyzer только режет текст на слова: им фразы ищутся без TextSearch,
+// с учётом регистра, как и поиск подстрокой
+var literalAnalyzer = &textAnalyzer{tokenize: letterTokens, synonyms: true}
+
+// newTextAnalyzer возвращает анализатор About, nil - текстовый поиск по словам выключен или язык неизвестен
+func newTextAnalyzer(cfg TextSearchConfig) *textAnalyzer {
+	if chain, ok := cfg.Analyzers[FieldAbout]; ok {
+		return newChainAnalyzer(chain)
+	}
+	a := &textAnalyzer{tokenize: letterTokens, filters: []wordFilter{lowercaseFilter}, synonyms: true}
+	stopwords := cfg.Stopwords
+	switch cfg.Language {
+	case TextLanguageEnglish:
+		if stopwords == nil {
+			stopwords = englishStopwords
+		}
+	case TextLanguageSimple:
+	case TextLanguageAuto:
+		a.perLanguage = true
+	default:
+		return nil
+	}
+	if len(stopwords) > 0 {
+		a.filters = append(a.filters, stopwordsFilter(stopwords))
+	}
+	if cfg.Language == TextLanguageEnglish {
+		a.filters = append(a.filters, stemFilter)
+	}
+	return a
+}
+
+// newNameAnalyzer возвращает анализатор Name, nil - Name ищется подстрокой
+func newNameAnalyzer(cfg TextSearchConfig) *textAnalyzer {
+	if chain, ok := cfg.Analyzers[FieldName]; ok {
+		return newChainAnalyzer(chain)
+	}
+	return nil
+}
+
+// terms возвращает термины text в порядке появления, с повторами
+func (a *textAnalyzer) terms(text string) []string {
+	words := a.tokenize(text)
+	terms := make([]string, 0, len(words))
+next:
+	for _, w := range words {
+		for _, filter := range a.filters {
+			var ok bool
+			if w, ok = filter(w); !ok {
+				continue next
+			}
+		}
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+// textIndex - термины About каждого пользователя, позиции совпадают с позициями в датасете
+type textIndex struct {
+	analyzer *textAnalyzer
+	// языки записей для TextLanguageAuto, иначе nil
+	langs []string
+	// отсортированные уникальные термины
+	terms [][]string
+	// термины в порядке следования в тексте, для фраз
+	seq [][]string
+}
+
+// buildTextIndex строит индекс терминов поля field, langs нужны только для TextLanguageAuto
+func buildTextIndex(analyzer *textAnalyzer, users []model.User, field func(model.User) string, langs []string) *textIndex {
+	idx := &textIndex{analyzer: analyzer, terms: make([][]string, len(users)), seq: make([][]string, len(users))}
+	if analyzer.perLanguage {
+		idx.langs = langs
+	}
+	for i, u := range users {
+		idx.seq[i] = analyzer.terms(field(u))
+		if stem := idx.stemmer(i); stem != nil {
+			for j, term := range idx.seq[i] {
+				idx.seq[i][j] = stem(term)
+			}
+		}
+		terms := append([]string(nil), idx.seq[i]...)
+		sort.Strings(terms)
+		uniq := terms[:0]
+		for j, term := range terms {
+			if j == 0 || term != terms[j-1] {
+				uniq = append(uniq, term)
+			}
+		}
+		idx.terms[i] = uniq
+	}
+	return idx
+}
+
+// containsAll сообщает, есть ли в About пользователя на позиции i все термины
+func (idx *textIndex) containsAll(i int, terms []string) bool {
+	have := idx.terms[i]
+	for _, term := range terms {
+		j := sort.SearchStrings(have, term)
+		if j == len(have) || have[j] != term {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAny сообщает, есть ли в About пользователя на позиции i хоть один из терминов
+func (idx *textIndex) containsAny(i int, terms []string) bool {
+	have := idx.terms[i]
+	for _, term := range terms {
+		if j := sort.SearchStrings(have, term); j < len(have) && have[j] == term {
+			return true
+		}
+	}
+	return false
+}
+
+// stemmer - стеммер языка записи на позиции i для TextLanguageAuto, nil - слова остаются как есть
+func (idx *textIndex) stemmer(i int) func(string) string {
+	if idx.langs == nil {
+		return nil
+	}
+	return languageStemmers[idx.langs[i]]
+}
+
+// localize возвращает термины query для записи на позиции i. Без TextLanguageAuto это сами terms,
+// с ним - terms, приведённые к основам языка записи. Основы считаются один раз на язык
+func (idx *textIndex) localize(terms []string) func(i int) []string {
+	if idx == nil || idx.langs == nil {
+		return func(int) []string { return terms }
+	}
+	stemmed := make(map[string][]string, len(languageStemmers))
+	for lang, stem := range languageStemmers {
+		local := make([]string, len(terms))
+		for k, term := range terms {
+			local[k] = stem(term)
+		}
+		stemmed[lang] = local
+	}
+	return func(i int) []string {
+		if local, ok := stemmed[idx.langs[i]]; ok {
+			return local
+		}
+		return terms
+	}
+}
+
+// containsPhrase сообщает, идут ли термины phrase в About пользователя на позиции i подряд
+func (idx *textIndex) containsPhrase(i int, phrase []string) bool {
+	return idx.containsAll(i, phrase) && containsSequence(idx.seq[i], phrase)
+}
+
+// containsSequence ищет phrase в seq как непрерывную подпоследовательность
+func containsSequence(seq, phrase []string) bool {
+	for start := 0; start+len(phrase) <= len(seq); start++ {
+		j := 0
+		for j < len(phrase) && seq[start+j] == phrase[j] {
+			j++
+		}
+		if j == len(phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// textQuery - query, разобранный на фразы в кавычках и остальной текст.
+// Незакрытая кавычка продолжает фразу до конца строки
+type textQuery struct {
+	phrases []string
+	rest    string
+}
+
+func parseTextQuery(query string) textQuery {
+	var tq textQuery
+	var rest []string
+	for n, part := range strings.Split(query, `"`) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		// части с нечётными номерами стоят внутри кавычек
+		if n%2 == 1 {
+			tq.phrases = append(tq.phrases, part)
+		} else {
+			rest = append(rest, part)
+		}
+	}
+	tq.rest = strings.Join(rest, " ")
+	if len(tq.phrases) == 0 {
+		// без кавычек query используется как есть, вместе с пробелами по краям
+		tq.rest = query
+	}
+	return tq
+}
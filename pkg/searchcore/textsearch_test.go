@@ -0,0 +1,169 @@
+package searchcore
+
+import (
+	"context"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestPorterStem(t *testing.T) {
+	cases := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"hopping":        "hop",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"generalization": "gener",
+		"hopeful":        "hope",
+		"goodness":       "good",
+		"adjustable":     "adjust",
+		"controll":       "control",
+		"developers":     "develop",
+		"developer":      "develop",
+		"development":    "develop",
+		"go":             "go",
+	}
+	for word, expected := range cases {
+		if stem := porterStem(word); stem != expected {
+			t.Errorf("Error : porterStem(%q) = %q, want %q", word, stem, expected)
+		}
+	}
+}
+
+func TestTextSearch(t *testing.T) {
+	users := []model.User{
+		{Id: 1, Name: "Ann", About: "Senior developer at the bank"},
+		{Id: 2, Name: "Bob", About: "Developers and designers"},
+		{Id: 3, Name: "Developing Dan", About: "Gardener"},
+		{Id: 4, Name: "Eve", About: "Thethe"},
+	}
+	cases := []struct {
+		query    string
+		expected []int
+	}{
+		{"developers", []int{1, 2}},
+		// стоп-слова не мешают совпадению
+		{"the developer", []int{1, 2}},
+		{"designing", []int{2}},
+		// Name ищется подстрокой, About - по основе
+		{"Developing", []int{1, 2, 3}},
+		{"Gardener", []int{3}},
+		// только стоп-слова - поиск подстрокой
+		{"the", []int{1, 4}},
+	}
+	e := newTestEngine(users, Config{TextSearch: TextSearchConfig{Language: TextLanguageEnglish}})
+	for _, c := range cases {
+		found, err := e.Search(context.Background(), Query{Query: c.query})
+		if err != nil {
+			t.Errorf("Error : %v", err)
+			continue
+		}
+		var ids []int
+		for _, u := range found.Users {
+			ids = append(ids, u.Id)
+		}
+		if !equalInts(ids, c.expected) {
+			t.Errorf("Error : query %q found %v, want %v", c.query, ids, c.expected)
+		}
+	}
+
+	// без TextSearch - подстрокой, как раньше
+	e = newTestEngine(users, Config{})
+	if found, _ := e.Search(context.Background(), Query{Query: "developers"}); len(found.Users) != 0 {
+		t.Errorf("Error : unexpected result %v", found)
+	}
+}
+
+func TestTextSearchStopwords(t *testing.T) {
+	a := newTextAnalyzer(TextSearchConfig{Language: TextLanguageSimple, Stopwords: []string{"Foo"}})
+	terms := a.terms("foo Bar, the baz")
+	if !equalStrings(terms, []string{"bar", "the", "baz"}) {
+		t.Errorf("Error : unexpected terms %v", terms)
+	}
+	if err := (TextSearchConfig{Language: "klingon"}).Validate(); err == nil {
+		t.Errorf("Error : unknown language accepted")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseTextQuery(t *testing.T) {
+	tq := parseTextQuery(`remote "senior golang developer" team "lead`)
+	if !equalStrings(tq.phrases, []string{"senior golang developer", "lead"}) || tq.rest != "remote team" {
+		t.Errorf("Error : unexpected query %+v", tq)
+	}
+	if tq := parseTextQuery(" nisi "); tq.phrases != nil || tq.rest != " nisi " {
+		t.Errorf("Error : unexpected query %+v", tq)
+	}
+}
+
+func TestPhraseQuery(t *testing.T) {
+	users := []model.User{
+		{Id: 1, Name: "Ann", About: "Senior golang developer, remote"},
+		{Id: 2, Name: "Bob", About: "Golang enthusiast and senior developer"},
+		{Id: 3, Name: "Senior Golang Developers", About: "Team lead"},
+		{Id: 4, Name: "Eve", About: "Never a senior golang developer"},
+	}
+	cases := []struct {
+		language string
+		query    string
+		expected []int
+	}{
+		// без кавычек - подстрока, с кавычками - слова подряд
+		{"", "ever", []int{4}},
+		{"", `"ever"`, nil},
+		{"", `"Senior golang developer"`, []int{1}},
+		{"", `"senior golang developer"`, []int{4}},
+		{"", `"golang developer" remote`, []int{1}},
+		{TextLanguageEnglish, `"senior golang developer"`, []int{1, 3, 4}},
+		{TextLanguageEnglish, `"senior developer"`, []int{2}},
+		{TextLanguageEnglish, `"golang developers" lead`, []int{3}},
+		// стоп-слова внутри фразы пропускаются с обеих сторон
+		{TextLanguageEnglish, `"enthusiast the senior"`, []int{2}},
+	}
+	for _, c := range cases {
+		e := newTestEngine(users, Config{TextSearch: TextSearchConfig{Language: c.language}})
+		found, err := e.Search(context.Background(), Query{Query: c.query})
+		if err != nil {
+			t.Errorf("Error : %v", err)
+			continue
+		}
+		var ids []int
+		for _, u := range found.Users {
+			ids = append(ids, u.Id)
+		}
+		if !equalInts(ids, c.expected) {
+			t.Errorf("Error : %s query %q found %v, want %v", c.language, c.query, ids, c.expected)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package searchserver
+
+import (
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+// packAbout возвращает копию users, в которой About всех записей - подстроки одной строки-арены,
+// а одинаковые тексты хранятся один раз. Вместо миллионов мелких объектов в куче остаётся
+// один большой без указателей: меньше накладных расходов аллокатора и работы сборщику.
+//
+// Арена не сжимается: поиск подстрокой проходит по About всех записей на каждый запрос,
+// и распаковка блоков стоила бы дороже самого поиска
+func packAbout(users []model.User) []model.User {
+	offsets := make(map[string]int, len(users))
+	var b strings.Builder
+	for _, u := range users {
+		if _, ok := offsets[u.About]; !ok {
+			offsets[u.About] = b.Len()
+			b.WriteString(u.About)
+		}
+	}
+	arena := b.String()
+
+	packed := make([]model.User, len(users))
+	copy(packed, users)
+	for i := range packed {
+		off := offsets[packed[i].About]
+		packed[i].About = arena[off : off+len(packed[i].About)]
+	}
+	return packed
+}
@@ -0,0 +1,44 @@
+package searchserver
+
+import (
+	"testing"
+	"unsafe"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestPackAbout(t *testing.T) {
+	users := []model.User{
+		{Id: 1, About: "first"},
+		{Id: 2, About: "second"},
+		{Id: 3, About: "first"},
+		{Id: 4},
+	}
+	packed := packAbout(users)
+	for i := range users {
+		if packed[i] != users[i] {
+			t.Errorf("Error : unexpected user %+v", packed[i])
+		}
+	}
+	// одинаковые тексты - одна и та же память, разные - соседние куски арены
+	if unsafe.StringData(packed[0].About) != unsafe.StringData(packed[2].About) {
+		t.Errorf("Error : duplicate About not shared")
+	}
+	if unsafe.Pointer(unsafe.StringData(packed[1].About)) != unsafe.Add(unsafe.Pointer(unsafe.StringData(packed[0].About)), len("first")) {
+		t.Errorf("Error : About not packed")
+	}
+}
+
+func TestCompactAbout(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, CompactAbout: true})
+
+	w := doRequest(s, "PATCH", "/users/0", `{"About": "patched"}`, map[string]string{"AccessToken": adminToken})
+	if w.Code != 200 {
+		t.Fatalf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+	u, _ := s.lookupUser(0, ScopeAdmin)
+	if u.About != "patched" || s.users[1].About != users[1].About {
+		t.Errorf("Error : unexpected users %q %q", u.About, s.users[1].About)
+	}
+}
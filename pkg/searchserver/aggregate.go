@@ -0,0 +1,204 @@
+package searchserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+const defaultDownstreamTimeout = 5 * time.Second
+
+// AggregateConfig - режим агрегатора: сервер не ищет по своему датасету, а рассылает поиск
+// по нижестоящим серверам (шардам или репликам), сливает выдачи в общий порядок
+// и убирает дубли по Id. Агрегируется только поиск, остальные ручки работают с локальным датасетом
+type AggregateConfig struct {
+	// адреса поиска нижестоящих серверов, пусто - режим выключен
+	URLs []string
+	// токен для нижестоящих серверов
+	AccessToken string
+	// таймаут запроса к одному серверу, 0 - defaultDownstreamTimeout
+	Timeout time.Duration
+	// сколько живёт страница в кэше результатов, 0 - пока не вытеснят.
+	// Агрегатор не знает об изменениях внизу, так что без TTL кэш отдаёт старые данные
+	CacheTTL time.Duration
+}
+
+type aggregator struct {
+	cfg    AggregateConfig
+	client *http.Client
+}
+
+func newAggregator(cfg AggregateConfig) *aggregator {
+	return &aggregator{
+		cfg:    cfg,
+		client: &http.Client{Timeout: durationOr(cfg.Timeout, defaultDownstreamTimeout)},
+	}
+}
+
+// validate отклоняет то, что агрегатор не может слить: нижестоящие серверы не отдают оценок,
+// так что сортировку по Score, в том числе оценкой своего Matcher, не восстановить
+func (a *aggregator) validate(q searchcore.Query) error {
+	if key, ok := q.SortKey(); ok && key.Field == searchcore.ScoreField {
+		return model.ErrBadOrderField
+	}
+	return nil
+}
+
+// each - Server.each для режима агрегатора: ищет и отдаёт fn слитую выдачу.
+// Неполная выдача, как и у локального поиска, заканчивается model.ErrSearchTimeout
+func (a *aggregator) each(ctx context.Context, q searchcore.Query, defaultLocale string, fn func(model.User) bool) error {
+	users, partial, err := a.find(ctx, q, defaultLocale)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if !fn(u) {
+			return nil
+		}
+	}
+	if partial {
+		return model.ErrSearchTimeout
+	}
+	return nil
+}
+
+// find ищет q на всех нижестоящих серверах. Каждый отдаёт первые offset+limit записей
+// своей выдачи, этого достаточно для общей страницы даже с дублями между серверами
+func (a *aggregator) find(ctx context.Context, q searchcore.Query, defaultLocale string) ([]model.User, bool, error) {
+	params := url.Values{}
+	for name, value := range q.Params() {
+		params.Set(name, value)
+	}
+	params.Del("offset")
+	params.Del("limit")
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Offset+q.Limit))
+	}
+	params.Set("format", model.FormatJSON)
+
+	type result struct {
+		users   []model.User
+		partial bool
+		err     error
+	}
+	results := make([]result, len(a.cfg.URLs))
+	var wg sync.WaitGroup
+	for i, u := range a.cfg.URLs {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			r := &results[i]
+			r.users, r.partial, r.err = a.fetch(ctx, u, params)
+		}(i, u)
+	}
+	wg.Wait()
+
+	var merged []model.User
+	partial := false
+	for _, r := range results {
+		if r.err == model.ErrSearchTimeout && q.AllowPartial {
+			partial = true
+			continue
+		}
+		if r.err != nil {
+			return nil, false, r.err
+		}
+		merged = append(merged, r.users...)
+		partial = partial || r.partial
+	}
+
+	if key, ok := q.SortKey(); ok {
+		if key.Field == "Name" && key.Locale == "" {
+			key.Locale = defaultLocale
+		}
+		sortMerged(merged, key)
+	}
+	merged = dedupUsers(merged)
+
+	if q.Limit > 0 {
+		if len(merged) > q.Offset+q.Limit {
+			merged = merged[:q.Offset+q.Limit]
+		}
+		if len(merged) > q.Offset {
+			merged = merged[q.Offset:]
+		} else {
+			merged = []model.User{}
+		}
+	}
+	return merged, partial, nil
+}
+
+func (a *aggregator) fetch(ctx context.Context, target string, params url.Values) ([]model.User, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("AccessToken", a.cfg.AccessToken)
+	req.Header.Set(model.VersionHeader, strconv.Itoa(model.WireV1))
+	resp, err := a.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, model.ErrSearchTimeout
+		}
+		return nil, false, model.ErrDownstream
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, model.ErrDownstream
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusGatewayTimeout:
+		return nil, false, model.ErrSearchTimeout
+	default:
+		return nil, false, model.ErrDownstream
+	}
+	page, err := model.DecodeSearchJSON(body)
+	if err != nil {
+		return nil, false, model.ErrDownstream
+	}
+	return page.Users, page.Partial || resp.Header.Get("X-Partial-Result") == "true", nil
+}
+
+// sortMerged упорядочивает слитую выдачу так же, как сервер сортирует свой датасет.
+// stable - при равных ключах серверы идут в порядке перечисления в конфиге
+func sortMerged(users []model.User, key searchcore.SortKey) {
+	less, _ := searchcore.OrderLess(key.Field)
+	if key.Locale != "" {
+		col := collate.New(language.Make(key.Locale))
+		less = func(lhs, rhs model.User) bool {
+			return col.CompareString(lhs.Name, rhs.Name) < 0
+		}
+	}
+	sort.SliceStable(users, func(i, j int) bool {
+		if key.Desc {
+			return less(users[j], users[i])
+		}
+		return less(users[i], users[j])
+	})
+}
+
+// dedupUsers оставляет первое вхождение каждого Id
+func dedupUsers(users []model.User) []model.User {
+	seen := make(map[int]bool, len(users))
+	unique := users[:0]
+	for _, u := range users {
+		if !seen[u.Id] {
+			seen[u.Id] = true
+			unique = append(unique, u)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,129 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// newAggregateTest раскладывает датасет по трём нижестоящим серверам, первая пятая часть
+// лежит ещё и на соседнем сервере - дубли агрегатор должен убрать
+func newAggregateTest(t *testing.T, cfg ServerConfig) (*Server, []model.User) {
+	users, _ := LoadDataset("../../dataset.xml")
+	parts := make([][]model.User, 3)
+	for i, u := range users {
+		parts[i%3] = append(parts[i%3], u)
+		if i < len(users)/5 {
+			parts[(i+1)%3] = append(parts[(i+1)%3], u)
+		}
+	}
+	for _, part := range parts {
+		downstream := httptest.NewServer(NewServer(part, testServerConfig))
+		t.Cleanup(downstream.Close)
+		cfg.Aggregate.URLs = append(cfg.Aggregate.URLs, downstream.URL)
+	}
+	cfg.Tokens = testServerConfig.Tokens
+	cfg.Aggregate.AccessToken = accessToken
+	return NewServer(nil, cfg), users
+}
+
+func decodeUsers(t *testing.T, w *httptest.ResponseRecorder) []model.User {
+	users := []model.User{}
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+		t.Fatalf("Error : %v %s", err, w.Body)
+	}
+	return users
+}
+
+func TestAggregateMatchesSingleServer(t *testing.T) {
+	agg, users := newAggregateTest(t, ServerConfig{})
+	single := NewServer(users, testServerConfig)
+
+	for _, target := range []string{
+		"/?order_field=Id&order_by=1&limit=10&offset=5",
+		"/?order_field=Name&order_by=-1&limit=7",
+		"/?query=nisi&order_field=Id&order_by=-1&limit=25",
+		"/?gender=female&order_field=Name&order_by=1",
+		"/?order_field=Id&order_by=-1&limit=10&offset=100",
+	} {
+		expected := decodeUsers(t, doRequest(single, "GET", target, "", nil))
+		got := decodeUsers(t, doRequest(agg, "GET", target, "", nil))
+		if len(got) != len(expected) {
+			t.Errorf("Error : %s: %d users, want %d", target, len(got), len(expected))
+			continue
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Errorf("Error : %s: user %d is %v, want %v", target, i, got[i].Id, expected[i].Id)
+				break
+			}
+		}
+	}
+}
+
+func TestAggregateCacheTTL(t *testing.T) {
+	agg, _ := newAggregateTest(t, ServerConfig{CacheSize: 10, Aggregate: AggregateConfig{CacheTTL: 50 * time.Millisecond}})
+
+	target := "/?order_field=Id&order_by=-1&limit=5"
+	for i, expected := range []string{"MISS", "HIT"} {
+		if w := doRequest(agg, "GET", target, "", nil); w.Header().Get("X-Cache") != expected {
+			t.Errorf("Error : request %d: X-Cache %q", i, w.Header().Get("X-Cache"))
+		}
+	}
+	time.Sleep(60 * time.Millisecond)
+	if w := doRequest(agg, "GET", target, "", nil); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Error : expired page served: %q", w.Header().Get("X-Cache"))
+	}
+}
+
+func TestAggregateDownstreamErrors(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusGatewayTimeout, model.ErrSearchTimeout.Error())
+	}))
+	defer slow.Close()
+	users, _ := LoadDataset("../../dataset.xml")
+	up := httptest.NewServer(NewServer(users, testServerConfig))
+	defer up.Close()
+
+	agg := NewServer(nil, ServerConfig{Tokens: testServerConfig.Tokens, Aggregate: AggregateConfig{URLs: []string{up.URL, down.URL}, AccessToken: accessToken}})
+	if w := doRequest(agg, "GET", "/?limit=5", "", nil); w.Code != http.StatusBadGateway {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+
+	// таймаут одного сервера при allow_partial - неполная выдача от остальных
+	agg = NewServer(nil, ServerConfig{Tokens: testServerConfig.Tokens, Aggregate: AggregateConfig{URLs: []string{up.URL, slow.URL}, AccessToken: accessToken}})
+	if w := doRequest(agg, "GET", "/?limit=5", "", nil); w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+	w := doRequest(agg, "GET", "/?limit=5&allow_partial=true", "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("X-Partial-Result") != "true" || len(decodeUsers(t, w)) != 5 {
+		t.Errorf("Error : unexpected response %d %v %s", w.Code, w.Header(), w.Body)
+	}
+}
+
+func TestAggregateRejectsScoreOrder(t *testing.T) {
+	agg, _ := newAggregateTest(t, ServerConfig{})
+
+	// раньше сортировка слитой выдачи по Score разыменовывала nil
+	for _, target := range []string{
+		"/?query=nisi&order_field=Score&order_by=1",
+		"/?query=nisi&order_field=Score&order_by=-1&limit=5",
+	} {
+		w := doRequest(agg, "GET", target, "", nil)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), model.ErrBadOrderField.Error()) {
+			t.Errorf("Error : %s: unexpected response %d %s", target, w.Code, w.Body)
+		}
+	}
+	if w := doRequest(agg, "POST", "/msearch", `[{"query": "nisi", "order_field": "Score", "order_by": 1}]`, nil); !strings.Contains(w.Body.String(), `"Status":400`) {
+		t.Errorf("Error : unexpected response %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,495 @@
+package searchserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// EventAlertTriggered - событие вебхука алерта, в WebhookEvent.Alert - что изменилось
+const EventAlertTriggered = "alert.triggered"
+
+const (
+	// сколько алертов может быть у одного токена
+	maxAlerts = 20
+	// самый короткий период алерта, если AlertMinInterval не задан
+	defaultAlertMinInterval = time.Minute
+	// планировщик проверяет, каким алертам пора, не реже раза в alertTick
+	alertTick = time.Second
+)
+
+// AlertMailConfig - SMTP-сервер для алертов с Email. Без Addr такие алерты не принимаются
+type AlertMailConfig struct {
+	// host:port
+	Addr string
+	From string
+	// вход на сервер, пустой Username - без авторизации
+	Username string
+	Password string
+}
+
+// AlertNotice - чем выдача алерта отличается от прошлой проверки
+type AlertNotice struct {
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
+	Previous  int    `json:"previous"`
+	Threshold int    `json:"threshold,omitempty"`
+	// Id записей, которые появились в выдаче и пропали из неё
+	Added   []int `json:"added,omitempty"`
+	Removed []int `json:"removed,omitempty"`
+}
+
+// sendMail подменяется в тестах
+var sendMail = smtp.SendMail
+
+type alertState struct {
+	alert model.Alert
+	// сам токен, а не отпечаток: права перепроверяются перед каждой проверкой
+	token string
+	every time.Duration
+	next  time.Time
+	// Id выдачи на прошлой проверке, nil - ещё не проверяли
+	ids  map[int]bool
+	hook *webhook
+}
+
+// alertScheduler - алерты по отпечатку токена (TokenFingerprint). Живут только в памяти,
+// фоновая проверка запускается с первым алертом
+type alertScheduler struct {
+	mu      sync.Mutex
+	byToken map[string]map[string]*alertState
+	// номер последнего события вебхуков алертов
+	seq     uint64
+	tick    time.Duration
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newAlertScheduler(minInterval time.Duration) *alertScheduler {
+	tick := alertTick
+	if minInterval < tick {
+		tick = minInterval
+	}
+	return &alertScheduler{
+		byToken: map[string]map[string]*alertState{},
+		tick:    tick,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (a *alertScheduler) list(token string) []model.Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := []model.Alert{}
+	for _, st := range a.byToken[token] {
+		list = append(list, publicAlert(st.alert))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (a *alertScheduler) get(token, name string) (model.Alert, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st, ok := a.byToken[token][name]
+	if !ok {
+		return model.Alert{}, false
+	}
+	return publicAlert(st.alert), true
+}
+
+// put заменяет алерт целиком, с чистым состоянием. false - у токена уже maxAlerts других
+func (a *alertScheduler) put(token string, st *alertState) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	alerts := a.byToken[token]
+	if alerts == nil {
+		alerts = map[string]*alertState{}
+		a.byToken[token] = alerts
+	}
+	old, ok := alerts[st.alert.Name]
+	if !ok && len(alerts) >= maxAlerts {
+		return false
+	}
+	if ok && old.hook != nil {
+		go old.hook.close()
+	}
+	if st.alert.Webhook != "" {
+		st.hook = newWebhook(WebhookConfig{URL: st.alert.Webhook, Secret: st.alert.Secret})
+	}
+	alerts[st.alert.Name] = st
+	return true
+}
+
+func (a *alertScheduler) remove(token, name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st, ok := a.byToken[token][name]
+	if !ok {
+		return false
+	}
+	if st.hook != nil {
+		go st.hook.close()
+	}
+	delete(a.byToken[token], name)
+	return true
+}
+
+// drop удаляет алерт, если его не успели заменить
+func (a *alertScheduler) drop(token string, st *alertState) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.byToken[token][st.alert.Name] != st {
+		return false
+	}
+	if st.hook != nil {
+		go st.hook.close()
+	}
+	delete(a.byToken[token], st.alert.Name)
+	return true
+}
+
+// close останавливает проверки и дожидается отправки уже поставленных событий
+func (a *alertScheduler) close() {
+	a.mu.Lock()
+	running := a.running
+	a.running = false
+	a.mu.Unlock()
+	if running {
+		close(a.stop)
+		<-a.done
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, alerts := range a.byToken {
+		for _, st := range alerts {
+			if st.hook != nil {
+				st.hook.close()
+				st.hook = nil
+			}
+		}
+	}
+}
+
+// publicAlert - алерт для ответа, без секрета вебхука
+func publicAlert(alert model.Alert) model.Alert {
+	alert.Secret = ""
+	return alert
+}
+
+// startAlerts запускает фоновую проверку алертов, если она ещё не идёт
+func (s *Server) startAlerts() {
+	s.alerts.mu.Lock()
+	defer s.alerts.mu.Unlock()
+	if s.alerts.running {
+		return
+	}
+	s.alerts.running = true
+	go s.alertLoop()
+}
+
+func (s *Server) alertLoop() {
+	defer close(s.alerts.done)
+	ticker := time.NewTicker(s.alerts.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.alerts.stop:
+			return
+		case now := <-ticker.C:
+			s.runDueAlerts(now)
+		}
+	}
+}
+
+// runDueAlerts проверяет алерты, которым пора, по очереди
+func (s *Server) runDueAlerts(now time.Time) {
+	type due struct {
+		token string
+		st    *alertState
+	}
+	var list []due
+	s.alerts.mu.Lock()
+	for token, alerts := range s.alerts.byToken {
+		for _, st := range alerts {
+			if !st.next.After(now) {
+				st.next = now.Add(st.every)
+				list = append(list, due{token, st})
+			}
+		}
+	}
+	s.alerts.mu.Unlock()
+	for _, d := range list {
+		s.checkAlert(d.token, d.st, now)
+	}
+}
+
+// checkAlert выполняет поиск алерта и сообщает получателям, если выдача изменилась
+// или число найденных перешло через порог. Первая проверка только запоминает выдачу.
+// Алерт отозванного токена удаляется, поиск идёт с текущими правами токена
+func (s *Server) checkAlert(token string, st *alertState, now time.Time) {
+	s.alerts.mu.Lock()
+	alert, prev := st.alert, st.ids
+	s.alerts.mu.Unlock()
+
+	scope, ok := s.tokenScope(st.token)
+	if !ok {
+		if s.alerts.drop(token, st) {
+			log.Printf("alert %s: token %s revoked, alert dropped", alert.Name, token)
+		}
+		return
+	}
+	ids, err := s.alertResult(token, alert, scope)
+	if err == nil && alert.Webhook != "" && !s.alertWebhookAllowed(alert.Webhook, scope) {
+		err = errors.New(model.ErrorAdminOnly)
+	}
+	s.alerts.mu.Lock()
+	st.alert.LastRun = now.UTC()
+	if err != nil {
+		st.alert.LastError = err.Error()
+		s.alerts.mu.Unlock()
+		return
+	}
+	st.alert.Count, st.alert.LastError, st.ids = len(ids), "", ids
+	s.alerts.mu.Unlock()
+	if prev == nil {
+		return
+	}
+
+	notice := AlertNotice{Name: alert.Name, Count: len(ids), Previous: len(prev), Threshold: alert.Threshold}
+	for id := range ids {
+		if !prev[id] {
+			notice.Added = append(notice.Added, id)
+		}
+	}
+	for id := range prev {
+		if !ids[id] {
+			notice.Removed = append(notice.Removed, id)
+		}
+	}
+	sort.Ints(notice.Added)
+	sort.Ints(notice.Removed)
+	if alert.Threshold > 0 {
+		if (notice.Previous >= alert.Threshold) == (notice.Count >= alert.Threshold) {
+			return
+		}
+	} else if len(notice.Added) == 0 && len(notice.Removed) == 0 {
+		return
+	}
+	s.notifyAlert(st, alert, notice)
+}
+
+// alertResult - Id всей выдачи поиска алерта, без limit и offset
+func (s *Server) alertResult(token string, alert model.Alert, scope Scope) (map[int]bool, error) {
+	params := alert.Params
+	if alert.Saved != "" {
+		saved, ok := s.saved.get(token, alert.Saved)
+		if !ok {
+			return nil, model.ErrSavedSearchNotFound
+		}
+		params = saved.Params
+	}
+	q := alertQuery(params)
+	if q.IncludeDeleted && scope != ScopeAdmin {
+		return nil, errors.New(model.ErrorAdminOnly)
+	}
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.searchContext(context.Background())
+	defer cancel()
+	ids := map[int]bool{}
+	err := s.each(ctx, q, func(u model.User) bool {
+		ids[u.Id] = true
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// alertQuery - поиск по параметрам GET /, но по всей выдаче
+func alertQuery(params map[string]string) searchcore.Query {
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	q := parseSearchQuery(values)
+	q.Limit, q.Offset, q.GroupBy = 0, 0, ""
+	return q
+}
+
+func (s *Server) notifyAlert(st *alertState, alert model.Alert, notice AlertNotice) {
+	s.alerts.mu.Lock()
+	if st.hook != nil {
+		s.alerts.seq++
+		st.hook.enqueue(WebhookEvent{ID: s.alerts.seq, Type: EventAlertTriggered, Time: time.Now().UTC(), Alert: &notice})
+	}
+	s.alerts.mu.Unlock()
+	if alert.Email == "" {
+		return
+	}
+	if err := s.mailAlert(alert.Email, notice); err != nil {
+		log.Printf("alert %s: mail to %s: %s", alert.Name, alert.Email, model.Redact(err.Error()))
+		s.alerts.mu.Lock()
+		st.alert.LastError = err.Error()
+		s.alerts.mu.Unlock()
+	}
+}
+
+func (s *Server) mailAlert(to string, notice AlertNotice) error {
+	cfg := s.cfg.AlertMail
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		host := cfg.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: alert %s: %d results\r\n\r\n", cfg.From, to, notice.Name, notice.Count)
+	fmt.Fprintf(&msg, "%d results, was %d\r\n", notice.Count, notice.Previous)
+	if notice.Threshold > 0 {
+		fmt.Fprintf(&msg, "threshold %d\r\n", notice.Threshold)
+	}
+	if len(notice.Added) > 0 {
+		fmt.Fprintf(&msg, "added: %v\r\n", notice.Added)
+	}
+	if len(notice.Removed) > 0 {
+		fmt.Fprintf(&msg, "removed: %v\r\n", notice.Removed)
+	}
+	return sendMail(cfg.Addr, auth, cfg.From, []string{to}, []byte(msg.String()))
+}
+
+// alertsHandler - алерты токена запроса: GET /alerts - список,
+// GET, PUT (тело - model.Alert без имени и состояния) и DELETE /alerts/{name}
+func (s *Server) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	token := TokenFingerprint(r.Header.Get("AccessToken"))
+	if r.URL.Path == "/alerts" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.alerts.list(token))
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/alerts/")
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		alert, ok := s.alerts.get(token, name)
+		if !ok {
+			writeError(w, http.StatusNotFound, model.ErrorAlertNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, alert)
+	case http.MethodPut:
+		var alert model.Alert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			writeError(w, http.StatusBadRequest, model.ErrorBadAlert)
+			return
+		}
+		st, status, code := s.newAlert(r.Header.Get("AccessToken"), name, alert, requestScope(r))
+		if code != "" {
+			writeError(w, status, code)
+			return
+		}
+		if !s.alerts.put(token, st) {
+			writeError(w, http.StatusBadRequest, model.ErrorBadAlert)
+			return
+		}
+		s.startAlerts()
+		writeJSON(w, http.StatusOK, publicAlert(st.alert))
+	case http.MethodDelete:
+		if !s.alerts.remove(token, name) {
+			writeError(w, http.StatusNotFound, model.ErrorAlertNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// newAlert проверяет алерт из тела PUT, при ошибке возвращает статус и код ответа
+func (s *Server) newAlert(access, name string, alert model.Alert, scope Scope) (*alertState, int, string) {
+	token := TokenFingerprint(access)
+	alert.Name = name
+	alert.LastRun, alert.Count, alert.LastError = time.Time{}, 0, ""
+	if (alert.Saved == "") == (alert.Params == nil) || alert.Threshold < 0 {
+		return nil, http.StatusBadRequest, model.ErrorBadAlert
+	}
+	if alert.Saved != "" {
+		if _, ok := s.saved.get(token, alert.Saved); !ok {
+			return nil, http.StatusNotFound, model.ErrorSavedSearchNotFound
+		}
+	} else if err := alertQuery(alert.Params).Validate(); err != nil {
+		return nil, http.StatusBadRequest, err.Error()
+	}
+	every, err := time.ParseDuration(alert.Every)
+	if err != nil || every < durationOr(s.cfg.AlertMinInterval, defaultAlertMinInterval) {
+		return nil, http.StatusBadRequest, model.ErrorBadAlert
+	}
+	if alert.Webhook == "" && alert.Email == "" {
+		return nil, http.StatusBadRequest, model.ErrorBadAlert
+	}
+	if alert.Webhook != "" {
+		u, err := url.Parse(alert.Webhook)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, http.StatusBadRequest, model.ErrorBadAlert
+		}
+		if !s.alertWebhookAllowed(alert.Webhook, scope) {
+			return nil, http.StatusForbidden, model.ErrorAdminOnly
+		}
+	}
+	if alert.Email != "" {
+		// только голый адрес: имя и угловые скобки ушли бы в заголовок To письма
+		addr, err := mail.ParseAddress(alert.Email)
+		if s.cfg.AlertMail.Addr == "" || err != nil || addr.Address != alert.Email {
+			return nil, http.StatusBadRequest, model.ErrorBadAlert
+		}
+	}
+	// первая проверка - сразу, она запоминает выдачу, с которой дальше сравниваются
+	return &alertState{alert: alert, token: access, every: every}, 0, ""
+}
+
+// alertWebhookAllowed - может ли токен с правами scope слать вебхук алерта на этот адрес.
+// Вебхук ходит с сервера, поэтому без ScopeAdmin - только на хосты AlertWebhookHosts
+func (s *Server) alertWebhookAllowed(webhook string, scope Scope) bool {
+	if scope == ScopeAdmin {
+		return true
+	}
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return false
+	}
+	for _, host := range s.cfg.AlertWebhookHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestAlerts(t *testing.T) {
+	events := make(chan WebhookEvent, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(webhookSignatureHeader) != SignWebhook("secret", body) {
+			t.Errorf("Error : bad signature")
+		}
+		var e WebhookEvent
+		json.Unmarshal(body, &e)
+		events <- e
+	}))
+	defer receiver.Close()
+
+	mails := make(chan string, 10)
+	defer func(orig func(string, smtp.Auth, string, []string, []byte) error) { sendMail = orig }(sendMail)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mails <- to[0] + "\n" + string(msg)
+		return nil
+	}
+
+	cfg := testServerConfig
+	cfg.AlertMail = AlertMailConfig{Addr: "localhost:25", From: "search@example.com"}
+	cfg.AlertWebhookHosts = []string{"127.0.0.1"}
+	users := newTestHandler().snapshot()
+	h := NewServer(users, cfg)
+	defer h.Close()
+
+	young := 0
+	removed := -1
+	rest := []model.User{}
+	for _, u := range users {
+		if u.Age <= 25 {
+			young++
+			if removed < 0 {
+				removed = u.Id
+				continue
+			}
+		}
+		rest = append(rest, u)
+	}
+
+	if rec := doRequest(h, "PUT", "/alerts/young", `{"params": {"age_max": "25"}, "every": "1m", "webhook": "`+receiver.URL+`", "secret": "secret"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", rec.Code, rec.Body)
+	}
+	doRequest(h, "PUT", "/saved/young", `{"age_max": 25}`, nil)
+	body := `{"saved": "young", "every": "1m", "email": "ops@example.com", "threshold": ` + strconv.Itoa(young) + `}`
+	if rec := doRequest(h, "PUT", "/alerts/threshold", body, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", rec.Code, rec.Body)
+	}
+	for _, bad := range []string{
+		`{"params": {"age_max": "25"}, "every": "1s", "webhook": "` + receiver.URL + `"}`,
+		`{"params": {"age_max": "25"}, "every": "1m"}`,
+		`{"every": "1m", "webhook": "` + receiver.URL + `"}`,
+		`{"params": {"order_field": "About", "order_by": "1"}, "every": "1m", "webhook": "` + receiver.URL + `"}`,
+	} {
+		if rec := doRequest(h, "PUT", "/alerts/bad", bad, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Error : %s accepted, %d", bad, rec.Code)
+		}
+	}
+
+	// первая проверка только запоминает выдачу
+	start := time.Now().Add(time.Hour)
+	h.runDueAlerts(start)
+	var alert model.Alert
+	json.Unmarshal(doRequest(h, "GET", "/alerts/young", "", nil).Body.Bytes(), &alert)
+	if alert.Count != young || alert.Secret != "" || alert.LastRun.IsZero() {
+		t.Errorf("Error : unexpected state %+v", alert)
+	}
+
+	h.Reload(rest)
+	h.runDueAlerts(start.Add(2 * time.Minute))
+	select {
+	case e := <-events:
+		if e.Type != EventAlertTriggered || e.Alert == nil || e.Alert.Name != "young" || e.Alert.Count != young-1 ||
+			len(e.Alert.Removed) != 1 || e.Alert.Removed[0] != removed {
+			t.Errorf("Error : unexpected event %+v %+v", e, e.Alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Error : no webhook event")
+	}
+	select {
+	case mail := <-mails:
+		if !strings.HasPrefix(mail, "ops@example.com\n") || !strings.Contains(mail, "alert threshold") {
+			t.Errorf("Error : unexpected mail %q", mail)
+		}
+	default:
+		t.Errorf("Error : threshold crossing not mailed")
+	}
+
+	// выдача не изменилась - сообщать не о чем
+	h.runDueAlerts(start.Add(4 * time.Minute))
+	select {
+	case e := <-events:
+		t.Errorf("Error : unexpected event %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// у другого токена свои алерты
+	admin := map[string]string{"AccessToken": adminToken}
+	if rec := doRequest(h, "GET", "/alerts/young", "", admin); rec.Code != http.StatusNotFound {
+		t.Errorf("Error : alert of another token found, %d", rec.Code)
+	}
+	list := []model.Alert{}
+	json.Unmarshal(doRequest(h, "GET", "/alerts", "", nil).Body.Bytes(), &list)
+	if len(list) != 2 || list[0].Name != "threshold" || list[1].Name != "young" {
+		t.Errorf("Error : unexpected list %+v", list)
+	}
+	if rec := doRequest(h, "DELETE", "/alerts/young", "", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+	if rec := doRequest(h, "GET", "/alerts/young", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Error : deleted alert found, %d", rec.Code)
+	}
+}
+
+func TestAlertRecipients(t *testing.T) {
+	cfg := testServerConfig
+	cfg.AlertMail = AlertMailConfig{Addr: "localhost:25", From: "search@example.com"}
+	cfg.AlertWebhookHosts = []string{"hooks.example.com"}
+	h := NewServer(newTestHandler().snapshot(), cfg)
+	defer h.Close()
+	admin := map[string]string{"AccessToken": adminToken}
+
+	// вебхук ходит с сервера: без прав admin - только на разрешённые хосты
+	internal := `{"params": {"age_max": "25"}, "every": "1m", "webhook": "http://169.254.169.254/latest"}`
+	if rec := doRequest(h, "PUT", "/alerts/internal", internal, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Error : webhook to internal host accepted, %d", rec.Code)
+	}
+	if rec := doRequest(h, "PUT", "/alerts/internal", internal, admin); rec.Code != http.StatusOK {
+		t.Errorf("Error : admin webhook rejected, %d %s", rec.Code, rec.Body)
+	}
+	allowed := `{"params": {"age_max": "25"}, "every": "1m", "webhook": "https://HOOKS.example.com/alerts"}`
+	if rec := doRequest(h, "PUT", "/alerts/allowed", allowed, nil); rec.Code != http.StatusOK {
+		t.Errorf("Error : webhook to allowed host rejected, %d %s", rec.Code, rec.Body)
+	}
+
+	for _, email := range []string{"ops", "Ops <ops@example.com>", "ops@example.com\r\nBcc: all@example.com"} {
+		body := `{"params": {"age_max": "25"}, "every": "1m", "email": "` + email + `"}`
+		if rec := doRequest(h, "PUT", "/alerts/mail", body, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Error : email %q accepted, %d", email, rec.Code)
+		}
+	}
+
+	// токен отозвали - его алерты больше не проверяются
+	h.SetTokens(map[string]Scope{adminToken: ScopeAdmin})
+	h.runDueAlerts(time.Now().Add(time.Hour))
+	if list := h.alerts.list(TokenFingerprint(accessToken)); len(list) != 0 {
+		t.Errorf("Error : alerts of revoked token kept %+v", list)
+	}
+	if list := h.alerts.list(TokenFingerprint(adminToken)); len(list) != 1 || list[0].LastRun.IsZero() {
+		t.Errorf("Error : unexpected admin alerts %+v", list)
+	}
+}
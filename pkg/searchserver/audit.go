@@ -0,0 +1,256 @@
+package searchserver
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// сколько байт тела запроса (GraphQL, JSON-RPC) сохранять в журнале
+const auditBodyLimit = 4 << 10
+
+// AuditEntry - одна запись журнала аудита: кто, когда и что запрашивал
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// отпечаток токена, сам токен в журнал не пишется
+	Token  string `json:"token"`
+	Scope  Scope  `json:"scope"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Body   string `json:"body,omitempty"`
+	Status int    `json:"status"`
+}
+
+// AuditLog - журнал обращений к данным пользователей, только дописывается (JSON lines)
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	// сколько записей не удалось дописать и не сбоит ли запись сейчас
+	failures uint64
+	failing  bool
+}
+
+// OpenAuditLog открывает журнал аудита на дозапись, при отсутствии создаёт
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{path: path, file: f}, nil
+}
+
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// record дописывает запись. Ошибки записи считаются в Failures и попадают в лог:
+// вызывающим отвечать на запрос всё равно нужно, а журнал не должен обрываться незаметно
+func (a *AuditLog) record(e AuditEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.file.Write(append(line, '\n'))
+	if err != nil {
+		a.failures++
+		if !a.failing {
+			log.Printf("audit %s: write failed, entries are lost: %s", a.path, err)
+		}
+	} else if a.failing {
+		log.Printf("audit %s: writing again, %d entries lost so far", a.path, a.failures)
+	}
+	a.failing = err != nil
+	return err
+}
+
+// Failures - сколько записей аудита потеряно из-за ошибок записи с открытия журнала
+func (a *AuditLog) Failures() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.failures
+}
+
+// auditFilter - условия выборки из журнала, пустые поля не фильтруют
+type auditFilter struct {
+	Token string
+	Since time.Time
+	Until time.Time
+	// не больше Limit последних записей
+	Limit int
+}
+
+// query читает журнал целиком и возвращает подходящие записи в порядке записи. Читает
+// отдельным дескриптором без a.mu, чтобы не держать запись, и только до размера на момент
+// вызова: строки пишутся под a.mu целиком, так что недописанная в выборку не попадёт
+func (a *AuditLog) query(f auditFilter) ([]AuditEntry, error) {
+	a.mu.Lock()
+	info, err := os.Stat(a.path)
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(io.LimitReader(file, info.Size()))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		e := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		if f.Token != "" && e.Token != f.Token ||
+			!f.Since.IsZero() && e.Time.Before(f.Since) ||
+			!f.Until.IsZero() && e.Time.After(f.Until) {
+			continue
+		}
+		entries = append(entries, e)
+		if f.Limit > 0 && len(entries) > f.Limit {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// TokenFingerprint - под каким именем токен виден в журнале аудита
+func TokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// statusRecorder запоминает код ответа и сколько байт отдано, не ломая потоковую отдачу
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// peekedBody - тело запроса, у которого уже прочитано начало
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// peekBody читает не больше n байт тела и склеивает его обратно, чтобы обработчик получил
+// тело целиком. Остаток не буферизуется: лимиты размера в обработчиках продолжают работать
+func peekBody(r *http.Request, n int64) []byte {
+	head, _ := ioutil.ReadAll(io.LimitReader(r.Body, n))
+	r.Body = peekedBody{Reader: io.MultiReader(bytes.NewReader(head), r.Body), Closer: r.Body}
+	return head
+}
+
+// audited выполняет запрос и пишет его в журнал аудита, если он включён
+func (s *Server) audited(w http.ResponseWriter, r *http.Request, token string, scope Scope, h http.HandlerFunc) {
+	if s.cfg.AuditLog == nil {
+		h(w, r)
+		return
+	}
+
+	entry := AuditEntry{
+		Time:   time.Now().UTC(),
+		Token:  TokenFingerprint(token),
+		Scope:  scope,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  model.Redact(r.URL.RawQuery),
+	}
+	if r.Method == http.MethodPost {
+		// в GraphQL и JSON-RPC сам запрос лежит в теле
+		entry.Body = model.Redact(string(peekBody(r, auditBodyLimit)))
+	}
+
+	rec := &statusRecorder{ResponseWriter: w}
+	h(rec, r)
+	entry.Status = rec.status
+	s.cfg.AuditLog.record(entry)
+}
+
+// auditQuery - GET /admin/audit?token=&since=&until=&limit=, время в RFC 3339
+func (s *Server) auditQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cfg.AuditLog == nil {
+		writeError(w, http.StatusNotImplemented, "audit log is disabled")
+		return
+	}
+	q := r.URL.Query()
+	f := auditFilter{Token: q.Get("token")}
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, "bad since: "+err.Error())
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, "bad until: "+err.Error())
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			writeError(w, http.StatusBadRequest, model.ErrBadLimit.Error())
+			return
+		}
+	}
+
+	entries, err := s.cfg.AuditLog.query(f)
+	if err != nil {
+		http.Error(w, "audit log read failed", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,171 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAuditedHandler(t *testing.T) *Server {
+	audit, err := OpenAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	t.Cleanup(func() { audit.Close() })
+	users, _ := LoadDataset("../../dataset.xml")
+	return NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, AuditLog: audit})
+}
+
+func queryAudit(t *testing.T, h http.Handler, target string) []AuditEntry {
+	w := doRequest(h, "GET", target, "", map[string]string{"AccessToken": adminToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d", w.Code)
+	}
+	entries := []AuditEntry{}
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	return entries
+}
+
+func TestAuditLog(t *testing.T) {
+	h := newAuditedHandler(t)
+
+	doRequest(h, "GET", "/?query=Boyd&limit=1", "", nil)
+	doRequest(h, "GET", "/users/1", "", nil)
+	doRequest(h, "POST", "/rpc", `{"jsonrpc": "2.0", "method": "search.getUser", "params": {"id": 2}, "id": 1}`, nil)
+	doRequest(h, "GET", "/?limit=1", "", map[string]string{"AccessToken": "bad"})
+
+	entries := queryAudit(t, h, "/admin/audit?token="+TokenFingerprint(accessToken))
+	if len(entries) != 3 {
+		t.Fatalf("Error : unexpected entries %+v", entries)
+	}
+	if entries[0].Path != "/" || entries[0].Query != "query=Boyd&limit=1" || entries[0].Status != http.StatusOK || entries[0].Scope != ScopeSearch {
+		t.Errorf("Error : unexpected entry %+v", entries[0])
+	}
+	if entries[1].Path != "/users/1" {
+		t.Errorf("Error : unexpected entry %+v", entries[1])
+	}
+	if !strings.Contains(entries[2].Body, "search.getUser") {
+		t.Errorf("Error : unexpected entry %+v", entries[2])
+	}
+	for _, e := range entries {
+		if e.Token == accessToken {
+			t.Errorf("Error : raw token in audit log")
+		}
+	}
+}
+
+func TestAuditLogRedacted(t *testing.T) {
+	h := newAuditedHandler(t)
+
+	doRequest(h, "GET", "/?query=Boyd&limit=1&api_key=secret-key", "", nil)
+	doRequest(h, "POST", "/rpc", `{"jsonrpc": "2.0", "method": "search.getUser", "params": {"id": 2, "password": "hunter2"}, "id": 1}`, nil)
+
+	entries := queryAudit(t, h, "/admin/audit?token="+TokenFingerprint(accessToken))
+	if len(entries) != 2 {
+		t.Fatalf("Error : unexpected entries %+v", entries)
+	}
+	if entries[0].Query != "query=Boyd&limit=1&api_key=REDACTED" {
+		t.Errorf("Error : unexpected query %q", entries[0].Query)
+	}
+	if strings.Contains(entries[1].Body, "hunter2") || !strings.Contains(entries[1].Body, "search.getUser") {
+		t.Errorf("Error : unexpected body %q", entries[1].Body)
+	}
+}
+
+func TestAuditLogBodyLimit(t *testing.T) {
+	h := newAuditedHandler(t)
+
+	// журнал читает только начало тела, лимит обработчика должен сработать как без аудита
+	w := doRequest(h, http.MethodPost, "/graphql", `{"query": "`+strings.Repeat(" ", maxGraphQLBytes)+`"}`, nil)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+
+	entries := queryAudit(t, h, "/admin/audit?token="+TokenFingerprint(accessToken))
+	if len(entries) != 1 || len(entries[0].Body) > auditBodyLimit || entries[0].Status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Error : unexpected entries %.200v", entries)
+	}
+}
+
+func TestAuditLogFilters(t *testing.T) {
+	h := newAuditedHandler(t)
+	for i := 0; i < 3; i++ {
+		doRequest(h, "GET", "/?limit=1", "", nil)
+	}
+
+	if entries := queryAudit(t, h, "/admin/audit?limit=2"); len(entries) != 2 {
+		t.Errorf("Error : unexpected entries %+v", entries)
+	}
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if entries := queryAudit(t, h, "/admin/audit?since="+future); len(entries) != 0 {
+		t.Errorf("Error : unexpected entries %+v", entries)
+	}
+
+	w := doRequest(h, "GET", "/admin/audit?since=yesterday", "", map[string]string{"AccessToken": adminToken})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+	w = doRequest(h, "GET", "/admin/audit", "", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+}
+
+func TestAuditLogWriteFailures(t *testing.T) {
+	h := newAuditedHandler(t)
+	audit := h.cfg.AuditLog
+	doRequest(h, "GET", "/?limit=1", "", nil)
+
+	// запись сломалась: потери видны в /admin/stats, а уже записанное по-прежнему читается
+	audit.file.Close()
+	doRequest(h, "GET", "/?limit=1", "", nil)
+	doRequest(h, "GET", "/?limit=1", "", nil)
+	stats := StatsResponse{}
+	json.Unmarshal(doRequest(h, "GET", "/admin/stats", "", adminHeader).Body.Bytes(), &stats)
+	if stats.AuditFailures != 2 || audit.Failures() != 3 {
+		t.Errorf("Error : %d failures in stats, %d in log", stats.AuditFailures, audit.Failures())
+	}
+	if entries := queryAudit(t, h, "/admin/audit?token="+TokenFingerprint(accessToken)); len(entries) != 1 {
+		t.Errorf("Error : unexpected entries %+v", entries)
+	}
+}
+
+// выборка не держит блокировку записи: журнал дописывается, пока его читают
+func TestAuditLogQueryWhileWriting(t *testing.T) {
+	h := newAuditedHandler(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			doRequest(h, "GET", "/?limit=1", "", nil)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		queryAudit(t, h, "/admin/audit?token="+TokenFingerprint(accessToken))
+	}
+	<-done
+	if entries := queryAudit(t, h, "/admin/audit?token="+TokenFingerprint(accessToken)); len(entries) != 200 {
+		t.Errorf("Error : %d entries, want 200", len(entries))
+	}
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	w := doRequest(newTestHandler(), "GET", "/admin/audit", "", map[string]string{"AccessToken": adminToken})
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+}
+
+// потоковая выдача должна работать и через обёртку аудита
+func TestAuditLogStream(t *testing.T) {
+	h := newAuditedHandler(t)
+	w := doRequest(h, "GET", "/?stream=true&limit=3", "", nil)
+	if strings.Count(w.Body.String(), "\n") != 3 || !w.Flushed {
+		t.Errorf("Error : unexpected stream %q", w.Body.String())
+	}
+}
@@ -0,0 +1,41 @@
+package searchserver
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// буферы больше этого в пул не возвращаем: одна огромная выдача не должна навсегда занять память
+const maxPooledBuffer = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// countingWriter считает записанные байты: если кодирование упало до первой записи,
+// клиенту ещё можно отдать 500
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
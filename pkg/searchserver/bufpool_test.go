@@ -0,0 +1,42 @@
+package searchserver
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+// буферы из пула переиспользуются, закэшированные страницы от этого портиться не должны
+func TestPooledBuffersAndCache(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, CacheSize: 10})
+
+	first := doRequest(s, "GET", "/?query=nisi&limit=5", "", nil)
+	if first.Header().Get("Content-Length") != strconv.Itoa(first.Body.Len()) {
+		t.Errorf("Error : unexpected Content-Length %q for %d bytes", first.Header().Get("Content-Length"), first.Body.Len())
+	}
+	doRequest(s, "GET", "/?query=Boyd&limit=25", "", nil)
+
+	hit := doRequest(s, "GET", "/?query=nisi&limit=5", "", nil)
+	if hit.Header().Get("X-Cache") != "HIT" || !bytes.Equal(hit.Body.Bytes(), first.Body.Bytes()) {
+		t.Errorf("Error : cached page differs: %s", hit.Body)
+	}
+
+	// без кэша ответ пишется прямо в ResponseWriter
+	s = NewServer(users, testServerConfig)
+	direct := doRequest(s, "GET", "/?query=nisi&limit=5", "", nil)
+	if direct.Code != 200 || !bytes.Equal(direct.Body.Bytes(), first.Body.Bytes()) {
+		t.Errorf("Error : unexpected response %d %s", direct.Code, direct.Body)
+	}
+}
+
+func TestPutBufferDropsLarge(t *testing.T) {
+	buf := getBuffer()
+	buf.Grow(2 * maxPooledBuffer)
+	putBuffer(buf)
+	for i := 0; i < 10; i++ {
+		if b := getBuffer(); b.Cap() > maxPooledBuffer {
+			t.Errorf("Error : large buffer pooled")
+		}
+	}
+}
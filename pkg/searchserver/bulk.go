@@ -0,0 +1,138 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"final_task_golang/pkg/model"
+)
+
+const (
+	// сколько правок принимает POST /users/bulk, если MaxBulk не задан
+	defaultMaxBulk = 1000
+	maxBulkBytes   = 16 << 20
+)
+
+// bulkUsers - POST /users/bulk: массив BulkOperation, например
+// [{"Op": "create", "User": {...}}, {"Op": "update", "Id": 3, "Version": 2, "User": {...}}, {"Op": "delete", "Id": 5}].
+// Правки применяются по порядку, каждая целиком или никак: ошибка одной не откатывает
+// и не останавливает остальные. Ответ - массив BulkResult в том же порядке
+func (s *Server) bulkUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBulkBytes))
+	if err != nil {
+		writeError(w, bodyErrorStatus(err), err.Error())
+		return
+	}
+	var ops []model.BulkOperation
+	max := s.cfg.MaxBulk
+	if max <= 0 {
+		max = defaultMaxBulk
+	}
+	if err := json.Unmarshal(body, &ops); err != nil || len(ops) == 0 || len(ops) > max {
+		writeError(w, http.StatusBadRequest, model.ErrorBadBulk)
+		return
+	}
+
+	redact := s.redacts(requestScope(r))
+	results := make([]model.BulkResult, len(ops))
+	s.mu.Lock()
+	// все правки - в одну копию среза, датасет подменяется один раз в конце
+	users := make([]model.User, len(s.users))
+	copy(users, s.users)
+	positions := make(map[int]int, len(users))
+	nextID := 0
+	for i, u := range users {
+		positions[u.Id] = i
+		if u.Id >= nextID {
+			nextID = u.Id + 1
+		}
+	}
+	changed := false
+	for n, op := range ops {
+		u, status, code := applyBulk(op, users, positions, nextID)
+		if code != "" {
+			results[n] = model.BulkResult{Status: status, Error: &model.SearchErrorResponse{Error: code}}
+			continue
+		}
+		if err := s.recordChange(replicationChange{Op: "put", User: u}); err != nil {
+			results[n] = model.BulkResult{Status: http.StatusInternalServerError, Error: &model.SearchErrorResponse{Error: err.Error()}}
+			continue
+		}
+		changed = true
+		if i, ok := positions[u.Id]; ok {
+			users[i] = u
+		} else {
+			positions[u.Id] = len(users)
+			users = append(users, u)
+			nextID++
+		}
+		switch op.Op {
+		case model.BulkCreate:
+			s.emit(WebhookEvent{Type: EventUserCreated, User: &u})
+		case model.BulkDelete:
+			s.emit(WebhookEvent{Type: EventUserDeleted, User: &u})
+		default:
+			s.emit(WebhookEvent{Type: EventUserUpdated, User: &u})
+		}
+		results[n].Status = status
+		if status != http.StatusNoContent {
+			if redact {
+				u = s.redactUser(u)
+			}
+			results[n].User = &u
+		}
+	}
+	if changed {
+		s.setUsers(users)
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, results)
+}
+
+// applyBulk проверяет правку и возвращает запись после неё, users не меняется.
+// При ошибке code - код для BulkResult.Error
+func applyBulk(op model.BulkOperation, users []model.User, positions map[int]int, nextID int) (u model.User, status int, code string) {
+	if op.Op == model.BulkCreate {
+		if op.User == nil {
+			return u, http.StatusBadRequest, model.ErrorBadUser
+		}
+		u = *op.User
+		u.Id, u.Version, u.Deleted = nextID, 1, false
+		if err := validateUser(u); err != nil {
+			return u, http.StatusBadRequest, model.ErrorBadUser + ": " + err.Error()
+		}
+		return u, http.StatusCreated, ""
+	}
+	if op.Op != model.BulkUpdate && op.Op != model.BulkDelete {
+		return u, http.StatusBadRequest, model.ErrorBadBulk
+	}
+
+	i, ok := positions[op.Id]
+	if !ok || users[i].Deleted {
+		return u, http.StatusNotFound, model.ErrorUserNotFound
+	}
+	current := users[i]
+	if op.Version != 0 && op.Version != current.Version {
+		return u, http.StatusConflict, model.ErrorVersionMismatch
+	}
+	if op.Op == model.BulkDelete {
+		current.Deleted = true
+		current.Version++
+		return current, http.StatusNoContent, ""
+	}
+	if op.User == nil {
+		return u, http.StatusBadRequest, model.ErrorBadUser
+	}
+	u = *op.User
+	u.Id, u.Version, u.Deleted = op.Id, current.Version+1, false
+	if err := validateUser(u); err != nil {
+		return u, http.StatusBadRequest, model.ErrorBadUser + ": " + err.Error()
+	}
+	return u, http.StatusOK, ""
+}
@@ -0,0 +1,99 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestBulkUsers(t *testing.T) {
+	users := newTestHandler().snapshot()
+	h := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, MaxBulk: 7})
+	defer h.Close()
+	nextID := 0
+	for _, u := range users {
+		if u.Id >= nextID {
+			nextID = u.Id + 1
+		}
+	}
+
+	updated := users[1]
+	updated.Name = "Bulk Updated"
+	ops := []model.BulkOperation{
+		{Op: model.BulkCreate, User: &model.User{Name: "Bulk Created", Age: 30, Gender: "female"}},
+		{Op: model.BulkCreate, User: &model.User{Name: "Bad", Gender: "other"}},
+		{Op: model.BulkUpdate, Id: users[0].Id, Version: users[0].Version + 1, User: &users[0]},
+		{Op: model.BulkUpdate, Id: updated.Id, User: &updated},
+		{Op: model.BulkDelete, Id: users[2].Id},
+		{Op: model.BulkDelete, Id: users[2].Id},
+		{Op: "upsert", Id: users[3].Id},
+	}
+	body, _ := json.Marshal(ops)
+	rec := doRequest(h, "POST", "/users/bulk", string(body), adminHeader)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", rec.Code, rec.Body)
+	}
+	results := []model.BulkResult{}
+	json.Unmarshal(rec.Body.Bytes(), &results)
+	if len(results) != len(ops) {
+		t.Fatalf("Error : unexpected results %+v", results)
+	}
+	if r := results[0]; r.Status != http.StatusCreated || r.User == nil || r.User.Id != nextID || r.User.Version != 1 {
+		t.Errorf("Error : unexpected create %+v", r)
+	}
+	expected := []struct {
+		status int
+		code   string
+	}{
+		1: {http.StatusBadRequest, ""},
+		2: {http.StatusConflict, model.ErrorVersionMismatch},
+		5: {http.StatusNotFound, model.ErrorUserNotFound},
+		6: {http.StatusBadRequest, model.ErrorBadBulk},
+	}
+	for i, e := range expected {
+		if e.status == 0 {
+			continue
+		}
+		r := results[i]
+		if r.Status != e.status || r.Error == nil || e.code != "" && r.Error.Error != e.code {
+			t.Errorf("Error : op %d: unexpected result %+v", i, r)
+		}
+	}
+	if r := results[3]; r.Status != http.StatusOK || r.User == nil || r.User.Name != "Bulk Updated" || r.User.Version != updated.Version+1 {
+		t.Errorf("Error : unexpected update %+v", r)
+	}
+	if r := results[4]; r.Status != http.StatusNoContent || r.User != nil || r.Error != nil {
+		t.Errorf("Error : unexpected delete %+v", r)
+	}
+
+	if rec := doRequest(h, "GET", "/users/"+strconv.Itoa(nextID), "", nil); rec.Code != http.StatusOK || decodeUser(t, rec).Name != "Bulk Created" {
+		t.Errorf("Error : created user not found, %d", rec.Code)
+	}
+	if rec := doRequest(h, "GET", "/users/"+strconv.Itoa(users[2].Id), "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Error : deleted user found, %d", rec.Code)
+	}
+	if u := decodeUser(t, doRequest(h, "GET", "/users/"+strconv.Itoa(users[0].Id), "", nil)); u.Version != users[0].Version {
+		t.Errorf("Error : failed update applied %+v", u)
+	}
+
+	ops = append(ops, model.BulkOperation{Op: model.BulkDelete, Id: users[4].Id})
+	body, _ = json.Marshal(ops)
+	for _, body := range []string{`{}`, `[]`, string(body)} {
+		if rec := doRequest(h, "POST", "/users/bulk", body, adminHeader); rec.Code != http.StatusBadRequest {
+			t.Errorf("Error : %.20s: unexpected status %d", body, rec.Code)
+		}
+	}
+	if rec := doRequest(h, "POST", "/users/bulk", `[{"Op": "`+strings.Repeat(" ", maxBulkBytes)+`"}]`, adminHeader); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+	if rec := doRequest(h, "POST", "/users/bulk", `[{"Op": "delete", "Id": 1}]`, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Error : bulk allowed for search token, %d", rec.Code)
+	}
+	if rec := doRequest(h, "GET", "/users/bulk", "", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+}
@@ -0,0 +1,137 @@
+package searchserver
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// resultCache - LRU закодированных страниц поиска. Любое изменение датасета
+// сбрасывает кэш целиком и меняет поколение, так что страница, посчитанная
+// по старым данным, в кэш уже не попадёт
+type resultCache struct {
+	mu         sync.Mutex
+	size       int
+	generation uint64
+	// сколько живёт страница, 0 - пока не вытеснят
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type cachedPage struct {
+	key         string
+	contentType string
+	body        []byte
+	// когда страница посчитана, для заголовка Age
+	stored time.Time
+	// когда страница устаревает, нулевое время - никогда
+	expires time.Time
+}
+
+// CacheStats - счётчики кэша результатов
+type CacheStats struct {
+	Size      int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+func newResultCache(size int) *resultCache {
+	return &resultCache{
+		size:  size,
+		items: map[string]*list.Element{},
+		order: list.New(),
+	}
+}
+
+func (c *resultCache) get(key string) (cachedPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if ok {
+		if expires := el.Value.(cachedPage).expires; !expires.IsZero() && time.Now().After(expires) {
+			c.order.Remove(el)
+			delete(c.items, key)
+			ok = false
+		}
+	}
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return cachedPage{}, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.order.MoveToFront(el)
+	return el.Value.(cachedPage), true
+}
+
+// contains проверяет, есть ли живая страница, не трогая счётчики и порядок вытеснения
+func (c *resultCache) contains(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	expires := el.Value.(cachedPage).expires
+	return expires.IsZero() || !time.Now().After(expires)
+}
+
+// currentGeneration нужно взять до чтения датасета и передать в put
+func (c *resultCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+func (c *resultCache) put(generation uint64, page cachedPage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	page.stored = time.Now()
+	if c.ttl > 0 {
+		page.expires = page.stored.Add(c.ttl)
+	}
+	if el, ok := c.items[page.key]; ok {
+		el.Value = page
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[page.key] = c.order.PushFront(page)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(cachedPage).key)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+func (c *resultCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+}
+
+func (c *resultCache) stats() CacheStats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Size:      size,
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+	}
+}
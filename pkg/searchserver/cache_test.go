@@ -0,0 +1,96 @@
+package searchserver
+
+import (
+	"net/http"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func newCachedTestHandler(size int) *Server {
+	users, err := LoadDataset("../../dataset.xml")
+	if err != nil {
+		panic(err)
+	}
+	cfg := testServerConfig
+	cfg.CacheSize = size
+	return NewServer(users, cfg)
+}
+
+func TestResultCacheHit(t *testing.T) {
+	h := newCachedTestHandler(10)
+
+	first := doRequest(h, http.MethodGet, "/?query=Boyd&order_by=1", "", nil)
+	// тот же запрос в другой записи: order_field по умолчанию Name, лишние параметры игнорируются
+	second := doRequest(h, http.MethodGet, "/?order_by=1&order_field=Name&query=Boyd&foo=bar", "", nil)
+
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Error : %v %v", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("Error : cached body differs")
+	}
+	if stats := h.CacheStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("Error : unexpected stats %+v", stats)
+	}
+}
+
+func TestResultCacheFormats(t *testing.T) {
+	h := newCachedTestHandler(10)
+
+	doRequest(h, http.MethodGet, "/", "", nil)
+	w := doRequest(h, http.MethodGet, "/", "", map[string]string{"Accept": "text/csv"})
+
+	if w.Header().Get("X-Cache") != "MISS" || w.Header().Get("Content-Type") != "text/csv" {
+		t.Errorf("Error : json page served for csv request")
+	}
+}
+
+func TestResultCacheInvalidation(t *testing.T) {
+	h := newCachedTestHandler(10)
+
+	doRequest(h, http.MethodGet, "/?query=Boyd", "", nil)
+	doRequest(h, http.MethodPatch, "/users/0", `{"Name": "Boyd Fox"}`, adminHeader)
+	w := doRequest(h, http.MethodGet, "/?query=Boyd", "", nil)
+
+	if w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("Error : stale page served after update")
+	}
+
+	h.Reload([]model.User{})
+	w = doRequest(h, http.MethodGet, "/?query=Boyd", "", nil)
+	if w.Header().Get("X-Cache") != "MISS" || w.Body.String() != "[]\n" {
+		t.Errorf("Error : stale page served after reload, %v", w.Body.String())
+	}
+}
+
+func TestResultCacheEviction(t *testing.T) {
+	h := newCachedTestHandler(2)
+
+	doRequest(h, http.MethodGet, "/?query=a", "", nil)
+	doRequest(h, http.MethodGet, "/?query=b", "", nil)
+	doRequest(h, http.MethodGet, "/?query=a", "", nil)
+	doRequest(h, http.MethodGet, "/?query=c", "", nil)
+
+	if w := doRequest(h, http.MethodGet, "/?query=a", "", nil); w.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Error : recently used page evicted")
+	}
+	if w := doRequest(h, http.MethodGet, "/?query=b", "", nil); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Error : least recently used page kept")
+	}
+	if stats := h.CacheStats(); stats.Evictions != 2 {
+		t.Errorf("Error : unexpected stats %+v", stats)
+	}
+}
+
+func TestResultCacheStaleGeneration(t *testing.T) {
+	c := newResultCache(10)
+
+	generation := c.currentGeneration()
+	c.invalidate()
+	c.put(generation, cachedPage{key: "k"})
+
+	if _, ok := c.get("k"); ok {
+		t.Errorf("Error : page computed before invalidation was cached")
+	}
+}
@@ -0,0 +1,129 @@
+package searchserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheHint - Cache-Control ответов одной точки, см. ServerConfig.CacheHints
+type CacheHint struct {
+	// наибольшая свежесть ответа. Сама свежесть - десятая часть времени с последней правки датасета,
+	// как эвристика по Last-Modified у http-кэшей: только что изменённый датасет скоро изменится снова.
+	// Ответ по версии на прошедший момент (as_of) уже не изменится и свеж весь MaxAge. 0 - "no-cache"
+	MaxAge time.Duration
+	// сколько после MaxAge кэш может отдавать ответ, обновляя его в фоне
+	StaleWhileRevalidate time.Duration
+	// ответ можно держать в общих кэшах, иначе "private". Ответы зависят от токена, поэтому
+	// в Vary всегда есть AccessToken
+	Public bool
+}
+
+// ParseCacheHints разбирает подсказки вида "/=1m:5m:public,/users/{id}=10s": точка (как в
+// /admin/stats, "/" - поиск), MaxAge, необязательные StaleWhileRevalidate и public
+func ParseCacheHints(s string) (map[string]CacheHint, error) {
+	hints := map[string]CacheHint{}
+	if strings.TrimSpace(s) == "" {
+		return hints, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		endpoint, def, ok := strings.Cut(strings.TrimSpace(item), "=")
+		parts := strings.Split(def, ":")
+		if !ok || !strings.HasPrefix(endpoint, "/") || len(parts) > 3 {
+			return nil, fmt.Errorf("bad cache hint %q, want /endpoint=max-age[:stale-while-revalidate][:public]", item)
+		}
+		var hint CacheHint
+		var err error
+		if hint.MaxAge, err = time.ParseDuration(parts[0]); err != nil {
+			return nil, fmt.Errorf("bad cache hint %q: %v", item, err)
+		}
+		for _, p := range parts[1:] {
+			if p == "public" {
+				hint.Public = true
+			} else if hint.StaleWhileRevalidate, err = time.ParseDuration(p); err != nil {
+				return nil, fmt.Errorf("bad cache hint %q: %v", item, err)
+			}
+		}
+		hints[endpoint] = hint
+	}
+	return hints, nil
+}
+
+// cacheControl - Cache-Control ответа со статусом status на r
+func (s *Server) cacheControl(r *http.Request, status int, changedAt time.Time) string {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || strings.HasPrefix(r.URL.Path, "/admin/") {
+		return "no-store"
+	}
+	hint, ok := s.cfg.CacheHints[endpointName(r.URL.Path)]
+	if !ok || status != http.StatusOK && status != http.StatusNotModified || r.URL.Query().Get("stream") == "true" {
+		return "no-store"
+	}
+	if hint.MaxAge <= 0 {
+		return "no-cache"
+	}
+	visibility := "private"
+	if hint.Public {
+		visibility = "public"
+	}
+	if t, err := time.Parse(time.RFC3339, r.URL.Query().Get("as_of")); err == nil && !t.After(time.Now()) {
+		return visibility + ", max-age=" + seconds(hint.MaxAge) + ", immutable"
+	}
+	fresh := time.Since(changedAt) / 10
+	if fresh > hint.MaxAge {
+		fresh = hint.MaxAge
+	}
+	cc := visibility + ", max-age=" + seconds(fresh)
+	if hint.StaleWhileRevalidate > 0 {
+		cc += ", stale-while-revalidate=" + seconds(hint.StaleWhileRevalidate)
+	}
+	return cc
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+// cacheHintWriter ставит Cache-Control и Last-Modified, когда становится известен статус ответа.
+// Age ставит сам обработчик, если отдаёт страницу из кэша результатов
+type cacheHintWriter struct {
+	http.ResponseWriter
+	s     *Server
+	r     *http.Request
+	wrote bool
+}
+
+func (c *cacheHintWriter) WriteHeader(status int) {
+	if !c.wrote {
+		c.wrote = true
+		changedAt := time.Unix(0, c.s.changedAt.Load())
+		h := c.Header()
+		cc := c.s.cacheControl(c.r, status, changedAt)
+		h.Set("Cache-Control", cc)
+		if cc == "no-store" {
+			h.Del("Age")
+		} else {
+			h.Add("Vary", "AccessToken")
+			h.Set("Last-Modified", changedAt.UTC().Format(http.TimeFormat))
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *cacheHintWriter) Write(p []byte) (int, error) {
+	if !c.wrote {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *cacheHintWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *cacheHintWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
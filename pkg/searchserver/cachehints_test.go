@@ -0,0 +1,94 @@
+package searchserver
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestParseCacheHints(t *testing.T) {
+	hints, err := ParseCacheHints("/=1m:5m:public, /users/{id}=10s")
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if hints["/"] != (CacheHint{MaxAge: time.Minute, StaleWhileRevalidate: 5 * time.Minute, Public: true}) ||
+		hints["/users/{id}"] != (CacheHint{MaxAge: 10 * time.Second}) {
+		t.Errorf("Error : unexpected hints %+v", hints)
+	}
+	for _, bad := range []string{"/=soon", "search=1m", "/=1m:5m:public:x"} {
+		if _, err := ParseCacheHints(bad); err == nil {
+			t.Errorf("Error : %q accepted", bad)
+		}
+	}
+}
+
+func TestCacheHints(t *testing.T) {
+	users, err := LoadDataset("../../dataset.xml")
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	cfg := testServerConfig
+	cfg.CacheSize = 10
+	cfg.History = 2
+	cfg.CacheHints = map[string]CacheHint{
+		"/":           {MaxAge: time.Hour, StaleWhileRevalidate: time.Minute, Public: true},
+		"/users/{id}": {},
+	}
+	h := NewServer(users, cfg)
+	// датасет менялся 100 секунд назад: свежесть - десятая часть, 10 секунд
+	h.changedAt.Store(time.Now().Add(-100 * time.Second).UnixNano())
+
+	w := doRequest(h, http.MethodGet, "/?query=Boyd", "", nil)
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=10, stale-while-revalidate=60" {
+		t.Errorf("Error : Cache-Control %q", cc)
+	}
+	if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "AccessToken") || w.Header().Get("Last-Modified") == "" {
+		t.Errorf("Error : unexpected headers %v", w.Header())
+	}
+	if w.Header().Get("Age") != "" {
+		t.Errorf("Error : Age on a fresh page")
+	}
+	hit := doRequest(h, http.MethodGet, "/?query=Boyd", "", nil)
+	if hit.Header().Get("X-Cache") != "HIT" || hit.Header().Get("Age") != "0" {
+		t.Errorf("Error : cache hit without Age: %v", hit.Header())
+	}
+	f := model.ParseFreshness(hit.Header())
+	if f == nil || f.MaxAge != 10*time.Second || f.StaleWhileRevalidate != time.Minute {
+		t.Errorf("Error : unexpected freshness %+v", f)
+	}
+
+	asOf := time.Now().UTC().Format(time.RFC3339Nano)
+	if cc := doRequest(h, http.MethodGet, "/?as_of="+asOf, "", nil).Header().Get("Cache-Control"); cc != "public, max-age=3600, immutable" {
+		t.Errorf("Error : as_of Cache-Control %q", cc)
+	}
+	for _, c := range []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/?stream=true", "no-store"},
+		{http.MethodGet, "/?limit=-1", "no-store"},
+		{http.MethodGet, "/users/0", "no-cache"},
+		{http.MethodPatch, "/users/0", "no-store"},
+		{http.MethodGet, "/saved", "no-store"},
+		{http.MethodGet, "/admin/stats", "no-store"},
+	} {
+		w := doRequest(h, c.method, c.path, `{"Age": 30}`, adminHeader)
+		if cc := w.Header().Get("Cache-Control"); cc != c.want {
+			t.Errorf("Error : %s %s: Cache-Control %q, want %q", c.method, c.path, cc, c.want)
+		}
+	}
+
+	// правка только что была - свежести нет
+	if cc := doRequest(h, http.MethodGet, "/?query=Boyd", "", nil).Header().Get("Cache-Control"); cc != "public, max-age=0, stale-while-revalidate=60" {
+		t.Errorf("Error : Cache-Control after update %q", cc)
+	}
+}
+
+func TestCacheHintsDisabled(t *testing.T) {
+	w := doRequest(newCachedTestHandler(10), http.MethodGet, "/", "", nil)
+	if cc := w.Header().Get("Cache-Control"); cc != "" {
+		t.Errorf("Error : Cache-Control %q without CacheHints", cc)
+	}
+}
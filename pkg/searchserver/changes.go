@@ -0,0 +1,79 @@
+package searchserver
+
+import (
+	"net/http"
+	"sort"
+
+	"final_task_golang/pkg/model"
+)
+
+// versionChanges сравнивает две версии по Id, в порядке Id
+func versionChanges(from, to []model.User, includeDeleted bool) (added, updated []model.User, removed []int) {
+	before := make(map[int]model.User, len(from))
+	for _, u := range from {
+		if includeDeleted || !u.Deleted {
+			before[u.Id] = u
+		}
+	}
+	added, updated, removed = []model.User{}, []model.User{}, []int{}
+	for _, u := range to {
+		if u.Deleted && !includeDeleted {
+			continue
+		}
+		old, ok := before[u.Id]
+		delete(before, u.Id)
+		switch {
+		case !ok:
+			added = append(added, u)
+		case old != u:
+			updated = append(updated, u)
+		}
+	}
+	for id := range before {
+		removed = append(removed, id)
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Id < added[j].Id })
+	sort.Slice(updated, func(i, j int) bool { return updated[i].Id < updated[j].Id })
+	sort.Ints(removed)
+	return added, updated, removed
+}
+
+// changes - GET /changes?since=<snapshot_id>: правки с версии since, которую клиент получил
+// в X-Snapshot-Id или в прошлом ответе. Сервер помнит последние ServerConfig.History версий,
+// более старая - 410, и клиенту надо выгрузить датасет заново: без since весь датасет
+// приходит в Added вместе с номером версии для следующего запроса
+func (s *Server) changes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.aggregator != nil {
+		writeError(w, http.StatusNotImplemented, "aggregator has no dataset")
+		return
+	}
+	since := r.URL.Query().Get("since")
+	from, current := s.history.byID(since)
+	if since == "" {
+		// без since - выгрузка всего датасета, с которой начинается синхронизация
+		from = &historyVersion{}
+	}
+	if from == nil {
+		writeError(w, http.StatusGone, model.ErrorChangesUnavailable)
+		return
+	}
+
+	scope := requestScope(r)
+	resp := model.ChangesResponse{Since: since, Snapshot: current.id}
+	resp.Added, resp.Updated, resp.Removed = versionChanges(from.users, current.users, scope == ScopeAdmin)
+	if s.redacts(scope) {
+		for _, list := range [][]model.User{resp.Added, resp.Updated} {
+			for i := range list {
+				list[i] = s.redactUser(list[i])
+			}
+		}
+	}
+	w.Header().Set(model.SnapshotHeader, current.id)
+	// полная выгрузка большого датасета медленному клиенту может идти дольше WriteTimeout
+	writeJSON(s.rollingDeadline(w), http.StatusOK, resp)
+}
@@ -0,0 +1,50 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestChangesSince(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, History: 5})
+	admin := map[string]string{"AccessToken": adminToken}
+	changes := func(since string, header map[string]string) (int, model.ChangesResponse) {
+		rec := doRequest(s, "GET", "/changes?since="+url.QueryEscape(since), "", header)
+		resp := model.ChangesResponse{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	since := doRequest(s, "GET", "/?limit=1", "", nil).Header().Get(model.SnapshotHeader)
+	doRequest(s, "PATCH", "/users/1", `{"Age": 99}`, admin)
+	doRequest(s, "DELETE", "/users/2", "", admin)
+
+	code, resp := changes(since, nil)
+	if code != http.StatusOK || resp.Since != since || resp.Snapshot == since {
+		t.Fatalf("Error : unexpected response %d %+v", code, resp)
+	}
+	if len(resp.Added) != 0 || len(resp.Updated) != 1 || resp.Updated[0].Age != 99 || len(resp.Removed) != 1 || resp.Removed[0] != 2 {
+		t.Errorf("Error : unexpected changes %+v", resp)
+	}
+
+	// admin видит мягкое удаление как правку записи
+	if _, resp := changes(since, admin); len(resp.Updated) != 2 || !resp.Updated[1].Deleted || len(resp.Removed) != 0 {
+		t.Errorf("Error : unexpected admin changes %+v", resp)
+	}
+
+	// с текущей версии правок нет
+	if code, next := changes(resp.Snapshot, nil); code != http.StatusOK || len(next.Added)+len(next.Updated)+len(next.Removed) != 0 {
+		t.Errorf("Error : unexpected changes since current %d %+v", code, next)
+	}
+	if code, all := changes("", nil); code != http.StatusOK || len(all.Added) != len(users)-1 || all.Snapshot != resp.Snapshot {
+		t.Errorf("Error : unexpected export %d, %d users", code, len(all.Added))
+	}
+	if code, _ := changes("unknown", nil); code != http.StatusGone {
+		t.Errorf("Error : unexpected status %d", code)
+	}
+}
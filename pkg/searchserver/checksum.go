@@ -0,0 +1,82 @@
+package searchserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+// checksumWriter считает sha256 тела ответа для model.ChecksumHeader, см. ServerConfig.ContentChecksum.
+// Обычный ответ копится целиком и уходит вместе с заголовком, поток ndjson пишется как есть,
+// а сумма приходит трейлером
+type checksumWriter struct {
+	w      http.ResponseWriter
+	hash   hash.Hash
+	buf    bytes.Buffer
+	status int
+	// заголовки уже решены: ответ копится или пишется потоком
+	started   bool
+	streaming bool
+}
+
+func newChecksumWriter(w http.ResponseWriter) *checksumWriter {
+	return &checksumWriter{w: w, hash: sha256.New(), status: http.StatusOK}
+}
+
+func (c *checksumWriter) Header() http.Header {
+	return c.w.Header()
+}
+
+func (c *checksumWriter) WriteHeader(status int) {
+	if c.started {
+		return
+	}
+	c.started, c.status = true, status
+	if strings.HasPrefix(c.w.Header().Get("Content-Type"), "application/x-ndjson") {
+		c.streaming = true
+		c.w.Header().Set("Trailer", model.ChecksumHeader)
+		c.w.WriteHeader(status)
+	}
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	c.WriteHeader(http.StatusOK)
+	c.hash.Write(p)
+	if c.streaming {
+		return c.w.Write(p)
+	}
+	return c.buf.Write(p)
+}
+
+// Flush нужен только потоку, обычный ответ всё равно уходит в finish
+func (c *checksumWriter) Flush() {
+	if !c.streaming {
+		return
+	}
+	if f, ok := c.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap даёт http.ResponseController дойти до соединения
+func (c *checksumWriter) Unwrap() http.ResponseWriter {
+	return c.w
+}
+
+// finish отправляет накопленный ответ с суммой или дописывает трейлер потока
+func (c *checksumWriter) finish() {
+	sum := hex.EncodeToString(c.hash.Sum(nil))
+	c.w.Header().Set(model.ChecksumHeader, sum)
+	if c.streaming {
+		return
+	}
+	c.w.Header().Set("Content-Length", strconv.Itoa(c.buf.Len()))
+	c.w.WriteHeader(c.status)
+	c.w.Write(c.buf.Bytes())
+}
@@ -0,0 +1,46 @@
+package searchserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestContentChecksum(t *testing.T) {
+	cfg := testServerConfig
+	cfg.ContentChecksum = true
+	h := NewServer(newTestHandler().snapshot(), cfg)
+
+	for _, target := range []string{"/?query=nisi&limit=3", "/?order_field=Unknown&order_by=1", "/users/1"} {
+		rec := doRequest(h, "GET", target, "", nil)
+		sum := sha256.Sum256(rec.Body.Bytes())
+		if rec.Header().Get(model.ChecksumHeader) != hex.EncodeToString(sum[:]) || rec.Body.Len() == 0 {
+			t.Errorf("Error : %s: checksum %q for %d %s", target, rec.Header().Get(model.ChecksumHeader), rec.Code, rec.Body)
+		}
+	}
+
+	// поток уходит сразу, сумма - трейлером
+	server := httptest.NewServer(h)
+	defer server.Close()
+	req, _ := http.NewRequest("GET", server.URL+"/?stream=true", nil)
+	req.Header.Set("AccessToken", accessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	sum := sha256.Sum256(body)
+	if resp.Header.Get(model.ChecksumHeader) != "" || resp.Trailer.Get(model.ChecksumHeader) != hex.EncodeToString(sum[:]) {
+		t.Errorf("Error : unexpected stream checksum %q %q", resp.Header.Get(model.ChecksumHeader), resp.Trailer.Get(model.ChecksumHeader))
+	}
+
+	if rec := doRequest(NewServer(newTestHandler().snapshot(), testServerConfig), "GET", "/", "", nil); rec.Header().Get(model.ChecksumHeader) != "" {
+		t.Errorf("Error : checksum without ContentChecksum")
+	}
+}
@@ -0,0 +1,78 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+var localeNames = []string{"Zoe", "Ärlig", "Olaf", "Émile", "Anna", "Öberg", "Eva"}
+
+func newLocaleHandler(locale string) *Server {
+	users := make([]model.User, len(localeNames))
+	for i, name := range localeNames {
+		users[i] = model.User{Id: i, Name: name}
+	}
+	return NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, OrderLocale: locale, CacheSize: 10})
+}
+
+func searchNames(t *testing.T, h http.Handler, params string) string {
+	w := doRequest(h, http.MethodGet, "/?"+params, "", nil)
+	users := []model.User{}
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+		t.Fatalf("Error : %v %s", err, w.Body.String())
+	}
+	names := []string{}
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSearchOrderLocale(t *testing.T) {
+	h := newLocaleHandler("")
+
+	cases := map[string]string{
+		"order_by=-1":                 "Anna,Eva,Olaf,Zoe,Ärlig,Émile,Öberg",
+		"order_by=-1&order_locale=de": "Anna,Ärlig,Émile,Eva,Öberg,Olaf,Zoe",
+		"order_by=1&order_locale=de":  "Zoe,Olaf,Öberg,Eva,Émile,Ärlig,Anna",
+		"order_by=-1&order_locale=sv": "Anna,Émile,Eva,Olaf,Zoe,Ärlig,Öberg",
+		// локаль влияет только на Name
+		"order_by=-1&order_field=Id&order_locale=de": "Zoe,Ärlig,Olaf,Émile,Anna,Öberg,Eva",
+	}
+	for params, expected := range cases {
+		if names := searchNames(t, h, params); names != expected {
+			t.Errorf("Error : %s: %s != %s", params, names, expected)
+		}
+	}
+
+	w := doRequest(h, http.MethodGet, "/?order_by=-1&order_locale=not_a_locale!", "", nil)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "ErrorBadOrderLocale") {
+		t.Errorf("Error : %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSearchDefaultOrderLocale(t *testing.T) {
+	h := newLocaleHandler("de")
+
+	if names := searchNames(t, h, "order_by=-1"); names != "Anna,Ärlig,Émile,Eva,Öberg,Olaf,Zoe" {
+		t.Errorf("Error : %s", names)
+	}
+	if names := searchNames(t, h, "order_by=-1&order_locale=sv"); names != "Anna,Émile,Eva,Olaf,Zoe,Ärlig,Öberg" {
+		t.Errorf("Error : %s", names)
+	}
+}
+
+// индекс по локали перестраивается вместе с датасетом
+func TestOrderLocaleAfterReload(t *testing.T) {
+	h := newLocaleHandler("de")
+	searchNames(t, h, "order_by=-1")
+
+	h.Reload([]model.User{{Id: 1, Name: "Ödön"}, {Id: 2, Name: "Oscar"}})
+	if names := searchNames(t, h, "order_by=-1"); names != "Ödön,Oscar" {
+		t.Errorf("Error : %s", names)
+	}
+}
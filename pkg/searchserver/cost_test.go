@@ -0,0 +1,45 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+func TestMaxQueryCost(t *testing.T) {
+	cfg := testServerConfig
+	// бюджет - ровно поиск подстрокой по всему датасету, сортировка по релевантности его превышает
+	unlimited := newTestHandler()
+	cfg.MaxQueryCost = unlimited.core.Cost(searchcore.Query{Query: "nisi"})
+	h := NewServer(unlimited.snapshot(), cfg)
+
+	if rec := doRequest(h, "GET", "/?query=nisi&limit=5", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Error : query within budget rejected, %d %s", rec.Code, rec.Body)
+	}
+	for _, target := range []string{
+		"/?query=nisi&order_field=Score&order_by=-1",
+		"/?query=nisi&order_field=Score&order_by=-1&stream=true",
+		"/?query=nisi&order_field=Score&order_by=-1&group_by=Gender",
+	} {
+		rec := doRequest(h, "GET", target, "", nil)
+		errResp := model.SearchErrorResponse{}
+		json.Unmarshal(rec.Body.Bytes(), &errResp)
+		if rec.Code != http.StatusBadRequest || errResp.Error != model.ErrorQueryTooExpensive {
+			t.Errorf("Error : %s: unexpected response %d %s", target, rec.Code, rec.Body)
+			continue
+		}
+		if errResp.MaxCost != cfg.MaxQueryCost || errResp.Hint == "" {
+			t.Errorf("Error : %s: unexpected costs %+v", target, errResp)
+		}
+	}
+
+	rec := doRequest(h, "POST", "/msearch", `[{"query": "nisi"}, {"query": "nisi", "order_field": "Score", "order_by": -1}]`, nil)
+	results := []model.MultiSearchResult{}
+	json.Unmarshal(rec.Body.Bytes(), &results)
+	if len(results) != 2 || results[0].Status != http.StatusOK || results[1].Error == nil || results[1].Error.Error != model.ErrorQueryTooExpensive {
+		t.Errorf("Error : unexpected msearch response %s", rec.Body)
+	}
+}
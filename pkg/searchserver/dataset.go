@@ -0,0 +1,192 @@
+package searchserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+// XMLRoot и XMLRow - формат dataset.xml, нужны, чтобы записывать датасеты (например, в тестах)
+type XMLRoot struct {
+	XMLName xml.Name `xml:"root"`
+	Rows    []XMLRow `xml:"row"`
+}
+
+type XMLRow struct {
+	XMLName   xml.Name `xml:"row"`
+	Id        int      `xml:"id"`
+	FirstName string   `xml:"first_name"`
+	LastName  string   `xml:"last_name"`
+	Age       int      `xml:"age"`
+	About     string   `xml:"about"`
+	Gender    string   `xml:"gender"`
+	Company   string   `xml:"company,omitempty"`
+	Email     string   `xml:"email,omitempty"`
+	Phone     string   `xml:"phone,omitempty"`
+	Address   string   `xml:"address,omitempty"`
+}
+
+// FieldMapping - из каких элементов строки датасета заполняются дополнительные поля User.
+// Пустое имя - поле не загружается
+type FieldMapping struct {
+	Email   string
+	Phone   string
+	Company string
+	Address string
+}
+
+// DefaultFieldMapping соответствует dataset.xml
+var DefaultFieldMapping = FieldMapping{Email: "email", Phone: "phone", Company: "company", Address: "address"}
+
+// ParseFieldMapping разбирает маппинг вида "Email=mail,Company=org": поле User=элемент строки.
+// Не упомянутые поля берутся из DefaultFieldMapping, "Phone=" отключает поле
+func ParseFieldMapping(s string) (FieldMapping, error) {
+	m := DefaultFieldMapping
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return m, fmt.Errorf("bad field mapping %q", pair)
+		}
+		source := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "Email":
+			m.Email = source
+		case "Phone":
+			m.Phone = source
+		case "Company":
+			m.Company = source
+		case "Address":
+			m.Address = source
+		default:
+			return m, fmt.Errorf("unknown field %q in mapping", kv[0])
+		}
+	}
+	return m, nil
+}
+
+// LoadDataset читает файл с пользователями (xml или json по расширению) с DefaultFieldMapping
+func LoadDataset(path string) ([]model.User, error) {
+	return LoadDatasetMapping(path, DefaultFieldMapping)
+}
+
+// LoadDatasetMapping читает файл с пользователями и приводит строки к User.
+// Файл .json - массив объектов с теми же ключами, что и элементы строки в xml
+func LoadDatasetMapping(path string, mapping FieldMapping) ([]model.User, error) {
+	fileContent, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := datasetRows(fileContent, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]model.User, 0, len(rows))
+	for i, row := range rows {
+		u, err := rowToUser(row, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", i, err)
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func rowToUser(row map[string]string, mapping FieldMapping) (model.User, error) {
+	id, err := strconv.Atoi(strings.TrimSpace(row["id"]))
+	if err != nil {
+		return model.User{}, fmt.Errorf("bad id: %v", err)
+	}
+	age := 0
+	if v := strings.TrimSpace(row["age"]); v != "" {
+		if age, err = strconv.Atoi(v); err != nil {
+			return model.User{}, fmt.Errorf("bad age: %v", err)
+		}
+	}
+	field := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return row[name]
+	}
+	return model.User{
+		Id:      id,
+		Age:     age,
+		Gender:  row["gender"],
+		About:   row["about"],
+		Name:    row["first_name"] + " " + row["last_name"],
+		Email:   field(mapping.Email),
+		Phone:   field(mapping.Phone),
+		Company: field(mapping.Company),
+		Address: field(mapping.Address),
+	}, nil
+}
+
+// datasetRows разбирает содержимое датасета в строки: json-массив или xml
+func datasetRows(data []byte, isJSON bool) ([]map[string]string, error) {
+	if isJSON {
+		return jsonRows(data)
+	}
+	return xmlRows(data)
+}
+
+// xmlRows читает строки <row> как набор элемент -> текст, чтобы маппинг мог ссылаться на любой элемент
+func xmlRows(data []byte) ([]map[string]string, error) {
+	var root struct {
+		Rows []struct {
+			Fields []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"row"`
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]string, 0, len(root.Rows))
+	for _, r := range root.Rows {
+		row := make(map[string]string, len(r.Fields))
+		for _, f := range r.Fields {
+			row[f.XMLName.Local] = f.Value
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func jsonRows(data []byte) ([]map[string]string, error) {
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]string, 0, len(raw))
+	for _, r := range raw {
+		row := make(map[string]string, len(r))
+		for key, value := range r {
+			value = bytes.TrimSpace(value)
+			if len(value) > 0 && value[0] == '"' {
+				var s string
+				if err := json.Unmarshal(value, &s); err != nil {
+					return nil, err
+				}
+				row[key] = s
+			} else if string(value) != "null" {
+				// числа и bool оставляем как есть в тексте
+				row[key] = string(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
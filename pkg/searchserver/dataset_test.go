@@ -0,0 +1,170 @@
+package searchserver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDatasetExtendedFields(t *testing.T) {
+	users, err := LoadDataset("../../dataset.xml")
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	u := users[0]
+	if u.Name != "Boyd Wolf" || u.Email != "boydwolf@hopeli.com" || u.Phone != "+1 (956) 593-2402" ||
+		u.Company != "HOPELI" || u.Address != "586 Winthrop Street, Edneyville, Mississippi, 9555" {
+		t.Errorf("Error : unexpected user %+v", u)
+	}
+}
+
+func TestLoadDatasetMapping(t *testing.T) {
+	mapping, err := ParseFieldMapping("Company=eyeColor, Phone=")
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	users, err := LoadDatasetMapping("../../dataset.xml", mapping)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if u := users[0]; u.Company != "green" || u.Phone != "" || u.Email != "boydwolf@hopeli.com" {
+		t.Errorf("Error : unexpected user %+v", u)
+	}
+
+	for _, bad := range []string{"Company", "Salary=balance"} {
+		if _, err := ParseFieldMapping(bad); err == nil {
+			t.Errorf("Error : %q parsed", bad)
+		}
+	}
+}
+
+func TestLoadDatasetJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	ioutil.WriteFile(path, []byte(`[
+		{"id": 7, "first_name": "Boyd", "last_name": "Wolf", "age": 22, "gender": "male", "email": "boyd@hopeli.com", "isActive": true},
+		{"id": "8", "first_name": "Hilda", "last_name": "Mayer", "company": null}
+	]`), 0644)
+
+	users, err := LoadDataset(path)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if len(users) != 2 || users[0].Id != 7 || users[0].Age != 22 || users[0].Email != "boyd@hopeli.com" ||
+		users[1].Id != 8 || users[1].Name != "Hilda Mayer" {
+		t.Errorf("Error : unexpected users %+v", users)
+	}
+
+	ioutil.WriteFile(path, []byte(`[{"id": "x"}]`), 0644)
+	if _, err := LoadDataset(path); err == nil {
+		t.Errorf("Error : bad id loaded")
+	}
+}
+
+func TestLoadDatasetSnapshot(t *testing.T) {
+	data, err := ioutil.ReadFile("../../dataset.xml")
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "dataset.xml")
+	ioutil.WriteFile(path, data, 0644)
+
+	parsed, fromSnapshot, err := LoadDatasetSnapshot(path, DefaultFieldMapping)
+	if err != nil || fromSnapshot {
+		t.Fatalf("Error : unexpected result %v %v", fromSnapshot, err)
+	}
+	users, fromSnapshot, err := LoadDatasetSnapshot(path, DefaultFieldMapping)
+	if err != nil || !fromSnapshot || len(users) != len(parsed) || users[3] != parsed[3] {
+		t.Errorf("Error : unexpected result %v %v %v", len(users), fromSnapshot, err)
+	}
+
+	// снимок с другой раскладкой model.User не читается, даже с той же версией
+	info, _ := os.Stat(path)
+	snap, ok := readSnapshot(SnapshotPath(path), info, DefaultFieldMapping, LoadOptions{})
+	if !ok {
+		t.Fatalf("Error : snapshot not written")
+	}
+	snap.Layout = "before-user-version"
+	writeGob(SnapshotPath(path), snap)
+	if _, fromSnapshot, _ := LoadDatasetSnapshot(path, DefaultFieldMapping); fromSnapshot {
+		t.Errorf("Error : snapshot with another layout used")
+	}
+
+	// другой маппинг - снимок не подходит
+	if _, fromSnapshot, _ := LoadDatasetSnapshot(path, FieldMapping{}); fromSnapshot {
+		t.Errorf("Error : snapshot used for another mapping")
+	}
+
+	// файл изменился - снимок устарел
+	ioutil.WriteFile(path, append(data, '\n'), 0644)
+	if users, fromSnapshot, _ := LoadDatasetSnapshot(path, DefaultFieldMapping); fromSnapshot || len(users) != len(parsed) {
+		t.Errorf("Error : stale snapshot used")
+	}
+
+	// битый снимок просто перечитывается
+	ioutil.WriteFile(SnapshotPath(path), []byte("garbage"), 0644)
+	if users, fromSnapshot, err := LoadDatasetSnapshot(path, DefaultFieldMapping); err != nil || fromSnapshot || len(users) != len(parsed) {
+		t.Errorf("Error : unexpected result %v %v %v", len(users), fromSnapshot, err)
+	}
+}
+
+func TestLoadDatasetPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.xml")
+	ioutil.WriteFile(path, []byte(badImport), 0644)
+	keepFirst := func(policy LoadPolicy) LoadOptions {
+		return LoadOptions{Policy: policy, Duplicates: DuplicateKeepFirst}
+	}
+
+	if _, stats, err := LoadDatasetChecked(path, DefaultFieldMapping, keepFirst(LoadFail)); err == nil || stats.Rejected != 2 {
+		t.Errorf("Error : bad rows loaded with fail policy, %+v %v", stats, err)
+	}
+
+	users, stats, err := LoadDatasetChecked(path, DefaultFieldMapping, keepFirst(LoadSkip))
+	expected := LoadStats{Policy: LoadSkip, DuplicatePolicy: DuplicateKeepFirst, Rows: 5, Loaded: 2, Rejected: 2, Duplicates: 1}
+	if err != nil || len(users) != 2 || stats != expected {
+		t.Errorf("Error : unexpected skip result %d %+v %v", len(users), stats, err)
+	}
+
+	users, stats, err = LoadDatasetChecked(path, DefaultFieldMapping, keepFirst(LoadQuarantine))
+	if err != nil || len(users) != 2 || stats.Quarantine != QuarantinePath(path) {
+		t.Fatalf("Error : unexpected quarantine result %d %+v %v", len(users), stats, err)
+	}
+	data, err := ioutil.ReadFile(stats.Quarantine)
+	if err != nil || bytes.Count(data, []byte("\n")) != 2 || !bytes.Contains(data, []byte(`"first_name":"Eve"`)) {
+		t.Errorf("Error : unexpected quarantine file %s %v", data, err)
+	}
+
+	// итог проверки переживает чтение из снимка
+	LoadDatasetSnapshotChecked(path, DefaultFieldMapping, keepFirst(LoadSkip))
+	_, cached, fromSnapshot, err := LoadDatasetSnapshotChecked(path, DefaultFieldMapping, keepFirst(LoadSkip))
+	if err != nil || !fromSnapshot || cached != expected {
+		t.Errorf("Error : unexpected snapshot stats %+v %v %v", cached, fromSnapshot, err)
+	}
+	if _, _, _, err := LoadDatasetSnapshotChecked(path, DefaultFieldMapping, keepFirst(LoadFail)); err == nil {
+		t.Errorf("Error : snapshot of skip policy used for fail policy")
+	}
+}
+
+func TestLoadDatasetDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.json")
+	ioutil.WriteFile(path, []byte(`[
+		{"id": 1, "first_name": "Ann"},
+		{"id": 2, "first_name": "Bob"},
+		{"id": 1, "first_name": "Ann", "last_name": "Lee"}
+	]`), 0644)
+
+	if _, stats, err := LoadDatasetChecked(path, DefaultFieldMapping, LoadOptions{}); err == nil || stats.Duplicates != 1 {
+		t.Errorf("Error : duplicates loaded by default, %+v %v", stats, err)
+	}
+
+	users, stats, err := LoadDatasetChecked(path, DefaultFieldMapping, LoadOptions{Duplicates: DuplicateKeepFirst})
+	if err != nil || len(users) != 2 || users[0].Name != "Ann " || stats.Duplicates != 1 {
+		t.Errorf("Error : unexpected keep-first result %+v %+v %v", users, stats, err)
+	}
+
+	users, _, err = LoadDatasetChecked(path, DefaultFieldMapping, LoadOptions{Duplicates: DuplicateKeepLast})
+	if err != nil || len(users) != 2 || users[0].Id != 2 || users[1].Name != "Ann Lee" {
+		t.Errorf("Error : unexpected keep-last result %+v %v", users, err)
+	}
+}
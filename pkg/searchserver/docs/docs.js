@@ -0,0 +1,113 @@
+// Просмотр /openapi.json без сторонних библиотек: список операций, параметры, ответы и
+// "попробовать" - запрос с токеном из поля в шапке
+"use strict";
+
+const methods = ["get", "post", "put", "patch", "delete"];
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    node.setAttribute(k, v);
+  }
+  for (const c of children) {
+    node.append(c);
+  }
+  return node;
+}
+
+function schemaName(schema) {
+  if (!schema) return "";
+  if (schema.$ref) return schema.$ref.split("/").pop();
+  if (schema.type === "array") return schemaName(schema.items) + "[]";
+  return schema.type || "object";
+}
+
+function paramsTable(params, inputs) {
+  const table = el("table", {}, el("tr", {}, el("th", {}, "параметр"), el("th", {}, "где"), el("th", {}, "тип"), el("th", {}, "описание"), el("th", {}, "значение")));
+  for (const p of params) {
+    const input = el("input", { type: "text" });
+    inputs.push([p, input]);
+    table.append(el("tr", {},
+      el("td", {}, p.name + (p.required ? " *" : "")),
+      el("td", {}, p.in),
+      el("td", {}, schemaName(p.schema)),
+      el("td", {}, p.description || ""),
+      el("td", {}, input)));
+  }
+  return table;
+}
+
+async function tryIt(method, path, inputs, body, out) {
+  let url = path;
+  const query = new URLSearchParams();
+  const headers = { AccessToken: document.getElementById("token").value };
+  for (const [p, input] of inputs) {
+    if (input.value === "") continue;
+    if (p.in === "path") url = url.replace("{" + p.name + "}", encodeURIComponent(input.value));
+    else if (p.in === "query") query.append(p.name, input.value);
+    else if (p.in === "header") headers[p.name] = input.value;
+  }
+  if (query.toString() !== "") url += "?" + query;
+  const init = { method: method.toUpperCase(), headers };
+  if (body && body.value.trim() !== "") {
+    init.body = body.value;
+    headers["Content-Type"] = "application/json";
+  }
+  out.textContent = init.method + " " + url + "\n…";
+  try {
+    const resp = await fetch(url, init);
+    let text = await resp.text();
+    try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) { /* не json */ }
+    out.textContent = init.method + " " + url + "\n" + resp.status + " " + resp.statusText + "\n\n" + text;
+  } catch (e) {
+    out.textContent = String(e);
+  }
+}
+
+function operation(path, method, op) {
+  const inputs = [];
+  const body = el("div", { class: "body" });
+  if (op.description) body.append(el("p", {}, op.description));
+  if (op.parameters) body.append(paramsTable(op.parameters, inputs));
+  let bodyInput = null;
+  if (op.requestBody) {
+    const schema = op.requestBody.content["application/json"].schema;
+    bodyInput = el("textarea", { placeholder: schemaName(schema) });
+    body.append(el("p", {}, "тело: " + schemaName(schema)), bodyInput);
+  }
+  const responses = el("table", {}, el("tr", {}, el("th", {}, "ответ"), el("th", {}, "описание"), el("th", {}, "тело")));
+  for (const [status, r] of Object.entries(op.responses || {})) {
+    const schema = r.content && r.content["application/json"].schema;
+    responses.append(el("tr", {}, el("td", {}, status), el("td", {}, r.description), el("td", {}, schemaName(schema))));
+  }
+  body.append(responses);
+  const out = el("pre", {});
+  const button = el("button", { type: "button" }, "Выполнить");
+  button.addEventListener("click", () => tryIt(method, path, inputs, bodyInput, out));
+  body.append(button, out);
+
+  const summary = el("summary", {}, el("span", { class: "method " + method }, method), el("span", { class: "path" }, path), " " + (op.summary || ""));
+  if (op.description) summary.append(el("span", { class: "admin" }, "admin"));
+  return el("details", {}, summary, body);
+}
+
+async function main() {
+  const root = document.getElementById("operations");
+  const token = document.getElementById("token");
+  token.value = sessionStorage.getItem("AccessToken") || "";
+  token.addEventListener("change", () => sessionStorage.setItem("AccessToken", token.value));
+  try {
+    const spec = await (await fetch("/openapi.json")).json();
+    document.getElementById("title").textContent = spec.info.title + " " + spec.info.version;
+    root.textContent = "";
+    for (const path of Object.keys(spec.paths).sort()) {
+      for (const method of methods) {
+        if (spec.paths[path][method]) root.append(operation(path, method, spec.paths[path][method]));
+      }
+    }
+  } catch (e) {
+    root.textContent = "Не удалось загрузить /openapi.json: " + e;
+  }
+}
+
+main();
@@ -0,0 +1,66 @@
+package searchserver
+
+import (
+	"context"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// EmbeddedSearcher ищет в том же процессе, без http: тот же Server с его лимитами страниц,
+// таймаутом поиска и проверкой snapshot, только запрос не проходит через транспорт.
+// Реализует searchclient.Searcher. Токенов нет, выдача не обезличивается
+type EmbeddedSearcher struct {
+	srv *Server
+}
+
+// NewEmbeddedSearcher загружает датасет из path (xml или json по расширению) и ищет по нему
+// с настройками cfg. Остановить - Close
+func NewEmbeddedSearcher(path string, cfg ServerConfig) (*EmbeddedSearcher, error) {
+	users, err := LoadDataset(path)
+	if err != nil {
+		return nil, err
+	}
+	return &EmbeddedSearcher{srv: NewServer(users, cfg)}, nil
+}
+
+// Server - сервер под EmbeddedSearcher, например чтобы подменить датасет через Reload
+func (e *EmbeddedSearcher) Server() *Server {
+	return e.srv
+}
+
+func (e *EmbeddedSearcher) FindUsers(req model.SearchRequest) (*model.SearchResponse, error) {
+	return e.FindUsersContext(context.Background(), req)
+}
+
+// FindUsersContext - FindUsers с контекстом вызывающего, поверх него действует ServerConfig.SearchTimeout
+func (e *EmbeddedSearcher) FindUsersContext(ctx context.Context, req model.SearchRequest) (*model.SearchResponse, error) {
+	snapshot := e.srv.snapshotID()
+	if req.Snapshot != "" && req.Snapshot != snapshot {
+		return nil, model.ErrSnapshotChanged
+	}
+	ctx, cancel := e.srv.searchContext(ctx)
+	defer cancel()
+	page, err := e.srv.findPage(ctx, searchcore.Query{
+		Query:        req.Query,
+		OrderField:   req.OrderField,
+		OrderBy:      req.OrderBy,
+		Limit:        req.Limit,
+		Offset:       req.Offset,
+		AllowPartial: req.AllowPartial,
+		Email:        req.Email,
+		Phone:        req.Phone,
+		Company:      req.Company,
+		Address:      req.Address,
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Snapshot = snapshot
+	return &page, nil
+}
+
+// Close останавливает фоновые горутины сервера
+func (e *EmbeddedSearcher) Close() {
+	e.srv.Close()
+}
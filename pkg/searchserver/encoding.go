@@ -0,0 +1,82 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+// negotiateCodec выбирает формат ответа: параметр format важнее заголовка Accept
+func negotiateCodec(format, accept string) (model.Codec, bool) {
+	if format != "" {
+		return model.CodecByFormat(format)
+	}
+	if strings.TrimSpace(accept) == "" {
+		return model.Codecs[0], true
+	}
+
+	type acceptRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, acceptRange{mediaType, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, r := range ranges {
+		if r.mediaType == "*/*" || r.mediaType == "application/*" {
+			return model.Codecs[0], true
+		}
+		for _, c := range model.Codecs {
+			if c.ContentType == r.mediaType || r.mediaType == "text/*" && strings.HasPrefix(c.ContentType, "text/") {
+				return c, true
+			}
+		}
+	}
+	return model.Codec{}, false
+}
+
+const ndjsonFlushEvery = 64
+
+// writeNDJSON отдаёт пользователей построчно (application/x-ndjson), периодически сбрасывая буфер клиенту.
+// produce вызывает emit для каждого пользователя и прекращает работу, если emit вернул false
+func writeNDJSON(w http.ResponseWriter, produce func(emit func(model.User) bool)) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	n := 0
+	produce(func(u model.User) bool {
+		if err := enc.Encode(u); err != nil {
+			return false
+		}
+		n++
+		if flusher != nil && n%ndjsonFlushEvery == 0 {
+			flusher.Flush()
+		}
+		return true
+	})
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
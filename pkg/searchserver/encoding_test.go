@@ -0,0 +1,37 @@
+package searchserver
+
+import (
+	"net/http"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"final_task_golang/searchpb"
+)
+
+func TestSearchProtobuf(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodGet, "/?query=Boyd", "", map[string]string{"Accept": "application/x-protobuf"})
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Fatalf("Error : content type %v", ct)
+	}
+	resp := &searchpb.SearchResponse{}
+	if err := proto.Unmarshal(w.Body.Bytes(), resp); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if len(resp.Users) != 1 || resp.Users[0].Name != "Boyd Wolf" {
+		t.Errorf("Error : unexpected response %v", resp)
+	}
+}
+
+func TestSearchMsgpackContentType(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodGet, "/", "", map[string]string{"Accept": "application/msgpack"})
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/msgpack" {
+		t.Errorf("Error : content type %v", ct)
+	}
+}
@@ -0,0 +1,68 @@
+package searchserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// выдача агрегатора упорядочивается слиянием выдачи нижестоящих серверов, см. ExplainResponse.Sort
+const sortMerge = "merge"
+
+// ExplainResponse - ответ GET /search/explain: как сервер выполнил бы поиск с теми же
+// параметрами. Сам поиск не выполняется, кэши и счётчики не меняются
+type ExplainResponse struct {
+	Params map[string]string
+	// запрос уйдёт на нижестоящие серверы, индексы и кэш планов не используются,
+	// а Sort - merge, если выдачу нужно упорядочить
+	Aggregator bool `json:",omitempty"`
+	searchcore.Explanation
+	// страница уже в кэше результатов - поиск не выполнится вовсе
+	CacheHit bool
+}
+
+// resultCacheKey - ключ страницы в кэше результатов
+func resultCacheKey(format string, version int, q searchcore.Query) string {
+	return fmt.Sprintf("%s|v%d|%+v", format, version, q.Normalize())
+}
+
+// explain - GET /search/explain с параметрами обычного поиска
+func (s *Server) explain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	codec, ok := negotiateCodec(q.Get("format"), r.Header.Get("Accept"))
+	if !ok {
+		codec, _ = model.CodecByFormat(model.FormatJSON)
+	}
+	query := parseSearchQuery(q)
+	query.Redact = s.redacts(requestScope(r))
+	query, err := s.pageLimit(query)
+	if err != nil {
+		s.writePageError(w, err)
+		return
+	}
+	if err := query.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := ExplainResponse{Params: query.Params(), Aggregator: s.aggregator != nil, Explanation: s.core.Explain(query)}
+	if s.cache != nil {
+		version := model.NegotiateVersion(r.Header.Get(model.VersionHeader))
+		resp.CacheHit = s.cache.contains(resultCacheKey(codec.Format, version, query))
+	}
+	if resp.Aggregator {
+		resp.Access = searchcore.AccessEstimate{Method: searchcore.AccessScan}
+		resp.Sort = searchcore.SortNone
+		if resp.SortKey != nil {
+			resp.Sort = sortMerge
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,46 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"final_task_golang/pkg/searchcore"
+)
+
+func doExplain(t *testing.T, h http.Handler, params string) ExplainResponse {
+	w := doRequest(h, http.MethodGet, "/search/explain?"+params, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error : %v %s", w.Code, w.Body.String())
+	}
+	var resp ExplainResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	return resp
+}
+
+func TestExplain(t *testing.T) {
+	h := NewServer(bigDataset(20), ServerConfig{Tokens: testServerConfig.Tokens, CacheSize: 10})
+
+	resp := doExplain(t, h, "age_min=25&age_max=25&order_field=Name&order_by=-1&limit=10")
+	if resp.Rows != 700 || resp.Access.Method != searchcore.AccessAge || resp.Access.Candidates == 0 ||
+		resp.Sort != searchcore.SortCandidates || resp.Text != "none" || resp.CacheHit || resp.PlanCached {
+		t.Errorf("Error : unexpected explain %+v", resp)
+	}
+
+	doRequest(h, http.MethodGet, "/?query=Boyd&order_field=Age&order_by=-1&limit=5", "", nil)
+	resp = doExplain(t, h, "query=Boyd&order_field=Age&order_by=-1&limit=5")
+	if resp.Access.Method != searchcore.AccessScan || resp.Sort != searchcore.SortIndex || resp.Text != "substring" ||
+		!resp.CacheHit || !resp.PlanCached || resp.SortKey == nil || resp.SortKey.Field != "Age" {
+		t.Errorf("Error : unexpected explain %+v", resp)
+	}
+	// explain не считается ни попаданием, ни промахом
+	if stats := h.CacheStats(); stats.Hits != 0 || stats.Misses != 1 {
+		t.Errorf("Error : explain touched cache stats %+v", stats)
+	}
+
+	if w := doRequest(h, http.MethodGet, "/search/explain?order_field=Salary&order_by=1", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Error : bad query explained, %v", w.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+// FuzzSearchParams гоняет произвольные query string через разбор параметров, сортировку и пагинацию.
+// Сервер не должен паниковать и должен отвечать либо выдачей, либо понятной ошибкой
+func FuzzSearchParams(f *testing.F) {
+	f.Add("limit=5&offset=3&order_field=Age&order_by=1")
+	f.Add("query=Boyd&order_by=-1")
+	f.Add("limit=-1")
+	f.Add("offset=99999999999999999999&limit=1")
+	f.Add("order_field=About&order_by=1")
+	f.Add("gender=female&age_min=30&age_max=20&format=xml")
+	f.Add("limit=2&stream=true")
+	f.Add("order_by=2&limit=%zz")
+
+	h := newTestHandler()
+	f.Fuzz(func(t *testing.T, params string) {
+		// query string кладём в url как есть, мимо разбора строки запроса
+		r := httptest.NewRequest("GET", "/", nil)
+		r.URL.RawQuery = params
+		r.Header.Set("AccessToken", accessToken)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		switch w.Code {
+		case http.StatusOK:
+			if w.Header().Get("Content-Type") == "application/json" {
+				users := []model.User{}
+				if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+					t.Errorf("Error : %q: invalid json: %v", params, err)
+				}
+			}
+		case http.StatusBadRequest, http.StatusForbidden, http.StatusNotAcceptable:
+			errResp := model.SearchErrorResponse{}
+			if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil || errResp.Error == "" {
+				t.Errorf("Error : %q: bad error body %q", params, w.Body.String())
+			}
+		default:
+			t.Errorf("Error : %q: unexpected status %d", params, w.Code)
+		}
+	})
+}
@@ -0,0 +1,660 @@
+package searchserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// Минимальная реализация GraphQL поверх поиска: поддерживается один query-запрос
+// с полем users(query, filters, orderBy, first, after), выбором полей,
+// алиасами и переменными. Мутаций, фрагментов и директив нет.
+//
+//	query ($q: String) {
+//	  users(query: $q, filters: {gender: "male"}, orderBy: {field: AGE, direction: DESC}, first: 10) {
+//	    edges { cursor node { id name } }
+//	    pageInfo { hasNextPage endCursor }
+//	  }
+//	}
+
+const graphqlMaxFirst = 100
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphqlError struct {
+	Message string `json:"message"`
+}
+
+type graphqlResponse struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []graphqlError `json:"errors,omitempty"`
+}
+
+// gqlField - поле из selection set запроса
+type gqlField struct {
+	Alias     string
+	Name      string
+	Arguments map[string]interface{}
+	Selection []gqlField
+}
+
+func (f gqlField) key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// gqlVariable - ссылка на переменную в аргументах, подставляется при выполнении
+type gqlVariable string
+
+// gqlEnum - значение перечисления (ASC, NAME, ...)
+type gqlEnum string
+
+// orderedObject сохраняет порядок полей таким, как он был в запросе
+type orderedObject []orderedField
+
+type orderedField struct {
+	Key   string
+	Value interface{}
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.Key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (s *Server) graphql(w http.ResponseWriter, r *http.Request) {
+	req := graphqlRequest{}
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				writeGraphQLError(w, http.StatusBadRequest, "bad variables: "+err.Error())
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxGraphQLBytes))
+		if err != nil {
+			writeGraphQLError(w, bodyErrorStatus(err), err.Error())
+			return
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeGraphQLError(w, http.StatusBadRequest, "bad request body: "+err.Error())
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fields, err := parseGraphQL(req.Query)
+	if err != nil {
+		writeGraphQLError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data := orderedObject{}
+	for _, f := range fields {
+		var value interface{}
+		switch f.Name {
+		case "users":
+			value, err = s.resolveUsers(r.Context(), f, req.Variables)
+		case "__typename":
+			value = "Query"
+		default:
+			err = fmt.Errorf("unknown field Query.%s", f.Name)
+		}
+		if err != nil {
+			writeGraphQLError(w, http.StatusOK, err.Error())
+			return
+		}
+		data = append(data, orderedField{f.key(), value})
+	}
+	writeJSON(w, http.StatusOK, graphqlResponse{Data: data})
+}
+
+func writeGraphQLError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, graphqlResponse{Errors: []graphqlError{{msg}}})
+}
+
+// resolveUsers переводит аргументы users(...) в searchcore.Query и собирает connection
+func (s *Server) resolveUsers(ctx context.Context, f gqlField, vars map[string]interface{}) (interface{}, error) {
+	args := map[string]interface{}{}
+	for name, v := range f.Arguments {
+		// null и незаданные переменные равносильны отсутствию аргумента
+		if v = resolveVariables(v, vars); v != nil {
+			args[name] = v
+		}
+	}
+
+	q := searchcore.Query{Limit: 10, Redact: s.redacts(scopeFrom(ctx))}
+	for name, v := range args {
+		switch name {
+		case "query":
+			q.Query, _ = v.(string)
+		case "first":
+			first, ok := v.(float64)
+			if !ok || first < 0 || first > graphqlMaxFirst {
+				return nil, fmt.Errorf("first must be between 0 and %d", graphqlMaxFirst)
+			}
+			q.Limit = int(first)
+		case "after":
+			cursor, _ := v.(string)
+			offset, err := decodeCursor(cursor)
+			if err != nil {
+				return nil, err
+			}
+			q.Offset = offset + 1
+		case "filters":
+			filters, _ := v.(map[string]interface{})
+			for key, value := range filters {
+				switch key {
+				case "gender":
+					q.Gender, _ = value.(string)
+				case "ageMin":
+					age, _ := value.(float64)
+					q.AgeMin = int(age)
+				case "ageMax":
+					age, _ := value.(float64)
+					q.AgeMax = int(age)
+				case "email":
+					q.Email, _ = value.(string)
+				case "phone":
+					q.Phone, _ = value.(string)
+				case "company":
+					q.Company, _ = value.(string)
+				case "address":
+					q.Address, _ = value.(string)
+				default:
+					return nil, fmt.Errorf("unknown filter %s", key)
+				}
+			}
+		case "orderBy":
+			order, _ := v.(map[string]interface{})
+			field := enumValue(order["field"])
+			switch field {
+			case "ID":
+				q.OrderField = "Id"
+			case "NAME", "":
+				q.OrderField = "Name"
+			case "AGE":
+				q.OrderField = "Age"
+			case "EMAIL":
+				q.OrderField = "Email"
+			case "PHONE":
+				q.OrderField = "Phone"
+			case "COMPANY":
+				q.OrderField = "Company"
+			case "ADDRESS":
+				q.OrderField = "Address"
+			default:
+				return nil, fmt.Errorf("unknown order field %s", field)
+			}
+			q.OrderLocale, _ = order["locale"].(string)
+			q.OrderBy = model.OrderByAsc
+			if enumValue(order["direction"]) == "DESC" {
+				q.OrderBy = model.OrderByDesc
+			}
+		default:
+			return nil, fmt.Errorf("unknown argument users.%s", name)
+		}
+	}
+
+	ctx, cancel := s.searchContext(ctx)
+	defer cancel()
+	page, err := s.findPage(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	users, hasNext := page.Users, page.NextPage
+
+	result := orderedObject{}
+	for _, sel := range f.Selection {
+		switch sel.Name {
+		case "edges":
+			edges := []interface{}{}
+			for i, u := range users {
+				edge := orderedObject{}
+				for _, esel := range sel.Selection {
+					switch esel.Name {
+					case "cursor":
+						edge = append(edge, orderedField{esel.key(), encodeCursor(q.Offset + i)})
+					case "node":
+						node, err := selectUser(u, esel.Selection)
+						if err != nil {
+							return nil, err
+						}
+						edge = append(edge, orderedField{esel.key(), node})
+					default:
+						return nil, fmt.Errorf("unknown field UserEdge.%s", esel.Name)
+					}
+				}
+				edges = append(edges, edge)
+			}
+			result = append(result, orderedField{sel.key(), edges})
+		case "nodes":
+			nodes := []interface{}{}
+			for _, u := range users {
+				node, err := selectUser(u, sel.Selection)
+				if err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, node)
+			}
+			result = append(result, orderedField{sel.key(), nodes})
+		case "pageInfo":
+			info := orderedObject{}
+			for _, psel := range sel.Selection {
+				switch psel.Name {
+				case "hasNextPage":
+					info = append(info, orderedField{psel.key(), hasNext})
+				case "endCursor":
+					var cursor interface{}
+					if len(users) > 0 {
+						cursor = encodeCursor(q.Offset + len(users) - 1)
+					}
+					info = append(info, orderedField{psel.key(), cursor})
+				default:
+					return nil, fmt.Errorf("unknown field PageInfo.%s", psel.Name)
+				}
+			}
+			result = append(result, orderedField{sel.key(), info})
+		default:
+			return nil, fmt.Errorf("unknown field UserConnection.%s", sel.Name)
+		}
+	}
+	return result, nil
+}
+
+func selectUser(u model.User, selection []gqlField) (orderedObject, error) {
+	if len(selection) == 0 {
+		return nil, fmt.Errorf("User requires a selection of fields")
+	}
+	node := orderedObject{}
+	for _, sel := range selection {
+		var value interface{}
+		switch sel.Name {
+		case "id":
+			value = u.Id
+		case "name":
+			value = u.Name
+		case "age":
+			value = u.Age
+		case "about":
+			value = u.About
+		case "gender":
+			value = u.Gender
+		case "email":
+			value = u.Email
+		case "phone":
+			value = u.Phone
+		case "company":
+			value = u.Company
+		case "address":
+			value = u.Address
+		case "version":
+			value = u.Version
+		case "__typename":
+			value = "User"
+		default:
+			return nil, fmt.Errorf("unknown field User.%s", sel.Name)
+		}
+		node = append(node, orderedField{sel.key(), value})
+	}
+	return node, nil
+}
+
+// курсор - непрозрачная для клиента позиция записи в выдаче
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.StdEncoding.DecodeString(cursor)
+	if err == nil && strings.HasPrefix(string(data), "offset:") {
+		offset, err := strconv.Atoi(strings.TrimPrefix(string(data), "offset:"))
+		if err == nil && offset >= 0 {
+			return offset, nil
+		}
+	}
+	return 0, fmt.Errorf("bad cursor %q", cursor)
+}
+
+// enumValue достаёт значение перечисления: из текста запроса оно приходит как gqlEnum, из переменных - строкой
+func enumValue(v interface{}) string {
+	switch v := v.(type) {
+	case gqlEnum:
+		return string(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+func resolveVariables(v interface{}, vars map[string]interface{}) interface{} {
+	switch v := v.(type) {
+	case gqlVariable:
+		return vars[string(v)]
+	case map[string]interface{}:
+		resolved := map[string]interface{}{}
+		for key, value := range v {
+			resolved[key] = resolveVariables(value, vars)
+		}
+		return resolved
+	}
+	return v
+}
+
+// ограничения запроса GraphQL: размер тела POST и вложенность выборок и объектов-аргументов.
+// Схеме хватает пары уровней, а без предела глубокая вложенность переполнит стек парсера
+const (
+	maxGraphQLBytes = 1 << 20
+	maxGraphQLDepth = 32
+)
+
+// gqlParser - рекурсивный спуск по подмножеству грамматики GraphQL
+type gqlParser struct {
+	src []rune
+	pos int
+	// текущая вложенность выборок и объектов
+	depth int
+}
+
+func parseGraphQL(src string) ([]gqlField, error) {
+	p := &gqlParser{src: []rune(src)}
+	p.skip()
+	if p.peekName() == "query" {
+		p.name()
+		p.skip()
+		if p.peek() != '(' && p.peek() != '{' {
+			p.name()
+			p.skip()
+		}
+		if p.peek() == '(' {
+			if err := p.skipVariableDefinitions(); err != nil {
+				return nil, err
+			}
+		}
+	} else if name := p.peekName(); name != "" {
+		return nil, fmt.Errorf("unsupported operation %s", name)
+	}
+	fields, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	if p.pos != len(p.src) {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("syntax error at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// skip пропускает пробелы, запятые и комментарии - в GraphQL они незначимы
+func (p *gqlParser) skip() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case unicode.IsSpace(c) || c == ',':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *gqlParser) peek() rune {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *gqlParser) expect(c rune) error {
+	p.skip()
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func isNameRune(c rune, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
+
+func (p *gqlParser) peekName() string {
+	pos := p.pos
+	name := p.name()
+	p.pos = pos
+	return name
+}
+
+func (p *gqlParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) && isNameRune(p.src[p.pos], p.pos == start) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+func (p *gqlParser) skipVariableDefinitions() error {
+	depth := 0
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				p.pos++
+				p.skip()
+				return nil
+			}
+		}
+		p.pos++
+	}
+	return p.errorf("unterminated variable definitions")
+}
+
+// enter спускается на уровень вложенности глубже, выход - p.depth--
+func (p *gqlParser) enter() error {
+	if p.depth >= maxGraphQLDepth {
+		return p.errorf("nesting deeper than %d", maxGraphQLDepth)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *gqlParser) selectionSet() ([]gqlField, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	var fields []gqlField
+	for {
+		p.skip()
+		if p.peek() == '}' {
+			p.pos++
+			break
+		}
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) field() (gqlField, error) {
+	f := gqlField{}
+	f.Name = p.name()
+	if f.Name == "" {
+		return f, p.errorf("expected field name")
+	}
+	p.skip()
+	if p.peek() == ':' {
+		p.pos++
+		p.skip()
+		f.Alias, f.Name = f.Name, p.name()
+		if f.Name == "" {
+			return f, p.errorf("expected field name")
+		}
+		p.skip()
+	}
+	if p.peek() == '(' {
+		p.pos++
+		f.Arguments = map[string]interface{}{}
+		for {
+			p.skip()
+			if p.peek() == ')' {
+				p.pos++
+				break
+			}
+			name := p.name()
+			if name == "" {
+				return f, p.errorf("expected argument name")
+			}
+			if err := p.expect(':'); err != nil {
+				return f, err
+			}
+			value, err := p.value()
+			if err != nil {
+				return f, err
+			}
+			f.Arguments[name] = value
+		}
+		p.skip()
+	}
+	if p.peek() == '{' {
+		selection, err := p.selectionSet()
+		if err != nil {
+			return f, err
+		}
+		f.Selection = selection
+	}
+	return f, nil
+}
+
+func (p *gqlParser) value() (interface{}, error) {
+	p.skip()
+	c := p.peek()
+	switch {
+	case c == '$':
+		p.pos++
+		return gqlVariable(p.name()), nil
+	case c == '"':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated string")
+		}
+		p.pos++
+		var s string
+		if err := json.Unmarshal([]byte(string(p.src[start:p.pos])), &s); err != nil {
+			return nil, p.errorf("bad string: %s", err)
+		}
+		return s, nil
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(string(p.src[start:p.pos]), 64)
+		if err != nil {
+			return nil, p.errorf("bad number")
+		}
+		return n, nil
+	case c == '{':
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer func() { p.depth-- }()
+		p.pos++
+		obj := map[string]interface{}{}
+		for {
+			p.skip()
+			if p.peek() == '}' {
+				p.pos++
+				return obj, nil
+			}
+			name := p.name()
+			if name == "" {
+				return nil, p.errorf("expected object field name")
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			obj[name] = value
+		}
+	case isNameRune(c, true):
+		switch name := p.name(); name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			return gqlEnum(name), nil
+		}
+	}
+	return nil, p.errorf("unexpected %q", c)
+}
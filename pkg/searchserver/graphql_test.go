@@ -0,0 +1,141 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func doGraphQL(t *testing.T, h http.Handler, query string, vars map[string]interface{}) (int, string) {
+	body, _ := json.Marshal(graphqlRequest{Query: query, Variables: vars})
+	w := doRequest(h, http.MethodPost, "/graphql", string(body), nil)
+	return w.Code, strings.TrimSpace(w.Body.String())
+}
+
+func TestGraphQLFieldSelection(t *testing.T) {
+	h := newTestHandler()
+
+	code, body := doGraphQL(t, h, `{ users(query: "Boyd") { nodes { name age } } }`, nil)
+
+	expected := `{"data":{"users":{"nodes":[{"name":"Boyd Wolf","age":22}]}}}`
+	if code != http.StatusOK || body != expected {
+		t.Errorf("Error : %v %v", code, body)
+	}
+}
+
+func TestGraphQLCursorPagination(t *testing.T) {
+	h := newTestHandler()
+	query := `query Page($after: String) {
+		users(first: 2, after: $after, orderBy: {field: ID, direction: DESC}) {
+			edges { cursor node { id } }
+			pageInfo { hasNextPage endCursor }
+		}
+	}`
+
+	var page struct {
+		Data struct {
+			Users struct {
+				Edges []struct {
+					Node struct{ ID int }
+				}
+				PageInfo struct {
+					HasNextPage bool
+					EndCursor   string
+				}
+			}
+		}
+	}
+	var ids []int
+	vars := map[string]interface{}{}
+	for i := 0; i < 2; i++ {
+		_, body := doGraphQL(t, h, query, vars)
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		for _, e := range page.Data.Users.Edges {
+			ids = append(ids, e.Node.ID)
+		}
+		vars["after"] = page.Data.Users.PageInfo.EndCursor
+	}
+
+	if len(ids) != 4 || ids[0] != 34 || ids[3] != 31 || !page.Data.Users.PageInfo.HasNextPage {
+		t.Errorf("Error : unexpected ids %v", ids)
+	}
+}
+
+func TestGraphQLFiltersAndAlias(t *testing.T) {
+	h := newTestHandler()
+
+	_, body := doGraphQL(t, h, `{
+		women: users(filters: {gender: "female", ageMin: 30}, first: 100) { nodes { gender age } }
+	}`, nil)
+
+	var resp struct {
+		Data struct {
+			Women struct {
+				Nodes []model.User
+			}
+		}
+	}
+	json.Unmarshal([]byte(body), &resp)
+	if len(resp.Data.Women.Nodes) == 0 {
+		t.Fatalf("Error : %v", body)
+	}
+	for _, u := range resp.Data.Women.Nodes {
+		if u.Gender != "female" || u.Age < 30 {
+			t.Errorf("Error : unexpected user %v", u)
+		}
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	h := newTestHandler()
+
+	code, body := doGraphQL(t, h, `{ users { nodes { password } } }`, nil)
+	if code != http.StatusOK || body != `{"errors":[{"message":"unknown field User.password"}]}` {
+		t.Errorf("Error : %v %v", code, body)
+	}
+
+	code, _ = doGraphQL(t, h, `{ users { nodes { id } }`, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Error : %v", code)
+	}
+
+	code, _ = doGraphQL(t, h, `mutation { deleteUser(id: 1) }`, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("Error : %v", code)
+	}
+}
+
+func TestGraphQLDeepNesting(t *testing.T) {
+	h := newTestHandler()
+
+	// раньше такой запрос переполнял стек и ронял весь процесс
+	code, body := doGraphQL(t, h, strings.Repeat("{a", 300000), nil)
+	if code != http.StatusBadRequest || !strings.Contains(body, "nesting deeper than") {
+		t.Errorf("Error : %v %.200s", code, body)
+	}
+	code, body = doGraphQL(t, h, `{ users(filter: `+strings.Repeat("{a: ", 100)+`1`+strings.Repeat("}", 100)+`) { total } }`, nil)
+	if code != http.StatusBadRequest || !strings.Contains(body, "nesting deeper than") {
+		t.Errorf("Error : %v %.200s", code, body)
+	}
+
+	w := doRequest(h, http.MethodPost, "/graphql", `{"query": "`+strings.Repeat(" ", maxGraphQLBytes)+`"}`, nil)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Error : %v", w.Code)
+	}
+}
+
+func TestGraphQLExtendedFields(t *testing.T) {
+	h := newTestHandler()
+
+	code, body := doGraphQL(t, h, `{ users(filters: {company: "Hopeli"}, orderBy: {field: EMAIL}) { nodes { email company } } }`, nil)
+
+	expected := `{"data":{"users":{"nodes":[{"email":"boydwolf@hopeli.com","company":"HOPELI"}]}}}`
+	if code != http.StatusOK || body != expected {
+		t.Errorf("Error : %v %v", code, body)
+	}
+}
@@ -0,0 +1,73 @@
+package searchserver
+
+import (
+	"context"
+	"net/http"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// findGroups делит всю выдачу q на группы по q.GroupBy и оставляет в каждой первые q.Limit записей
+// (без лимита - все). Группы идут в порядке их лучшей записи, так что сортировка выдачи
+// упорядочивает и группы. q должен быть уже проверен Validate
+func (s *Server) findGroups(ctx context.Context, q searchcore.Query) ([]model.UserGroup, bool, error) {
+	perGroup, field := q.Limit, q.GroupBy
+	// обходим всю выдачу: нижестоящим серверам агрегатора group_by не передаём
+	q.Limit, q.GroupBy = 0, ""
+
+	groups := []model.UserGroup{}
+	if _, err := s.checkCost(ctx, q); err != nil {
+		return nil, false, err
+	}
+	byValue := map[string]int{}
+	err := s.each(ctx, q, func(u model.User) bool {
+		value, _ := searchcore.GroupValue(u, field)
+		i, ok := byValue[value]
+		if !ok {
+			i = len(groups)
+			byValue[value] = i
+			groups = append(groups, model.UserGroup{Value: value, Users: []model.User{}})
+		}
+		g := &groups[i]
+		g.Total++
+		if perGroup <= 0 || len(g.Users) < perGroup {
+			g.Users = append(g.Users, u)
+		}
+		return true
+	})
+	if err != nil {
+		if !q.AllowPartial {
+			return nil, false, err
+		}
+		return groups, true, nil
+	}
+	return groups, false, nil
+}
+
+// searchGroups отвечает на поиск с group_by, всегда json. Сгруппированные ответы не кэшируются
+func (s *Server) searchGroups(ctx context.Context, w http.ResponseWriter, q searchcore.Query) {
+	ctx, cancel := s.searchContext(ctx)
+	defer cancel()
+	groups, partial, err := s.findGroups(ctx, q)
+	switch {
+	case err == model.ErrSearchTimeout:
+		writeError(w, http.StatusGatewayTimeout, err.Error())
+		return
+	case err == model.ErrDownstream:
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if partial {
+		w.Header().Set("X-Partial-Result", "true")
+	}
+	if trace := traceFrom(ctx); trace != nil {
+		for _, g := range groups {
+			trace.Returned += len(g.Users)
+		}
+	}
+	writeJSON(w, http.StatusOK, model.GroupedResponse{GroupBy: q.GroupBy, Groups: groups, Partial: partial})
+}
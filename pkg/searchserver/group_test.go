@@ -0,0 +1,55 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestSearchGroupBy(t *testing.T) {
+	h := newTestHandler()
+	rec := doRequest(h, "GET", "/?group_by=Gender&limit=2&order_field=Age&order_by=1", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", rec.Code, rec.Body)
+	}
+	resp := model.GroupedResponse{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.GroupBy != "Gender" || len(resp.Groups) != 2 {
+		t.Fatalf("Error : unexpected groups %+v", resp)
+	}
+
+	all := []model.User{}
+	json.Unmarshal(doRequest(h, "GET", "/?limit=100&order_field=Age&order_by=1", "", nil).Body.Bytes(), &all)
+	total := 0
+	for i, g := range resp.Groups {
+		total += g.Total
+		if len(g.Users) != 2 {
+			t.Errorf("Error : group %s has %d users", g.Value, len(g.Users))
+		}
+		// в группе - лучшие записи общей выдачи с этим значением
+		k := 0
+		for _, u := range all {
+			if u.Gender == g.Value && k < len(g.Users) {
+				if g.Users[k].Id != u.Id {
+					t.Errorf("Error : group %s: %d != %d", g.Value, g.Users[k].Id, u.Id)
+				}
+				k++
+			}
+		}
+		// группа с лучшей записью идёт первой
+		if i == 0 && g.Value != all[0].Gender {
+			t.Errorf("Error : first group %s, best record %s", g.Value, all[0].Gender)
+		}
+	}
+	if total != len(all) {
+		t.Errorf("Error : groups total %d, users %d", total, len(all))
+	}
+
+	for _, target := range []string{"/?group_by=About", "/?group_by=Gender&offset=1", "/?group_by=Gender&stream=true"} {
+		if rec := doRequest(h, "GET", target, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Error : %s: unexpected status %d", target, rec.Code)
+		}
+	}
+}
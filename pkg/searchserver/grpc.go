@@ -0,0 +1,84 @@
+package searchserver
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+	"final_task_golang/searchpb"
+)
+
+// grpcServer отдаёт тот же поиск, что и http-обработчик, но по gRPC
+type grpcServer struct {
+	searchpb.UnimplementedSearchServiceServer
+	srv *Server
+}
+
+// RegisterGRPC регистрирует gRPC-сервис поиска поверх того же Server
+func (s *Server) RegisterGRPC(gs *grpc.Server) {
+	searchpb.RegisterSearchServiceServer(gs, &grpcServer{srv: s})
+}
+
+func (g *grpcServer) FindUsers(ctx context.Context, req *searchpb.SearchRequest) (*searchpb.SearchResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := md.Get("accesstoken")
+	if len(token) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Bad access token")
+	}
+	scope, ok := g.srv.tokenScope(token[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Bad access token")
+	}
+	resp, err := g.findUsers(ctx, req, scope)
+	if audit := g.srv.cfg.AuditLog; audit != nil {
+		audit.record(AuditEntry{
+			Time:   time.Now().UTC(),
+			Token:  TokenFingerprint(token[0]),
+			Scope:  scope,
+			Method: "GRPC",
+			Path:   searchpb.SearchService_FindUsers_FullMethodName,
+			Query:  protojson.Format(req),
+			Status: int(status.Code(err)),
+		})
+	}
+	return resp, err
+}
+
+func (g *grpcServer) findUsers(ctx context.Context, req *searchpb.SearchRequest, scope Scope) (*searchpb.SearchResponse, error) {
+	ctx, cancel := g.srv.searchContext(ctx)
+	defer cancel()
+	page, err := g.srv.findPage(ctx, searchcore.Query{
+		Query:        req.Query,
+		OrderField:   req.OrderField,
+		OrderBy:      int(req.OrderBy),
+		Limit:        int(req.Limit),
+		Offset:       int(req.Offset),
+		AllowPartial: req.AllowPartial,
+		Email:        req.Email,
+		Phone:        req.Phone,
+		Company:      req.Company,
+		Address:      req.Address,
+		Redact:       g.srv.redacts(scope),
+	})
+	if err == model.ErrSearchTimeout {
+		return nil, status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	if err == model.ErrDownstream {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := model.UsersToProto(page.Users)
+	resp.NextPage = page.NextPage
+	resp.Partial = page.Partial
+	return resp, nil
+}
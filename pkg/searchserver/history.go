@@ -0,0 +1,105 @@
+package searchserver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// historyVersion - версия датасета, по которой искали начиная с activeFrom
+type historyVersion struct {
+	// snapshotID версии
+	id         string
+	activeFrom time.Time
+	users      []model.User
+	// индексы строятся при первом запросе as_of к версии
+	once   sync.Once
+	engine *searchcore.Engine
+}
+
+// datasetHistory - последние версии датасета для запросов с as_of, в памяти.
+// Срез пользователей меняется копированием, так что каждая версия держит свой срез
+type datasetHistory struct {
+	mu sync.Mutex
+	// прежних версий сверх текущей
+	size int
+	// от старых к новым, последняя - текущая
+	versions []*historyVersion
+	cfg      searchcore.Config
+}
+
+func newDatasetHistory(size int, cfg searchcore.Config) *datasetHistory {
+	// исторические версии ищутся редко: без пула горутин и кэша планов
+	cfg.Parallelism, cfg.PlanCacheSize = 0, -1
+	return &datasetHistory{size: size, cfg: cfg}
+}
+
+// record запоминает users как текущую версию, вытесняя самые старые
+func (h *datasetHistory) record(users []model.User, id string, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.versions = append(h.versions, &historyVersion{id: id, activeFrom: at, users: users})
+	if extra := len(h.versions) - h.size - 1; extra > 0 {
+		h.versions = append([]*historyVersion(nil), h.versions[extra:]...)
+	}
+}
+
+// at возвращает версию, по которой искали в момент t, и признак того, что это текущая версия.
+// nil - t раньше самой старой сохранённой версии
+func (h *datasetHistory) at(t time.Time) (*historyVersion, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.versions) - 1; i >= 0; i-- {
+		if !h.versions[i].activeFrom.After(t) {
+			return h.versions[i], i == len(h.versions)-1
+		}
+	}
+	return nil, false
+}
+
+// byID возвращает сохранённую версию по snapshotID и текущую версию, nil - такой уже нет
+func (h *datasetHistory) byID(id string) (found, current *historyVersion) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	current = h.versions[len(h.versions)-1]
+	for _, v := range h.versions {
+		if v.id == id {
+			return v, current
+		}
+	}
+	return nil, current
+}
+
+func (h *datasetHistory) searchEngine(v *historyVersion) *searchcore.Engine {
+	v.once.Do(func() {
+		v.engine = searchcore.New(h.cfg)
+		v.engine.SetUsers(v.users)
+	})
+	return v.engine
+}
+
+type engineKey struct{}
+
+// asOf подменяет в ctx ядро поиска на версию датасета, по которой искали в момент t.
+// false - такой старой версии уже нет
+func (s *Server) asOf(ctx context.Context, t time.Time) (context.Context, bool) {
+	v, current := s.history.at(t)
+	if v == nil {
+		return ctx, false
+	}
+	if current {
+		return ctx, true
+	}
+	return context.WithValue(ctx, engineKey{}, s.history.searchEngine(v)), true
+}
+
+// engine - ядро поиска для запроса: текущее или историческое, см. asOf
+func (s *Server) engine(ctx context.Context) *searchcore.Engine {
+	if e, ok := ctx.Value(engineKey{}).(*searchcore.Engine); ok {
+		return e
+	}
+	return s.core
+}
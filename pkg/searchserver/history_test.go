@@ -0,0 +1,53 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestSearchAsOf(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, CacheSize: 10, History: 2})
+	admin := map[string]string{"AccessToken": adminToken}
+	search := func(asOf time.Time) (int, []model.User) {
+		target := "/?age_min=99&age_max=99&limit=10"
+		if !asOf.IsZero() {
+			target += "&as_of=" + url.QueryEscape(asOf.Format(time.RFC3339Nano))
+		}
+		rec := doRequest(s, "GET", target, "", nil)
+		found := []model.User{}
+		json.Unmarshal(rec.Body.Bytes(), &found)
+		return rec.Code, found
+	}
+
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+	if rec := doRequest(s, "PATCH", "/users/1", `{"Age": 99}`, admin); rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d", rec.Code)
+	}
+	if code, found := search(time.Time{}); code != http.StatusOK || len(found) != 1 {
+		t.Fatalf("Error : unexpected current result %d %v", code, found)
+	}
+	if code, found := search(before); code != http.StatusOK || len(found) != 0 {
+		t.Errorf("Error : as_of sees later edit, %d %v", code, found)
+	}
+	if code, found := search(time.Now()); code != http.StatusOK || len(found) != 1 {
+		t.Errorf("Error : as_of now differs from current, %d %v", code, found)
+	}
+
+	// ещё две правки вытесняют исходную версию
+	for _, age := range []string{"98", "97"} {
+		doRequest(s, "PATCH", "/users/2", `{"Age": `+age+`}`, admin)
+	}
+	if code, _ := search(before); code != http.StatusGone {
+		t.Errorf("Error : evicted version served, status %d", code)
+	}
+	if rec := doRequest(s, "GET", "/?as_of=yesterday", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+}
@@ -0,0 +1,185 @@
+package searchserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+const (
+	// IdempotencyKeyHeader - ключ правки от клиента: повтор с тем же ключом получает сохранённый
+	// ответ первой попытки, а не выполняет правку ещё раз
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// выставляется на ответ, повторённый по ключу
+	idempotentReplayHeader = "Idempotent-Replayed"
+
+	defaultIdempotencyWindow = 24 * time.Hour
+	// сколько ключей помнит сервер, самые старые вытесняются раньше окна
+	maxIdempotencyKeys   = 10000
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotentResult - правка, выполненная по ключу: пока done == false, она ещё идёт
+type idempotentResult struct {
+	// sha256 метода, пути с параметрами и тела: тот же ключ с другим запросом - ошибка клиента
+	request string
+	done    bool
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// idempotencyStore - ответы правок по токену и ключу в пределах окна
+type idempotencyStore struct {
+	mu      sync.Mutex
+	window  time.Duration
+	results map[string]*idempotentResult
+	// ключи в порядке добавления, он же порядок истечения
+	order []idempotencyKeyAt
+}
+
+type idempotencyKeyAt struct {
+	key     string
+	expires time.Time
+}
+
+func newIdempotencyStore(window time.Duration) *idempotencyStore {
+	if window < 0 {
+		return nil
+	}
+	return &idempotencyStore{window: durationOr(window, defaultIdempotencyWindow), results: map[string]*idempotentResult{}}
+}
+
+// begin возвращает уже известный результат ключа или заводит новый, тогда started == true
+func (st *idempotencyStore) begin(key, request string, now time.Time) (res idempotentResult, started bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	// истёкшие ключи и лишние сверх maxIdempotencyKeys - с начала очереди
+	for len(st.order) > 0 {
+		first := st.order[0]
+		if now.Before(first.expires) && len(st.order) < maxIdempotencyKeys {
+			break
+		}
+		// ключ могли забыть и завести заново, тогда в очереди он ещё раз, дальше
+		if r, ok := st.results[first.key]; ok && r.expires.Equal(first.expires) {
+			delete(st.results, first.key)
+		}
+		st.order = st.order[1:]
+	}
+	if r, ok := st.results[key]; ok {
+		return *r, false
+	}
+	expires := now.Add(st.window)
+	st.results[key] = &idempotentResult{request: request, expires: expires}
+	st.order = append(st.order, idempotencyKeyAt{key, expires})
+	return idempotentResult{}, true
+}
+
+func (st *idempotencyStore) finish(key string, status int, header http.Header, body []byte) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if r, ok := st.results[key]; ok {
+		r.done, r.status, r.header, r.body = true, status, header, body
+	}
+}
+
+// forget убирает ключ правки, которая не удалась на стороне сервера: её можно повторить
+func (st *idempotencyStore) forget(key string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.results, key)
+}
+
+// capturingWriter пишет ответ клиенту и запоминает его для повторов
+type capturingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (c *capturingWriter) WriteHeader(status int) {
+	if c.header == nil {
+		c.status, c.header = status, c.ResponseWriter.Header().Clone()
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *capturingWriter) Write(p []byte) (int, error) {
+	if c.header == nil {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.body.Write(p)
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *capturingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// idempotent выполняет правку (см. mutates) с заголовком Idempotency-Key один раз на ключ и токен
+// в пределах ServerConfig.IdempotencyWindow. Повтор получает сохранённый ответ с Idempotent-Replayed,
+// тот же ключ с другим запросом - 422, повтор до конца первой попытки - 409.
+// Ответы 5xx не запоминаются: правку после сбоя сервера можно повторить с тем же ключом
+func (s *Server) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idemKey := r.Header.Get(IdempotencyKeyHeader)
+		if s.idempotency == nil || idemKey == "" || !mutates(r) {
+			h(w, r)
+			return
+		}
+		if len(idemKey) > maxIdempotencyKeyLen {
+			writeError(w, http.StatusBadRequest, model.ErrorBadIdempotencyKey)
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxImportBytes+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		sum := sha256.New()
+		io.WriteString(sum, r.Method+" "+r.URL.RequestURI()+"\n")
+		sum.Write(body)
+		request := hex.EncodeToString(sum.Sum(nil))
+
+		key := TokenFingerprint(r.Header.Get("AccessToken")) + "|" + idemKey
+		res, started := s.idempotency.begin(key, request, time.Now())
+		switch {
+		case started:
+		case res.request != request:
+			writeError(w, http.StatusUnprocessableEntity, model.ErrorIdempotencyKeyReused)
+			return
+		case !res.done:
+			writeError(w, http.StatusConflict, model.ErrorIdempotencyInProgress)
+			return
+		default:
+			for name, values := range res.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(idempotentReplayHeader, "true")
+			w.WriteHeader(res.status)
+			w.Write(res.body)
+			return
+		}
+
+		cw := &capturingWriter{ResponseWriter: w}
+		h(cw, r)
+		if cw.header == nil {
+			cw.status, cw.header = http.StatusOK, w.Header().Clone()
+		}
+		if cw.status >= 500 {
+			s.idempotency.forget(key)
+			return
+		}
+		s.idempotency.finish(key, cw.status, cw.header, cw.body.Bytes())
+	}
+}
@@ -0,0 +1,81 @@
+package searchserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	// правки - только админам, а ключи сравниваются у двух разных токенов
+	cfg := testServerConfig
+	cfg.Tokens = map[string]Scope{accessToken: ScopeAdmin, adminToken: ScopeAdmin}
+	h := NewServer(newTestHandler().snapshot(), cfg)
+	key := map[string]string{IdempotencyKeyHeader: "retry-1"}
+
+	first := doRequest(h, http.MethodPatch, "/users/0", `{"Age": 41}`, key)
+	if first.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", first.Code, first.Body)
+	}
+	// повтор после обрыва сети не правит запись второй раз
+	retry := doRequest(h, http.MethodPatch, "/users/0", `{"Age": 41}`, key)
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() || retry.Header().Get("ETag") != first.Header().Get("ETag") ||
+		retry.Header().Get(idempotentReplayHeader) != "true" {
+		t.Errorf("Error : unexpected replay %d %s %v", retry.Code, retry.Body, retry.Header())
+	}
+	if u := decodeUser(t, doRequest(h, http.MethodGet, "/users/0", "", nil)); u.Version != decodeUser(t, first).Version {
+		t.Errorf("Error : user updated twice, version %d", u.Version)
+	}
+
+	if rec := doRequest(h, http.MethodPatch, "/users/0", `{"Age": 42}`, key); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Error : key reused for another request, %d", rec.Code)
+	}
+	// ключи у каждого токена свои
+	admin := map[string]string{IdempotencyKeyHeader: "retry-1", "AccessToken": adminToken}
+	if rec := doRequest(h, http.MethodPatch, "/users/0", `{"Age": 42}`, admin); rec.Code != http.StatusOK || rec.Header().Get(idempotentReplayHeader) != "" {
+		t.Errorf("Error : unexpected status %d %s", rec.Code, rec.Body)
+	}
+	long := map[string]string{IdempotencyKeyHeader: strings.Repeat("k", maxIdempotencyKeyLen+1)}
+	if rec := doRequest(h, http.MethodPatch, "/users/0", `{"Age": 43}`, long); rec.Code != http.StatusBadRequest {
+		t.Errorf("Error : long key accepted, %d", rec.Code)
+	}
+
+	// 5xx не запоминается, правку можно повторить с тем же ключом
+	h.SetMaintenance(true)
+	retryKey := map[string]string{IdempotencyKeyHeader: "retry-2"}
+	if rec := doRequest(h, http.MethodPatch, "/users/1", `{"Age": 50}`, retryKey); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Error : unexpected status %d", rec.Code)
+	}
+	h.SetMaintenance(false)
+	if rec := doRequest(h, http.MethodPatch, "/users/1", `{"Age": 50}`, retryKey); rec.Code != http.StatusOK || rec.Header().Get(idempotentReplayHeader) != "" {
+		t.Errorf("Error : unexpected status %d %s", rec.Code, rec.Body)
+	}
+
+	cfg.IdempotencyWindow = -1
+	h = NewServer(h.snapshot(), cfg)
+	doRequest(h, http.MethodPatch, "/users/0", `{"Age": 41}`, key)
+	if rec := doRequest(h, http.MethodPatch, "/users/0", `{"Age": 41}`, key); rec.Header().Get(idempotentReplayHeader) != "" {
+		t.Errorf("Error : replay with idempotency disabled")
+	}
+}
+
+func TestIdempotencyStoreEviction(t *testing.T) {
+	st := newIdempotencyStore(time.Minute)
+	now := time.Now()
+	st.begin("a", "req", now)
+	st.finish("a", http.StatusOK, http.Header{}, nil)
+	if _, started := st.begin("a", "req", now.Add(30*time.Second)); started {
+		t.Errorf("Error : key forgotten within window")
+	}
+	if _, started := st.begin("a", "req", now.Add(2*time.Minute)); !started {
+		t.Errorf("Error : key kept after window")
+	}
+	for i := 0; i < maxIdempotencyKeys+10; i++ {
+		st.begin(strconv.Itoa(i), "req", now.Add(2*time.Minute))
+	}
+	if len(st.results) > maxIdempotencyKeys || len(st.order) > maxIdempotencyKeys {
+		t.Errorf("Error : %d keys kept", len(st.results))
+	}
+}
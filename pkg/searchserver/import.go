@@ -0,0 +1,151 @@
+package searchserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+const (
+	// больше датасет через http не принимаем, его лучше положить файлом и перезапустить
+	maxImportBytes = 64 << 20
+	maxImportAge   = 150
+)
+
+// ImportIssue - одна проблема загружаемого датасета
+type ImportIssue struct {
+	// номер строки с 0, -1 - файл не разобрался целиком
+	Row int
+	// элемент строки, к которому относится проблема
+	Field string `json:",omitempty"`
+	Error string
+}
+
+// ImportReport - ответ POST /admin/import
+type ImportReport struct {
+	DryRun bool
+	Rows   int
+	// строк без проблем
+	Valid int
+	// датасет подменён. Подменяется только целиком и только без единой проблемы
+	Applied bool
+	// датасет подготовлен рядом с текущим, см. POST /admin/datasets/switch
+	Staged bool          `json:",omitempty"`
+	Issues []ImportIssue `json:",omitempty"`
+}
+
+// validateDataset разбирает датасет как LoadDatasetMapping, но не останавливается на первой
+// ошибке: собирает в отчёт все проблемные строки. Повторы Id - тоже проблема
+func validateDataset(data []byte, isJSON bool, mapping FieldMapping) ([]model.User, ImportReport) {
+	rows, err := datasetRows(data, isJSON)
+	if err != nil {
+		return nil, ImportReport{Issues: []ImportIssue{{Row: -1, Error: err.Error()}}}
+	}
+	users, rowOf, report := validateRows(rows, mapping)
+	users, duplicates := resolveDuplicates(users, rowOf, DuplicateReject)
+	if len(duplicates) > 0 {
+		report.Issues = append(report.Issues, duplicates...)
+		sort.SliceStable(report.Issues, func(i, j int) bool { return report.Issues[i].Row < report.Issues[j].Row })
+		report.Valid = len(users)
+	}
+	return users, report
+}
+
+// validateRows приводит строки к User, строки с проблемами в результат не попадают.
+// rowOf - номер строки для каждого пользователя, Id на повторы не проверяются
+func validateRows(rows []map[string]string, mapping FieldMapping) (users []model.User, rowOf []int, report ImportReport) {
+	report.Rows = len(rows)
+	users = make([]model.User, 0, len(rows))
+	for i, row := range rows {
+		issues := validateRow(i, row)
+		var u model.User
+		if len(issues) == 0 {
+			var err error
+			if u, err = rowToUser(row, mapping); err != nil {
+				issues = append(issues, ImportIssue{Row: i, Error: err.Error()})
+			}
+		}
+		if len(issues) > 0 {
+			report.Issues = append(report.Issues, issues...)
+			continue
+		}
+		report.Valid++
+		users = append(users, u)
+		rowOf = append(rowOf, i)
+	}
+	return users, rowOf, report
+}
+
+// validateRow проверяет поля строки по отдельности, чтобы в отчёт попали все сразу
+func validateRow(i int, row map[string]string) []ImportIssue {
+	var issues []ImportIssue
+	if _, err := strconv.Atoi(strings.TrimSpace(row["id"])); err != nil {
+		issues = append(issues, ImportIssue{Row: i, Field: "id", Error: "bad id " + strconv.Quote(row["id"])})
+	}
+	if v := strings.TrimSpace(row["age"]); v != "" {
+		if age, err := strconv.Atoi(v); err != nil {
+			issues = append(issues, ImportIssue{Row: i, Field: "age", Error: "bad age " + strconv.Quote(v)})
+		} else if age < 0 || age > maxImportAge {
+			issues = append(issues, ImportIssue{Row: i, Field: "age", Error: fmt.Sprintf("age %d out of range 0..%d", age, maxImportAge)})
+		}
+	}
+	if strings.TrimSpace(row["first_name"]) == "" && strings.TrimSpace(row["last_name"]) == "" {
+		issues = append(issues, ImportIssue{Row: i, Field: "first_name", Error: "missing name"})
+	}
+	return issues
+}
+
+// importDataset - POST /admin/import: датасет в теле (xml, json при format=json или
+// Content-Type: application/json) целиком заменяет текущий, как Reload.
+// С dry_run=true только проверяет и возвращает отчёт, со stage=true - кладёт рядом с текущим
+// до переключения. Есть проблемы - 422 и датасет не трогаем
+func (s *Server) importDataset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if len(s.cfg.Aggregate.URLs) > 0 {
+		writeError(w, http.StatusNotImplemented, "aggregator has no dataset to import into")
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		writeError(w, bodyErrorStatus(err), err.Error())
+		return
+	}
+	q := r.URL.Query()
+	isJSON := q.Get("format") == model.FormatJSON ||
+		q.Get("format") == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+	mapping := s.cfg.FieldMapping
+	if mapping == (FieldMapping{}) {
+		mapping = DefaultFieldMapping
+	}
+
+	users, report := validateDataset(data, isJSON, mapping)
+	report.DryRun = q.Get("dry_run") == "true"
+	if len(report.Issues) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+	load := LoadStats{Rows: report.Rows, Loaded: len(users)}
+	switch {
+	case report.DryRun:
+	case q.Get("stage") == "true":
+		s.StageDataset(users, load)
+		report.Staged = true
+	default:
+		// прямой импорт тоже можно откатить
+		s.versions.mu.Lock()
+		s.versions.previous = s.activate(&datasetVersion{users: users, load: load})
+		s.versions.mu.Unlock()
+		report.Applied = true
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,76 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+const badImport = `<root>
+<row><id>1</id><first_name>Ann</first_name><age>30</age></row>
+<row><id>1</id><first_name>Bob</first_name><age>40</age></row>
+<row><id>x</id><first_name>Eve</first_name><age>-3</age></row>
+<row><id>4</id><age>old</age></row>
+<row><id>5</id><last_name>Lee</last_name></row>
+</root>`
+
+func doImport(t *testing.T, h http.Handler, target, body string) (int, ImportReport) {
+	w := doRequest(h, http.MethodPost, target, body, map[string]string{"AccessToken": adminToken})
+	var report ImportReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Error : %v %s", err, w.Body.String())
+	}
+	return w.Code, report
+}
+
+func TestImportDryRunReport(t *testing.T) {
+	h := newTestHandler()
+	code, report := doImport(t, h, "/admin/import?dry_run=true", badImport)
+	if code != http.StatusUnprocessableEntity || report.Applied || report.Rows != 5 || report.Valid != 2 {
+		t.Fatalf("Error : unexpected report %v %+v", code, report)
+	}
+	fields := map[string]int{}
+	for _, issue := range report.Issues {
+		fields[issue.Field]++
+	}
+	// дубль в строке 1, плохие id и возраст в строке 2, возраст и имя в строке 3
+	if fields["id"] != 2 || fields["age"] != 2 || fields["first_name"] != 1 {
+		t.Errorf("Error : unexpected issues %+v", report.Issues)
+	}
+
+	code, report = doImport(t, h, "/admin/import?dry_run=true", "<root><row>")
+	if code != http.StatusUnprocessableEntity || len(report.Issues) != 1 || report.Issues[0].Row != -1 {
+		t.Errorf("Error : malformed xml not reported, %v %+v", code, report)
+	}
+
+	// без dry_run проблемный файл тоже не применяется
+	doImport(t, h, "/admin/import", badImport)
+	if userCount(h) != 35 {
+		t.Errorf("Error : dataset changed, %d users", userCount(h))
+	}
+}
+
+func TestImportApply(t *testing.T) {
+	h := newTestHandler()
+	good := `[{"id": 1, "first_name": "Ann", "age": 30}, {"id": 2, "first_name": "Bob"}]`
+
+	code, report := doImport(t, h, "/admin/import?dry_run=true&format=json", good)
+	if code != http.StatusOK || report.Applied || report.Valid != 2 || userCount(h) != 35 {
+		t.Fatalf("Error : dry run %v %+v", code, report)
+	}
+
+	code, report = doImport(t, h, "/admin/import?format=json", good)
+	if code != http.StatusOK || !report.Applied || userCount(h) != 2 {
+		t.Errorf("Error : import not applied %v %+v", code, report)
+	}
+
+	if w := doRequest(h, http.MethodPost, "/admin/import", good, nil); w.Code != http.StatusForbidden {
+		t.Errorf("Error : import without admin scope, %v", w.Code)
+	}
+}
+
+func userCount(s *Server) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.users)
+}
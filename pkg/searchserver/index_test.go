@@ -0,0 +1,80 @@
+package searchserver
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// поиск по индексам должен совпадать с сортировкой совпадений на лету
+func TestSortIndexesMatchNaiveSort(t *testing.T) {
+	h := newTestHandler()
+	users := h.snapshot()
+
+	for _, field := range []string{"Id", "Name", "Age", ""} {
+		for _, orderBy := range []int{model.OrderByAsc, model.OrderByDesc} {
+			for _, query := range []string{"", "nisi", "Boyd"} {
+				q := searchcore.Query{Query: query, OrderField: field, OrderBy: orderBy, Limit: 7, Offset: 2}
+
+				var expected []model.User
+				for _, u := range users {
+					if q.Match(u) {
+						expected = append(expected, u)
+					}
+				}
+				less, _ := searchcore.OrderLess(field)
+				sort.SliceStable(expected, func(i, j int) bool {
+					if orderBy == model.OrderByDesc {
+						return less(expected[j], expected[i])
+					}
+					return less(expected[i], expected[j])
+				})
+				if len(expected) > q.Offset {
+					expected = expected[q.Offset:]
+				} else {
+					expected = nil
+				}
+				if len(expected) > q.Limit {
+					expected = expected[:q.Limit]
+				}
+
+				got, _, err := h.find(context.Background(), q)
+				if err != nil {
+					t.Fatalf("Error : %v", err)
+				}
+				if len(got) != len(expected) {
+					t.Fatalf("Error : %+v: %v != %v", q, got, expected)
+				}
+				for i := range got {
+					if got[i] != expected[i] {
+						t.Errorf("Error : %+v: %v != %v", q, got[i], expected[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSortIndexesRebuiltOnUpdate(t *testing.T) {
+	h := newTestHandler()
+
+	doRequest(h, "PATCH", "/users/5", `{"Age": 1000}`, adminHeader)
+	users, _, _ := h.find(context.Background(), searchcore.Query{OrderField: "Age", OrderBy: model.OrderByDesc, Limit: 1})
+
+	if len(users) != 1 || users[0].Id != 5 {
+		t.Errorf("Error : unexpected users %v", users)
+	}
+}
+
+func BenchmarkSortedSearch(b *testing.B) {
+	s := NewServer(bigDataset(300), testServerConfig)
+	q := searchcore.Query{OrderField: "Name", OrderBy: model.OrderByDesc, Limit: 25, Offset: 100}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.find(context.Background(), q)
+	}
+}
@@ -0,0 +1,139 @@
+package searchserver
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// ActiveRequest - выполняющийся поиск в GET /admin/requests
+type ActiveRequest struct {
+	ID uint64
+	// отпечаток токена, как в журнале аудита
+	Token  string
+	Method string
+	Path   string
+	// строка запроса, у GraphQL и JSON-RPC - начало тела
+	Query   string
+	Started time.Time
+	// сколько уже выполняется, в миллисекундах
+	Elapsed float64
+}
+
+type activeRequest struct {
+	ActiveRequest
+	cancel context.CancelFunc
+}
+
+// requestRegistry - выполняющиеся поиски, которые администратор может отменить
+type requestRegistry struct {
+	mu     sync.Mutex
+	nextID uint64
+	active map[uint64]*activeRequest
+}
+
+func newRequestRegistry() *requestRegistry {
+	return &requestRegistry{active: map[uint64]*activeRequest{}}
+}
+
+func (reg *requestRegistry) add(req ActiveRequest, cancel context.CancelFunc) uint64 {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.nextID++
+	req.ID = reg.nextID
+	reg.active[req.ID] = &activeRequest{ActiveRequest: req, cancel: cancel}
+	return req.ID
+}
+
+func (reg *requestRegistry) remove(id uint64) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.active, id)
+}
+
+// cancel отменяет контекст поиска, false - поиск уже закончился
+func (reg *requestRegistry) cancel(id uint64) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	req, ok := reg.active[id]
+	if ok {
+		req.cancel()
+	}
+	return ok
+}
+
+func (reg *requestRegistry) list() []ActiveRequest {
+	reg.mu.Lock()
+	now := time.Now()
+	list := make([]ActiveRequest, 0, len(reg.active))
+	for _, req := range reg.active {
+		item := req.ActiveRequest
+		item.Elapsed = milliseconds(now.Sub(item.Started))
+		list = append(list, item)
+	}
+	reg.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// tracked регистрирует поиск на время выполнения, включая ожидание слота, и даёт отменить
+// его через DELETE /admin/requests/{id}. Отменённый поиск отвечает так же, как истёкший по дедлайну
+func (s *Server) tracked(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := ActiveRequest{
+			Token:   TokenFingerprint(r.Header.Get("AccessToken")),
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   model.Redact(r.URL.RawQuery),
+			Started: time.Now(),
+		}
+		if r.Method == http.MethodPost {
+			req.Query = model.Redact(string(peekBody(r, auditBodyLimit)))
+		}
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		id := s.requests.add(req, cancel)
+		defer s.requests.remove(id)
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// searchHandler - обработчик поиска со всей обвязкой: реестр для отмены, SLO и лимит одновременных поисков
+func (s *Server) searchHandler(h http.HandlerFunc) http.HandlerFunc {
+	return s.tracked(s.withSLO(s.limit(h)))
+}
+
+// activeRequests - GET /admin/requests и DELETE /admin/requests/{id}
+func (s *Server) activeRequests(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin/requests" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.requests.list())
+		return
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/admin/requests/"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.requests.cancel(id) {
+		writeError(w, http.StatusNotFound, model.ErrorRequestNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
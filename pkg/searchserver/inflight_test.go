@@ -0,0 +1,89 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func listRequests(t *testing.T, s *Server) []ActiveRequest {
+	rec := doRequest(s, "GET", "/admin/requests", "", map[string]string{"AccessToken": adminToken})
+	list := []ActiveRequest{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected response %d %v", rec.Code, err)
+	}
+	return list
+}
+
+func TestCancelActiveRequest(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, MaxInFlight: 1, QueueTimeout: time.Minute})
+	admin := map[string]string{"AccessToken": adminToken}
+
+	// поиск повиснет в очереди за занятым слотом
+	s.limiter.acquire(httptest.NewRequest("GET", "/", nil))
+	defer s.limiter.release()
+	done := make(chan int)
+	go func() {
+		done <- doRequest(s, "GET", "/?query=nisi&limit=5", "", nil).Code
+	}()
+
+	var list []ActiveRequest
+	for deadline := time.Now().Add(5 * time.Second); len(list) == 0 && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		list = listRequests(t, s)
+	}
+	if len(list) != 1 || list[0].Token != TokenFingerprint(accessToken) || list[0].Query != "query=nisi&limit=5" {
+		t.Fatalf("Error : unexpected requests %+v", list)
+	}
+
+	if rec := doRequest(s, "DELETE", "/admin/requests/"+strconv.FormatUint(list[0].ID, 10), "", admin); rec.Code != http.StatusNoContent {
+		t.Fatalf("Error : unexpected status %d", rec.Code)
+	}
+	select {
+	case code := <-done:
+		if code == http.StatusOK {
+			t.Errorf("Error : canceled request succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Error : request not canceled")
+	}
+
+	if list := listRequests(t, s); len(list) != 0 {
+		t.Errorf("Error : finished request still listed %+v", list)
+	}
+	if rec := doRequest(s, "DELETE", "/admin/requests/"+strconv.FormatUint(list[0].ID, 10), "", admin); rec.Code != http.StatusNotFound {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+	if rec := doRequest(s, "DELETE", "/admin/requests/1", "", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Error : search token canceled request, status %d", rec.Code)
+	}
+}
+
+// countingReader - бесконечное тело запроса, считает прочитанные байты
+type countingReader struct{ n int }
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+	c.n += len(p)
+	return len(p), nil
+}
+
+func TestTrackedReadsOnlyBodyPrefix(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens})
+	body := &countingReader{}
+	h := s.tracked(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest("POST", "/graphql", body))
+	if rec.Code != http.StatusNoContent || body.n > auditBodyLimit {
+		t.Errorf("Error : status %d, read %d bytes", rec.Code, body.n)
+	}
+}
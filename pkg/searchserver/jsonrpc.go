@@ -0,0 +1,228 @@
+package searchserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// JSON-RPC 2.0 поверх того же поиска: методы search.findUsers и search.getUser, поддерживаются батчи
+
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+
+	// коды ошибок приложения
+	rpcUserNotFound = -32001
+	rpcTimeout      = -32002
+	rpcDownstream   = -32003
+
+	// больше тело запроса или батча не читаем, как и у GraphQL
+	maxRPCBytes = 1 << 20
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcFindUsersParams struct {
+	Limit          int     `json:"limit"`
+	Offset         int     `json:"offset"`
+	Query          string  `json:"query"`
+	OrderField     string  `json:"order_field"`
+	OrderBy        int     `json:"order_by"`
+	OrderLocale    string  `json:"order_locale"`
+	Gender         string  `json:"gender"`
+	AgeMin         int     `json:"age_min"`
+	AgeMax         int     `json:"age_max"`
+	Email          string  `json:"email"`
+	Phone          string  `json:"phone"`
+	Company        string  `json:"company"`
+	Address        string  `json:"address"`
+	IncludeDeleted bool    `json:"include_deleted"`
+	AllowPartial   bool    `json:"allow_partial"`
+	AfterID        string  `json:"after_id"`
+	AfterValue     string  `json:"after_value"`
+	Matcher        string  `json:"matcher"`
+	BoostName      float64 `json:"boost_name"`
+	BoostAbout     float64 `json:"boost_about"`
+}
+
+type rpcGetUserParams struct {
+	ID *int `json:"id"`
+}
+
+func (s *Server) jsonRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRPCBytes))
+	if err != nil {
+		writeJSON(w, bodyErrorStatus(err), rpcErrorResponse(nil, rpcInvalidRequest, err.Error()))
+		return
+	}
+	scope := requestScope(r)
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeJSON(w, http.StatusOK, rpcErrorResponse(nil, rpcParseError, "Parse error"))
+			return
+		}
+		if len(batch) == 0 {
+			writeJSON(w, http.StatusOK, rpcErrorResponse(nil, rpcInvalidRequest, "Invalid Request"))
+			return
+		}
+		responses := []*rpcResponse{}
+		for _, raw := range batch {
+			if resp := s.rpcCall(r.Context(), raw, scope); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			// в батче были только уведомления
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, responses)
+		return
+	}
+
+	resp := s.rpcCall(r.Context(), body, scope)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// rpcCall выполняет один вызов, для уведомлений (без id) возвращает nil
+func (s *Server) rpcCall(ctx context.Context, raw json.RawMessage, scope Scope) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return rpcErrorResponse(nil, rpcParseError, "Parse error")
+		}
+		return rpcErrorResponse(nil, rpcInvalidRequest, "Invalid Request")
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcErrorResponse(req.ID, rpcInvalidRequest, "Invalid Request")
+	}
+
+	result, rpcErr := s.rpcDispatch(ctx, req, scope)
+	if req.ID == nil {
+		return nil
+	}
+	if rpcErr != nil {
+		return &rpcResponse{JSONRPC: "2.0", Error: rpcErr, ID: req.ID}
+	}
+	return &rpcResponse{JSONRPC: "2.0", Result: result, ID: req.ID}
+}
+
+func (s *Server) rpcDispatch(ctx context.Context, req rpcRequest, scope Scope) (interface{}, *rpcError) {
+	switch req.Method {
+	case "search.findUsers":
+		params := rpcFindUsersParams{}
+		if err := decodeRPCParams(req.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		if params.IncludeDeleted && scope != ScopeAdmin {
+			return nil, &rpcError{rpcInvalidParams, model.ErrorAdminOnly}
+		}
+		ctx, cancel := s.searchContext(ctx)
+		defer cancel()
+		resp, err := s.findPage(ctx, searchcore.Query{
+			Query:          params.Query,
+			OrderField:     params.OrderField,
+			OrderBy:        params.OrderBy,
+			OrderLocale:    params.OrderLocale,
+			Limit:          params.Limit,
+			Offset:         params.Offset,
+			IncludeDeleted: params.IncludeDeleted,
+			AllowPartial:   params.AllowPartial,
+			Redact:         s.redacts(scope),
+			Gender:         params.Gender,
+			AgeMin:         params.AgeMin,
+			AgeMax:         params.AgeMax,
+			Email:          params.Email,
+			Phone:          params.Phone,
+			Company:        params.Company,
+			Address:        params.Address,
+			AfterID:        params.AfterID,
+			AfterValue:     params.AfterValue,
+			Matcher:        params.Matcher,
+			BoostName:      params.BoostName,
+			BoostAbout:     params.BoostAbout,
+		})
+		if err == model.ErrSearchTimeout {
+			return nil, &rpcError{rpcTimeout, err.Error()}
+		}
+		if err == model.ErrDownstream {
+			return nil, &rpcError{rpcDownstream, err.Error()}
+		}
+		if err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		return resp, nil
+
+	case "search.getUser":
+		params := rpcGetUserParams{}
+		if err := decodeRPCParams(req.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		if params.ID == nil {
+			return nil, &rpcError{rpcInvalidParams, "id is required"}
+		}
+		u, ok := s.lookupUser(*params.ID, scope)
+		if !ok {
+			return nil, &rpcError{rpcUserNotFound, model.ErrorUserNotFound}
+		}
+		if s.redacts(scope) {
+			u = s.redactUser(u)
+		}
+		return u, nil
+	}
+	return nil, &rpcError{rpcMethodNotFound, "Method not found"}
+}
+
+// decodeRPCParams принимает параметры только по имени (объект), отсутствие params - пустой объект
+func decodeRPCParams(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func rpcErrorResponse(id json.RawMessage, code int, msg string) *rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{code, msg}, ID: id}
+}
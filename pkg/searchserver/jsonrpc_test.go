@@ -0,0 +1,122 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestRPCFindUsers(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodPost, "/rpc",
+		`{"jsonrpc": "2.0", "method": "search.findUsers", "params": {"query": "Boyd", "limit": 5}, "id": 1}`, nil)
+
+	var resp struct {
+		Result model.SearchResponse
+		ID     int
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.ID != 1 || len(resp.Result.Users) != 1 || resp.Result.Users[0].Name != "Boyd Wolf" || resp.Result.NextPage {
+		t.Errorf("Error : %v", w.Body.String())
+	}
+}
+
+func TestRPCFindUsersNoLimit(t *testing.T) {
+	h := newTestHandler()
+	expected := decodeUsers(t, doRequest(h, http.MethodGet, "/?query=nisi", "", nil))
+
+	w := doRequest(h, http.MethodPost, "/rpc",
+		`{"jsonrpc": "2.0", "method": "search.findUsers", "params": {"query": "nisi"}, "id": 1}`, nil)
+
+	var resp struct {
+		Result model.SearchResponse
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(expected) == 0 || len(resp.Result.Users) != len(expected) || resp.Result.NextPage {
+		t.Errorf("Error : %d users, want %d: %.200s", len(resp.Result.Users), len(expected), w.Body.String())
+	}
+}
+
+func TestRPCGetUser(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodPost, "/rpc", `{"jsonrpc": "2.0", "method": "search.getUser", "params": {"id": 1}, "id": "a"}`, nil)
+
+	var resp struct {
+		Result model.User
+		ID     string
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.ID != "a" || resp.Result.Id != 1 {
+		t.Errorf("Error : %v", w.Body.String())
+	}
+}
+
+func TestRPCBatch(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodPost, "/rpc", `[
+		{"jsonrpc": "2.0", "method": "search.getUser", "params": {"id": 1000}, "id": 1},
+		{"jsonrpc": "2.0", "method": "search.findUsers", "params": {"order_field": "bad", "order_by": 1}, "id": 2},
+		{"jsonrpc": "2.0", "method": "search.unknown", "id": 3},
+		{"jsonrpc": "2.0", "method": "search.findUsers"},
+		{"foo": "bar"}
+	]`, nil)
+
+	var resp []rpcResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	expected := []int{rpcUserNotFound, rpcInvalidParams, rpcMethodNotFound, rpcInvalidRequest}
+	if len(resp) != len(expected) {
+		t.Fatalf("Error : %v", w.Body.String())
+	}
+	for i, code := range expected {
+		if resp[i].Error == nil || resp[i].Error.Code != code {
+			t.Errorf("Error : response %v is %v", i, resp[i])
+		}
+	}
+	if resp[1].Error.Message != "ErrorBadOrderField" {
+		t.Errorf("Error : %v", resp[1].Error.Message)
+	}
+}
+
+func TestRPCNotificationsOnly(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodPost, "/rpc", `[{"jsonrpc": "2.0", "method": "search.findUsers"}]`, nil)
+
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("Error : %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestRPCParseError(t *testing.T) {
+	h := newTestHandler()
+
+	for _, body := range []string{`{"jsonrpc": "2.0", "method"`, `[`, `[]`} {
+		w := doRequest(h, http.MethodPost, "/rpc", body, nil)
+
+		resp := rpcResponse{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Error == nil || string(resp.ID) != "null" {
+			t.Errorf("Error : %v -> %v", body, w.Body.String())
+		}
+	}
+}
+
+func TestRPCBodyTooLarge(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodPost, "/rpc", `{"jsonrpc": "2.0", "method": "`+strings.Repeat(" ", maxRPCBytes)+`"}`, nil)
+
+	resp := rpcResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusRequestEntityTooLarge || resp.Error == nil || resp.Error.Code != rpcInvalidRequest {
+		t.Errorf("Error : %v %.200v", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,133 @@
+package searchserver
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// сколько ждать свободного слота, если в конфиге не задано
+const defaultQueueTimeout = 100 * time.Millisecond
+
+// inflightLimiter ограничивает число одновременно выполняемых поисковых запросов.
+// Лишние запросы недолго ждут слот, а затем получают 503, чтобы сервер под перегрузкой
+// не захлёбывался, а предсказуемо отказывал. Освободившийся слот достаётся ждущему
+// с наибольшим приоритетом токена, а PriorityLow под перегрузкой не ждёт вовсе
+type inflightLimiter struct {
+	mu       sync.Mutex
+	max      int
+	inflight int
+	// очереди ждущих по приоритетам, от PriorityLow до PriorityHigh
+	waiters      [PriorityHigh - PriorityLow + 1]list.List
+	queueTimeout time.Duration
+}
+
+// slotWaiter ждёт, пока release передаст ему слот
+type slotWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+func newInflightLimiter(max int, queueTimeout time.Duration) *inflightLimiter {
+	if queueTimeout <= 0 {
+		queueTimeout = defaultQueueTimeout
+	}
+	return &inflightLimiter{
+		max:          max,
+		queueTimeout: queueTimeout,
+	}
+}
+
+func (l *inflightLimiter) acquire(r *http.Request) bool {
+	priority := requestPriority(r)
+	l.mu.Lock()
+	if l.inflight < l.max {
+		l.inflight++
+		l.mu.Unlock()
+		return true
+	}
+	if priority <= PriorityLow {
+		l.mu.Unlock()
+		return false
+	}
+	waiter := &slotWaiter{ready: make(chan struct{})}
+	queue := &l.waiters[priority-PriorityLow]
+	el := queue.PushBack(waiter)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case <-waiter.ready:
+		return true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// слот могли передать, пока истекал таймер, - тогда он уже наш
+	if waiter.granted {
+		return true
+	}
+	queue.Remove(el)
+	return false
+}
+
+// tryAcquire занимает свободный слот без ожидания. Пока слоты есть, очередь пуста,
+// так что ждущих с более высоким приоритетом он не обгоняет
+func (l *inflightLimiter) tryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight < l.max {
+		l.inflight++
+		return true
+	}
+	return false
+}
+
+// release передаёт слот первому ждущему с наибольшим приоритетом или освобождает его
+func (l *inflightLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.waiters) - 1; i >= 0; i-- {
+		if el := l.waiters[i].Front(); el != nil {
+			waiter := l.waiters[i].Remove(el).(*slotWaiter)
+			waiter.granted = true
+			close(waiter.ready)
+			return
+		}
+	}
+	l.inflight--
+}
+
+// inUse - сколько слотов занято
+func (l *inflightLimiter) inUse() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+// limit оборачивает обработчик поиска, без лимита в конфиге возвращает его как есть
+func (s *Server) limit(h http.HandlerFunc) http.HandlerFunc {
+	if s.limiter == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.limiter.acquire(r) {
+			retry := int(s.limiter.queueTimeout / time.Second)
+			if retry < 1 {
+				retry = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			writeError(w, http.StatusServiceUnavailable, model.ErrorOverloaded)
+			return
+		}
+		defer s.limiter.release()
+		h(w, r)
+	}
+}
@@ -0,0 +1,141 @@
+package searchserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInflightLimitOverloaded(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, MaxInFlight: 1, QueueTimeout: 10 * time.Millisecond})
+
+	// занимаем единственный слот
+	s.limiter.acquire(httptest.NewRequest("GET", "/", nil))
+
+	rec := doRequest(s, "GET", "/?limit=1", "", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Error : unexpected status %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Error : unexpected Retry-After %q", rec.Header().Get("Retry-After"))
+	}
+
+	// одиночные запросы к пользователям не ограничиваются
+	if rec := doRequest(s, "GET", "/users/1", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+
+	s.limiter.release()
+	if rec := doRequest(s, "GET", "/?limit=1", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+}
+
+func TestInflightLimitQueues(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, MaxInFlight: 1, QueueTimeout: time.Second})
+
+	s.limiter.acquire(httptest.NewRequest("GET", "/", nil))
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.limiter.release()
+	}()
+
+	if rec := doRequest(s, "GET", "/?limit=1", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+}
+
+func TestInflightLimitConcurrent(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, MaxInFlight: 2, QueueTimeout: time.Second})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := doRequest(s, "GET", "/?limit=5", "", nil); rec.Code != http.StatusOK {
+				t.Errorf("Error : unexpected status %d", rec.Code)
+			}
+		}()
+	}
+	wg.Wait()
+	if s.limiter.inUse() != 0 {
+		t.Errorf("Error : slots leaked: %d", s.limiter.inUse())
+	}
+}
+
+// освободившийся слот достаётся высокому приоритету, даже если обычный ждёт дольше
+func TestInflightLimitPriority(t *testing.T) {
+	l := newInflightLimiter(1, time.Second)
+	request := func(priority Priority) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		return r.WithContext(context.WithValue(r.Context(), priorityKey{}, priority))
+	}
+	l.acquire(request(PriorityNormal))
+
+	// низкий приоритет под перегрузкой не ждёт
+	if l.acquire(request(PriorityLow)) {
+		t.Fatalf("Error : low priority admitted over limit")
+	}
+
+	order := make(chan Priority, 2)
+	for _, priority := range []Priority{PriorityNormal, PriorityHigh} {
+		go func(priority Priority) {
+			if l.acquire(request(priority)) {
+				order <- priority
+			}
+		}(priority)
+		// ждём, пока запрос встанет в очередь
+		for waiting := 0; waiting == 0; time.Sleep(time.Millisecond) {
+			l.mu.Lock()
+			waiting = l.waiters[priority-PriorityLow].Len()
+			l.mu.Unlock()
+		}
+	}
+
+	l.release()
+	if first := <-order; first != PriorityHigh {
+		t.Errorf("Error : %v admitted before high priority", first)
+	}
+	l.release()
+	if second := <-order; second != PriorityNormal {
+		t.Errorf("Error : unexpected %v", second)
+	}
+	l.release()
+	if l.inUse() != 0 || !l.acquire(request(PriorityLow)) {
+		t.Errorf("Error : free slot not given to low priority, in use %d", l.inUse())
+	}
+}
+
+func TestTokenPriorityShed(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	const lowToken = "dashboard"
+	s := NewServer(users, ServerConfig{
+		Tokens:          map[string]Scope{accessToken: ScopeSearch, lowToken: ScopeSearch},
+		TokenPriorities: map[string]Priority{lowToken: PriorityLow},
+		MaxInFlight:     1,
+		QueueTimeout:    time.Second,
+	})
+	low := map[string]string{"AccessToken": lowToken}
+	if rec := doRequest(s, "GET", "/?limit=1", "", low); rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d", rec.Code)
+	}
+
+	s.limiter.acquire(httptest.NewRequest("GET", "/", nil))
+	if rec := doRequest(s, "GET", "/?limit=1", "", low); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Error : low priority not shed, status %d", rec.Code)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.limiter.release()
+	}()
+	if rec := doRequest(s, "GET", "/?limit=1", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+}
@@ -0,0 +1,203 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+// LoadPolicy - что делать со строками датасета, не прошедшими проверку при загрузке
+type LoadPolicy string
+
+const (
+	// LoadFail - не загружать датасет вовсе, по умолчанию
+	LoadFail LoadPolicy = "fail"
+	// LoadSkip - загрузить остальное, пропущенные строки записать в лог
+	LoadSkip LoadPolicy = "skip"
+	// LoadQuarantine - как LoadSkip, но строки целиком пишутся в QuarantinePath, чтобы их можно было поправить.
+	// Повторы Id решает DuplicatePolicy, в карантин они не попадают
+	LoadQuarantine LoadPolicy = "quarantine"
+)
+
+// DuplicatePolicy - что делать со строками датасета с уже встречавшимся Id
+type DuplicatePolicy string
+
+const (
+	// DuplicateReject - не загружать датасет с повторами, по умолчанию
+	DuplicateReject DuplicatePolicy = "reject"
+	// DuplicateKeepFirst - оставить первую строку с Id, остальные отбросить
+	DuplicateKeepFirst DuplicatePolicy = "keep-first"
+	// DuplicateKeepLast - оставить последнюю строку с Id, например если правки дописывают в конец файла
+	DuplicateKeepLast DuplicatePolicy = "keep-last"
+)
+
+// LoadOptions - как LoadDatasetChecked поступает с проблемными строками. Пустые поля - LoadFail и DuplicateReject
+type LoadOptions struct {
+	Policy     LoadPolicy
+	Duplicates DuplicatePolicy
+}
+
+func (o LoadOptions) withDefaults() LoadOptions {
+	if o.Policy == "" {
+		o.Policy = LoadFail
+	}
+	if o.Duplicates == "" {
+		o.Duplicates = DuplicateReject
+	}
+	return o
+}
+
+func ParseLoadPolicy(s string) (LoadPolicy, error) {
+	switch p := LoadPolicy(strings.TrimSpace(s)); p {
+	case "":
+		return LoadFail, nil
+	case LoadFail, LoadSkip, LoadQuarantine:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown load policy %q, want fail, skip or quarantine", s)
+}
+
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(strings.TrimSpace(s)); p {
+	case "":
+		return DuplicateReject, nil
+	case DuplicateReject, DuplicateKeepFirst, DuplicateKeepLast:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown duplicate policy %q, want reject, keep-first or keep-last", s)
+}
+
+// LoadStats - итог проверки датасета при загрузке, отдаётся в /admin/stats
+type LoadStats struct {
+	Policy          LoadPolicy      `json:",omitempty"`
+	DuplicatePolicy DuplicatePolicy `json:",omitempty"`
+	Rows            int
+	Loaded          int
+	Rejected        int
+	// строк, отброшенных как повтор Id
+	Duplicates int
+	// куда сложены отброшенные строки при LoadQuarantine
+	Quarantine string `json:",omitempty"`
+}
+
+// QuarantinePath - куда LoadQuarantine складывает отброшенные строки датасета path
+func QuarantinePath(path string) string {
+	return path + ".rejected.jsonl"
+}
+
+// quarantinedRow - строка файла карантина: исходные элементы строки и что с ними не так
+type quarantinedRow struct {
+	Row    int
+	Fields map[string]string
+	Issues []ImportIssue
+}
+
+// LoadDatasetChecked читает датасет как LoadDatasetMapping, но проверяет каждую строку,
+// как /admin/import, и поступает с проблемными по opts. Файл, который не разобрался
+// целиком, - ошибка при любой политике. Повторы Id решаются после проверки строк:
+// строка с ошибкой повтором не считается
+func LoadDatasetChecked(path string, mapping FieldMapping, opts LoadOptions) ([]model.User, LoadStats, error) {
+	opts = opts.withDefaults()
+	stats := LoadStats{Policy: opts.Policy, DuplicatePolicy: opts.Duplicates}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, stats, err
+	}
+	rows, err := datasetRows(data, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return nil, stats, err
+	}
+
+	users, rowOf, report := validateRows(rows, mapping)
+	users, duplicates := resolveDuplicates(users, rowOf, opts.Duplicates)
+	stats.Rows, stats.Rejected, stats.Duplicates = report.Rows, report.Rows-report.Valid, len(duplicates)
+	stats.Loaded = len(users)
+	if len(duplicates) > 0 {
+		if opts.Duplicates == DuplicateReject {
+			first := duplicates[0]
+			return nil, stats, fmt.Errorf("%d duplicate ids, first: row %d: %s", len(duplicates), first.Row, first.Error)
+		}
+		for _, d := range duplicates {
+			log.Printf("dataset %s: row %d dropped: %s", path, d.Row, d.Error)
+		}
+	}
+	if len(report.Issues) == 0 {
+		return users, stats, nil
+	}
+
+	first := report.Issues[0]
+	switch opts.Policy {
+	case LoadSkip:
+		for _, issue := range report.Issues {
+			log.Printf("dataset %s: row %d skipped: %s", path, issue.Row, issue.Error)
+		}
+	case LoadQuarantine:
+		stats.Quarantine = QuarantinePath(path)
+		if err := writeQuarantine(stats.Quarantine, rows, report.Issues); err != nil {
+			return nil, stats, fmt.Errorf("quarantine: %v", err)
+		}
+		log.Printf("dataset %s: %d rows moved to %s", path, stats.Rejected, stats.Quarantine)
+	default:
+		return nil, stats, fmt.Errorf("%d invalid rows, first: row %d: %s", stats.Rejected, first.Row, first.Error)
+	}
+	return users, stats, nil
+}
+
+// resolveDuplicates оставляет по одному пользователю на Id: последнего при DuplicateKeepLast,
+// иначе первого. Отброшенные строки возвращаются как проблемы, порядок пользователей сохраняется
+func resolveDuplicates(users []model.User, rowOf []int, policy DuplicatePolicy) ([]model.User, []ImportIssue) {
+	keep := make(map[int]int, len(users))
+	for i, u := range users {
+		if _, ok := keep[u.Id]; !ok || policy == DuplicateKeepLast {
+			keep[u.Id] = i
+		}
+	}
+	if len(keep) == len(users) {
+		return users, nil
+	}
+
+	kept := make([]model.User, 0, len(keep))
+	var dropped []ImportIssue
+	for i, u := range users {
+		if k := keep[u.Id]; k != i {
+			dropped = append(dropped, ImportIssue{Row: rowOf[i], Field: "id", Error: fmt.Sprintf("duplicate id %d, kept row %d", u.Id, rowOf[k])})
+			continue
+		}
+		kept = append(kept, u)
+	}
+	return kept, dropped
+}
+
+// writeQuarantine перезаписывает файл карантина отброшенными строками, по одной json на строку
+func writeQuarantine(path string, rows []map[string]string, issues []ImportIssue) error {
+	var rejected []quarantinedRow
+	for _, issue := range issues {
+		if n := len(rejected); n > 0 && rejected[n-1].Row == issue.Row {
+			rejected[n-1].Issues = append(rejected[n-1].Issues, issue)
+			continue
+		}
+		rejected = append(rejected, quarantinedRow{Row: issue.Row, Fields: rows[issue.Row], Issues: []ImportIssue{issue}})
+	}
+
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	for _, row := range rejected {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, []byte(buf.String()), 0600)
+}
+
+// SetLoadStats запоминает итог загрузки датасета для /admin/stats
+func (s *Server) SetLoadStats(stats LoadStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadStats = stats
+}
@@ -0,0 +1,87 @@
+package searchserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// MaintenanceStatus - ответ /admin/maintenance
+type MaintenanceStatus struct {
+	Enabled bool
+	// с какого момента включён, нулевое время - выключен
+	Since time.Time `json:",omitempty"`
+}
+
+// SetMaintenance включает и выключает режим обслуживания. В нём поиск и чтение идут
+// по датасету в памяти и кэшу, а правки, импорт и версии датасета, очистка, приём репликации,
+// сохранённые поиски и алерты получают 503,
+// журнал правок не сжимается - хранилище можно переносить
+func (s *Server) SetMaintenance(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if enabled == s.maintenance.Load() {
+		return
+	}
+	s.maintenanceSince = time.Time{}
+	if enabled {
+		s.maintenanceSince = time.Now()
+	}
+	s.maintenance.Store(enabled)
+}
+
+// Maintenance возвращает состояние режима обслуживания
+func (s *Server) Maintenance() MaintenanceStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return MaintenanceStatus{Enabled: s.maintenance.Load(), Since: s.maintenanceSince}
+}
+
+// mutates сообщает, что запрос меняет датасет или хранилище
+func mutates(r *http.Request) bool {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/users/"),
+		path == "/saved", strings.HasPrefix(path, "/saved/"),
+		path == "/alerts", strings.HasPrefix(path, "/alerts/"),
+		path == "/admin/datasets", strings.HasPrefix(path, "/admin/datasets/"):
+		return r.Method != http.MethodGet && r.Method != http.MethodHead
+	case path == "/admin/import":
+		// stage=true тоже пишет: кладёт подготовленную версию рядом с текущей
+		return r.URL.Query().Get("dry_run") != "true"
+	case path == "/admin/purge", strings.HasPrefix(path, "/admin/replication/"):
+		return true
+	}
+	return false
+}
+
+// rejectMaintenance отвечает 503 на правку в режиме обслуживания, false - запрос можно выполнять
+func (s *Server) rejectMaintenance(w http.ResponseWriter, r *http.Request) bool {
+	if !s.maintenance.Load() || !mutates(r) {
+		return false
+	}
+	writeError(w, http.StatusServiceUnavailable, model.ErrorMaintenance)
+	return true
+}
+
+// maintenanceHandler - GET /admin/maintenance и POST /admin/maintenance?enabled=true|false
+func (s *Server) maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad enabled: "+err.Error())
+			return
+		}
+		s.SetMaintenance(enabled)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Maintenance())
+}
@@ -0,0 +1,66 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	s := newTestHandler()
+	admin := map[string]string{"AccessToken": adminToken}
+
+	rec := doRequest(s, "POST", "/admin/maintenance?enabled=true", "", admin)
+	status := MaintenanceStatus{}
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if rec.Code != http.StatusOK || !status.Enabled || status.Since.IsZero() {
+		t.Fatalf("Error : unexpected response %d %+v", rec.Code, status)
+	}
+
+	// чтение работает
+	for _, target := range []string{"/?limit=3", "/users/1", "/search/explain?limit=3", "/saved", "/alerts", "/admin/datasets"} {
+		if rec := doRequest(s, "GET", target, "", admin); rec.Code != http.StatusOK {
+			t.Errorf("Error : GET %s: unexpected status %d", target, rec.Code)
+		}
+	}
+	if rec := doRequest(s, "POST", "/admin/import?dry_run=true", `[]`, map[string]string{"AccessToken": adminToken, "Content-Type": "application/json"}); rec.Code == http.StatusServiceUnavailable {
+		t.Errorf("Error : dry run import rejected")
+	}
+
+	// правки - нет
+	for _, req := range []struct{ method, target, body string }{
+		{"PATCH", "/users/1", `{"Age": 50}`},
+		{"DELETE", "/users/1", ""},
+		{"POST", "/admin/purge", ""},
+		{"POST", "/admin/import", `[]`},
+		{"POST", "/admin/import?stage=true", `[]`},
+		{"POST", "/admin/replication/changes", `{}`},
+		{"PUT", "/saved/go", `{"query": "go"}`},
+		{"DELETE", "/saved/go", ""},
+		{"PUT", "/alerts/go", `{"Params": {"query": "go"}, "Webhook": "http://127.0.0.1/hook"}`},
+		{"DELETE", "/alerts/go", ""},
+		{"POST", "/admin/datasets/switch", ""},
+		{"POST", "/admin/datasets/rollback", ""},
+		{"DELETE", "/admin/datasets/staged", ""},
+		{"DELETE", "/admin/datasets/previous", ""},
+	} {
+		rec := doRequest(s, req.method, req.target, req.body, admin)
+		if rec.Code != http.StatusServiceUnavailable || !json.Valid(rec.Body.Bytes()) {
+			t.Errorf("Error : %s %s: unexpected status %d", req.method, req.target, rec.Code)
+		}
+	}
+	if u, _ := s.lookupUser(1, ScopeAdmin); u.Deleted || u.Age == 50 {
+		t.Errorf("Error : user changed in maintenance %+v", u)
+	}
+
+	rec = doRequest(s, "POST", "/admin/maintenance?enabled=false", "", admin)
+	if json.Unmarshal(rec.Body.Bytes(), &status); status.Enabled {
+		t.Fatalf("Error : maintenance not disabled %+v", status)
+	}
+	if rec := doRequest(s, "PATCH", "/users/1", `{"Age": 50}`, admin); rec.Code != http.StatusOK {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+	if rec := doRequest(s, "POST", "/admin/maintenance?enabled=maybe", "", admin); rec.Code != http.StatusBadRequest {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+}
@@ -0,0 +1,192 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+const (
+	// сколько зеркальных запросов может висеть одновременно, лишние отбрасываются
+	mirrorMaxInFlight = 16
+	mirrorTimeout     = 5 * time.Second
+)
+
+// MirrorConfig - теневой режим: часть поисковых запросов асинхронно повторяется
+// на другой реализации поиска, расхождения выдачи пишутся в журнал
+type MirrorConfig struct {
+	// куда повторять запросы, пусто - зеркалирование выключено
+	URL string
+	// токен для вторичного сервера
+	AccessToken string
+	// доля зеркалируемых запросов в процентах, 0..100
+	Percent int
+	// куда писать расхождения (JSON lines), по умолчанию stderr
+	Log io.Writer
+}
+
+// mirrorDiff - одна строка журнала расхождений
+type mirrorDiff struct {
+	Time    time.Time `json:"time"`
+	Query   string    `json:"query"`
+	Error   string    `json:"error,omitempty"`
+	Primary int       `json:"primary_count"`
+	Mirror  int       `json:"mirror_count"`
+	// Id, которые есть только в основной или только во вторичной выдаче
+	Missing []int `json:"missing,omitempty"`
+	Extra   []int `json:"extra,omitempty"`
+	// Id, у которых отличаются поля
+	Changed []int `json:"changed,omitempty"`
+	// тот же набор записей, но в другом порядке
+	OrderMismatch bool `json:"order_mismatch,omitempty"`
+}
+
+type mirror struct {
+	cfg    MirrorConfig
+	client *http.Client
+	slots  chan struct{}
+	wg     sync.WaitGroup
+
+	mu  sync.Mutex
+	log io.Writer
+	rnd *rand.Rand
+}
+
+func newMirror(cfg MirrorConfig) *mirror {
+	m := &mirror{
+		cfg:    cfg,
+		client: &http.Client{Timeout: mirrorTimeout},
+		slots:  make(chan struct{}, mirrorMaxInFlight),
+		log:    cfg.Log,
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if m.log == nil {
+		m.log = os.Stderr
+	}
+	return m
+}
+
+func (m *mirror) sampled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rnd.Intn(100) < m.cfg.Percent
+}
+
+// maybeMirror с вероятностью Percent повторяет запрос в фоне и сравнивает ответ с primary.
+// Основной запрос не ждёт зеркала: если все слоты заняты, запрос просто не зеркалируется
+func (m *mirror) maybeMirror(params url.Values, primary []model.User) {
+	if !m.sampled() {
+		return
+	}
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		return
+	}
+	m.wg.Add(1)
+	go func() {
+		defer func() {
+			<-m.slots
+			m.wg.Done()
+		}()
+		m.compare(params, primary)
+	}()
+}
+
+func (m *mirror) compare(params url.Values, primary []model.User) {
+	mirrored := url.Values{}
+	for k, v := range params {
+		mirrored[k] = v
+	}
+	// сравниваем всегда json, независимо от формата основного ответа
+	mirrored.Set("format", model.FormatJSON)
+	mirrored.Del("stream")
+
+	diff := mirrorDiff{Time: time.Now().UTC(), Query: model.Redact(params.Encode()), Primary: len(primary)}
+	secondary, err := m.fetch(mirrored)
+	if err != nil {
+		diff.Error = model.Redact(err.Error())
+		m.write(diff)
+		return
+	}
+	diff.Mirror = len(secondary)
+	if diffUsers(&diff, primary, secondary) {
+		m.write(diff)
+	}
+}
+
+func (m *mirror) fetch(params url.Values) ([]model.User, error) {
+	req, err := http.NewRequest(http.MethodGet, m.cfg.URL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("AccessToken", m.cfg.AccessToken)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mirror status %d", resp.StatusCode)
+	}
+	users := []model.User{}
+	if err := json.Unmarshal(body, &users); err != nil {
+		return nil, fmt.Errorf("cant unpack mirror json: %s", err)
+	}
+	return users, nil
+}
+
+// diffUsers заполняет расхождения и сообщает, есть ли они
+func diffUsers(diff *mirrorDiff, primary, secondary []model.User) bool {
+	byID := make(map[int]model.User, len(secondary))
+	for _, u := range secondary {
+		byID[u.Id] = u
+	}
+	seen := make(map[int]bool, len(primary))
+	for _, u := range primary {
+		seen[u.Id] = true
+		other, ok := byID[u.Id]
+		if !ok {
+			diff.Missing = append(diff.Missing, u.Id)
+		} else if other != u {
+			diff.Changed = append(diff.Changed, u.Id)
+		}
+	}
+	for _, u := range secondary {
+		if !seen[u.Id] {
+			diff.Extra = append(diff.Extra, u.Id)
+		}
+	}
+	if len(diff.Missing) == 0 && len(diff.Extra) == 0 {
+		for i := range primary {
+			if primary[i].Id != secondary[i].Id {
+				diff.OrderMismatch = true
+				break
+			}
+		}
+	}
+	return len(diff.Missing) > 0 || len(diff.Extra) > 0 || len(diff.Changed) > 0 || diff.OrderMismatch
+}
+
+func (m *mirror) write(diff mirrorDiff) {
+	line, err := json.Marshal(diff)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.log.Write(append(line, '\n'))
+}
@@ -0,0 +1,99 @@
+package searchserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func newMirroredHandler(t *testing.T, secondary http.Handler, percent int) (*Server, *bytes.Buffer) {
+	ts := httptest.NewServer(secondary)
+	t.Cleanup(ts.Close)
+
+	var log bytes.Buffer
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{
+		Tokens: testServerConfig.Tokens,
+		Mirror: MirrorConfig{URL: ts.URL, AccessToken: accessToken, Percent: percent, Log: &log},
+	})
+	return s, &log
+}
+
+func TestMirrorSameResults(t *testing.T) {
+	h, log := newMirroredHandler(t, newTestHandler(), 100)
+
+	doRequest(h, "GET", "/?query=nisi&limit=5&order_field=Age&order_by=1", "", nil)
+	doRequest(h, "GET", "/?limit=3&format=xml", "", nil)
+	h.Close()
+
+	if log.Len() != 0 {
+		t.Errorf("Error : unexpected diff %s", log.String())
+	}
+}
+
+func TestMirrorDiff(t *testing.T) {
+	// вторичная реализация, которая выдаёт записи в обратном порядке
+	secondary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != model.FormatJSON || r.Header.Get("AccessToken") != accessToken {
+			t.Errorf("Error : unexpected mirrored request %v", r.URL)
+		}
+		users, _, _ := newTestHandler().find(r.Context(), parseSearchQuery(r.URL.Query()))
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+		json.NewEncoder(w).Encode(users)
+	})
+	h, log := newMirroredHandler(t, secondary, 100)
+
+	doRequest(h, "GET", "/?limit=3", "", nil)
+	h.Close()
+
+	diff := mirrorDiff{}
+	if err := json.Unmarshal(log.Bytes(), &diff); err != nil {
+		t.Fatalf("Error : %v: %s", err, log.String())
+	}
+	if !diff.OrderMismatch || diff.Primary != 3 || diff.Mirror != 3 || !strings.Contains(diff.Query, "limit=3") {
+		t.Errorf("Error : unexpected diff %+v", diff)
+	}
+}
+
+func TestMirrorError(t *testing.T) {
+	secondary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	h, log := newMirroredHandler(t, secondary, 100)
+
+	w := doRequest(h, "GET", "/?limit=3", "", nil)
+	h.Close()
+
+	if w.Code != http.StatusOK || !strings.Contains(log.String(), "mirror status 500") {
+		t.Errorf("Error : unexpected log %s", log.String())
+	}
+}
+
+func TestMirrorDisabled(t *testing.T) {
+	secondary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Error : request mirrored with 0%%")
+	})
+	h, _ := newMirroredHandler(t, secondary, 0)
+	doRequest(h, "GET", "/?limit=3", "", nil)
+	h.Close()
+}
+
+func TestDiffUsers(t *testing.T) {
+	primary := []model.User{{Id: 1}, {Id: 2, Name: "a"}, {Id: 3}}
+	secondary := []model.User{{Id: 1}, {Id: 2, Name: "b"}, {Id: 4}}
+	diff := mirrorDiff{}
+	if !diffUsers(&diff, primary, secondary) {
+		t.Fatalf("Error : no diff")
+	}
+	if len(diff.Missing) != 1 || diff.Missing[0] != 3 || len(diff.Extra) != 1 || diff.Extra[0] != 4 ||
+		len(diff.Changed) != 1 || diff.Changed[0] != 2 {
+		t.Errorf("Error : unexpected diff %+v", diff)
+	}
+}
@@ -0,0 +1,155 @@
+package searchserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+const (
+	// сколько запросов принимает POST /msearch, если MaxMultiSearch не задан
+	defaultMaxMultiSearch = 50
+	maxMultiSearchBytes   = 1 << 20
+	// сколько запросов /msearch выполняется одновременно
+	maxMultiSearchParallel = 4
+)
+
+// msearch - POST /msearch: массив запросов с теми же параметрами, что у GET /, например
+// [{"query": "nisi", "limit": 5}, {"gender": "male", "order_field": "Age", "order_by": 1}].
+// Запросы выполняются параллельно, ответ - массив MultiSearchResult в том же порядке.
+// Ошибка одного запроса не мешает остальным. stream, format и group_by не поддерживаются.
+// Каждый запрос списывается с квоты токена, а параллельно выполняются только те, кому
+// хватило свободного слота поиска: под нагрузкой пакет идёт по одному в слоте самого запроса
+func (s *Server) msearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxMultiSearchBytes))
+	if err != nil {
+		writeError(w, bodyErrorStatus(err), err.Error())
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var items []map[string]interface{}
+	max := s.cfg.MaxMultiSearch
+	if max <= 0 {
+		max = defaultMaxMultiSearch
+	}
+	if err := dec.Decode(&items); err != nil || len(items) == 0 || len(items) > max {
+		writeError(w, http.StatusBadRequest, model.ErrorBadMultiSearch)
+		return
+	}
+
+	scope := requestScope(r)
+	token := r.Header.Get("AccessToken")
+	results := make([]model.MultiSearchResult, len(items))
+	queue := make(chan int, len(items))
+	params := make([]url.Values, len(items))
+	for i, item := range items {
+		// первый запрос уже засчитан при входе в ServeHTTP
+		if i > 0 && !s.usage.admit(token, scope) {
+			results[i] = model.MultiSearchResult{Status: http.StatusTooManyRequests, Error: &model.SearchErrorResponse{Error: model.ErrorQuotaExceeded}}
+			continue
+		}
+		params[i] = url.Values{}
+		for name, value := range item {
+			params[i].Set(name, fmtParam(value))
+		}
+		queue <- i
+	}
+	close(queue)
+
+	run := func() {
+		for i := range queue {
+			results[i] = s.msearchOne(r.Context(), params[i], scope)
+		}
+	}
+	var wg sync.WaitGroup
+	for workers := 1; workers < maxMultiSearchParallel && workers < len(queue); workers++ {
+		if s.limiter != nil && !s.limiter.tryAcquire() {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.limiter != nil {
+				defer s.limiter.release()
+			}
+			run()
+		}()
+	}
+	run()
+	wg.Wait()
+	writeJSON(w, http.StatusOK, results)
+}
+
+// fmtParam - значение параметра из json-объекта (разобранного с UseNumber) в том виде, в каком оно пришло бы в url
+func fmtParam(value interface{}) string {
+	return fmt.Sprint(value)
+}
+
+// msearchOne выполняет один запрос /msearch так же, как GET /, но без кэша страниц
+func (s *Server) msearchOne(ctx context.Context, q url.Values, scope Scope) model.MultiSearchResult {
+	fail := func(status int, code string) model.MultiSearchResult {
+		return model.MultiSearchResult{Status: status, Error: &model.SearchErrorResponse{Error: code}}
+	}
+	query := parseSearchQuery(q)
+	query.Redact = s.redacts(scope)
+	if query.IncludeDeleted && scope != ScopeAdmin {
+		return fail(http.StatusForbidden, model.ErrorAdminOnly)
+	}
+	if query.GroupBy != "" || q.Get("stream") == "true" {
+		return fail(http.StatusBadRequest, model.ErrBadGroupBy.Error())
+	}
+	if asOf := q.Get("as_of"); asOf != "" {
+		t, err := time.Parse(time.RFC3339, asOf)
+		if err != nil || s.aggregator != nil {
+			return fail(http.StatusBadRequest, model.ErrorBadAsOf)
+		}
+		var ok bool
+		if ctx, ok = s.asOf(ctx, t); !ok {
+			return fail(http.StatusGone, model.ErrorAsOfUnavailable)
+		}
+	}
+	snapshot := s.snapshotID()
+	if pinned := q.Get("snapshot"); pinned != "" && pinned != snapshot {
+		return fail(http.StatusConflict, model.ErrorSnapshotChanged)
+	}
+	s.counters.countQuery(query)
+
+	ctx, cancel := s.searchContext(ctx)
+	defer cancel()
+	page, err := s.findPage(ctx, query)
+	switch {
+	case err == model.ErrLimitTooLarge:
+		res := fail(http.StatusBadRequest, model.ErrorLimitTooLarge)
+		res.Error.MaxLimit = s.maxLimit()
+		return res
+	case err == model.ErrOffsetTooLarge:
+		res := fail(http.StatusBadRequest, model.ErrorOffsetTooLarge)
+		res.Error.MaxOffset, res.Error.Hint = s.cfg.MaxOffset, offsetHint
+		return res
+	case err == model.ErrQueryTooExpensive:
+		res := fail(http.StatusBadRequest, model.ErrorQueryTooExpensive)
+		res.Error.MaxCost, res.Error.Hint = s.cfg.MaxQueryCost, costHint
+		return res
+	case err == model.ErrSearchTimeout:
+		return fail(http.StatusGatewayTimeout, err.Error())
+	case err == model.ErrDownstream:
+		return fail(http.StatusBadGateway, err.Error())
+	case err != nil:
+		return fail(http.StatusBadRequest, err.Error())
+	}
+	return model.MultiSearchResult{Status: http.StatusOK, Users: page.Users, NextPage: page.NextPage, Partial: page.Partial, Snapshot: snapshot}
+}
@@ -0,0 +1,69 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestMultiSearchEndpoint(t *testing.T) {
+	h := NewServer(newTestHandler().snapshot(), ServerConfig{Tokens: testServerConfig.Tokens, MaxLimit: 10, MaxMultiSearch: 3})
+	body := `[{"query": "nisi", "limit": 2}, {"include_deleted": true}, {"limit": 11}]`
+	rec := doRequest(h, "POST", "/msearch", body, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", rec.Code, rec.Body)
+	}
+	results := []model.MultiSearchResult{}
+	json.Unmarshal(rec.Body.Bytes(), &results)
+	if len(results) != 3 {
+		t.Fatalf("Error : unexpected results %+v", results)
+	}
+	page := results[0]
+	if page.Status != http.StatusOK || len(page.Users) != 2 || !page.NextPage || page.Snapshot == "" {
+		t.Errorf("Error : unexpected page %+v", page)
+	}
+	if r := results[1]; r.Status != http.StatusForbidden || r.Error.Error != model.ErrorAdminOnly {
+		t.Errorf("Error : unexpected result %+v", r)
+	}
+	if r := results[2]; r.Status != http.StatusBadRequest || r.Error.Error != model.ErrorLimitTooLarge || r.Error.MaxLimit != 10 {
+		t.Errorf("Error : unexpected result %+v", r)
+	}
+
+	for _, body := range []string{`{}`, `[]`, "[" + strings.Repeat(`{},`, 3) + "{}]"} {
+		if rec := doRequest(h, "POST", "/msearch", body, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Error : %s: unexpected status %d", body, rec.Code)
+		}
+	}
+}
+
+func TestMultiSearchLimits(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, MaxInFlight: 1, DefaultQuota: TokenQuota{Requests: 3}})
+
+	if rec := doRequest(s, "POST", "/msearch", `[{"query": "`+strings.Repeat(" ", maxMultiSearchBytes)+`"}]`, nil); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+
+	// в единственном слоте пакет выполняется по одному, каждый запрос списывается с квоты
+	rec := doRequest(s, "POST", "/msearch", `[{"limit": 1}, {"limit": 1}, {"limit": 1}]`, nil)
+	results := []model.MultiSearchResult{}
+	json.Unmarshal(rec.Body.Bytes(), &results)
+	if rec.Code != http.StatusOK || len(results) != 3 {
+		t.Fatalf("Error : unexpected response %d %s", rec.Code, rec.Body)
+	}
+	if results[0].Status != http.StatusOK || results[1].Status != http.StatusOK {
+		t.Errorf("Error : unexpected results %+v", results)
+	}
+	if r := results[2]; r.Status != http.StatusTooManyRequests || r.Error.Error != model.ErrorQuotaExceeded {
+		t.Errorf("Error : unexpected result %+v", r)
+	}
+	if s.limiter.inUse() != 0 {
+		t.Errorf("Error : %d slots still in use", s.limiter.inUse())
+	}
+	if rec := doRequest(s, "GET", "/?limit=1", "", nil); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Error : quota not charged per item, status %d", rec.Code)
+	}
+}
@@ -0,0 +1,550 @@
+package searchserver
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+// Спецификация OpenAPI собирается из описания обработчиков ниже и из Go-типов через reflect,
+// поэтому при изменении User или ответов схема обновляется сама
+
+// страница /docs: свой просмотрщик спецификации, всё встроено в бинарник и работает без сети
+//
+//go:embed docs
+var docsFiles embed.FS
+
+var docsFS, _ = fs.Sub(docsFiles, "docs")
+
+type apiParam struct {
+	Name        string
+	In          string
+	Type        reflect.Type
+	Description string
+}
+
+type apiOperation struct {
+	Method    string
+	Path      string
+	Summary   string
+	Params    []apiParam
+	Body      reflect.Type
+	Responses map[int]reflect.Type
+	// только для токенов с ScopeAdmin
+	Admin bool
+}
+
+var (
+	typeString = reflect.TypeOf("")
+	typeInt    = reflect.TypeOf(0)
+	typeNumber = reflect.TypeOf(0.0)
+	typeBool   = reflect.TypeOf(false)
+	typeUser   = reflect.TypeOf(model.User{})
+	typeUsers  = reflect.TypeOf([]model.User{})
+	typeError  = reflect.TypeOf(model.SearchErrorResponse{})
+	// ответ /admin/datasets
+	typeVersions = reflect.TypeOf(DatasetVersionsResponse{})
+)
+
+var userIDParam = apiParam{"id", "path", typeInt, "Id пользователя"}
+
+var savedNameParam = apiParam{"name", "path", typeString, "имя сохранённого поиска"}
+
+var alertNameParam = apiParam{"name", "path", typeString, "имя алерта"}
+
+var apiOperations = []apiOperation{
+	{
+		Method:  http.MethodGet,
+		Path:    "/",
+		Summary: "Поиск пользователей",
+		Params: []apiParam{
+			{"query", "query", typeString, "подстрока в Name или About; при включённом TextSearch - слова About с учётом словоформ. Фразы в кавычках ищутся как слова подряд"},
+			{"order_field", "query", typeString, "Id, Name, Age, Email, Phone, Company, Address или вычисляемое поле, по умолчанию Name; Score - по релевантности query или оценке matcher"},
+			{"order_by", "query", typeInt, "-1 по возрастанию, 0 как встретилось, 1 по убыванию"},
+			{"order_locale", "query", typeString, "локаль сравнения имён при order_field=Name, например de или sv"},
+			{"limit", "query", typeInt, "0 - DefaultLimit сервера; больше MaxLimit - 400 ErrorLimitTooLarge с MaxLimit в ответе"},
+			{"offset", "query", typeInt, "больше MaxOffset - 400 ErrorOffsetTooLarge с MaxOffset и подсказкой в Hint"},
+			{"matcher", "query", typeString, "стратегия поиска query, зарегистрированная на сервере; неизвестная - 400 ErrorBadMatcher"},
+			{"boost_name", "query", typeNumber, "вес совпадения в Name при order_field=Score, 0 - как настроено на сервере"},
+			{"boost_about", "query", typeNumber, "вес совпадения в About при order_field=Score"},
+			{"group_by", "query", typeString, "Gender, Age, Company, Name, Email, Phone или Address: ответ GroupedResponse, limit - записей в каждой группе; без offset и after_id"},
+			{"after_id", "query", typeInt, "keyset-пагинация: Id последней записи прошлой страницы, выдача начнётся сразу за ней"},
+			{"after_value", "query", typeString, "значение поля сортировки последней записи прошлой страницы"},
+			{"gender", "query", typeString, ""},
+			{"field.{name}", "query", typeString, "значение вычисляемого поля, например field.AgeBucket=30; по ним же работают order_field и group_by"},
+			{"lang", "query", typeString, "язык About, определённый при загрузке: en, de, fr, es, ru, la или und - не определился"},
+			{"age_min", "query", typeInt, ""},
+			{"age_max", "query", typeInt, ""},
+			{"email", "query", typeString, "без учёта регистра"},
+			{"phone", "query", typeString, ""},
+			{"company", "query", typeString, "без учёта регистра"},
+			{"address", "query", typeString, "подстрока адреса"},
+			{"format", "query", typeString, "json, xml или csv, важнее заголовка Accept"},
+			{"stream", "query", typeBool, "отдавать результат как application/x-ndjson"},
+			{"include_deleted", "query", typeBool, "только для admin-токенов"},
+			{"allow_partial", "query", typeBool, "при истечении дедлайна вернуть найденное с заголовком X-Partial-Result"},
+			{"as_of", "query", typeString, "RFC 3339: искать по версии датасета на этот момент, слишком старая - 410 ErrorAsOfUnavailable"},
+			{"snapshot", "query", typeString, "X-Snapshot-Id предыдущей страницы: если датасет изменился, ответ 409 ErrorSnapshotChanged"},
+			{model.VersionHeader, "header", typeInt, "версия json-ответа: 1 - массив, 2 - объект SearchResponseV2"},
+			{"If-None-Match", "header", typeString, "ETag прошлого ответа: датасет не менялся - 304 без поиска"},
+		},
+		Responses: map[int]reflect.Type{200: typeUsers, 304: nil, 400: typeError, 403: typeError, 406: typeError, 409: typeError, 410: typeError, 429: typeError, 502: typeError, 503: typeError, 504: typeError},
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/search/explain",
+		Summary:   "Как выполнился бы поиск с теми же параметрами: индекс, оценка кандидатов, сортировка, кэш",
+		Params:    []apiParam{{"query", "query", typeString, "и остальные параметры поиска, как у GET /"}},
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(ExplainResponse{}), 400: typeError},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/changes",
+		Summary: "Добавленные, изменённые и исчезнувшие пользователи с версии датасета since",
+		Params: []apiParam{
+			{"since", "query", typeString, "X-Snapshot-Id или Snapshot прошлого ответа, пусто - весь датасет; версия старше хранимых - 410 ErrorChangesUnavailable"},
+		},
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(model.ChangesResponse{}), 410: typeError, 501: typeError},
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/users/{id}",
+		Summary:   "Пользователь по Id, в ETag - версия записи",
+		Params:    []apiParam{userIDParam},
+		Responses: map[int]reflect.Type{200: typeUser, 404: typeError},
+	},
+	{
+		Method:    http.MethodPut,
+		Path:      "/users/{id}",
+		Summary:   "Полная замена пользователя, поддерживает If-Match",
+		Params:    []apiParam{userIDParam, {"If-Match", "header", typeString, ""}},
+		Body:      typeUser,
+		Responses: map[int]reflect.Type{200: typeUser, 400: typeError, 404: typeError, 409: typeError, 413: typeError},
+
+		Admin: true,
+	},
+	{
+		Method:    http.MethodPatch,
+		Path:      "/users/{id}",
+		Summary:   "Частичное обновление через JSON merge patch, поддерживает If-Match",
+		Params:    []apiParam{userIDParam, {"If-Match", "header", typeString, ""}},
+		Body:      typeUser,
+		Responses: map[int]reflect.Type{200: typeUser, 400: typeError, 404: typeError, 409: typeError, 413: typeError},
+
+		Admin: true,
+	},
+	{
+		Method:    http.MethodDelete,
+		Path:      "/users/{id}",
+		Summary:   "Мягкое удаление пользователя",
+		Params:    []apiParam{userIDParam, {"If-Match", "header", typeString, ""}},
+		Responses: map[int]reflect.Type{204: nil, 404: typeError, 409: typeError},
+
+		Admin: true,
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/users/bulk",
+		Summary:   "Пачка правок create, update и delete: каждая применяется целиком или никак, ответ - итог каждой",
+		Body:      reflect.TypeOf([]model.BulkOperation{}),
+		Responses: map[int]reflect.Type{200: reflect.TypeOf([]model.BulkResult{}), 400: typeError, 413: typeError},
+
+		Admin: true,
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/graphql",
+		Summary:   "GraphQL-запрос к users(query, filters, orderBy, first, after)",
+		Body:      reflect.TypeOf(graphqlRequest{}),
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(graphqlResponse{}), 400: reflect.TypeOf(graphqlResponse{}), 413: reflect.TypeOf(graphqlResponse{})},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/graphql",
+		Summary: "GraphQL-запрос в строке запроса",
+		Params: []apiParam{
+			{"query", "query", typeString, "запрос GraphQL"},
+			{"variables", "query", typeString, "переменные запроса, объект json"},
+		},
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(graphqlResponse{}), 400: reflect.TypeOf(graphqlResponse{})},
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/rpc",
+		Summary:   "JSON-RPC 2.0: search.findUsers, search.getUser, поддерживаются батчи",
+		Body:      reflect.TypeOf(rpcRequest{}),
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(rpcResponse{}), 204: nil, 413: reflect.TypeOf(rpcResponse{})},
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/saved",
+		Summary:   "Сохранённые поиски токена",
+		Responses: map[int]reflect.Type{200: reflect.TypeOf([]model.SavedSearch{})},
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/saved/{name}",
+		Summary:   "Сохранённый поиск токена",
+		Params:    []apiParam{savedNameParam},
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(model.SavedSearch{}), 404: typeError},
+	},
+	{
+		Method:    http.MethodPut,
+		Path:      "/saved/{name}",
+		Summary:   "Сохранить поиск: тело - объект с параметрами GET /",
+		Params:    []apiParam{savedNameParam},
+		Body:      reflect.TypeOf(map[string]interface{}{}),
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(model.SavedSearch{}), 400: typeError},
+	},
+	{
+		Method:    http.MethodDelete,
+		Path:      "/saved/{name}",
+		Summary:   "Удалить сохранённый поиск",
+		Params:    []apiParam{savedNameParam},
+		Responses: map[int]reflect.Type{204: nil, 404: typeError},
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/saved/{name}/run",
+		Summary:   "Выполнить сохранённый поиск, параметры запроса переопределяют сохранённые; ответ как у GET /",
+		Params:    []apiParam{savedNameParam},
+		Responses: map[int]reflect.Type{200: typeUsers, 400: typeError, 404: typeError},
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/alerts",
+		Summary:   "Алерты токена",
+		Responses: map[int]reflect.Type{200: reflect.TypeOf([]model.Alert{})},
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/alerts/{name}",
+		Summary:   "Алерт токена с состоянием последней проверки",
+		Params:    []apiParam{alertNameParam},
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(model.Alert{}), 404: typeError},
+	},
+	{
+		Method:    http.MethodPut,
+		Path:      "/alerts/{name}",
+		Summary:   "Завести алерт: сохранённый поиск или параметры, период, вебхук и/или email, порог",
+		Params:    []apiParam{alertNameParam},
+		Body:      reflect.TypeOf(model.Alert{}),
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(model.Alert{}), 400: typeError, 403: typeError, 404: typeError},
+	},
+	{
+		Method:    http.MethodDelete,
+		Path:      "/alerts/{name}",
+		Summary:   "Удалить алерт",
+		Params:    []apiParam{alertNameParam},
+		Responses: map[int]reflect.Type{204: nil, 404: typeError},
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/msearch",
+		Summary:   "Несколько поисков за раз: массив объектов с параметрами GET /, ответы в том же порядке",
+		Body:      reflect.TypeOf([]map[string]interface{}{}),
+		Responses: map[int]reflect.Type{200: reflect.TypeOf([]model.MultiSearchResult{}), 400: typeError, 413: typeError},
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/admin/purge",
+		Summary:   "Окончательно удалить мягко удалённых пользователей",
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(PurgeResponse{})},
+		Admin:     true,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/admin/audit",
+		Summary: "Журнал аудита обращений к данным",
+		Params: []apiParam{
+			{"token", "query", typeString, "отпечаток токена"},
+			{"since", "query", typeString, "RFC 3339"},
+			{"until", "query", typeString, "RFC 3339"},
+			{"limit", "query", typeInt, "сколько последних записей вернуть"},
+		},
+		Responses: map[int]reflect.Type{200: reflect.TypeOf([]AuditEntry{}), 400: typeError, 501: typeError},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/admin/stats",
+		Summary:   "Размер датасета, кэш, популярные запросы и перцентили времени ответа",
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(StatsResponse{})},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/admin/usage",
+		Summary:   "Запросы и отданные байты по токенам, суточные квоты",
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(UsageResponse{})},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/admin/requests",
+		Summary:   "Выполняющиеся поиски: токен, запрос, сколько уже идут",
+		Responses: map[int]reflect.Type{200: reflect.TypeOf([]ActiveRequest{})},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodDelete,
+		Path:      "/admin/requests/{id}",
+		Summary:   "Отменить выполняющийся поиск, он ответит как истёкший по дедлайну",
+		Params:    []apiParam{{"id", "path", typeInt, "ID из GET /admin/requests"}},
+		Responses: map[int]reflect.Type{204: nil, 404: typeError},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/admin/maintenance",
+		Summary:   "Включён ли режим обслуживания",
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(MaintenanceStatus{})},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/admin/maintenance",
+		Summary:   "Включить или выключить режим обслуживания: поиск работает, правки и импорт получают 503",
+		Params:    []apiParam{{"enabled", "query", typeBool, ""}},
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(MaintenanceStatus{}), 400: typeError},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodGet,
+		Path:      "/admin/datasets",
+		Summary:   "Текущая, подготовленная и прежняя версии датасета с отличиями от текущей",
+		Responses: map[int]reflect.Type{200: typeVersions},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/admin/datasets/switch",
+		Summary:   "Сделать подготовленную версию текущей, текущая остаётся для отката",
+		Responses: map[int]reflect.Type{200: typeVersions, 409: typeError},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/admin/datasets/rollback",
+		Summary:   "Вернуть прежнюю версию датасета",
+		Responses: map[int]reflect.Type{200: typeVersions, 409: typeError},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodDelete,
+		Path:      "/admin/datasets/staged",
+		Summary:   "Выбросить подготовленную версию",
+		Responses: map[int]reflect.Type{200: typeVersions},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodDelete,
+		Path:      "/admin/datasets/previous",
+		Summary:   "Выбросить прежнюю версию, откат станет невозможен",
+		Responses: map[int]reflect.Type{200: typeVersions},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/admin/tokens/reload",
+		Summary:   "Перечитать файл токенов без перезапуска, то же делает SIGHUP",
+		Responses: map[int]reflect.Type{204: nil, 500: typeError, 501: typeError},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/admin/synonyms/reload",
+		Summary:   "Перечитать файл синонимов без перезапуска, кэш страниц сбрасывается",
+		Responses: map[int]reflect.Type{204: nil, 500: typeError, 501: typeError},
+		Admin:     true,
+	},
+	{
+		Method:  http.MethodPost,
+		Path:    "/admin/import",
+		Summary: "Заменить датасет загруженным файлом (xml или json), с dry_run - только проверить",
+		Params: []apiParam{
+			{"dry_run", "query", typeBool, "вернуть отчёт о проверке, не меняя датасет"},
+			{"stage", "query", typeBool, "положить рядом с текущим, включить - POST /admin/datasets/switch"},
+			{"format", "query", typeString, "xml или json, по умолчанию по Content-Type"},
+		},
+		Responses: map[int]reflect.Type{200: reflect.TypeOf(ImportReport{}), 413: typeError, 422: reflect.TypeOf(ImportReport{}), 501: typeError},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/admin/replication/snapshot",
+		Summary:   "Репликация: заменить датасет снимком основного сервера",
+		Body:      reflect.TypeOf(replicationSnapshot{}),
+		Responses: map[int]reflect.Type{204: nil, 400: nil, 500: nil},
+		Admin:     true,
+	},
+	{
+		Method:    http.MethodPost,
+		Path:      "/admin/replication/changes",
+		Summary:   "Репликация: применить правки, продолжающие уже применённые, иначе 409",
+		Body:      reflect.TypeOf(replicationBatch{}),
+		Responses: map[int]reflect.Type{204: nil, 400: nil, 409: nil, 500: nil},
+		Admin:     true,
+	},
+}
+
+// openAPISpec строит документ OpenAPI 3 по apiOperations
+func openAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+
+	for _, op := range apiOperations {
+		operation := map[string]interface{}{
+			"summary":  op.Summary,
+			"security": []map[string][]string{{"AccessToken": {}}},
+		}
+		if op.Admin {
+			operation["description"] = "Требует токен с правами администратора"
+		}
+
+		var params []map[string]interface{}
+		for _, p := range op.Params {
+			param := map[string]interface{}{
+				"name":     p.Name,
+				"in":       p.In,
+				"required": p.In == "path",
+				"schema":   jsonSchema(p.Type, schemas),
+			}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			params = append(params, param)
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		if op.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": jsonSchema(op.Body, schemas)},
+				},
+			}
+		}
+
+		responses := map[string]interface{}{
+			"401": map[string]interface{}{"description": "Bad access token"},
+		}
+		for status, t := range op.Responses {
+			resp := map[string]interface{}{"description": http.StatusText(status)}
+			if t != nil {
+				resp["content"] = map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": jsonSchema(t, schemas)},
+				}
+			}
+			responses[strconv.Itoa(status)] = resp
+		}
+		operation["responses"] = responses
+
+		item, _ := paths[op.Path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "SearchServer",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"AccessToken": map[string]interface{}{"type": "apiKey", "in": "header", "name": "AccessToken"},
+			},
+		},
+	}
+}
+
+// jsonSchema описывает тип так, как его кодирует encoding/json. Структуры попадают в components/schemas
+func jsonSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return jsonSchema(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := t.Name()
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		if _, ok := schemas[name]; ok {
+			return ref
+		}
+		// заглушка на случай рекурсивных типов
+		schemas[name] = nil
+
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name, opts := f.Name, ""
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				if tag == "-" {
+					continue
+				}
+				parts := strings.SplitN(tag, ",", 2)
+				if parts[0] != "" {
+					name = parts[0]
+				}
+				if len(parts) > 1 {
+					opts = parts[1]
+				}
+			}
+			properties[name] = jsonSchema(f.Type, schemas)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[t.Name()] = schema
+		return ref
+	}
+	// interface{} и прочее - произвольное значение
+	return map[string]interface{}{}
+}
+
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPISpec())
+}
+
+// docs отдаёт /docs и его файлы /docs/*
+func (s *Server) docs(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/docs" {
+		http.ServeFileFS(w, r, docsFS, "index.html")
+		return
+	}
+	http.StripPrefix("/docs/", http.FileServer(http.FS(docsFS))).ServeHTTP(w, r)
+}
@@ -0,0 +1,169 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	h := newTestHandler()
+
+	r := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var spec struct {
+		OpenAPI    string
+		Paths      map[string]map[string]interface{}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{}
+			}
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if spec.OpenAPI != "3.0.3" || spec.Paths["/users/{id}"]["patch"] == nil {
+		t.Errorf("Error : unexpected spec %v", w.Body.String())
+	}
+
+	// omitempty-поля заполнены, чтобы попасть в json
+	data, _ := json.Marshal(model.User{Deleted: true, Email: "e", Phone: "p", Company: "c", Address: "a", Version: 1})
+	var user map[string]interface{}
+	json.Unmarshal(data, &user)
+	props := spec.Components.Schemas["User"].Properties
+	if len(props) != len(user) {
+		t.Errorf("Error : User schema %v does not match %v", props, user)
+	}
+	for name := range user {
+		if props[name] == nil {
+			t.Errorf("Error : User schema misses %v", name)
+		}
+	}
+}
+
+// каждая операция из спецификации должна реально обслуживаться сервером
+func TestOpenAPIOperationsRouted(t *testing.T) {
+	for _, op := range apiOperations {
+		h := newTestHandler()
+		path := strings.Replace(op.Path, "{id}", "0", 1)
+
+		w := doRequest(h, op.Method, path, "", map[string]string{"AccessToken": adminToken})
+
+		// 404 с кодом ошибки в теле отдаёт сам обработчик, например на уже закончившийся поиск
+		unrouted := w.Code == http.StatusNotFound && !strings.Contains(w.Body.String(), "Error")
+		if unrouted || w.Code == http.StatusMethodNotAllowed {
+			t.Errorf("Error : %v %v is not routed, %v", op.Method, op.Path, w.Code)
+		}
+	}
+}
+
+// routedPaths - пути и префиксы путей (с "/" на конце), которые разбирает Server.route
+func routedPaths(t *testing.T) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), "server.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	var paths []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "route" {
+			continue
+		}
+		ast.Inspect(fn, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				if p, _ := strconv.Unquote(lit.Value); strings.HasPrefix(p, "/") {
+					paths = append(paths, p)
+				}
+			}
+			return true
+		})
+	}
+	if len(paths) == 0 {
+		t.Fatalf("Error : no paths in Server.route")
+	}
+	return paths
+}
+
+// и наоборот: всё, что разбирает route, описано в спецификации, а методы, которые обработчик
+// принимает, - тоже
+func TestOpenAPICoversRoutes(t *testing.T) {
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		documented[op.Method+" "+op.Path] = true
+	}
+	var specPaths []string
+	for _, op := range apiOperations {
+		specPaths = append(specPaths, op.Path)
+	}
+
+	for _, p := range routedPaths(t) {
+		if p == "/admin/" {
+			continue
+		}
+		found := false
+		for _, sp := range specPaths {
+			if sp == p || strings.HasSuffix(p, "/") && strings.HasPrefix(sp, p) && len(sp) > len(p) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Error : %v is routed but missing in apiOperations", p)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, sp := range specPaths {
+		if seen[sp] || sp == "/" {
+			continue
+		}
+		seen[sp] = true
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			path := strings.NewReplacer("{id}", "1", "{name}", "x").Replace(sp)
+			w := doRequest(newTestHandler(), method, path, "", map[string]string{"AccessToken": adminToken})
+			if w.Code != http.StatusMethodNotAllowed && w.Code != http.StatusNotFound && !documented[method+" "+sp] {
+				t.Errorf("Error : %v %v answers %v but missing in apiOperations", method, sp, w.Code)
+			}
+		}
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	h := newTestHandler()
+
+	r := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `src="/docs/docs.js"`) {
+		t.Errorf("Error : %v", w.Code)
+	}
+	// всё своё, без CDN: страница работает без сети
+	if strings.Contains(w.Body.String(), "https://") {
+		t.Errorf("Error : docs page loads external assets")
+	}
+
+	for _, file := range []string{"/docs/docs.js", "/docs/docs.css"} {
+		r = httptest.NewRequest(http.MethodGet, file, nil)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("Error : %v %v", file, w.Code)
+		}
+	}
+	r = httptest.NewRequest(http.MethodGet, "/docs/docs.js", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), "/openapi.json") {
+		t.Errorf("Error : docs.js does not load the spec")
+	}
+}
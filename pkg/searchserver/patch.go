@@ -0,0 +1,61 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"final_task_golang/pkg/model"
+)
+
+// mergePatch применяет JSON merge patch (RFC 7386) к документу
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+func mergePatchUser(u model.User, patch interface{}) (model.User, error) {
+	if _, ok := patch.(map[string]interface{}); !ok {
+		return u, fmt.Errorf("patch must be a json object")
+	}
+
+	data, _ := json.Marshal(u)
+	var doc interface{}
+	json.Unmarshal(data, &doc)
+
+	data, _ = json.Marshal(mergePatch(doc, patch))
+	result := model.User{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return u, err
+	}
+	return result, nil
+}
+
+func validateUser(u model.User) error {
+	if strings.TrimSpace(u.Name) == "" {
+		return fmt.Errorf("name is empty")
+	}
+	if u.Age < 0 {
+		return fmt.Errorf("age must be >= 0")
+	}
+	switch u.Gender {
+	case "male", "female":
+	default:
+		return fmt.Errorf("unknown gender %q", u.Gender)
+	}
+	return nil
+}
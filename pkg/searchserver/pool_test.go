@@ -0,0 +1,68 @@
+package searchserver
+
+import (
+	"context"
+	"testing"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+func bigDataset(copies int) []model.User {
+	users, _ := LoadDataset("../../dataset.xml")
+	var big []model.User
+	for i := 0; i < copies; i++ {
+		for _, u := range users {
+			u.Id = len(big)
+			big = append(big, u)
+		}
+	}
+	return big
+}
+
+// параллельная фильтрация должна давать ровно ту же выдачу, что и последовательная
+func TestParallelSearchMatchesSerial(t *testing.T) {
+	users := bigDataset(300)
+	serial := NewServer(users, testServerConfig)
+	parallel := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, SearchParallelism: 4})
+	defer parallel.Close()
+
+	queries := []searchcore.Query{
+		{},
+		{Query: "nisi"},
+		{Query: "Boyd", OrderField: "Age", OrderBy: model.OrderByDesc},
+		{Query: "nisi", Limit: 25, Offset: 3000},
+		{Gender: "female", OrderField: "Id", OrderBy: model.OrderByAsc, Limit: 10, Offset: 5000},
+		{Query: "no such user"},
+	}
+	for _, q := range queries {
+		expected, _, err := serial.find(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		got, _, err := parallel.find(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("Error : %+v: %d != %d", q, len(got), len(expected))
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Errorf("Error : %+v: %v != %v", q, got[i], expected[i])
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkParallelSearch(b *testing.B) {
+	s := NewServer(bigDataset(300), ServerConfig{Tokens: testServerConfig.Tokens, SearchParallelism: 4})
+	defer s.Close()
+	q := searchcore.Query{Query: "no such user"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.find(context.Background(), q)
+	}
+}
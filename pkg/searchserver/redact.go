@@ -0,0 +1,124 @@
+package searchserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// RedactConfig - режим обезличивания для не-production окружений: ответы для выбранных
+// прав доступа отдаются с псевдонимами вместо имён и без контактов. Иначе подбором фильтров
+// можно было бы проверить настоящие данные, поэтому query ищется по уже обезличенным записям,
+// а фильтры, сортировка и группировка по обезличиваемым полям отклоняются с ErrRedactedField
+type RedactConfig struct {
+	// права доступа, для которых ответы обезличиваются
+	Scopes []Scope
+	// соль псевдонимов: при одной соли одно и то же имя всегда даёт один псевдоним,
+	// так что данные остаются связными между запросами
+	Salt string
+}
+
+var (
+	redactEmailRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	redactPhoneRe = regexp.MustCompile(`\+?\d[\d ().-]{6,}\d`)
+)
+
+// обезличиваемые поля выдачи: по ним нельзя сортировать и группировать
+var redactedFields = map[string]bool{"Name": true, "Email": true, "Phone": true, "Address": true}
+
+// checkRedacted отклоняет обезличенный запрос, который смотрит на настоящие значения
+// обезличиваемых полей. Company в выдаче не скрывается, фильтр по ней ничего не раскрывает
+func checkRedacted(q searchcore.Query) error {
+	if !q.Redact {
+		return nil
+	}
+	if q.Email != "" || q.Phone != "" || q.Address != "" || redactedFields[q.GroupBy] {
+		return model.ErrRedactedField
+	}
+	if key, ok := q.SortKey(); ok && redactedFields[key.Field] {
+		return model.ErrRedactedField
+	}
+	return nil
+}
+
+// redactedText ищет query по обезличенным записям: движок ищет всю выдачу по настоящим
+// данным, fn получает только те, что подходят и после обезличивания. Offset и limit
+// поэтому применяются здесь, а не в движке
+func (s *Server) redactedText(q searchcore.Query, fn func(model.User) bool) (searchcore.Query, func(model.User) bool) {
+	text := searchcore.Query{Query: q.Query, Matcher: q.Matcher}
+	skip, left := q.Offset, q.Limit
+	q.Offset, q.Limit = 0, 0
+	return q, func(u model.User) bool {
+		redacted := s.redactUser(u)
+		if !text.Match(redacted) {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		if !fn(u) {
+			return false
+		}
+		left--
+		return left != 0
+	}
+}
+
+// redacts сообщает, нужно ли обезличивать ответы для scope
+func (s *Server) redacts(scope Scope) bool {
+	for _, sc := range s.cfg.Redact.Scopes {
+		if sc == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// redactUser заменяет персональные поля: имя и email - псевдонимами, телефон маскируется,
+// адрес скрывается, из About вырезаются email и телефоны
+func (s *Server) redactUser(u model.User) model.User {
+	u.Name = "User " + s.pseudonym(u.Name)
+	if u.Email != "" {
+		u.Email = s.pseudonym(u.Email) + "@example.invalid"
+	}
+	u.Phone = maskPhone(u.Phone)
+	if u.Address != "" {
+		u.Address = "[address]"
+	}
+	u.About = redactEmailRe.ReplaceAllString(u.About, "[email]")
+	u.About = redactPhoneRe.ReplaceAllString(u.About, "[phone]")
+	return u
+}
+
+func (s *Server) pseudonym(value string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.Redact.Salt))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// maskPhone оставляет только две последние цифры номера
+func maskPhone(phone string) string {
+	digits := 0
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits--
+			if digits >= 2 {
+				r = '*'
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
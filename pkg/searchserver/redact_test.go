@@ -0,0 +1,155 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func newRedactedHandler() *Server {
+	users, _ := LoadDataset("../../dataset.xml")
+	return NewServer(users, ServerConfig{
+		Tokens:    testServerConfig.Tokens,
+		CacheSize: 10,
+		Redact:    RedactConfig{Scopes: []Scope{ScopeSearch}, Salt: "test"},
+	})
+}
+
+func TestRedactUser(t *testing.T) {
+	s := NewServer(nil, ServerConfig{Redact: RedactConfig{Salt: "a"}})
+	u := model.User{
+		Id:      1,
+		Name:    "Boyd Wolf",
+		About:   "Write to boyd.wolf@hopeli.com or call +1 (956) 593-2402 after 10",
+		Email:   "boydwolf@hopeli.com",
+		Phone:   "+1 (956) 593-2402",
+		Address: "586 Winthrop Street",
+		Company: "HOPELI",
+	}
+
+	r := s.redactUser(u)
+	if !strings.HasPrefix(r.Name, "User ") || strings.Contains(r.Name, "Boyd") ||
+		!strings.HasSuffix(r.Email, "@example.invalid") || r.Phone != "+* (***) ***-**02" ||
+		r.Address != "[address]" || r.Company != "HOPELI" || r.Id != 1 {
+		t.Errorf("Error : unexpected %+v", r)
+	}
+	if r.About != "Write to [email] or call [phone] after 10" {
+		t.Errorf("Error : unexpected about %q", r.About)
+	}
+
+	// псевдоним стабилен при одной соли и меняется вместе с ней
+	if s.redactUser(u).Name != r.Name {
+		t.Errorf("Error : pseudonym is not stable")
+	}
+	other := NewServer(nil, ServerConfig{Redact: RedactConfig{Salt: "b"}})
+	if other.redactUser(u).Name == r.Name {
+		t.Errorf("Error : salt is ignored")
+	}
+}
+
+func TestSearchRedactedScope(t *testing.T) {
+	h := newRedactedHandler()
+
+	// админ видит настоящие данные, и они не должны попасть из кэша к обычному токену
+	w := doRequest(h, http.MethodGet, "/?query=Boyd", "", map[string]string{"AccessToken": adminToken})
+	users := []model.User{}
+	json.Unmarshal(w.Body.Bytes(), &users)
+	if len(users) != 1 || users[0].Name != "Boyd Wolf" {
+		t.Fatalf("Error : unexpected %s", w.Body.String())
+	}
+
+	// query ищется по обезличенным записям: настоящее имя не находится
+	w = doRequest(h, http.MethodGet, "/?query=Boyd", "", map[string]string{"AccessToken": accessToken})
+	users = []model.User{}
+	json.Unmarshal(w.Body.Bytes(), &users)
+	if w.Code != http.StatusOK || len(users) != 0 {
+		t.Errorf("Error : unexpected %s", w.Body.String())
+	}
+
+	w = doRequest(h, http.MethodGet, "/?query=nisi&order_field=Id&order_by=-1", "", map[string]string{"AccessToken": accessToken})
+	users = []model.User{}
+	json.Unmarshal(w.Body.Bytes(), &users)
+	if len(users) == 0 || users[0].Id != 0 || users[0].Name == "Boyd Wolf" || users[0].Email == "boydwolf@hopeli.com" {
+		t.Errorf("Error : unexpected %s", w.Body.String())
+	}
+
+	w = doRequest(h, http.MethodGet, "/?query=nisi&stream=true", "", map[string]string{"AccessToken": accessToken})
+	if w.Body.Len() == 0 || strings.Contains(w.Body.String(), "Boyd Wolf") {
+		t.Errorf("Error : stream is not redacted %s", w.Body.String())
+	}
+}
+
+func TestRedactedFiltersRejected(t *testing.T) {
+	h := newRedactedHandler()
+	admin := map[string]string{"AccessToken": adminToken}
+
+	// настоящий email находит запись у админа, но обезличенный токен не может им проверить человека
+	if users := decodeUsers(t, doRequest(h, http.MethodGet, "/?email=boydwolf@hopeli.com", "", admin)); len(users) != 1 {
+		t.Fatalf("Error : unexpected admin result %+v", users)
+	}
+	for _, target := range []string{
+		"/?email=boydwolf@hopeli.com",
+		"/?phone=%2B1%20(956)%20593-2402",
+		"/?address=Winthrop",
+		"/?order_field=Name&order_by=1",
+		"/?order_field=Email&order_by=-1",
+		"/?order_field=Phone&order_by=1",
+		"/?group_by=Email",
+	} {
+		w := doRequest(h, http.MethodGet, target, "", nil)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), model.ErrRedactedField.Error()) {
+			t.Errorf("Error : %s: unexpected response %d %s", target, w.Code, w.Body)
+		}
+	}
+
+	// то, что в выдаче и так видно, остаётся доступным
+	w := doRequest(h, http.MethodGet, "/?company=hopeli&order_field=Age&order_by=1", "", nil)
+	if users := decodeUsers(t, w); len(users) != 1 || users[0].Company != "HOPELI" {
+		t.Errorf("Error : unexpected %s", w.Body)
+	}
+}
+
+func TestRedactedQueryPaging(t *testing.T) {
+	h := newRedactedHandler()
+
+	all := decodeUsers(t, doRequest(h, http.MethodGet, "/?query=nisi&order_field=Id&order_by=1", "", nil))
+	page := decodeUsers(t, doRequest(h, http.MethodGet, "/?query=nisi&order_field=Id&order_by=1&limit=2&offset=1", "", nil))
+	if len(all) < 3 || len(page) != 2 || page[0].Id != all[1].Id || page[1].Id != all[2].Id {
+		t.Errorf("Error : unexpected page %v of %v", page, all)
+	}
+}
+
+func TestGetUserRedacted(t *testing.T) {
+	h := newRedactedHandler()
+
+	admin := doRequest(h, http.MethodGet, "/users/0", "", map[string]string{"AccessToken": adminToken})
+	search := doRequest(h, http.MethodGet, "/users/0", "", map[string]string{"AccessToken": accessToken})
+	u := decodeUser(t, search)
+	if u.Name == "Boyd Wolf" || u.Phone == "+1 (956) 593-2402" {
+		t.Errorf("Error : unexpected %+v", u)
+	}
+	// ETag по настоящей записи, чтобы If-Match работал одинаково для всех токенов
+	if search.Header().Get("ETag") != admin.Header().Get("ETag") {
+		t.Errorf("Error : %s != %s", search.Header().Get("ETag"), admin.Header().Get("ETag"))
+	}
+}
+
+func TestRedactedRPCAndGraphQL(t *testing.T) {
+	h := newRedactedHandler()
+
+	w := doRequest(h, http.MethodPost, "/rpc", `[
+		{"jsonrpc": "2.0", "method": "search.findUsers", "params": {"query": "nisi"}, "id": 1},
+		{"jsonrpc": "2.0", "method": "search.getUser", "params": {"id": 0}, "id": 2}
+	]`, nil)
+	if strings.Contains(w.Body.String(), "Boyd Wolf") || strings.Contains(w.Body.String(), "hopeli.com") {
+		t.Errorf("Error : rpc is not redacted %s", w.Body.String())
+	}
+
+	_, body := doGraphQL(t, h, `{ users(query: "nisi") { nodes { name email } } }`, nil)
+	if strings.Contains(body, "Boyd Wolf") || !strings.Contains(body, "example.invalid") {
+		t.Errorf("Error : graphql is not redacted %s", body)
+	}
+}
@@ -0,0 +1,383 @@
+package searchserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+const (
+	defaultReplicationRetry = time.Second
+	replicationTimeout      = 30 * time.Second
+)
+
+// ReplicationConfig - репликация датасета без общего хранилища: основной сервер отправляет
+// репликам снимок датасета, а затем каждую правку. Реплика, пропустившая правку
+// (например, после перезапуска), отвечает 409 и получает снимок заново
+type ReplicationConfig struct {
+	// адреса реплик (корень сервера, например http://replica:8080), пусто - репликация выключена
+	Replicas []string
+	// admin-токен на репликах
+	AccessToken string
+	// пауза перед повтором после ошибки, 0 - defaultReplicationRetry
+	RetryInterval time.Duration
+}
+
+// replicationChange - одна правка датасета, в том же виде уходит репликам и в журнал правок.
+// "put" заменяет запись с тем же Id или добавляет новую, "purge" убирает мягко удалённые записи
+type replicationChange struct {
+	Op   string     `json:"op"`
+	User model.User `json:"user"`
+}
+
+// replicationBatch - правки с номерами Seq, Seq+1, ...
+type replicationBatch struct {
+	Seq     uint64              `json:"seq"`
+	Changes []replicationChange `json:"changes"`
+}
+
+// replicationSnapshot - датасет после правки номер Seq
+type replicationSnapshot struct {
+	Seq   uint64       `json:"seq"`
+	Users []model.User `json:"users"`
+}
+
+// ReplicaStatus - состояние одной реплики для /admin/stats
+type ReplicaStatus struct {
+	URL string
+	// номер последней правки, которую реплика подтвердила
+	AckedSeq uint64
+	// сколько правок ещё не доставлено
+	Pending   int
+	LastError string `json:",omitempty"`
+}
+
+// replica - очередь доставки на одну реплику, работает в своей горутине
+type replica struct {
+	url    string
+	token  string
+	retry  time.Duration
+	client *http.Client
+	// снимок датасета и номер последней правки в нём, берётся под Server.mu
+	source func() ([]model.User, uint64)
+
+	mu           sync.Mutex
+	pending      []replicationChange
+	pendingSeq   uint64
+	needSnapshot bool
+	// растёт при каждом resync, чтобы не потерять подмену датасета во время отправки снимка
+	resyncs   uint64
+	acked     uint64
+	lastError string
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newReplica(url string, cfg ReplicationConfig, source func() ([]model.User, uint64)) *replica {
+	r := &replica{
+		url:          strings.TrimRight(url, "/"),
+		token:        cfg.AccessToken,
+		retry:        durationOr(cfg.RetryInterval, defaultReplicationRetry),
+		client:       &http.Client{Timeout: replicationTimeout},
+		source:       source,
+		needSnapshot: true,
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.run()
+	r.notify()
+	return r
+}
+
+// enqueue ставит правку номер seq в очередь, вызывается под Server.mu, поэтому не блокируется на сети
+func (r *replica) enqueue(seq uint64, change replicationChange) {
+	r.mu.Lock()
+	if len(r.pending) == 0 {
+		r.pendingSeq = seq
+	}
+	r.pending = append(r.pending, change)
+	r.mu.Unlock()
+	r.notify()
+}
+
+// resync - датасет подменён целиком, очередь правок больше не нужна
+func (r *replica) resync() {
+	r.mu.Lock()
+	r.pending, r.needSnapshot = nil, true
+	r.resyncs++
+	r.mu.Unlock()
+	r.notify()
+}
+
+func (r *replica) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *replica) close() {
+	close(r.done)
+	r.wg.Wait()
+}
+
+func (r *replica) run() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-r.wake:
+		}
+		for r.deliver() {
+		}
+	}
+}
+
+// deliver отправляет реплике снимок или накопленные правки. true - есть что отправлять дальше
+func (r *replica) deliver() bool {
+	r.mu.Lock()
+	needSnapshot := r.needSnapshot
+	batch := replicationBatch{Seq: r.pendingSeq, Changes: r.pending}
+	r.mu.Unlock()
+
+	var err error
+	switch {
+	case needSnapshot:
+		err = r.sendSnapshot()
+	case len(batch.Changes) > 0:
+		err = r.sendBatch(batch)
+	default:
+		return false
+	}
+
+	r.mu.Lock()
+	if err != nil {
+		r.lastError = model.Redact(err.Error())
+	} else {
+		r.lastError = ""
+	}
+	r.mu.Unlock()
+	if err != nil {
+		select {
+		case <-r.done:
+			return false
+		case <-time.After(r.retry):
+		}
+	}
+	return true
+}
+
+func (r *replica) sendSnapshot() error {
+	r.mu.Lock()
+	resyncs := r.resyncs
+	r.mu.Unlock()
+	users, seq := r.source()
+	if err := r.post("/admin/replication/snapshot", replicationSnapshot{Seq: seq, Users: users}); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.acked = seq
+	if r.resyncs != resyncs {
+		return nil
+	}
+	r.needSnapshot = false
+	// правки, вошедшие в снимок, повторно не шлём
+	if n := len(r.pending); n > 0 {
+		skip := int(seq + 1 - r.pendingSeq)
+		if skip >= n {
+			r.pending = nil
+		} else if skip > 0 {
+			r.pending, r.pendingSeq = r.pending[skip:], seq+1
+		}
+	}
+	return nil
+}
+
+func (r *replica) sendBatch(batch replicationBatch) error {
+	err := r.post("/admin/replication/changes", batch)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == errReplicaGap {
+		r.needSnapshot = true
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	r.acked = batch.Seq + uint64(len(batch.Changes)) - 1
+	// пока пакет был в пути, датасет могли подменить целиком - тогда очередь уже сброшена
+	if r.needSnapshot {
+		return nil
+	}
+	// или в очередь добавились новые правки
+	r.pending = r.pending[len(batch.Changes):]
+	r.pendingSeq = r.acked + 1
+	return nil
+}
+
+var errReplicaGap = fmt.Errorf("replica missed changes, resending snapshot")
+
+func (r *replica) post(path string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, r.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("AccessToken", r.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return errReplicaGap
+	}
+	return fmt.Errorf("replica status %d", resp.StatusCode)
+}
+
+func (r *replica) status() ReplicaStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ReplicaStatus{URL: model.Redact(r.url), AckedSeq: r.acked, Pending: len(r.pending), LastError: r.lastError}
+}
+
+// recordChange пишет правку в журнал, нумерует и ставит в очереди реплик. Вызывается под s.mu
+// до того, как правка применена: не записали в журнал - правку не применяем
+func (s *Server) recordChange(change replicationChange) error {
+	if s.cfg.WAL != nil {
+		if err := s.cfg.WAL.append(change); err != nil {
+			return err
+		}
+	}
+	s.replSeq++
+	for _, r := range s.replicas {
+		r.enqueue(s.replSeq, change)
+	}
+	return nil
+}
+
+// replicationSource - снимок для реплик, согласованный с номером последней правки
+func (s *Server) replicationSource() ([]model.User, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.users, s.replSeq
+}
+
+// ReplicationStatus возвращает состояние реплик, nil - репликация выключена
+func (s *Server) ReplicationStatus() []ReplicaStatus {
+	var statuses []ReplicaStatus
+	for _, r := range s.replicas {
+		statuses = append(statuses, r.status())
+	}
+	return statuses
+}
+
+// replicationSnapshotHandler принимает снимок датасета от основного сервера
+func (s *Server) replicationSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var snap replicationSnapshot
+	if err := json.NewDecoder(r.Body).Decode(&snap); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg.WAL != nil {
+		if err := s.cfg.WAL.compact(snap.Users); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	s.loadedAt = time.Now()
+	s.replSeq = snap.Seq
+	s.setUsers(snap.Users)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// replicationChangesHandler применяет правки, если они продолжают уже применённые, иначе 409
+func (s *Server) replicationChangesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var batch replicationBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if batch.Seq != s.replSeq+1 {
+		http.Error(w, fmt.Sprintf("expected seq %d", s.replSeq+1), http.StatusConflict)
+		return
+	}
+	if s.cfg.WAL != nil {
+		if err := s.cfg.WAL.append(batch.Changes...); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	s.replSeq += uint64(len(batch.Changes))
+	s.setUsers(applyChanges(s.users, batch.Changes))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyChanges возвращает копию users с применёнными правками, users не меняется
+func applyChanges(users []model.User, changes []replicationChange) []model.User {
+	if len(changes) == 0 {
+		return users
+	}
+	applied := make([]model.User, len(users))
+	copy(applied, users)
+	for _, change := range changes {
+		switch change.Op {
+		case "put":
+			replaced := false
+			for i := range applied {
+				if applied[i].Id == change.User.Id {
+					applied[i], replaced = change.User, true
+					break
+				}
+			}
+			if !replaced {
+				applied = append(applied, change.User)
+			}
+		case "purge":
+			live := applied[:0]
+			for _, u := range applied {
+				if !u.Deleted {
+					live = append(live, u)
+				}
+			}
+			applied = live
+		}
+	}
+	return applied
+}
@@ -0,0 +1,78 @@
+package searchserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// waitReplicated ждёт, пока реплика не увидит те же записи, что и основной сервер
+func waitReplicated(t *testing.T, primary, replica *Server) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if equalUsers(primary.snapshot(), replica.snapshot()) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Error : replica did not converge, %v", primary.ReplicationStatus())
+}
+
+func TestReplication(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	replica := NewServer(nil, testServerConfig)
+	ts := httptest.NewServer(replica)
+	defer ts.Close()
+
+	cfg := testServerConfig
+	cfg.Replication = ReplicationConfig{Replicas: []string{ts.URL}, AccessToken: adminToken, RetryInterval: 10 * time.Millisecond}
+	primary := NewServer(users, cfg)
+	defer primary.Close()
+	waitReplicated(t, primary, replica)
+
+	admin := map[string]string{"AccessToken": adminToken}
+	if w := doRequest(primary, "PATCH", "/users/3", `{"Age": 99}`, admin); w.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+	if w := doRequest(primary, "DELETE", "/users/5", "", admin); w.Code != http.StatusNoContent {
+		t.Fatalf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+	waitReplicated(t, primary, replica)
+	if u := replica.snapshot()[3]; u.Age != 99 {
+		t.Errorf("Error : patch not replicated %v", u)
+	}
+
+	if w := doRequest(primary, "POST", "/admin/purge", "", admin); w.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+	waitReplicated(t, primary, replica)
+	if len(replica.snapshot()) != len(users)-1 {
+		t.Errorf("Error : purge not replicated, %d users", len(replica.snapshot()))
+	}
+
+	primary.Reload(users[:10])
+	waitReplicated(t, primary, replica)
+}
+
+func TestReplicationGap(t *testing.T) {
+	s := NewServer([]model.User{{Id: 1, Name: "a"}}, testServerConfig)
+	admin := map[string]string{"AccessToken": adminToken}
+
+	w := doRequest(s, "POST", "/admin/replication/changes", `{"seq": 2, "changes": [{"op": "put", "user": {"Id": 2}}]}`, admin)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+	w = doRequest(s, "POST", "/admin/replication/changes", `{"seq": 1, "changes": [{"op": "put", "user": {"Id": 2, "Name": "b"}}]}`, admin)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+	if users := s.snapshot(); len(users) != 2 || users[1].Name != "b" {
+		t.Errorf("Error : change not applied %v", users)
+	}
+	if w := doRequest(s, "POST", "/admin/replication/changes", `{"seq": 2}`, nil); w.Code != http.StatusForbidden {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+}
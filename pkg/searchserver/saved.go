@@ -0,0 +1,220 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// сколько сохранённых поисков может быть у одного токена
+const maxSavedSearches = 100
+
+// savedSearches - сохранённые поиски по отпечатку токена (TokenFingerprint), чтобы
+// в файле не лежали сами токены. Без файла живут только в памяти
+type savedSearches struct {
+	mu      sync.Mutex
+	path    string
+	byToken map[string]map[string]model.SavedSearch
+}
+
+// LoadSavedSearches читает сохранённые поиски из файла ServerConfig.SavedSearchesFile,
+// нет файла - поисков пока нет
+func LoadSavedSearches(path string) (map[string]map[string]model.SavedSearch, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]map[string]model.SavedSearch{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	saved := map[string]map[string]model.SavedSearch{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func newSavedSearches(path string, saved map[string]map[string]model.SavedSearch) *savedSearches {
+	if saved == nil {
+		saved = map[string]map[string]model.SavedSearch{}
+	}
+	return &savedSearches{path: path, byToken: saved}
+}
+
+func (s *savedSearches) list(token string) []model.SavedSearch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []model.SavedSearch{}
+	for _, saved := range s.byToken[token] {
+		list = append(list, saved)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (s *savedSearches) get(token, name string) (model.SavedSearch, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved, ok := s.byToken[token][name]
+	return saved, ok
+}
+
+// put сохраняет поиск, false - у токена уже maxSavedSearches других
+func (s *savedSearches) put(token string, saved model.SavedSearch) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	searches := s.byToken[token]
+	if searches == nil {
+		searches = map[string]model.SavedSearch{}
+		s.byToken[token] = searches
+	}
+	if _, ok := searches[saved.Name]; !ok && len(searches) >= maxSavedSearches {
+		return false
+	}
+	searches[saved.Name] = saved
+	s.persistLocked()
+	return true
+}
+
+func (s *savedSearches) remove(token, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byToken[token][name]; !ok {
+		return false
+	}
+	delete(s.byToken[token], name)
+	s.persistLocked()
+	return true
+}
+
+// persistLocked переписывает файл целиком через временный, ошибка только в журнал:
+// поиск уже сохранён в памяти
+func (s *savedSearches) persistLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.Marshal(s.byToken)
+	if err == nil {
+		err = writeFileAtomic(s.path, data)
+	}
+	if err != nil {
+		log.Printf("saved searches: %s", err)
+	}
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// savedHandler - сохранённые поиски токена запроса:
+// GET /saved - список, GET, PUT (тело - объект параметров GET /) и DELETE /saved/{name},
+// GET /saved/{name}/run - выполнить, параметры запроса переопределяют сохранённые
+func (s *Server) savedHandler(w http.ResponseWriter, r *http.Request) {
+	token := TokenFingerprint(r.Header.Get("AccessToken"))
+	if r.URL.Path == "/saved" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.saved.list(token))
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/saved/")
+	if strings.HasSuffix(name, "/run") {
+		s.runSaved(w, r, token, strings.TrimSuffix(name, "/run"))
+		return
+	}
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		saved, ok := s.saved.get(token, name)
+		if !ok {
+			writeError(w, http.StatusNotFound, model.ErrorSavedSearchNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+	case http.MethodPut:
+		var params map[string]interface{}
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&params); err != nil {
+			writeError(w, http.StatusBadRequest, model.ErrorBadSavedSearch)
+			return
+		}
+		saved := model.SavedSearch{Name: name, Params: map[string]string{}, Updated: time.Now().UTC()}
+		values := url.Values{}
+		for key, value := range params {
+			saved.Params[key] = fmtParam(value)
+			values.Set(key, saved.Params[key])
+		}
+		// параметры проверяем сразу, а не при первом запуске
+		if err := parseSearchQuery(values).Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !s.saved.put(token, saved) {
+			writeError(w, http.StatusBadRequest, model.ErrorBadSavedSearch)
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		if !s.saved.remove(token, name) {
+			writeError(w, http.StatusNotFound, model.ErrorSavedSearchNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// runSaved выполняет сохранённый поиск тем же обработчиком, что и GET /
+func (s *Server) runSaved(w http.ResponseWriter, r *http.Request, token, name string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	saved, ok := s.saved.get(token, name)
+	if !ok {
+		writeError(w, http.StatusNotFound, model.ErrorSavedSearchNotFound)
+		return
+	}
+	params := r.URL.Query()
+	for key, value := range saved.Params {
+		if _, ok := params[key]; !ok {
+			params.Set(key, value)
+		}
+	}
+	search := r.Clone(r.Context())
+	search.URL.Path, search.URL.RawQuery = "/", params.Encode()
+	s.searchHandler(s.search)(w, search)
+}
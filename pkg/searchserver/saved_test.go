@@ -0,0 +1,63 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestSavedSearches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "saved.json")
+	cfg := testServerConfig
+	cfg.SavedSearchesFile = path
+	h := NewServer(newTestHandler().snapshot(), cfg)
+
+	rec := doRequest(h, "PUT", "/saved/young", `{"age_max": 25, "order_field": "Age", "order_by": 1, "limit": 3}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", rec.Code, rec.Body)
+	}
+	if rec := doRequest(h, "PUT", "/saved/bad", `{"order_field": "About", "order_by": 1}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Error : invalid search saved, %d", rec.Code)
+	}
+
+	run := func(target string) []model.User {
+		rec := doRequest(h, "GET", target, "", nil)
+		users := []model.User{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &users); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("Error : %s: %d %s", target, rec.Code, rec.Body)
+		}
+		return users
+	}
+	saved, direct := run("/saved/young/run"), run("/?age_max=25&order_field=Age&order_by=1&limit=3")
+	if len(saved) != 3 || saved[0] != direct[0] || saved[2] != direct[2] {
+		t.Errorf("Error : %v != %v", saved, direct)
+	}
+	// параметры запроса переопределяют сохранённые
+	if users := run("/saved/young/run?limit=1"); len(users) != 1 || users[0] != direct[0] {
+		t.Errorf("Error : overrides ignored, %v", users)
+	}
+
+	// у другого токена свои сохранённые поиски
+	admin := map[string]string{"AccessToken": adminToken}
+	if rec := doRequest(h, "GET", "/saved/young/run", "", admin); rec.Code != http.StatusNotFound {
+		t.Errorf("Error : search of another token found, %d", rec.Code)
+	}
+
+	// после перезапуска поиски читаются из файла
+	cfg.SavedSearches, _ = LoadSavedSearches(path)
+	h = NewServer(newTestHandler().snapshot(), cfg)
+	list := []model.SavedSearch{}
+	json.Unmarshal(doRequest(h, "GET", "/saved", "", nil).Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Name != "young" || list[0].Params["age_max"] != "25" {
+		t.Fatalf("Error : unexpected list %+v", list)
+	}
+	if rec := doRequest(h, "DELETE", "/saved/young", "", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+	if rec := doRequest(h, "GET", "/saved/young", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+}
@@ -0,0 +1,225 @@
+package searchserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+// parseSearchQuery разбирает параметры поиска из url
+func parseSearchQuery(q url.Values) searchcore.Query {
+	orderBy, _ := strconv.Atoi(q.Get("order_by"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	ageMin, _ := strconv.Atoi(q.Get("age_min"))
+	ageMax, _ := strconv.Atoi(q.Get("age_max"))
+	boostName, _ := strconv.ParseFloat(q.Get("boost_name"), 64)
+	boostAbout, _ := strconv.ParseFloat(q.Get("boost_about"), 64)
+	// field.<имя>=значение - фильтры по вычисляемым полям
+	fields := url.Values{}
+	for name := range q {
+		if field := strings.TrimPrefix(name, "field."); field != name {
+			fields.Set(field, q.Get(name))
+		}
+	}
+	return searchcore.Query{
+		Query:          q.Get("query"),
+		OrderField:     q.Get("order_field"),
+		OrderBy:        orderBy,
+		OrderLocale:    q.Get("order_locale"),
+		Limit:          limit,
+		Offset:         offset,
+		IncludeDeleted: q.Get("include_deleted") == "true",
+		AllowPartial:   q.Get("allow_partial") == "true",
+		Gender:         q.Get("gender"),
+		Lang:           q.Get("lang"),
+		Fields:         fields.Encode(),
+		AgeMin:         ageMin,
+		AgeMax:         ageMax,
+		Email:          q.Get("email"),
+		Phone:          q.Get("phone"),
+		Company:        q.Get("company"),
+		Address:        q.Get("address"),
+		AfterID:        q.Get("after_id"),
+		AfterValue:     q.Get("after_value"),
+		Matcher:        q.Get("matcher"),
+		BoostName:      boostName,
+		BoostAbout:     boostAbout,
+		GroupBy:        q.Get("group_by"),
+	}
+}
+
+// find выполняет поиск по текущему датасету: фильтрация, сортировка, пагинация.
+// Если ctx истёк посреди обхода, при q.AllowPartial возвращает найденное с partial == true,
+// иначе model.ErrSearchTimeout
+func (s *Server) find(ctx context.Context, q searchcore.Query) (users []model.User, partial bool, err error) {
+	if err := s.validate(q); err != nil {
+		return nil, false, err
+	}
+	// без лимита offset не учитывается, как и раньше
+	if q.Limit <= 0 {
+		q.Offset = 0
+	}
+
+	if _, err := s.checkCost(ctx, q); err != nil {
+		return nil, false, err
+	}
+
+	users = []model.User{}
+	err = s.each(ctx, q, func(u model.User) bool {
+		users = append(users, u)
+		return true
+	})
+	if err != nil {
+		if !q.AllowPartial {
+			return nil, false, err
+		}
+		return users, true, nil
+	}
+	return users, false, nil
+}
+
+// validate - Query.Validate и ограничения режима агрегатора
+func (s *Server) validate(q searchcore.Query) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	if err := checkRedacted(q); err != nil {
+		return err
+	}
+	if s.aggregator != nil {
+		return s.aggregator.validate(q)
+	}
+	return nil
+}
+
+// findPage ищет страницу из q.Limit записей и сообщает, есть ли за ней ещё записи.
+// Лимиты страницы из конфига применяет сам, см. pageLimit
+func (s *Server) findPage(ctx context.Context, q searchcore.Query) (model.SearchResponse, error) {
+	q, err := s.pageLimit(q)
+	if err != nil {
+		return model.SearchResponse{}, err
+	}
+	if err := s.validate(q); err != nil {
+		return model.SearchResponse{}, err
+	}
+	// limit 0 и после pageLimit - лимита нет, выдача целиком, как у GET /
+	limit := q.Limit
+	if limit > 0 {
+		q.Limit++
+	}
+	users, partial, err := s.find(ctx, q)
+	if err != nil {
+		return model.SearchResponse{}, err
+	}
+	if limit > 0 && len(users) > limit {
+		return model.SearchResponse{Users: users[:limit], NextPage: true, Partial: partial}, nil
+	}
+	return model.SearchResponse{Users: users, Partial: partial}, nil
+}
+
+// offsetHint - куда отправить клиента с отклонённым offset
+const offsetHint = "deep pages are not served by offset: pass after_id and after_value of the last row instead"
+
+// pageLimit подставляет limit по умолчанию в запрос без limit и проверяет максимумы limit и offset
+// из конфига. Отрицательные limit и offset оставляет Validate
+func (s *Server) pageLimit(q searchcore.Query) (searchcore.Query, error) {
+	if err := s.checkOffset(q); err != nil {
+		return q, err
+	}
+	limitMax := s.maxLimit()
+	if q.Limit == 0 {
+		q.Limit = s.cfg.DefaultLimit
+		if q.Limit <= 0 || limitMax > 0 && q.Limit > limitMax {
+			q.Limit = limitMax
+		}
+	}
+	if limitMax > 0 && q.Limit > limitMax {
+		return q, model.ErrLimitTooLarge
+	}
+	return q, nil
+}
+
+// maxLimit - наибольший limit поиска сейчас: при сбросе нагрузки действует SLOConfig.ShedMaxLimit
+func (s *Server) maxLimit() int {
+	if shed := s.cfg.SLO.ShedMaxLimit; shed > 0 && s.shedding() && (s.cfg.MaxLimit <= 0 || shed < s.cfg.MaxLimit) {
+		return shed
+	}
+	return s.cfg.MaxLimit
+}
+
+// checkOffset отклоняет offset больше ServerConfig.MaxOffset: такие страницы стоят обхода всего,
+// что перед ними
+func (s *Server) checkOffset(q searchcore.Query) error {
+	if s.cfg.MaxOffset > 0 && q.Offset > s.cfg.MaxOffset {
+		return model.ErrOffsetTooLarge
+	}
+	return nil
+}
+
+// writePageError отвечает 400 на ошибку pageLimit вместе с максимумом, чтобы клиент мог повторить запрос
+func (s *Server) writePageError(w http.ResponseWriter, err error) {
+	if err == model.ErrOffsetTooLarge {
+		writeJSON(w, http.StatusBadRequest, model.SearchErrorResponse{Error: model.ErrorOffsetTooLarge, MaxOffset: s.cfg.MaxOffset, Hint: offsetHint})
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, model.SearchErrorResponse{Error: model.ErrorLimitTooLarge, MaxLimit: s.maxLimit()})
+}
+
+// costHint - как удешевить запрос, отклонённый по стоимости
+const costHint = "query is too expensive: add gender or age filters, a smaller limit or a more selective query"
+
+// checkCost оценивает q до выполнения и отклоняет его, если оценка больше ServerConfig.MaxQueryCost.
+// Агрегатор датасета не обходит, его запросы не оцениваются
+func (s *Server) checkCost(ctx context.Context, q searchcore.Query) (int, error) {
+	if s.cfg.MaxQueryCost <= 0 || s.aggregator != nil {
+		return 0, nil
+	}
+	cost := s.engine(ctx).Cost(q)
+	if cost > s.cfg.MaxQueryCost {
+		return cost, model.ErrQueryTooExpensive
+	}
+	return cost, nil
+}
+
+// writeCostError отвечает 400 на запрос дороже бюджета вместе с оценкой и максимумом
+func (s *Server) writeCostError(w http.ResponseWriter, cost int) {
+	writeJSON(w, http.StatusBadRequest, model.SearchErrorResponse{Error: model.ErrorQueryTooExpensive, Cost: cost, MaxCost: s.cfg.MaxQueryCost, Hint: costHint})
+}
+
+// searchContext ограничивает обработку поиска SearchTimeout из конфига
+func (s *Server) searchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.cfg.SearchTimeout > 0 {
+		return context.WithTimeout(ctx, s.cfg.SearchTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// each вызывает fn для подходящих пользователей в порядке выдачи, учитывая limit и offset:
+// обезличивает выдачу, если нужно, и ищет у нижестоящих серверов в режиме агрегатора,
+// иначе в своём датасете через searchcore.Engine.Each. q должен быть уже проверен Validate
+func (s *Server) each(ctx context.Context, q searchcore.Query, fn func(model.User) bool) error {
+	if q.Redact {
+		emit := fn
+		fn = func(u model.User) bool {
+			return emit(s.redactUser(u))
+		}
+		if q.Query != "" {
+			q, fn = s.redactedText(q, fn)
+		}
+	}
+	if s.aggregator != nil {
+		return s.aggregator.each(ctx, q, s.cfg.OrderLocale, fn)
+	}
+	stats, err := s.engine(ctx).Each(ctx, q, fn)
+	if t := traceFrom(ctx); t != nil {
+		t.Access, t.Scanned, t.Matched = stats.Access, stats.Scanned, stats.Matched
+		t.Sort, t.Filter = stats.Sort, stats.Filter
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package searchserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+func expiredContext() context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	cancel()
+	return ctx
+}
+
+func newTimeoutHandler() *Server {
+	users, _ := LoadDataset("../../dataset.xml")
+	// дедлайн истекает раньше, чем начнётся обход
+	return NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, SearchTimeout: time.Nanosecond, CacheSize: 10})
+}
+
+func TestFindDeadline(t *testing.T) {
+	for _, parallelism := range []int{0, 4} {
+		s := NewServer(bigDataset(10), ServerConfig{SearchParallelism: parallelism})
+
+		if _, _, err := s.find(expiredContext(), searchcore.Query{}); err != model.ErrSearchTimeout {
+			t.Errorf("Error : unexpected error %v", err)
+		}
+
+		users, partial, err := s.find(expiredContext(), searchcore.Query{AllowPartial: true})
+		if err != nil || !partial || len(users) != 0 {
+			t.Errorf("Error : unexpected result %v %v %v", len(users), partial, err)
+		}
+
+		users, partial, err = s.find(context.Background(), searchcore.Query{AllowPartial: true})
+		if err != nil || partial || len(users) != len(s.users) {
+			t.Errorf("Error : unexpected result %v %v %v", len(users), partial, err)
+		}
+		s.Close()
+	}
+}
+
+func TestSearchTimeout(t *testing.T) {
+	h := newTimeoutHandler()
+
+	w := doRequest(h, "GET", "/?limit=5", "", nil)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+
+	for i := 0; i < 2; i++ {
+		w = doRequest(h, "GET", "/?limit=5&allow_partial=true", "", nil)
+		if w.Code != http.StatusOK || w.Header().Get("X-Partial-Result") != "true" {
+			t.Errorf("Error : unexpected response %d %v", w.Code, w.Header())
+		}
+		// неполный результат не должен попадать в кэш
+		if w.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("Error : partial result was cached")
+		}
+	}
+}
+
+func TestRPCFindUsersPartial(t *testing.T) {
+	h := newTimeoutHandler()
+
+	w := doRequest(h, http.MethodPost, "/rpc",
+		`{"jsonrpc": "2.0", "method": "search.findUsers", "params": {"limit": 5, "allow_partial": true}, "id": 1}`, nil)
+	var resp struct {
+		Result model.SearchResponse
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.Result.Partial {
+		t.Errorf("Error : %v", w.Body.String())
+	}
+
+	w = doRequest(h, http.MethodPost, "/rpc",
+		`{"jsonrpc": "2.0", "method": "search.findUsers", "params": {"limit": 5}, "id": 1}`, nil)
+	var errResp struct {
+		Error rpcError
+	}
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if errResp.Error.Code != rpcTimeout {
+		t.Errorf("Error : %v", w.Body.String())
+	}
+}
+
+func TestSearchExtendedFields(t *testing.T) {
+	h := newTestHandler()
+
+	for _, c := range []struct {
+		params string
+		ids    []int
+	}{
+		{"company=hopeli", []int{0}},
+		{"email=HildaMayer@quintity.com", []int{1}},
+		{"phone=%2B1+(956)+593-2402", []int{0}},
+		{"address=Kansas", []int{1, 3}},
+		{"order_field=Company&order_by=1&limit=2", []int{2, 15}},
+		{"order_field=Email&order_by=-1&limit=1", []int{15}},
+	} {
+		w := doRequest(h, http.MethodGet, "/?"+c.params, "", nil)
+		users := []model.User{}
+		json.Unmarshal(w.Body.Bytes(), &users)
+		if len(users) != len(c.ids) {
+			t.Errorf("Error : %s: unexpected %v", c.params, users)
+			continue
+		}
+		for i, id := range c.ids {
+			if users[i].Id != id {
+				t.Errorf("Error : %s: %d != %d", c.params, users[i].Id, id)
+			}
+		}
+	}
+}
+
+func TestSearchComputedFields(t *testing.T) {
+	h := newTestHandler()
+
+	users := decodeUsers(t, doRequest(h, http.MethodGet, "/?field.AgeBucket=20&order_field=AboutWordCount&order_by=1", "", nil))
+	if len(users) == 0 {
+		t.Fatalf("Error : nothing found")
+	}
+	words := func(u model.User) int {
+		v, _ := searchcore.GroupValue(u, "AboutWordCount")
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	for i, u := range users {
+		if u.Age < 20 || u.Age >= 30 {
+			t.Errorf("Error : %d is not in AgeBucket 20, age %d", u.Id, u.Age)
+		}
+		if i > 0 && words(u) > words(users[i-1]) {
+			t.Errorf("Error : unexpected order at %d", i)
+		}
+	}
+	w := doRequest(h, http.MethodGet, "/?field.AgeBucket=twenty", "", nil)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), model.ErrBadField.Error()) {
+		t.Errorf("Error : unexpected response %d %s", w.Code, w.Body)
+	}
+}
+
+func TestSearchIfNoneMatch(t *testing.T) {
+	h := newTestHandler()
+
+	first := doRequest(h, http.MethodGet, "/?query=Boyd", "", nil)
+	etag := first.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("Error : ETag %q", etag)
+	}
+	w := doRequest(h, http.MethodGet, "/?query=Boyd", "", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+		t.Errorf("Error : %d %q", w.Code, w.Body.String())
+	}
+	if n := h.counters.notModified.Load(); n != 1 {
+		t.Errorf("Error : %d not modified, want 1", n)
+	}
+
+	// правка меняет версию датасета - выдача приходит заново
+	doRequest(h, http.MethodPatch, "/users/0", `{"Name": "Boyd Fox"}`, adminHeader)
+	w = doRequest(h, http.MethodGet, "/?query=Boyd", "", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("Error : %d after update, ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	// as_of отвечает не по текущей версии, ETag у него нет
+	asOf := time.Now().UTC().Format(time.RFC3339Nano)
+	w = doRequest(h, http.MethodGet, "/?as_of="+asOf, "", map[string]string{"If-None-Match": "*"})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != "" {
+		t.Errorf("Error : as_of %d %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestSearchETagSynonyms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	if err := os.WriteFile(path, []byte("dev, developer\n"), 0600); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	users, _ := LoadDataset("../../dataset.xml")
+	cfg := testServerConfig
+	cfg.SynonymsFile = path
+	h := NewServer(users, cfg)
+
+	etag := doRequest(h, http.MethodGet, "/?query=dev", "", nil).Header().Get("ETag")
+	if err := h.ReloadSynonyms(); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	// синонимы меняют выдачу, не меняя версии датасета
+	if w := doRequest(h, http.MethodGet, "/?query=dev", "", map[string]string{"If-None-Match": etag}); w.Code != http.StatusOK {
+		t.Errorf("Error : %d after synonyms reload", w.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package searchserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHSTSMaxAge = 365 * 24 * time.Hour
+
+// defaultAPICSP - ответы api не html, им не нужно ничего
+const defaultAPICSP = "default-src 'none'; frame-ancestors 'none'"
+
+// SecurityHeadersConfig - заголовки безопасности всех ответов, см. ServerConfig.SecurityHeaders.
+// По умолчанию ставятся X-Content-Type-Options: nosniff, Content-Security-Policy, Strict-Transport-Security
+// для запросов по TLS, а Server и X-Powered-By убираются
+type SecurityHeadersConfig struct {
+	// не трогать заголовки вовсе
+	Disabled bool
+	// Content-Security-Policy ответов api, пусто - defaultAPICSP
+	APICSP string
+	// Content-Security-Policy страницы /docs и её файлов, пусто - docsCSP
+	DocsCSP string
+	// max-age Strict-Transport-Security, 0 - defaultHSTSMaxAge, меньше 0 - заголовок не ставится
+	HSTSMaxAge time.Duration
+	// добавить includeSubDomains к Strict-Transport-Security
+	HSTSIncludeSubdomains bool
+	// запрос пришёл по TLS, если X-Forwarded-Proto: https. Только за прокси, который этот заголовок
+	// перезаписывает: иначе его подставит кто угодно
+	TrustForwardedProto bool
+	// значение заголовка Server, пусто - заголовка нет
+	ServerName string
+}
+
+// docsCSP - страница /docs целиком своя (см. docsFiles): скрипт, стили и запрос /openapi.json
+// только со своего адреса
+const docsCSP = "default-src 'none'; script-src 'self'; style-src 'self'; img-src 'self' data:; connect-src 'self'; " +
+	"frame-ancestors 'none'; base-uri 'none'; form-action 'none'"
+
+// securityHeaders ставит заголовки безопасности ответа на r
+func (s *Server) securityHeaders(h http.Header, r *http.Request) {
+	cfg := s.cfg.SecurityHeaders
+	h.Set("X-Content-Type-Options", "nosniff")
+	csp := cfg.APICSP
+	if csp == "" {
+		csp = defaultAPICSP
+	}
+	if r.URL.Path == "/docs" || strings.HasPrefix(r.URL.Path, "/docs/") {
+		csp = cfg.DocsCSP
+		if csp == "" {
+			csp = docsCSP
+		}
+	}
+	h.Set("Content-Security-Policy", csp)
+	if cfg.HSTSMaxAge >= 0 && (r.TLS != nil || cfg.TrustForwardedProto && r.Header.Get("X-Forwarded-Proto") == "https") {
+		hsts := "max-age=" + strconv.FormatInt(int64(durationOr(cfg.HSTSMaxAge, defaultHSTSMaxAge)/time.Second), 10)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		h.Set("Strict-Transport-Security", hsts)
+	}
+}
+
+// securityWriter убирает из ответа заголовки, по которым узнаётся сервер, когда они уже
+// выставлены обработчиком: Server и X-Powered-By. Вместо Server ставится ServerName, если задан
+type securityWriter struct {
+	http.ResponseWriter
+	name  string
+	wrote bool
+}
+
+func (sw *securityWriter) WriteHeader(status int) {
+	if !sw.wrote {
+		sw.wrote = true
+		h := sw.Header()
+		h.Del("X-Powered-By")
+		if sw.name != "" {
+			h.Set("Server", sw.name)
+		} else {
+			h.Del("Server")
+		}
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *securityWriter) Write(p []byte) (int, error) {
+	if !sw.wrote {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *securityWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *securityWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
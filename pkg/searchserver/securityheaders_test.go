@@ -0,0 +1,84 @@
+package searchserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSecurityHandler(cfg SecurityHeadersConfig) *Server {
+	users, _ := LoadDataset("../../dataset.xml")
+	return NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, SecurityHeaders: cfg})
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	h := newSecurityHandler(SecurityHeadersConfig{})
+
+	for _, target := range []string{"/?limit=1", "/users/1", "/openapi.json"} {
+		w := doRequest(h, "GET", target, "", nil)
+		if w.Header().Get("X-Content-Type-Options") != "nosniff" || w.Header().Get("Content-Security-Policy") != defaultAPICSP {
+			t.Errorf("Error : %s: unexpected headers %v", target, w.Header())
+		}
+		if w.Header().Get("Strict-Transport-Security") != "" {
+			t.Errorf("Error : %s: hsts without tls", target)
+		}
+	}
+	w := doRequest(h, "GET", "/?limit=1", "", map[string]string{"AccessToken": "bad"})
+	if w.Code != http.StatusUnauthorized || w.Header().Get("Content-Security-Policy") != defaultAPICSP {
+		t.Errorf("Error : unexpected 401 headers %v", w.Header())
+	}
+
+	w = doRequest(h, "GET", "/docs", "", nil)
+	csp := w.Header().Get("Content-Security-Policy")
+	if csp != docsCSP || strings.Contains(csp, "https:") {
+		t.Errorf("Error : unexpected docs csp %q", csp)
+	}
+}
+
+func TestSecurityHeadersHSTS(t *testing.T) {
+	h := newSecurityHandler(SecurityHeadersConfig{HSTSMaxAge: time.Hour, HSTSIncludeSubdomains: true})
+	w := doRequest(h, "GET", "https://search.example/?limit=1", "", nil)
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=3600; includeSubDomains" {
+		t.Errorf("Error : unexpected hsts %q", got)
+	}
+	// без TrustForwardedProto заголовку не верим
+	w = doRequest(h, "GET", "/?limit=1", "", map[string]string{"X-Forwarded-Proto": "https"})
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("Error : hsts from untrusted X-Forwarded-Proto")
+	}
+
+	h = newSecurityHandler(SecurityHeadersConfig{TrustForwardedProto: true})
+	w = doRequest(h, "GET", "/?limit=1", "", map[string]string{"X-Forwarded-Proto": "https"})
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("Error : unexpected hsts %q", got)
+	}
+
+	h = newSecurityHandler(SecurityHeadersConfig{HSTSMaxAge: -1})
+	w = doRequest(h, "GET", "https://search.example/?limit=1", "", nil)
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("Error : hsts is disabled")
+	}
+}
+
+func TestSecurityWriterServerName(t *testing.T) {
+	for _, name := range []string{"", "search"} {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Server", "Go-http-server/1.1")
+		rec.Header().Set("X-Powered-By", "Go")
+		w := &securityWriter{ResponseWriter: rec, name: name}
+		w.Write([]byte("ok"))
+		if rec.Header().Get("Server") != name || rec.Header().Get("X-Powered-By") != "" {
+			t.Errorf("Error : %q: unexpected headers %v", name, rec.Header())
+		}
+	}
+}
+
+func TestSecurityHeadersDisabled(t *testing.T) {
+	h := newSecurityHandler(SecurityHeadersConfig{Disabled: true})
+	w := doRequest(h, "GET", "https://search.example/?limit=1", "", nil)
+	if w.Header().Get("Content-Security-Policy") != "" || w.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("Error : unexpected headers %v", w.Header())
+	}
+}
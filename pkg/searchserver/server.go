@@ -0,0 +1,1135 @@
+package searchserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+type PurgeResponse struct {
+	Purged int
+}
+
+// Scope - права, которые даёт токен доступа
+type Scope int
+
+const (
+	ScopeSearch Scope = iota
+	ScopeAdmin
+)
+
+// Priority - приоритет токена: под перегрузкой слоты поиска достаются сначала
+// высокоприоритетным запросам, а низкоприоритетные отбрасываются без очереди
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+type ServerConfig struct {
+	// токены доступа и выданные им права
+	Tokens map[string]Scope
+	// приоритеты токенов, токены без записи - PriorityNormal
+	TokenPriorities map[string]Priority
+	// суточные квоты токенов, см. TokenQuota. Токены без своей квоты получают DefaultQuota,
+	// кроме admin-токенов
+	TokenQuotas  map[string]TokenQuota
+	DefaultQuota TokenQuota
+	// файл, из которого ReloadTokens перечитывает токены, см. LoadTokens
+	TokensFile string
+	// сколько закодированных страниц поиска держать в кэше, 0 - кэш выключен
+	CacheSize int
+	// limit поиска без limit, 0 - MaxLimit, а без него - вся выдача. Потоковую выдачу (stream=true) не ограничивает
+	DefaultLimit int
+	// наибольший limit поиска, больший - 400 ErrorLimitTooLarge, 0 - без ограничения.
+	// Клиенты WireV1 просят на запись больше страницы, чтобы узнать про следующую, - это тоже limit
+	MaxLimit int
+	// наибольший offset поиска, больший - 400 ErrorOffsetTooLarge, 0 - без ограничения.
+	// Действует и на потоковую выдачу
+	MaxOffset int
+	// сколько разобранных запросов держать в кэше планов, 0 - размер по умолчанию, меньше 0 - без кэша
+	PlanCacheSize int
+	// сколько горутин фильтруют большие датасеты параллельно, 0 или 1 - в одной горутине
+	SearchParallelism int
+	// сколько поисковых запросов выполняется одновременно, 0 - без ограничения
+	MaxInFlight int
+	// сколько запрос ждёт свободного слота, прежде чем получить 503
+	QueueTimeout time.Duration
+	// цель по времени ответа поиска и сброс нагрузки, пока она не выполняется, см. SLOConfig
+	SLO SLOConfig
+	// дедлайн обработки одного поиска, 0 - без дедлайна
+	SearchTimeout time.Duration
+	// локаль сортировки по Name (BCP 47, например "de" или "sv"), пусто - побайтово
+	OrderLocale string
+	// хранить About всех записей в одной арене, см. packAbout. Каждая правка датасета
+	// переупаковывает арену целиком
+	CompactAbout bool
+	// поиск query по словам About со стеммингом и стоп-словами, по умолчанию - подстрокой
+	TextSearch searchcore.TextSearchConfig
+	// наборы синонимов query, см. searchcore.Synonyms. ReloadSynonyms перечитывает их из SynonymsFile
+	Synonyms     *searchcore.Synonyms
+	SynonymsFile string
+	// сохранённые поиски при запуске, см. LoadSavedSearches. Если задан SavedSearchesFile,
+	// каждая правка переписывает его
+	SavedSearches     map[string]map[string]model.SavedSearch
+	SavedSearchesFile string
+
+	// сколько помнить ответы правок с Idempotency-Key, 0 - defaultIdempotencyWindow,
+	// меньше нуля - заголовок не поддерживается
+	IdempotencyWindow time.Duration
+
+	// Cache-Control ответов по точкам, как они называются в /admin/stats ("/" - поиск), см. CacheHint.
+	// Пусто - заголовки не ставятся. Правки, /admin/* и точки без подсказки получают "no-store"
+	CacheHints map[string]CacheHint
+
+	// отдавать sha256 тела в model.ChecksumHeader, чтобы клиент заметил порчу ответа по дороге.
+	// Ответ тогда копится в памяти целиком, кроме потока ndjson - ему сумма приходит трейлером
+	ContentChecksum bool
+
+	// X-Content-Type-Options, Content-Security-Policy, Strict-Transport-Security и без Server,
+	// см. SecurityHeadersConfig
+	SecurityHeaders SecurityHeadersConfig
+
+	// наибольшая оценка стоимости поиска (searchcore.Engine.Cost), дороже - ErrorQueryTooExpensive.
+	// 0 - без ограничения
+	MaxQueryCost int
+
+	// самый короткий период алерта, 0 - defaultAlertMinInterval
+	AlertMinInterval time.Duration
+	// SMTP для алертов с Email, см. AlertMailConfig
+	AlertMail AlertMailConfig
+	// хосты, на которые вебхуки алертов могут ставить токены без ScopeAdmin; пусто - только админы
+	AlertWebhookHosts []string
+	// сколько запросов принимает POST /msearch, 0 - defaultMaxMultiSearch
+	MaxMultiSearch int
+	// сколько правок принимает POST /users/bulk, 0 - defaultMaxBulk
+	MaxBulk int
+	// веса Name и About в сортировке по релевантности (order_field=Score), запрос переопределяет их
+	// в boost_name и boost_about
+	Boosts searchcore.Boosts
+
+	// поиски дольше порога пишутся в SlowQueryLog (по умолчанию stderr), 0 - журнал выключен
+	SlowQueryThreshold time.Duration
+	SlowQueryLog       io.Writer
+
+	// журнал аудита всех запросов с токеном, nil - не ведётся
+	AuditLog *AuditLog
+
+	// теневое зеркалирование поисковых запросов на другой сервер
+	Mirror MirrorConfig
+
+	// реализация json для выдачи поиска, nil - model.StdJSON
+	JSON model.JSONEngine
+
+	// режим агрегатора над другими серверами поиска, см. AggregateConfig
+	Aggregate AggregateConfig
+
+	// рассылка датасета и правок репликам, см. ReplicationConfig
+	Replication ReplicationConfig
+
+	// кому сообщать об изменениях датасета, см. WebhookConfig
+	Webhooks []WebhookConfig
+
+	// журнал правок, см. OpenWAL, nil - правки теряются при перезапуске
+	WAL *WAL
+	// как часто сжимать журнал в контрольную точку, 0 - defaultWALCompactInterval
+	WALCompactInterval time.Duration
+
+	// маппинг полей для датасетов, загруженных через /admin/import, пусто - DefaultFieldMapping
+	FieldMapping FieldMapping
+
+	// обезличивание ответов для части токенов, см. RedactConfig
+	Redact RedactConfig
+
+	// сколько прежних версий датасета держать в памяти для поиска с as_of, 0 - только текущую.
+	// Каждая правка - новая версия со своим срезом пользователей
+	History int
+
+	// запуститься в режиме обслуживания, см. SetMaintenance
+	Maintenance bool
+
+	// таймауты и лимиты http.Server из HTTPServer, 0 - значение по умолчанию. Потоку (stream=true)
+	// и /changes WriteTimeout даётся на каждый кусок ответа, а не на весь
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+}
+
+// значения по умолчанию для HTTPServer: без них медленный клиент держит соединение вечно
+const (
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 10 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+	defaultMaxHeaderBytes    = 64 << 10
+)
+
+// Server - внешняя система поиска пользователей, хранит датасет в памяти
+type Server struct {
+	cfg ServerConfig
+	// текущий набор токенов map[string]Scope, подменяется целиком при ротации
+	tokens atomic.Value
+	// приоритеты токенов map[string]Priority, подменяются вместе с токенами
+	priorities atomic.Value
+
+	mu    sync.RWMutex
+	users []model.User
+	// фильтрация, сортировка и пагинация по users с индексами, подменяются вместе в setUsers
+	core *searchcore.Engine
+
+	// кодек FormatJSON поверх ServerConfig.JSON
+	jsonCodec model.Codec
+
+	cache *resultCache
+
+	limiter *inflightLimiter
+	slo     *sloTracker
+	// выполняющиеся поиски, см. tracked
+	requests *requestRegistry
+	slowLog  *slowQueryLog
+
+	// последние версии датасета для as_of
+	history *datasetHistory
+	// подготовленная и прежняя версии датасета, см. StageDataset
+	versions datasetVersions
+
+	// режим обслуживания, см. SetMaintenance. maintenanceSince - под mu
+	maintenance      atomic.Bool
+	maintenanceSince time.Time
+
+	// когда датасет был загружен целиком (NewServer, Reload)
+	loadedAt time.Time
+	// когда датасет последний раз менялся, UnixNano. От него считается свежесть в Cache-Control.
+	// Не под mu: правки пишут ответ, не отпуская его
+	changedAt atomic.Int64
+	// сколько раз перечитывались синонимы: они меняют выдачу, не меняя версии датасета, см. searchETag
+	synonymsEpoch atomic.Uint64
+	// итог проверки строк при загрузке из файла, см. SetLoadStats
+	loadStats LoadStats
+	counters  *serverStats
+	// запросы и байты по токенам, квоты
+	usage *usageTracker
+	// сохранённые поиски по токенам
+	saved *savedSearches
+	// алерты по токенам, см. alertsHandler
+	alerts *alertScheduler
+	// ответы правок по Idempotency-Key, nil - выключено
+	idempotency *idempotencyStore
+
+	mirror     *mirror
+	aggregator *aggregator
+
+	replicas []*replica
+	// номер последней правки датасета, общий для основного сервера и реплик
+	replSeq uint64
+
+	walDone chan struct{}
+	walWG   sync.WaitGroup
+
+	webhooks []*webhook
+	// номер последнего события вебхуков
+	eventSeq uint64
+}
+
+func NewServer(users []model.User, cfg ServerConfig) *Server {
+	s := &Server{
+		cfg:         cfg,
+		loadedAt:    time.Now(),
+		counters:    newServerStats(),
+		usage:       newUsageTracker(cfg.DefaultQuota, cfg.TokenQuotas),
+		saved:       newSavedSearches(cfg.SavedSearchesFile, cfg.SavedSearches),
+		alerts:      newAlertScheduler(durationOr(cfg.AlertMinInterval, defaultAlertMinInterval)),
+		idempotency: newIdempotencyStore(cfg.IdempotencyWindow),
+		requests:    newRequestRegistry(),
+		jsonCodec:   model.JSONCodec(cfg.JSON),
+	}
+	s.SetTokens(cfg.Tokens)
+	s.SetTokenPriorities(cfg.TokenPriorities)
+	if cfg.CacheSize > 0 {
+		s.cache = newResultCache(cfg.CacheSize)
+		s.cache.ttl = cfg.Aggregate.CacheTTL
+	}
+	if len(cfg.Aggregate.URLs) > 0 {
+		s.aggregator = newAggregator(cfg.Aggregate)
+	}
+	coreCfg := searchcore.Config{
+		OrderLocale:   cfg.OrderLocale,
+		TextSearch:    cfg.TextSearch,
+		PlanCacheSize: cfg.PlanCacheSize,
+		Parallelism:   cfg.SearchParallelism,
+		Synonyms:      cfg.Synonyms,
+		Boosts:        cfg.Boosts,
+	}
+	s.core = searchcore.New(coreCfg)
+	s.history = newDatasetHistory(cfg.History, coreCfg)
+	if cfg.Mirror.URL != "" && cfg.Mirror.Percent > 0 {
+		s.mirror = newMirror(cfg.Mirror)
+	}
+	if cfg.SlowQueryThreshold > 0 {
+		s.slowLog = newSlowQueryLog(cfg.SlowQueryThreshold, cfg.SlowQueryLog)
+	}
+	if cfg.MaxInFlight > 0 {
+		s.limiter = newInflightLimiter(cfg.MaxInFlight, cfg.QueueTimeout)
+	}
+	if cfg.SLO.Target > 0 {
+		s.slo = newSLOTracker(cfg.SLO)
+	}
+	s.setUsers(users)
+	s.SetMaintenance(cfg.Maintenance)
+	for _, url := range cfg.Replication.Replicas {
+		s.replicas = append(s.replicas, newReplica(url, cfg.Replication, s.replicationSource))
+	}
+	for _, h := range cfg.Webhooks {
+		s.webhooks = append(s.webhooks, newWebhook(h))
+	}
+	if cfg.WAL != nil {
+		s.walDone = make(chan struct{})
+		s.walWG.Add(1)
+		go s.compactLoop(durationOr(cfg.WALCompactInterval, defaultWALCompactInterval))
+	}
+	return s
+}
+
+// json возвращает реализацию json из конфига
+func (s *Server) json() model.JSONEngine {
+	if s.cfg.JSON == nil {
+		return model.StdJSON
+	}
+	return s.cfg.JSON
+}
+
+// HTTPServer возвращает http.Server с этим обработчиком и таймаутами из конфига
+func (s *Server) HTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: durationOr(s.cfg.ReadHeaderTimeout, defaultReadHeaderTimeout),
+		ReadTimeout:       durationOr(s.cfg.ReadTimeout, defaultReadTimeout),
+		WriteTimeout:      durationOr(s.cfg.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:       durationOr(s.cfg.IdleTimeout, defaultIdleTimeout),
+		MaxHeaderBytes:    intOr(s.cfg.MaxHeaderBytes, defaultMaxHeaderBytes),
+	}
+}
+
+// rollingDeadlineWriter продлевает дедлайн записи на каждом куске тела и сбросе буфера. Поток
+// и большая выгрузка иначе упираются в WriteTimeout сервера целиком и обрываются посередине,
+// а так обрывается только клиент, который перестал читать дольше чем на WriteTimeout
+type rollingDeadlineWriter struct {
+	http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+// кусок тела, на который продлевается дедлайн
+const rollingDeadlineChunk = 64 << 10
+
+func (s *Server) rollingDeadline(w http.ResponseWriter) http.ResponseWriter {
+	return &rollingDeadlineWriter{
+		ResponseWriter: w,
+		rc:             http.NewResponseController(w),
+		timeout:        durationOr(s.cfg.WriteTimeout, defaultWriteTimeout),
+	}
+}
+
+// extend продлевает дедлайн. Ошибку (например, ErrNotSupported у httptest) можно не смотреть:
+// тогда действует дедлайн сервера
+func (d *rollingDeadlineWriter) extend() {
+	d.rc.SetWriteDeadline(time.Now().Add(d.timeout))
+}
+
+func (d *rollingDeadlineWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > rollingDeadlineChunk {
+			chunk = chunk[:rollingDeadlineChunk]
+		}
+		d.extend()
+		n, err := d.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (d *rollingDeadlineWriter) Flush() {
+	d.extend()
+	d.rc.Flush()
+}
+
+func (d *rollingDeadlineWriter) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
+
+func durationOr(v, def time.Duration) time.Duration {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+func intOr(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// Close останавливает фоновые горутины сервера и дожидается зеркальных запросов
+func (s *Server) Close() {
+	// сначала всё, что работает в фоне и может искать, движок - последним
+	s.alerts.close()
+	if s.mirror != nil {
+		s.mirror.wg.Wait()
+	}
+	for _, r := range s.replicas {
+		r.close()
+	}
+	if s.walDone != nil {
+		close(s.walDone)
+		s.walWG.Wait()
+	}
+	for _, h := range s.webhooks {
+		h.close()
+	}
+	s.core.Close()
+}
+
+// Reload атомарно подменяет датасет, например после повторного чтения файла
+func (s *Server) Reload(users []model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg.WAL != nil {
+		if err := s.cfg.WAL.compact(users); err != nil {
+			log.Printf("wal compaction: %s", err)
+		}
+	}
+	// номер версии меняется до setUsers: история версий запоминает её под новым snapshotID
+	s.loadedAt = time.Now()
+	s.replSeq++
+	s.setUsers(users)
+	s.emit(WebhookEvent{Type: EventDatasetReloaded, Rows: len(users)})
+	for _, r := range s.replicas {
+		r.resync()
+	}
+}
+
+// snapshotID - идентификатор текущей версии датасета: время загрузки и номер правки.
+// Меняется при любой правке и перезагрузке, а за счёт времени - и после перезапуска.
+// Берётся до поиска: если правка успела между ними, следующая страница получит 409 зря, но не
+// страницу из другой версии
+func (s *Server) snapshotID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshotIDLocked()
+}
+
+// snapshotIDLocked - snapshotID под уже взятым s.mu
+func (s *Server) snapshotIDLocked() string {
+	return strconv.FormatInt(s.loadedAt.UnixNano(), 36) + "." + strconv.FormatUint(s.replSeq, 10)
+}
+
+// CacheStats возвращает счётчики кэша результатов, если он включён
+func (s *Server) CacheStats() CacheStats {
+	if s.cache == nil {
+		return CacheStats{}
+	}
+	return s.cache.stats()
+}
+
+// PlanCacheStats возвращает счётчики кэша планов запросов
+func (s *Server) PlanCacheStats() CacheStats {
+	return CacheStats(s.core.PlanCacheStats())
+}
+
+type scopeKey struct{}
+
+type priorityKey struct{}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.SecurityHeaders.Disabled {
+		s.securityHeaders(w.Header(), r)
+		w = &securityWriter{ResponseWriter: w, name: s.cfg.SecurityHeaders.ServerName}
+	}
+	if s.cfg.ContentChecksum {
+		cw := newChecksumWriter(w)
+		defer cw.finish()
+		w = cw
+	}
+	// документация доступна без токена
+	switch r.URL.Path {
+	case "/openapi.json":
+		s.openAPI(w, r)
+		return
+	}
+	if r.URL.Path == "/docs" || strings.HasPrefix(r.URL.Path, "/docs/") {
+		s.docs(w, r)
+		return
+	}
+
+	token := r.Header.Get("AccessToken")
+	scope, ok := s.tokenScope(token)
+	if !ok {
+		http.Error(w, "Bad access token", http.StatusUnauthorized)
+		return
+	}
+	ctx := context.WithValue(r.Context(), scopeKey{}, scope)
+	r = r.WithContext(context.WithValue(ctx, priorityKey{}, s.tokenPriority(token)))
+
+	if len(s.cfg.CacheHints) > 0 {
+		w = &cacheHintWriter{ResponseWriter: w, s: s, r: r}
+	}
+
+	start := time.Now()
+	s.metered(w, r, token, scope, func(w http.ResponseWriter, r *http.Request) {
+		s.audited(w, r, token, scope, s.idempotent(s.route))
+	})
+	s.counters.observe(endpointName(r.URL.Path), time.Since(start))
+}
+
+// route разбирает запросы, уже прошедшие проверку токена
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	if s.rejectMaintenance(w, r) {
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/admin/") {
+		if requestScope(r) != ScopeAdmin {
+			http.Error(w, "admin scope required", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/admin/requests" || strings.HasPrefix(r.URL.Path, "/admin/requests/") {
+			s.activeRequests(w, r)
+			return
+		}
+		if r.URL.Path == "/admin/datasets" || strings.HasPrefix(r.URL.Path, "/admin/datasets/") {
+			s.datasetsHandler(w, r)
+			return
+		}
+		switch r.URL.Path {
+		case "/admin/purge":
+			s.purge(w, r)
+		case "/admin/audit":
+			s.auditQuery(w, r)
+		case "/admin/stats":
+			s.stats(w, r)
+		case "/admin/usage":
+			s.usageReport(w, r)
+		case "/admin/maintenance":
+			s.maintenanceHandler(w, r)
+		case "/admin/tokens/reload":
+			s.reloadTokens(w, r)
+		case "/admin/synonyms/reload":
+			s.reloadSynonyms(w, r)
+		case "/admin/import":
+			s.importDataset(w, r)
+		case "/admin/replication/snapshot":
+			s.replicationSnapshotHandler(w, r)
+		case "/admin/replication/changes":
+			s.replicationChangesHandler(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+
+	switch r.URL.Path {
+	case "/graphql":
+		s.searchHandler(s.graphql)(w, r)
+		return
+	case "/rpc":
+		s.searchHandler(s.jsonRPC)(w, r)
+		return
+	case "/msearch":
+		s.searchHandler(s.msearch)(w, r)
+		return
+	case "/search/explain":
+		s.explain(w, r)
+		return
+	case "/changes":
+		s.changes(w, r)
+		return
+	}
+	if r.URL.Path == "/saved" || strings.HasPrefix(r.URL.Path, "/saved/") {
+		s.savedHandler(w, r)
+		return
+	}
+	if r.URL.Path == "/alerts" || strings.HasPrefix(r.URL.Path, "/alerts/") {
+		s.alertsHandler(w, r)
+		return
+	}
+
+	if r.URL.Path == "/users/bulk" {
+		if r.Method == http.MethodPost && requestScope(r) != ScopeAdmin {
+			writeError(w, http.StatusForbidden, model.ErrorAdminOnly)
+			return
+		}
+		s.bulkUsers(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/users/") {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/users/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		// правки датасета - только админам, читать может любой токен
+		switch r.Method {
+		case http.MethodPut, http.MethodPatch, http.MethodDelete:
+			if requestScope(r) != ScopeAdmin {
+				writeError(w, http.StatusForbidden, model.ErrorAdminOnly)
+				return
+			}
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s.getUser(w, r, id)
+		case http.MethodPut:
+			s.replaceUser(w, r, id)
+		case http.MethodPatch:
+			s.patchUser(w, r, id)
+		case http.MethodDelete:
+			s.deleteUser(w, r, id)
+		default:
+			w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	s.searchHandler(s.search)(w, r)
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q := r.URL.Query()
+
+	stream := q.Get("stream") == "true"
+	var codec model.Codec
+	if !stream {
+		var ok bool
+		codec, ok = negotiateCodec(q.Get("format"), r.Header.Get("Accept"))
+		if !ok {
+			if q.Get("format") != "" {
+				writeError(w, http.StatusBadRequest, model.ErrorBadFormat)
+			} else {
+				writeError(w, http.StatusNotAcceptable, model.ErrorBadFormat)
+			}
+			return
+		}
+	}
+
+	if codec.Format == model.FormatJSON {
+		codec = s.jsonCodec
+	}
+
+	query := parseSearchQuery(q)
+	query.Redact = s.redacts(requestScope(r))
+	if query.IncludeDeleted && requestScope(r) != ScopeAdmin {
+		writeError(w, http.StatusForbidden, model.ErrorAdminOnly)
+		return
+	}
+	var err error
+	if stream {
+		err = s.checkOffset(query)
+	} else {
+		query, err = s.pageLimit(query)
+	}
+	if err != nil {
+		s.writePageError(w, err)
+		return
+	}
+
+	if err := s.validate(query); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.counters.countQuery(query)
+
+	ctx := r.Context()
+	if s.slowLog != nil {
+		trace := &queryTrace{Parse: time.Since(start)}
+		ctx = withTrace(ctx, trace)
+		defer func() {
+			s.slowLog.record(query, trace, time.Since(start))
+		}()
+	}
+
+	// as_of - поиск по версии датасета, действовавшей в тот момент. Такие ответы не кэшируем
+	asOf := q.Get("as_of")
+	if asOf != "" {
+		t, err := time.Parse(time.RFC3339, asOf)
+		if err != nil || s.aggregator != nil {
+			writeError(w, http.StatusBadRequest, model.ErrorBadAsOf)
+			return
+		}
+		var ok bool
+		if ctx, ok = s.asOf(ctx, t); !ok {
+			writeError(w, http.StatusGone, model.ErrorAsOfUnavailable)
+			return
+		}
+	}
+
+	// стоимость проверяем до потока: начатый поток ошибкой уже не закончить
+	if cost, err := s.checkCost(ctx, query); err != nil {
+		s.writeCostError(w, cost)
+		return
+	}
+
+	// поток пишем прямо по ходу обхода, не собирая результат
+	if stream && query.GroupBy != "" {
+		writeError(w, http.StatusBadRequest, model.ErrBadGroupBy.Error())
+		return
+	}
+	if stream {
+		// поток длиннее WriteTimeout: дедлайн продлевается по ходу записи
+		sent := 0
+		writeNDJSON(s.rollingDeadline(w), func(emit func(model.User) bool) {
+			s.each(ctx, query, func(u model.User) bool {
+				sent++
+				return emit(u)
+			})
+		})
+		if trace := traceFrom(ctx); trace != nil {
+			trace.Returned = sent
+		}
+		return
+	}
+
+	snapshot := s.snapshotID()
+	if pinned := q.Get("snapshot"); pinned != "" && pinned != snapshot {
+		writeError(w, http.StatusConflict, model.ErrorSnapshotChanged)
+		return
+	}
+	w.Header().Set(model.SnapshotHeader, snapshot)
+	// If-None-Match с ETag прошлого ответа: датасет и синонимы те же - выдача та же, 304 без поиска.
+	// Агрегатор не знает версий нижестоящих серверов, а as_of отвечает не по текущей версии
+	if s.aggregator == nil && asOf == "" {
+		etag := s.searchETag(snapshot)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			s.counters.notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if query.GroupBy != "" {
+		s.searchGroups(ctx, w, query)
+		return
+	}
+
+	// версия влияет только на json: остальные форматы одинаковы во всех версиях
+	version := model.NegotiateVersion(r.Header.Get(model.VersionHeader))
+	w.Header().Set(model.VersionHeader, strconv.Itoa(version))
+	envelope := version >= model.WireV2 && codec.Format == model.FormatJSON
+
+	var cacheKey string
+	var generation uint64
+	if s.cache != nil && asOf == "" {
+		cacheKey = resultCacheKey(codec.Format, version, query)
+		if page, ok := s.cache.get(cacheKey); ok {
+			w.Header().Set("X-Cache", "HIT")
+			w.Header().Set("Age", seconds(time.Since(page.stored)))
+			writeEncoded(w, page.contentType, page.body)
+			return
+		}
+		if s.cfg.SLO.ShedCacheOnly && s.shedding() {
+			writeShed(w)
+			return
+		}
+		w.Header().Set("X-Cache", "MISS")
+		generation = s.cache.currentGeneration()
+	}
+
+	ctx, cancel := s.searchContext(ctx)
+	defer cancel()
+	limit := query.Limit
+	if envelope && limit > 0 {
+		// лишняя запись нужна только чтобы узнать, есть ли следующая страница
+		query.Limit++
+	}
+	users, partial, err := s.find(ctx, query)
+	if err == model.ErrSearchTimeout {
+		writeError(w, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	if err == model.ErrDownstream {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	nextPage := false
+	if envelope && limit > 0 && len(users) > limit {
+		users, nextPage = users[:limit], true
+	}
+
+	encodeStart := time.Now()
+	encode := func(out io.Writer) error {
+		if envelope {
+			return s.json().Encode(out, model.SearchResponseV2{Version: version, Users: users, NextPage: nextPage, Partial: partial})
+		}
+		return codec.Encode(out, users)
+	}
+	if partial {
+		w.Header().Set("X-Partial-Result", "true")
+	}
+	if cacheKey != "" && !partial {
+		// страница пойдёт в кэш, так что всё равно собираем её целиком - заодно с Content-Length
+		buf := getBuffer()
+		defer putBuffer(buf)
+		if err := encode(buf); err != nil {
+			http.Error(w, "data marshalling failed", http.StatusInternalServerError)
+			return
+		}
+		// буфер вернётся в пул, кэшу нужна своя копия
+		s.cache.put(generation, cachedPage{key: cacheKey, contentType: codec.ContentType, body: append([]byte(nil), buf.Bytes()...)})
+		writeEncoded(w, codec.ContentType, buf.Bytes())
+	} else {
+		// без кэша (и для неполной выдачи, которую не кэшируем) пишем прямо в ответ
+		w.Header().Set("Content-Type", codec.ContentType)
+		w.Header().Add("Vary", "Accept")
+		out := &countingWriter{w: w}
+		if err := encode(out); err != nil && out.n == 0 {
+			http.Error(w, "data marshalling failed", http.StatusInternalServerError)
+			return
+		}
+	}
+	if trace := traceFrom(ctx); trace != nil {
+		trace.Returned = len(users)
+		trace.Encode = time.Since(encodeStart)
+	}
+
+	// зеркалим только полностью посчитанные ответы: попадания в кэш и частичные выдачи сравнивать не с чем
+	// обезличенную выдачу тоже не с чем сравнивать
+	if s.mirror != nil && !partial && !query.Redact {
+		s.mirror.maybeMirror(q, users)
+	}
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request, id int) {
+	u, ok := s.lookupUser(id, requestScope(r))
+	if !ok {
+		writeError(w, http.StatusNotFound, model.ErrorUserNotFound)
+		return
+	}
+	s.writeUser(w, r, u)
+}
+
+// lookupUser ищет пользователя по Id, удалённые записи видны только администратору
+func (s *Server) lookupUser(id int, scope Scope) (model.User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.indexOf(id)
+	if i < 0 || s.users[i].Deleted && scope != ScopeAdmin {
+		return model.User{}, false
+	}
+	return s.users[i], true
+}
+
+// больше тело PUT и PATCH одной записи не читаем
+const maxUserBytes = 64 << 10
+
+// replaceUser полностью заменяет запись пользователя (PUT)
+func (s *Server) replaceUser(w http.ResponseWriter, r *http.Request, id int) {
+	var user model.User
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxUserBytes))
+	if err != nil {
+		writeError(w, bodyErrorStatus(err), err.Error())
+		return
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		writeError(w, http.StatusBadRequest, model.ErrorBadUser+": "+err.Error())
+		return
+	}
+
+	s.update(w, r, id, func(model.User) (model.User, error) {
+		return user, nil
+	})
+}
+
+// patchUser частично обновляет запись пользователя через JSON merge patch (RFC 7386)
+func (s *Server) patchUser(w http.ResponseWriter, r *http.Request, id int) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxUserBytes))
+	if err != nil {
+		writeError(w, bodyErrorStatus(err), err.Error())
+		return
+	}
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		writeError(w, http.StatusBadRequest, model.ErrorBadUser+": "+err.Error())
+		return
+	}
+
+	s.update(w, r, id, func(current model.User) (model.User, error) {
+		return mergePatchUser(current, patch)
+	})
+}
+
+// deleteUser мягко удаляет пользователя: запись остаётся до purge, но пропадает из поиска
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request, id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.lookupForUpdate(w, r, id)
+	if !ok {
+		return
+	}
+	user := s.users[i]
+	user.Deleted = true
+	user.Version++
+	if err := s.replaceAt(i, user); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purge окончательно удаляет все мягко удалённые записи
+func (s *Server) purge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	users := make([]model.User, 0, len(s.users))
+	for _, u := range s.users {
+		if !u.Deleted {
+			users = append(users, u)
+		}
+	}
+	purged := len(s.users) - len(users)
+	err := s.recordChange(replicationChange{Op: "purge"})
+	if err == nil {
+		s.setUsers(users)
+		s.emit(WebhookEvent{Type: EventUsersPurged, Rows: len(users)})
+	}
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PurgeResponse{Purged: purged})
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request, id int, apply func(model.User) (model.User, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.lookupForUpdate(w, r, id)
+	if !ok {
+		return
+	}
+
+	user, err := apply(s.users[i])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, model.ErrorBadUser+": "+err.Error())
+		return
+	}
+	user.Id = id
+	user.Deleted = false
+	user.Version = s.users[i].Version + 1
+	if err := validateUser(user); err != nil {
+		writeError(w, http.StatusBadRequest, model.ErrorBadUser+": "+err.Error())
+		return
+	}
+
+	if err := s.replaceAt(i, user); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.writeUser(w, r, user)
+}
+
+// lookupForUpdate ищет живую запись и проверяет If-Match, при ошибке сам пишет ответ
+func (s *Server) lookupForUpdate(w http.ResponseWriter, r *http.Request, id int) (int, bool) {
+	i := s.indexOf(id)
+	if i < 0 || s.users[i].Deleted {
+		writeError(w, http.StatusNotFound, model.ErrorUserNotFound)
+		return 0, false
+	}
+	if match := r.Header.Get("If-Match"); match != "" && !etagMatches(match, userETag(s.users[i])) {
+		writeError(w, http.StatusConflict, model.ErrorVersionMismatch)
+		return 0, false
+	}
+	return i, true
+}
+
+// snapshot возвращает текущий срез пользователей. Срез не меняется на месте:
+// все мутации подменяют его копией, поэтому читать его можно без блокировки
+func (s *Server) snapshot() []model.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.users
+}
+
+// replaceAt подменяет запись копированием среза, вызывается под s.mu
+func (s *Server) replaceAt(i int, u model.User) error {
+	if err := s.recordChange(replicationChange{Op: "put", User: u}); err != nil {
+		return err
+	}
+	users := make([]model.User, len(s.users))
+	copy(users, s.users)
+	users[i] = u
+	s.setUsers(users)
+	if u.Deleted {
+		s.emit(WebhookEvent{Type: EventUserDeleted, User: &u})
+	} else {
+		s.emit(WebhookEvent{Type: EventUserUpdated, User: &u})
+	}
+	return nil
+}
+
+// versionUsers выдаёт записям без версии версию 1, users не меняется
+func versionUsers(users []model.User) []model.User {
+	for i := range users {
+		if users[i].Version == 0 {
+			versioned := make([]model.User, len(users))
+			copy(versioned, users)
+			for j := i; j < len(versioned); j++ {
+				if versioned[j].Version == 0 {
+					versioned[j].Version = 1
+				}
+			}
+			return versioned
+		}
+	}
+	return users
+}
+
+// setUsers - единственное место, где меняется датасет, вызывается под s.mu
+func (s *Server) setUsers(users []model.User) {
+	users = versionUsers(users)
+	if s.cfg.CompactAbout {
+		users = packAbout(users)
+	}
+	s.users = users
+	s.changedAt.Store(time.Now().UnixNano())
+	s.core.SetUsers(users)
+	s.history.record(users, s.snapshotIDLocked(), time.Now())
+	if s.cache != nil {
+		s.cache.invalidate()
+	}
+}
+
+func (s *Server) indexOf(id int) int {
+	for i, u := range s.users {
+		if u.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func requestScope(r *http.Request) Scope {
+	return scopeFrom(r.Context())
+}
+
+// scopeFrom достаёт права токена, выставленные в ServeHTTP
+func scopeFrom(ctx context.Context) Scope {
+	scope, _ := ctx.Value(scopeKey{}).(Scope)
+	return scope
+}
+
+// requestPriority - приоритет токена, выставленный в ServeHTTP
+func requestPriority(r *http.Request) Priority {
+	priority, _ := r.Context().Value(priorityKey{}).(Priority)
+	return priority
+}
+
+// userETag - сильный ETag по версии записи
+func userETag(u model.User) string {
+	return `"` + strconv.Itoa(u.Version) + `"`
+}
+
+// searchETag - слабый ETag выдачи поиска по версии датасета snapshot и поколению синонимов.
+// Слабый: выдача одна и та же, но кодироваться может по-разному (format, X-Search-Version)
+func (s *Server) searchETag(snapshot string) string {
+	return `W/"` + snapshot + "." + strconv.FormatUint(s.synonymsEpoch.Load(), 10) + `"`
+}
+
+// etagMatches проверяет If-None-Match: список ETag через запятую или "*", слабое сравнение
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeUser отдаёт запись с ETag, посчитанным по настоящим данным, даже если сама запись обезличена
+func (s *Server) writeUser(w http.ResponseWriter, r *http.Request, u model.User) {
+	w.Header().Set("ETag", userETag(u))
+	if s.redacts(requestScope(r)) {
+		u = s.redactUser(u)
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func writeEncoded(w http.ResponseWriter, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		http.Error(w, "data marshalling failed", http.StatusInternalServerError)
+		return
+	}
+	// Encoder дописывает перевод строки, json.Marshal - нет
+	buf.Truncate(buf.Len() - 1)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, model.SearchErrorResponse{Error: msg})
+}
+
+// bodyErrorStatus - код ответа на ошибку чтения тела через http.MaxBytesReader: 413, только если
+// тело больше лимита, обрыв соединения и прочие ошибки чтения - 400
+func bodyErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
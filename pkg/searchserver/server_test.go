@@ -0,0 +1,597 @@
+package searchserver
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchcore"
+)
+
+const (
+	accessToken = "abc-def"
+	adminToken  = "admin-token"
+)
+
+var testServerConfig = ServerConfig{
+	Tokens: map[string]Scope{
+		accessToken: ScopeSearch,
+		adminToken:  ScopeAdmin,
+	},
+}
+
+func newTestHandler() *Server {
+	users, err := LoadDataset("../../dataset.xml")
+	if err != nil {
+		panic(err)
+	}
+	return NewServer(users, testServerConfig)
+}
+
+// adminHeader - запрос от токена с ScopeAdmin, правки пользователей требуют его
+var adminHeader = map[string]string{"AccessToken": adminToken}
+
+func doRequest(h http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("AccessToken", accessToken)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decodeUser(t *testing.T, w *httptest.ResponseRecorder) model.User {
+	u := model.User{}
+	if err := json.Unmarshal(w.Body.Bytes(), &u); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	return u
+}
+
+func TestGetUser(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodGet, "/users/0", "", nil)
+
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+		t.Fatalf("Error : %v %v", w.Code, w.Body.String())
+	}
+	if u := decodeUser(t, w); u.Name != "Boyd Wolf" {
+		t.Errorf("Error : unexpected user %v", u)
+	}
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodGet, "/users/1000", "", nil)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Error : %v", w.Code)
+	}
+}
+
+func TestPutUser(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodPut, "/users/0",
+		`{"Id": 5, "Name": "John Doe", "Age": 30, "About": "", "Gender": "male"}`, adminHeader)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error : %v %v", w.Code, w.Body.String())
+	}
+	u := decodeUser(t, doRequest(h, http.MethodGet, "/users/0", "", nil))
+	if u.Id != 0 || u.Name != "John Doe" || u.Age != 30 || u.About != "" {
+		t.Errorf("Error : unexpected user %v", u)
+	}
+}
+
+func TestPutUserValidation(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodPut, "/users/0", `{"Name": "", "Age": 30, "Gender": "male"}`, adminHeader)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Error : %v", w.Code)
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodPatch, "/users/0", `{"Age": 40, "About": null}`, adminHeader)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error : %v %v", w.Code, w.Body.String())
+	}
+	u := decodeUser(t, w)
+	if u.Name != "Boyd Wolf" || u.Age != 40 || u.About != "" {
+		t.Errorf("Error : unexpected user %v", u)
+	}
+}
+
+func TestPatchUserNotObject(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodPatch, "/users/0", `[1, 2]`, adminHeader)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Error : %v", w.Code)
+	}
+}
+
+func TestUpdateUserBodyTooLarge(t *testing.T) {
+	h := newTestHandler()
+	about := strings.Repeat("a", maxUserBytes)
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		w := doRequest(h, method, "/users/0", `{"Name": "John Doe", "Age": 30, "Gender": "male", "About": "`+about+`"}`, adminHeader)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Error : %v: %v", method, w.Code)
+		}
+	}
+}
+
+// errReader - тело запроса, которое обрывается на чтении
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func TestUpdateUserBodyReadError(t *testing.T) {
+	h := newTestHandler()
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		r := httptest.NewRequest(method, "/users/0", errReader{})
+		r.Header.Set("AccessToken", adminToken)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Error : %v: %v", method, w.Code)
+		}
+	}
+}
+
+func TestUpdateUserAdminOnly(t *testing.T) {
+	h := newTestHandler()
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		w := doRequest(h, method, "/users/0", `{"Name": "John Doe", "Age": 30, "Gender": "male"}`, nil)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), model.ErrorAdminOnly) {
+			t.Errorf("Error : %v allowed for search token, %v", method, w.Code)
+		}
+	}
+	if u := decodeUser(t, doRequest(h, http.MethodGet, "/users/0", "", nil)); u.Name != "Boyd Wolf" || u.Version != 1 {
+		t.Errorf("Error : user changed %v", u)
+	}
+}
+
+func TestUpdateIfMatch(t *testing.T) {
+	h := newTestHandler()
+	etag := doRequest(h, http.MethodGet, "/users/0", "", nil).Header().Get("ETag")
+
+	w := doRequest(h, http.MethodPatch, "/users/0", `{"Age": 41, "Version": 7}`, map[string]string{"If-Match": etag, "AccessToken": adminToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error : %v %v", w.Code, w.Body.String())
+	}
+	// версию ведёт сервер, присланная в теле игнорируется
+	if u := decodeUser(t, w); etag != `"1"` || u.Version != 2 || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("Error : unexpected version %v, ETag %s -> %s", u.Version, etag, w.Header().Get("ETag"))
+	}
+
+	w = doRequest(h, http.MethodPatch, "/users/0", `{"Age": 42}`, map[string]string{"If-Match": etag, "AccessToken": adminToken})
+	if w.Code != http.StatusConflict {
+		t.Errorf("Error : stale ETag accepted, %v", w.Code)
+	}
+
+	// список ETag, слабые и * сравниваются так же, как в If-None-Match; версия растёт с каждой правкой
+	for _, match := range []string{`"1", "2"`, `W/"3"`, `*`} {
+		w = doRequest(h, http.MethodPatch, "/users/0", `{"Age": 42}`, map[string]string{"If-Match": match, "AccessToken": adminToken})
+		if w.Code != http.StatusOK {
+			t.Errorf("Error : If-Match %s rejected, %v", match, w.Code)
+		}
+	}
+}
+
+func TestSearchSnapshot(t *testing.T) {
+	h := newTestHandler()
+	snapshot := doRequest(h, http.MethodGet, "/?limit=5", "", nil).Header().Get(model.SnapshotHeader)
+	if snapshot == "" {
+		t.Fatalf("Error : no %s header", model.SnapshotHeader)
+	}
+
+	if w := doRequest(h, http.MethodGet, "/?limit=5&offset=5&snapshot="+snapshot, "", nil); w.Code != http.StatusOK {
+		t.Errorf("Error : same snapshot rejected, %v", w.Code)
+	}
+
+	doRequest(h, http.MethodPatch, "/users/0", `{"Age": 41}`, adminHeader)
+	w := doRequest(h, http.MethodGet, "/?limit=5&offset=5&snapshot="+snapshot, "", nil)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), model.ErrorSnapshotChanged) {
+		t.Errorf("Error : changed snapshot accepted, %v %s", w.Code, w.Body.String())
+	}
+	if doRequest(h, http.MethodGet, "/?limit=5", "", nil).Header().Get(model.SnapshotHeader) == snapshot {
+		t.Errorf("Error : snapshot did not change after update")
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodDelete, "/users/0", "", adminHeader)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Error : %v %v", w.Code, w.Body.String())
+	}
+
+	if w = doRequest(h, http.MethodGet, "/users/0", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Error : deleted user is visible, %v", w.Code)
+	}
+	if w = doRequest(h, http.MethodDelete, "/users/0", "", adminHeader); w.Code != http.StatusNotFound {
+		t.Errorf("Error : deleted twice, %v", w.Code)
+	}
+
+	var users []model.User
+	w = doRequest(h, http.MethodGet, "/?query=Boyd", "", nil)
+	json.Unmarshal(w.Body.Bytes(), &users)
+	if len(users) != 0 {
+		t.Errorf("Error : deleted user found, %v", users)
+	}
+}
+
+func TestSearchIncludeDeleted(t *testing.T) {
+	h := newTestHandler()
+	doRequest(h, http.MethodDelete, "/users/0", "", adminHeader)
+
+	w := doRequest(h, http.MethodGet, "/?query=Boyd&include_deleted=true", "", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Error : include_deleted allowed for search token, %v", w.Code)
+	}
+
+	var users []model.User
+	w = doRequest(h, http.MethodGet, "/?query=Boyd&include_deleted=true", "", map[string]string{"AccessToken": adminToken})
+	json.Unmarshal(w.Body.Bytes(), &users)
+	if len(users) != 1 || !users[0].Deleted {
+		t.Errorf("Error : unexpected users %v", users)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	h := newTestHandler()
+	doRequest(h, http.MethodDelete, "/users/0", "", adminHeader)
+	doRequest(h, http.MethodDelete, "/users/1", "", adminHeader)
+
+	if w := doRequest(h, http.MethodPost, "/admin/purge", "", nil); w.Code != http.StatusForbidden {
+		t.Errorf("Error : purge allowed for search token, %v", w.Code)
+	}
+
+	w := doRequest(h, http.MethodPost, "/admin/purge", "", map[string]string{"AccessToken": adminToken})
+	resp := PurgeResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Purged != 2 {
+		t.Errorf("Error : purged %v", resp.Purged)
+	}
+
+	w = doRequest(h, http.MethodGet, "/users/0", "", map[string]string{"AccessToken": adminToken})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Error : purged user is visible, %v", w.Code)
+	}
+}
+
+func TestSearchCSV(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodGet, "/?query=Boyd", "", map[string]string{"Accept": "text/csv"})
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Fatalf("Error : content type %v", ct)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if len(records) != 2 || strings.Join(records[0], ",") != "Id,Name,Age,About,Gender,Email,Phone,Company,Address" ||
+		records[1][1] != "Boyd Wolf" || records[1][4] != "male" || records[1][7] != "HOPELI" {
+		t.Errorf("Error : unexpected csv %v", records)
+	}
+}
+
+func TestSearchFormatParam(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodGet, "/?format=xml", "", map[string]string{"Accept": "text/csv"})
+	if ct := w.Header().Get("Content-Type"); ct != "application/xml" {
+		t.Errorf("Error : content type %v", ct)
+	}
+
+	w = doRequest(h, http.MethodGet, "/?format=yaml", "", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Error : %v", w.Code)
+	}
+}
+
+func TestSearchNotAcceptable(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodGet, "/", "", map[string]string{"Accept": "image/png, application/json;q=0"})
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Error : %v", w.Code)
+	}
+
+	w = doRequest(h, http.MethodGet, "/", "", map[string]string{"Accept": "text/csv;q=0.5, application/xml"})
+	if ct := w.Header().Get("Content-Type"); ct != "application/xml" {
+		t.Errorf("Error : content type %v", ct)
+	}
+}
+
+func TestSearchNegativeOffset(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodGet, "/?limit=5&offset=-1", "", nil)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "ErrorBadOffset") {
+		t.Errorf("Error : %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestSearchLimits(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	h := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, DefaultLimit: 5, MaxLimit: 10})
+
+	count := func(params string) int {
+		w := doRequest(h, http.MethodGet, "/?"+params, "", nil)
+		found := []model.User{}
+		if err := json.Unmarshal(w.Body.Bytes(), &found); err != nil {
+			t.Fatalf("Error : %v %s", err, w.Body.String())
+		}
+		return len(found)
+	}
+	if n := count(""); n != 5 {
+		t.Errorf("Error : default limit not applied, %d users", n)
+	}
+	if n := count("limit=10"); n != 10 {
+		t.Errorf("Error : %d users", n)
+	}
+	// поток лимиты не ограничивают
+	if w := doRequest(h, http.MethodGet, "/?stream=true", "", nil); strings.Count(w.Body.String(), "\n") != len(users) {
+		t.Errorf("Error : stream limited %q", w.Body.String())
+	}
+
+	w := doRequest(h, http.MethodGet, "/?limit=1000000", "", nil)
+	errResp := model.SearchErrorResponse{}
+	json.Unmarshal(w.Body.Bytes(), &errResp)
+	if w.Code != http.StatusBadRequest || errResp.Error != model.ErrorLimitTooLarge || errResp.MaxLimit != 10 {
+		t.Errorf("Error : %v %s", w.Code, w.Body.String())
+	}
+	if _, err := h.findPage(context.Background(), searchcore.Query{Limit: 11}); err != model.ErrLimitTooLarge {
+		t.Errorf("Error : unexpected error %v", err)
+	}
+
+	// без DefaultLimit страница по умолчанию - MaxLimit
+	h = NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, MaxLimit: 10})
+	if n := count(""); n != 10 {
+		t.Errorf("Error : max limit not applied, %d users", n)
+	}
+}
+
+func TestSearchMaxOffset(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	h := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, MaxOffset: 20})
+
+	if w := doRequest(h, http.MethodGet, "/?limit=5&offset=20", "", nil); w.Code != http.StatusOK {
+		t.Errorf("Error : %v %s", w.Code, w.Body.String())
+	}
+	for _, params := range []string{"limit=5&offset=21", "stream=true&limit=5&offset=21"} {
+		w := doRequest(h, http.MethodGet, "/?"+params, "", nil)
+		errResp := model.SearchErrorResponse{}
+		json.Unmarshal(w.Body.Bytes(), &errResp)
+		if w.Code != http.StatusBadRequest || errResp.Error != model.ErrorOffsetTooLarge || errResp.MaxOffset != 20 || errResp.Hint == "" {
+			t.Errorf("Error : %s: %v %s", params, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSearchKeyset(t *testing.T) {
+	h := newTestHandler()
+
+	var all []model.User
+	json.Unmarshal(doRequest(h, http.MethodGet, "/?order_field=Age&order_by=-1", "", nil).Body.Bytes(), &all)
+	last := all[9]
+	params := fmt.Sprintf("/?order_field=Age&order_by=-1&limit=5&after_id=%d&after_value=%d", last.Id, last.Age)
+	var page []model.User
+	json.Unmarshal(doRequest(h, http.MethodGet, params, "", nil).Body.Bytes(), &page)
+	if len(page) != 5 || page[0] != all[10] || page[4] != all[14] {
+		t.Errorf("Error : unexpected page %v", page)
+	}
+
+	w := doRequest(h, http.MethodGet, "/?order_field=Age&order_by=-1&limit=5&after_id=abc", "", nil)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), model.ErrBadCursor.Error()) {
+		t.Errorf("Error : %v %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHTTPServerDefaults(t *testing.T) {
+	srv := newTestHandler().HTTPServer(":8080")
+	if srv.Addr != ":8080" || srv.Handler == nil {
+		t.Errorf("Error : unexpected server %+v", srv)
+	}
+	if srv.ReadHeaderTimeout != defaultReadHeaderTimeout || srv.ReadTimeout != defaultReadTimeout ||
+		srv.WriteTimeout != defaultWriteTimeout || srv.IdleTimeout != defaultIdleTimeout ||
+		srv.MaxHeaderBytes != defaultMaxHeaderBytes {
+		t.Errorf("Error : unexpected defaults %+v", srv)
+	}
+}
+
+func TestHTTPServerConfig(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{
+		Tokens:            testServerConfig.Tokens,
+		ReadHeaderTimeout: time.Second,
+		WriteTimeout:      2 * time.Second,
+		MaxHeaderBytes:    1024,
+	})
+	srv := s.HTTPServer("")
+	if srv.ReadHeaderTimeout != time.Second || srv.WriteTimeout != 2*time.Second || srv.MaxHeaderBytes != 1024 {
+		t.Errorf("Error : config ignored %+v", srv)
+	}
+	if srv.ReadTimeout != defaultReadTimeout || srv.IdleTimeout != defaultIdleTimeout {
+		t.Errorf("Error : unexpected defaults %+v", srv)
+	}
+}
+
+// медленный клиент, не дославший заголовки, отключается по ReadHeaderTimeout
+func TestHTTPServerSlowHeaders(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, ReadHeaderTimeout: 50 * time.Millisecond})
+	srv := s.HTTPServer("")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n"))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Errorf("Error : connection was not closed: %v", err)
+	}
+}
+
+// без заголовка версии ответ остаётся голым массивом: на этом держатся все старые клиенты
+func TestSearchWireV1(t *testing.T) {
+	h := newTestHandler()
+
+	w := doRequest(h, http.MethodGet, "/?limit=2", "", nil)
+	if v := w.Header().Get(model.VersionHeader); v != "1" {
+		t.Errorf("Error : version %q", v)
+	}
+	users := []model.User{}
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil || len(users) != 2 {
+		t.Errorf("Error : %v %s", err, w.Body.String())
+	}
+}
+
+func TestSearchWireV2(t *testing.T) {
+	users := make([]model.User, 10)
+	for i := range users {
+		users[i].Id = i
+	}
+	h := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, CacheSize: 10})
+
+	for _, c := range []struct {
+		params   string
+		count    int
+		nextPage bool
+	}{
+		{"limit=3", 3, true},
+		{"limit=3&offset=7", 3, false},
+		{"limit=5&offset=8", 2, false},
+		{"", 10, false},
+	} {
+		w := doRequest(h, http.MethodGet, "/?"+c.params, "", map[string]string{model.VersionHeader: "7"})
+		if v := w.Header().Get(model.VersionHeader); v != "2" {
+			t.Errorf("Error : version %q", v)
+		}
+		resp := model.SearchResponseV2{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Error : %v %s", err, w.Body.String())
+		}
+		if resp.Version != model.WireV2 || len(resp.Users) != c.count || resp.NextPage != c.nextPage {
+			t.Errorf("Error : %s: unexpected %+v", c.params, resp)
+		}
+	}
+
+	// версии кэшируются раздельно
+	w := doRequest(h, http.MethodGet, "/?limit=3", "", nil)
+	if w.Header().Get("X-Cache") != "MISS" || !strings.HasPrefix(w.Body.String(), "[") {
+		t.Errorf("Error : %s %s", w.Header().Get("X-Cache"), w.Body.String())
+	}
+}
+
+// версия меняет только json, остальные форматы одинаковы
+func TestSearchWireV2XML(t *testing.T) {
+	h := newTestHandler()
+
+	v1 := doRequest(h, http.MethodGet, "/?format=xml&limit=2", "", nil)
+	v2 := doRequest(h, http.MethodGet, "/?format=xml&limit=2", "", map[string]string{model.VersionHeader: "2"})
+	if v1.Body.String() != v2.Body.String() {
+		t.Errorf("Error : %s != %s", v1.Body.String(), v2.Body.String())
+	}
+}
+
+// запросы, пришедшие после Close, не роняют процесс на закрытом пуле фильтрации
+func TestSearchAfterClose(t *testing.T) {
+	users := make([]model.User, 10000)
+	for i := range users {
+		users[i] = model.User{Id: i, Name: fmt.Sprintf("User %d", i), About: "nisi", Age: 20 + i%40}
+	}
+	cfg := testServerConfig
+	cfg.SearchParallelism = 4
+	h := NewServer(users, cfg)
+	h.Close()
+
+	w := doRequest(h, "GET", "/?query=nisi&limit=5", "", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("Error : %v %v", w.Code, w.Body.String())
+	}
+}
+
+// поток дольше WriteTimeout не обрывается, пока клиент читает
+func TestStreamLongerThanWriteTimeout(t *testing.T) {
+	users := make([]model.User, 20000)
+	for i := range users {
+		users[i] = model.User{Id: i, Name: fmt.Sprintf("User %d", i), About: strings.Repeat("lorem ipsum ", 80)}
+	}
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, WriteTimeout: 200 * time.Millisecond})
+	srv := s.HTTPServer("")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	for _, target := range []string{"/?stream=true", "/changes"} {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+target, nil)
+		req.Header.Set("AccessToken", accessToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error : %v", err)
+		}
+		// читаем медленно: весь ответ идёт заметно дольше WriteTimeout
+		start := time.Now()
+		buf := make([]byte, 256<<10)
+		total := 0
+		for {
+			n, err := resp.Body.Read(buf)
+			total += n
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Error : %v: cut after %d bytes, %v: %v", target, total, time.Since(start), err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		resp.Body.Close()
+		if time.Since(start) < 200*time.Millisecond || total < 16<<20 {
+			t.Errorf("Error : %v: %d bytes in %v, test is too fast", target, total, time.Since(start))
+		}
+	}
+}
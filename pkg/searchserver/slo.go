@@ -0,0 +1,159 @@
+package searchserver
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+const (
+	defaultSLOPercentile = 99
+	defaultSLOWindow     = 1000
+	// сколько замеров проходит между пересчётами перцентиля, в долях окна
+	sloCheckDivisor = 10
+)
+
+// SLOConfig - цель по времени ответа поиска и что отключать, пока она не выполняется.
+// Перцентиль считается по последним Window поискам. Когда он выше Target, сервер
+// переходит в режим сброса нагрузки и выходит из него, когда перцентиль опускается до Recover
+type SLOConfig struct {
+	// цель по времени ответа, 0 - режим сброса нагрузки выключен
+	Target time.Duration
+	// какой перцентиль сравнивается с Target, 0 - 99
+	Percentile int
+	// по скольким последним поискам считается перцентиль, 0 - defaultSLOWindow
+	Window int
+	// при каком перцентиле сброс нагрузки выключается, 0 - Target
+	Recover time.Duration
+
+	// меры на время сброса нагрузки, каждая включается отдельно:
+	// наибольший limit поиска, 0 - обычный MaxLimit
+	ShedMaxLimit int
+	// отвечать на поиск только из кэша страниц, промах - 503. Без кэша не действует
+	ShedCacheOnly bool
+	// токены, поиски которых получают 503, вдобавок к токенам с PriorityLow
+	ShedTokens []string
+}
+
+// sloTracker копит время ответа поиска и включает режим сброса нагрузки
+type sloTracker struct {
+	cfg        SLOConfig
+	shedTokens map[string]bool
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	// замеров с последнего пересчёта
+	pending int
+	current time.Duration
+
+	shedding atomic.Bool
+	// сколько раз сервер входил в режим сброса нагрузки
+	episodes uint64
+}
+
+// SLOStatus - состояние SLO в /admin/stats
+type SLOStatus struct {
+	Shedding bool
+	// перцентиль на последнем пересчёте и цель, в миллисекундах
+	Current  float64
+	Target   float64
+	Episodes uint64
+}
+
+func newSLOTracker(cfg SLOConfig) *sloTracker {
+	if cfg.Percentile <= 0 || cfg.Percentile > 100 {
+		cfg.Percentile = defaultSLOPercentile
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultSLOWindow
+	}
+	if cfg.Recover <= 0 || cfg.Recover > cfg.Target {
+		cfg.Recover = cfg.Target
+	}
+	t := &sloTracker{cfg: cfg, shedTokens: map[string]bool{}}
+	for _, token := range cfg.ShedTokens {
+		t.shedTokens[token] = true
+	}
+	return t
+}
+
+// observe добавляет замер и время от времени пересчитывает перцентиль. Режим переключается
+// только на пересчёте, так что один медленный запрос его не дёргает
+func (t *sloTracker) observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples) < t.cfg.Window {
+		t.samples = append(t.samples, d)
+	} else {
+		t.samples[t.next] = d
+		t.next = (t.next + 1) % t.cfg.Window
+	}
+	t.pending++
+	if t.pending < t.cfg.Window/sloCheckDivisor {
+		return
+	}
+	t.pending = 0
+
+	sorted := append([]time.Duration(nil), t.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	t.current = percentile(sorted, t.cfg.Percentile)
+	switch {
+	case !t.shedding.Load() && t.current > t.cfg.Target:
+		t.shedding.Store(true)
+		t.episodes++
+	case t.shedding.Load() && t.current <= t.cfg.Recover:
+		t.shedding.Store(false)
+	}
+}
+
+func (t *sloTracker) status() SLOStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return SLOStatus{
+		Shedding: t.shedding.Load(),
+		Current:  milliseconds(t.current),
+		Target:   milliseconds(t.cfg.Target),
+		Episodes: t.episodes,
+	}
+}
+
+// shedding сообщает, что сервер сбрасывает нагрузку
+func (s *Server) shedding() bool {
+	return s.slo != nil && s.slo.shedding.Load()
+}
+
+// SLOStatus возвращает состояние SLO, без цели в конфиге - нулевое
+func (s *Server) SLOStatus() SLOStatus {
+	if s.slo == nil {
+		return SLOStatus{}
+	}
+	return s.slo.status()
+}
+
+// withSLO замеряет время ответа поиска, а в режиме сброса нагрузки сразу отказывает
+// токенам PriorityLow и SLOConfig.ShedTokens. Отказы в замеры не попадают
+func (s *Server) withSLO(h http.HandlerFunc) http.HandlerFunc {
+	if s.slo == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.shedding() && (requestPriority(r) == PriorityLow || s.slo.shedTokens[r.Header.Get("AccessToken")]) {
+			writeShed(w)
+			return
+		}
+		start := time.Now()
+		h(w, r)
+		s.slo.observe(time.Since(start))
+	}
+}
+
+// writeShed отвечает 503 на запрос, отброшенный сбросом нагрузки
+func writeShed(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	writeError(w, http.StatusServiceUnavailable, model.ErrorOverloaded)
+}
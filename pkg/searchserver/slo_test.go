@@ -0,0 +1,98 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestSLOShedding(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	const lowToken = "low-priority"
+	s := NewServer(users, ServerConfig{
+		Tokens:    map[string]Scope{accessToken: ScopeSearch, adminToken: ScopeAdmin, lowToken: ScopeSearch},
+		CacheSize: 10,
+		MaxLimit:  20,
+		SLO: SLOConfig{
+			Target:        time.Second,
+			Window:        10,
+			ShedMaxLimit:  5,
+			ShedCacheOnly: true,
+			ShedTokens:    []string{lowToken},
+		},
+	})
+	low := map[string]string{"AccessToken": lowToken}
+
+	// страница в кэше до перегрузки
+	if rec := doRequest(s, "GET", "/?limit=3", "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d", rec.Code)
+	}
+	if rec := doRequest(s, "GET", "/?limit=10", "", low); rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d", rec.Code)
+	}
+
+	for i := 0; i < 10; i++ {
+		s.slo.observe(2 * time.Second)
+	}
+	if !s.shedding() {
+		t.Fatalf("Error : no shedding over SLO, %+v", s.SLOStatus())
+	}
+
+	if rec := doRequest(s, "GET", "/?limit=3", "", low); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Error : low priority token not shed, status %d", rec.Code)
+	}
+	rec := doRequest(s, "GET", "/?limit=10", "", nil)
+	resp := model.SearchErrorResponse{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusBadRequest || resp.MaxLimit != 5 {
+		t.Errorf("Error : shed max limit not applied, status %d, %+v", rec.Code, resp)
+	}
+	if rec := doRequest(s, "GET", "/?limit=3", "", nil); rec.Code != http.StatusOK || rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Error : cached page not served, status %d", rec.Code)
+	}
+	if rec := doRequest(s, "GET", "/?limit=4", "", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Error : cache miss served while shedding, status %d", rec.Code)
+	}
+
+	rec = doRequest(s, "GET", "/admin/stats", "", map[string]string{"AccessToken": adminToken})
+	stats := StatsResponse{}
+	json.Unmarshal(rec.Body.Bytes(), &stats)
+	if stats.SLO == nil || !stats.SLO.Shedding || stats.SLO.Episodes != 1 {
+		t.Errorf("Error : unexpected SLO stats %+v", stats.SLO)
+	}
+
+	// время ответа вернулось в норму - меры снимаются
+	for i := 0; i < 10; i++ {
+		s.slo.observe(time.Millisecond)
+	}
+	if s.shedding() {
+		t.Fatalf("Error : shedding after recovery, %+v", s.SLOStatus())
+	}
+	if rec := doRequest(s, "GET", "/?limit=10", "", low); rec.Code != http.StatusOK {
+		t.Errorf("Error : unexpected status %d after recovery", rec.Code)
+	}
+}
+
+func TestSLORollingPercentile(t *testing.T) {
+	slo := newSLOTracker(SLOConfig{Target: 100 * time.Millisecond, Percentile: 90, Window: 100})
+	// один медленный запрос из двадцати не выводит p90 за цель
+	for i := 0; i < 100; i++ {
+		d := time.Millisecond
+		if i%20 == 0 {
+			d = time.Second
+		}
+		slo.observe(d)
+	}
+	if slo.shedding.Load() {
+		t.Errorf("Error : shedding on rare slow requests, %+v", slo.status())
+	}
+	for i := 0; i < 20; i++ {
+		slo.observe(time.Second)
+	}
+	if !slo.shedding.Load() {
+		t.Errorf("Error : no shedding, %+v", slo.status())
+	}
+}
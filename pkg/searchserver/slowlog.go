@@ -0,0 +1,98 @@
+package searchserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/searchcore"
+)
+
+// queryTrace собирает счётчики и время фаз одного поиска для журнала медленных запросов
+type queryTrace struct {
+	// как обходился датасет: searchcore.AccessScan или индекс фильтра
+	Access   string
+	Scanned  int
+	Matched  int
+	Returned int
+
+	Parse  time.Duration
+	Sort   time.Duration
+	Filter time.Duration
+	Encode time.Duration
+}
+
+type traceKey struct{}
+
+func withTrace(ctx context.Context, t *queryTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// traceFrom возвращает трассировку запроса, если её кто-то собирает
+func traceFrom(ctx context.Context) *queryTrace {
+	t, _ := ctx.Value(traceKey{}).(*queryTrace)
+	return t
+}
+
+// slowQueryEntry - одна строка журнала медленных запросов (JSON lines)
+type slowQueryEntry struct {
+	Time     time.Time          `json:"time"`
+	Duration float64            `json:"duration_ms"`
+	Params   map[string]string  `json:"params"`
+	Access   string             `json:"access"`
+	Scanned  int                `json:"scanned"`
+	Matched  int                `json:"matched"`
+	Returned int                `json:"returned"`
+	Phases   map[string]float64 `json:"phases_ms"`
+}
+
+type slowQueryLog struct {
+	threshold time.Duration
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newSlowQueryLog(threshold time.Duration, w io.Writer) *slowQueryLog {
+	if w == nil {
+		w = os.Stderr
+	}
+	return &slowQueryLog{threshold: threshold, w: w}
+}
+
+// record пишет запрос в журнал, если он выполнялся дольше порога
+func (l *slowQueryLog) record(q searchcore.Query, t *queryTrace, elapsed time.Duration) {
+	if elapsed < l.threshold {
+		return
+	}
+	entry := slowQueryEntry{
+		Time:     time.Now(),
+		Duration: milliseconds(elapsed),
+		Params:   q.Params(),
+		Access:   t.Access,
+		Scanned:  t.Scanned,
+		Matched:  t.Matched,
+		Returned: t.Returned,
+		Phases: map[string]float64{
+			"parse":  milliseconds(t.Parse),
+			"sort":   milliseconds(t.Sort),
+			"filter": milliseconds(t.Filter),
+			"encode": milliseconds(t.Encode),
+		},
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(line, '\n'))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,64 @@
+package searchserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	var buf bytes.Buffer
+	// порог в 1нс - в журнал попадает любой поиск
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, SlowQueryThreshold: time.Nanosecond, SlowQueryLog: &buf})
+
+	doRequest(s, "GET", "/?query=nisi&limit=3&offset=1&order_field=Age&order_by=1", "", nil)
+
+	var entry slowQueryEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Error : %v: %s", err, buf.String())
+	}
+	if entry.Params["query"] != "nisi" || entry.Params["limit"] != "3" || entry.Params["order_field"] != "Age" {
+		t.Errorf("Error : unexpected params %v", entry.Params)
+	}
+	if entry.Returned != 3 || entry.Matched != 4 || entry.Scanned < entry.Matched {
+		t.Errorf("Error : unexpected counts %+v", entry)
+	}
+	for _, phase := range []string{"parse", "sort", "filter", "encode"} {
+		if _, ok := entry.Phases[phase]; !ok {
+			t.Errorf("Error : no phase %s", phase)
+		}
+	}
+}
+
+func TestSlowQueryLogThreshold(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	var buf bytes.Buffer
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, SlowQueryThreshold: time.Hour, SlowQueryLog: &buf})
+
+	doRequest(s, "GET", "/?limit=3", "", nil)
+	if buf.Len() != 0 {
+		t.Errorf("Error : fast query logged: %s", buf.String())
+	}
+}
+
+func TestSlowQueryLogStream(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	var buf bytes.Buffer
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, SlowQueryThreshold: time.Nanosecond, SlowQueryLog: &buf})
+
+	doRequest(s, "GET", "/?stream=true&limit=5", "", nil)
+	doRequest(s, "GET", "/?limit=2", "", nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Error : unexpected log %s", buf.String())
+	}
+	var entry slowQueryEntry
+	json.Unmarshal([]byte(lines[0]), &entry)
+	if entry.Returned != 5 {
+		t.Errorf("Error : unexpected entry %+v", entry)
+	}
+}
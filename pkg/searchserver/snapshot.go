@@ -0,0 +1,155 @@
+package searchserver
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// версия формата снимка, меняется вместе с model.User или FieldMapping
+const snapshotVersion = 2
+
+// snapshotLayout - отпечаток полей снимка вместе с model.User. gob молча пропускает
+// недостающие поля, так что снимок со старой раскладкой отбрасывается, даже если
+// snapshotVersion забыли поднять
+var snapshotLayout = typeLayout(reflect.TypeOf(datasetSnapshot{}))
+
+// typeLayout хэширует имена и типы полей t, заходя в структуры этого модуля
+func typeLayout(t reflect.Type) string {
+	var b strings.Builder
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		switch {
+		case t.Kind() == reflect.Slice || t.Kind() == reflect.Ptr:
+			b.WriteString(t.Kind().String() + " ")
+			walk(t.Elem())
+		case t.Kind() == reflect.Struct && strings.HasPrefix(t.PkgPath(), "final_task_golang/"):
+			b.WriteString(t.String() + "{")
+			for i := 0; i < t.NumField(); i++ {
+				b.WriteString(t.Field(i).Name + " ")
+				walk(t.Field(i).Type)
+				b.WriteString(";")
+			}
+			b.WriteString("}")
+		default:
+			b.WriteString(t.String())
+		}
+	}
+	walk(t)
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// datasetSnapshot - уже разобранный датасет в gob. Действителен, пока исходный файл
+// не изменился (размер и время изменения), а маппинг полей и политики проверки те же
+type datasetSnapshot struct {
+	Version    int
+	Layout     string
+	SourceSize int64
+	SourceMod  time.Time
+	Mapping    FieldMapping
+	// пусто - датасет читался LoadDatasetMapping, без проверки строк
+	Checks LoadOptions
+	Stats  LoadStats
+	Users  []model.User
+}
+
+// SnapshotPath - где лежит снимок датасета path
+func SnapshotPath(path string) string {
+	return path + ".snapshot"
+}
+
+// LoadDatasetSnapshot читает датасет как LoadDatasetMapping, но сначала пробует снимок рядом с файлом.
+// Если снимка нет или он устарел, датасет разбирается заново и снимок перезаписывается.
+// fromSnapshot сообщает, откуда взяты пользователи. Не получилось записать снимок - не ошибка:
+// датасет уже прочитан, а снимок попробуем записать при следующем запуске
+func LoadDatasetSnapshot(path string, mapping FieldMapping) (users []model.User, fromSnapshot bool, err error) {
+	users, _, fromSnapshot, err = loadDatasetSnapshot(path, mapping, LoadOptions{}, func() ([]model.User, LoadStats, error) {
+		users, err := LoadDatasetMapping(path, mapping)
+		return users, LoadStats{}, err
+	})
+	return users, fromSnapshot, err
+}
+
+// LoadDatasetSnapshotChecked - LoadDatasetSnapshot поверх LoadDatasetChecked. Итог проверки
+// хранится в снимке, так что stats одинаковы и после чтения из снимка
+func LoadDatasetSnapshotChecked(path string, mapping FieldMapping, opts LoadOptions) (users []model.User, stats LoadStats, fromSnapshot bool, err error) {
+	opts = opts.withDefaults()
+	return loadDatasetSnapshot(path, mapping, opts, func() ([]model.User, LoadStats, error) {
+		return LoadDatasetChecked(path, mapping, opts)
+	})
+}
+
+func loadDatasetSnapshot(path string, mapping FieldMapping, checks LoadOptions, load func() ([]model.User, LoadStats, error)) ([]model.User, LoadStats, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, LoadStats{}, false, err
+	}
+	if snap, ok := readSnapshot(SnapshotPath(path), info, mapping, checks); ok {
+		return snap.Users, snap.Stats, true, nil
+	}
+
+	users, stats, err := load()
+	if err != nil {
+		return nil, stats, false, err
+	}
+	snap := datasetSnapshot{
+		Version:    snapshotVersion,
+		Layout:     snapshotLayout,
+		SourceSize: info.Size(),
+		SourceMod:  info.ModTime(),
+		Mapping:    mapping,
+		Checks:     checks,
+		Stats:      stats,
+		Users:      users,
+	}
+	if err := writeGob(SnapshotPath(path), snap); err != nil {
+		log.Printf("dataset snapshot: %s", err)
+	}
+	return users, stats, false, nil
+}
+
+func readSnapshot(path string, source os.FileInfo, mapping FieldMapping, checks LoadOptions) (datasetSnapshot, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return datasetSnapshot{}, false
+	}
+	defer f.Close()
+
+	var snap datasetSnapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return datasetSnapshot{}, false
+	}
+	if snap.Version != snapshotVersion || snap.Layout != snapshotLayout || snap.SourceSize != source.Size() ||
+		!snap.SourceMod.Equal(source.ModTime()) || snap.Mapping != mapping || snap.Checks != checks {
+		return datasetSnapshot{}, false
+	}
+	return snap, true
+}
+
+// writeGob пишет снимок во временный файл и переименовывает, чтобы при сбое
+// рядом с датасетом не остался обрезанный снимок
+func writeGob(path string, snap interface{}) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,210 @@
+package searchserver
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"final_task_golang/pkg/searchcore"
+)
+
+const (
+	// сколько последних замеров времени ответа хранить на каждый обработчик
+	latencySamples = 1024
+	// сколько разных запросов считать, остальные не учитываются
+	maxTrackedQueries = 1000
+	topQueriesCount   = 10
+)
+
+// StatsResponse - ответ GET /admin/stats
+type StatsResponse struct {
+	Rows     int
+	LoadedAt time.Time
+	// сколько строк датасета загружено и отброшено проверкой
+	Load       LoadStats
+	Cache      CacheStats
+	PlanCache  CacheStats
+	TopQueries []QueryCount
+	Latency    map[string]LatencyStats
+	// сколько поисков с If-None-Match получили 304 без поиска
+	NotModified uint64
+	// сколько записей на каждом языке About, см. searchcore.DetectLanguage
+	Languages map[string]int
+	// состояние реплик, только на основном сервере
+	Replicas []ReplicaStatus `json:",omitempty"`
+	// режим сброса нагрузки, только с ServerConfig.SLO
+	SLO *SLOStatus `json:",omitempty"`
+	// сколько записей журнала аудита потеряно из-за ошибок записи, см. AuditLog.Failures
+	AuditFailures uint64 `json:",omitempty"`
+}
+
+type QueryCount struct {
+	Query string
+	Count int
+}
+
+// LatencyStats - перцентили времени ответа в миллисекундах по последним замерам
+type LatencyStats struct {
+	Count int
+	P50   float64
+	P90   float64
+	P99   float64
+}
+
+// serverStats копит счётчики для /admin/stats
+type serverStats struct {
+	mu      sync.Mutex
+	queries map[string]int
+	latency map[string]*latencyRing
+	// ответы 304 на условный поиск
+	notModified atomic.Uint64
+}
+
+type latencyRing struct {
+	samples []time.Duration
+	next    int
+}
+
+func newServerStats() *serverStats {
+	return &serverStats{
+		queries: map[string]int{},
+		latency: map[string]*latencyRing{},
+	}
+}
+
+func (st *serverStats) observe(endpoint string, d time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	ring, ok := st.latency[endpoint]
+	if !ok {
+		ring = &latencyRing{}
+		st.latency[endpoint] = ring
+	}
+	if len(ring.samples) < latencySamples {
+		ring.samples = append(ring.samples, d)
+		return
+	}
+	ring.samples[ring.next] = d
+	ring.next = (ring.next + 1) % latencySamples
+}
+
+func (st *serverStats) countQuery(q searchcore.Query) {
+	params := url.Values{}
+	for name, value := range q.Normalize().Params() {
+		params.Set(name, value)
+	}
+	key := params.Encode()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.queries[key]; ok || len(st.queries) < maxTrackedQueries {
+		st.queries[key]++
+	}
+}
+
+func (st *serverStats) topQueries() []QueryCount {
+	st.mu.Lock()
+	top := make([]QueryCount, 0, len(st.queries))
+	for q, n := range st.queries {
+		top = append(top, QueryCount{q, n})
+	}
+	st.mu.Unlock()
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Query < top[j].Query
+	})
+	if len(top) > topQueriesCount {
+		top = top[:topQueriesCount]
+	}
+	return top
+}
+
+func (st *serverStats) latencies() map[string]LatencyStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	result := make(map[string]LatencyStats, len(st.latency))
+	for endpoint, ring := range st.latency {
+		sorted := append([]time.Duration(nil), ring.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		result[endpoint] = LatencyStats{
+			Count: len(sorted),
+			P50:   milliseconds(percentile(sorted, 50)),
+			P90:   milliseconds(percentile(sorted, 90)),
+			P99:   milliseconds(percentile(sorted, 99)),
+		}
+	}
+	return result
+}
+
+// percentile по отсортированной выборке, методом ближайшего ранга
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// endpointName группирует пути по обработчикам, чтобы /users/1 и /users/2 считались вместе
+func endpointName(path string) string {
+	switch {
+	case path == "/users/bulk":
+		return path
+	case strings.HasPrefix(path, "/users/"):
+		return "/users/{id}"
+	case strings.HasSuffix(path, "/run") && strings.HasPrefix(path, "/saved/"):
+		return "/saved/{name}/run"
+	case strings.HasPrefix(path, "/saved/"):
+		return "/saved/{name}"
+	case strings.HasPrefix(path, "/alerts/"):
+		return "/alerts/{name}"
+	case path == "/saved", path == "/alerts":
+		return path
+	case path == "/graphql", path == "/rpc", path == "/msearch", path == "/search/explain", path == "/changes", strings.HasPrefix(path, "/admin/"):
+		return path
+	}
+	return "/"
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	users := s.snapshot()
+	s.mu.RLock()
+	loadedAt, load := s.loadedAt, s.loadStats
+	s.mu.RUnlock()
+
+	resp := StatsResponse{
+		Rows:        len(users),
+		LoadedAt:    loadedAt,
+		Load:        load,
+		Cache:       s.CacheStats(),
+		PlanCache:   s.PlanCacheStats(),
+		TopQueries:  s.counters.topQueries(),
+		Latency:     s.counters.latencies(),
+		NotModified: s.counters.notModified.Load(),
+		Languages:   s.core.Languages(),
+		Replicas:    s.ReplicationStatus(),
+	}
+	if s.slo != nil {
+		slo := s.slo.status()
+		resp.SLO = &slo
+	}
+	if s.cfg.AuditLog != nil {
+		resp.AuditFailures = s.cfg.AuditLog.Failures()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
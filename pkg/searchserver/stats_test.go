@@ -0,0 +1,85 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdminStats(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	h := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, CacheSize: 10})
+	h.SetLoadStats(LoadStats{Policy: LoadSkip, Rows: len(users) + 1, Loaded: len(users), Rejected: 1})
+
+	for i := 0; i < 3; i++ {
+		doRequest(h, "GET", "/?query=Boyd&limit=1", "", nil)
+	}
+	doRequest(h, "GET", "/?limit=1&order_field=Age", "", nil)
+	doRequest(h, "GET", "/users/1", "", nil)
+	doRequest(h, "GET", "/users/2", "", nil)
+
+	w := doRequest(h, "GET", "/admin/stats", "", map[string]string{"AccessToken": adminToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d", w.Code)
+	}
+	stats := StatsResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+
+	if stats.Rows != len(users) || time.Since(stats.LoadedAt) > time.Minute || stats.Load.Rejected != 1 {
+		t.Errorf("Error : unexpected dataset stats %+v", stats)
+	}
+	if stats.Cache.Hits != 2 || stats.Cache.Misses != 2 {
+		t.Errorf("Error : unexpected cache stats %+v", stats.Cache)
+	}
+	if len(stats.TopQueries) != 2 || stats.TopQueries[0].Count != 3 || stats.TopQueries[0].Query != "limit=1&query=Boyd" {
+		t.Errorf("Error : unexpected top queries %+v", stats.TopQueries)
+	}
+	if stats.Latency["/"].Count != 4 || stats.Latency["/users/{id}"].Count != 2 {
+		t.Errorf("Error : unexpected latency %+v", stats.Latency)
+	}
+
+	// About в датасете - lorem ipsum, фильтр lang отдаёт столько же, сколько насчитала статистика
+	total := 0
+	for _, n := range stats.Languages {
+		total += n
+	}
+	if total != len(users) || stats.Languages["la"] == 0 {
+		t.Errorf("Error : unexpected languages %+v", stats.Languages)
+	}
+	if found := decodeUsers(t, doRequest(h, "GET", "/?lang=la", "", nil)); len(found) != stats.Languages["la"] {
+		t.Errorf("Error : lang=la found %d, want %d", len(found), stats.Languages["la"])
+	}
+	if w := doRequest(h, "GET", "/?lang=klingon", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+}
+
+func TestAdminStatsForbidden(t *testing.T) {
+	w := doRequest(newTestHandler(), "GET", "/admin/stats", "", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	samples := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, time.Duration(i))
+	}
+	if percentile(samples, 50) != 50 || percentile(samples, 99) != 99 || percentile(samples[:1], 90) != 1 || percentile(nil, 50) != 0 {
+		t.Errorf("Error : unexpected percentiles")
+	}
+}
+
+func TestLatencyRing(t *testing.T) {
+	st := newServerStats()
+	for i := 0; i < latencySamples+10; i++ {
+		st.observe("/", time.Millisecond)
+	}
+	if l := st.latencies()["/"]; l.Count != latencySamples || l.P50 != 1 {
+		t.Errorf("Error : unexpected latency %+v", l)
+	}
+}
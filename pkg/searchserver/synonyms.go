@@ -0,0 +1,45 @@
+package searchserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"final_task_golang/pkg/searchcore"
+)
+
+// ReloadSynonyms перечитывает SynonymsFile и сбрасывает кэш страниц, собранных со старыми синонимами.
+// При ошибке прежние синонимы остаются в силе. Запросы as_of к прошлым версиям датасета
+// ищут с синонимами, с которыми сервер запускался
+func (s *Server) ReloadSynonyms() error {
+	if s.cfg.SynonymsFile == "" {
+		return fmt.Errorf("synonyms file is not configured")
+	}
+	synonyms, err := searchcore.LoadSynonyms(s.cfg.SynonymsFile)
+	if err != nil {
+		return err
+	}
+	s.core.SetSynonyms(synonyms)
+	s.synonymsEpoch.Add(1)
+	if s.cache != nil {
+		s.cache.invalidate()
+	}
+	return nil
+}
+
+// reloadSynonyms - POST /admin/synonyms/reload
+func (s *Server) reloadSynonyms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cfg.SynonymsFile == "" {
+		writeError(w, http.StatusNotImplemented, "synonyms file is not configured")
+		return
+	}
+	if err := s.ReloadSynonyms(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
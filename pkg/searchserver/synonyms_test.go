@@ -0,0 +1,50 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+func TestReloadSynonyms(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	path := filepath.Join(t.TempDir(), "synonyms.txt")
+	if err := ioutil.WriteFile(path, []byte("# пока пусто\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	h := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens, CacheSize: 10, SynonymsFile: path})
+	search := func() int {
+		rec := doRequest(h, "GET", "/?query=zzsyn&limit=5", "", nil)
+		found := []model.User{}
+		json.Unmarshal(rec.Body.Bytes(), &found)
+		return len(found)
+	}
+	admin := map[string]string{"AccessToken": adminToken}
+
+	if n := search(); n != 0 {
+		t.Fatalf("Error : unexpected %d users", n)
+	}
+	ioutil.WriteFile(path, []byte("zzsyn, nisi\n"), 0600)
+	if rec := doRequest(h, "POST", "/admin/synonyms/reload", "", admin); rec.Code != http.StatusNoContent {
+		t.Fatalf("Error : unexpected status %d", rec.Code)
+	}
+	// страница из кэша собрана без синонимов и должна быть сброшена
+	if n := search(); n != 5 {
+		t.Errorf("Error : synonyms not applied, %d users", n)
+	}
+
+	ioutil.WriteFile(path, []byte("zzsyn\n"), 0600)
+	if rec := doRequest(h, "POST", "/admin/synonyms/reload", "", admin); rec.Code != http.StatusInternalServerError {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+	if n := search(); n != 5 {
+		t.Errorf("Error : broken file dropped synonyms, %d users", n)
+	}
+	if rec := doRequest(newTestHandler(), "POST", "/admin/synonyms/reload", "", admin); rec.Code != http.StatusNotImplemented {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// имена прав в файле токенов
+var scopeNames = map[string]Scope{
+	"search": ScopeSearch,
+	"admin":  ScopeAdmin,
+}
+
+// ParseScope переводит имя прав ("search", "admin") в Scope
+func ParseScope(name string) (Scope, error) {
+	scope, ok := scopeNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown scope %q", name)
+	}
+	return scope, nil
+}
+
+// имена приоритетов в файле токенов
+var priorityNames = map[string]Priority{
+	"low":    PriorityLow,
+	"normal": PriorityNormal,
+	"high":   PriorityHigh,
+}
+
+// tokenEntry - запись файла токенов в полной форме
+type tokenEntry struct {
+	Scope    string `json:"scope"`
+	Priority string `json:"priority"`
+}
+
+// LoadTokens читает файл токенов - json-объект {"<токен>": "search" | "admin"}.
+// Вместо имени прав можно указать {"scope": "search", "priority": "low" | "normal" | "high"},
+// приоритеты читает LoadTokenPriorities
+func LoadTokens(path string) (map[string]Scope, error) {
+	tokens, _, err := readTokens(path)
+	return tokens, err
+}
+
+// LoadTokenPriorities читает приоритеты из файла токенов, см. LoadTokens.
+// Токены без priority в результат не попадают и считаются PriorityNormal
+func LoadTokenPriorities(path string) (map[string]Priority, error) {
+	_, priorities, err := readTokens(path)
+	return priorities, err
+}
+
+func readTokens(path string) (map[string]Scope, map[string]Priority, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("cant unpack tokens %s: %s", path, err)
+	}
+	tokens := make(map[string]Scope, len(raw))
+	priorities := map[string]Priority{}
+	for token, value := range raw {
+		entry := tokenEntry{}
+		if err := json.Unmarshal(value, &entry.Scope); err != nil {
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return nil, nil, fmt.Errorf("bad token entry %s in %s", value, path)
+			}
+		}
+		scope, ok := scopeNames[entry.Scope]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown scope %q in %s", entry.Scope, path)
+		}
+		if token == "" {
+			return nil, nil, fmt.Errorf("empty token in %s", path)
+		}
+		tokens[token] = scope
+		if entry.Priority != "" {
+			priority, ok := priorityNames[entry.Priority]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown priority %q in %s", entry.Priority, path)
+			}
+			priorities[token] = priority
+		}
+	}
+	return tokens, priorities, nil
+}
+
+// tokenScope - права токена по текущему набору токенов
+func (s *Server) tokenScope(token string) (Scope, bool) {
+	tokens := s.tokens.Load().(map[string]Scope)
+	scope, ok := tokens[token]
+	return scope, ok
+}
+
+// SetTokens атомарно подменяет набор токенов, запросы в полёте дорабатывают со старыми правами
+func (s *Server) SetTokens(tokens map[string]Scope) {
+	copied := make(map[string]Scope, len(tokens))
+	for token, scope := range tokens {
+		copied[token] = scope
+	}
+	s.tokens.Store(copied)
+}
+
+// tokenPriority - приоритет токена, по умолчанию PriorityNormal
+func (s *Server) tokenPriority(token string) Priority {
+	return s.priorities.Load().(map[string]Priority)[token]
+}
+
+// SetTokenPriorities атомарно подменяет приоритеты токенов
+func (s *Server) SetTokenPriorities(priorities map[string]Priority) {
+	copied := make(map[string]Priority, len(priorities))
+	for token, priority := range priorities {
+		copied[token] = priority
+	}
+	s.priorities.Store(copied)
+}
+
+// ReloadTokens перечитывает TokensFile, при ошибке прежние токены остаются в силе
+func (s *Server) ReloadTokens() error {
+	if s.cfg.TokensFile == "" {
+		return fmt.Errorf("tokens file is not configured")
+	}
+	tokens, priorities, err := readTokens(s.cfg.TokensFile)
+	if err != nil {
+		return err
+	}
+	s.SetTokens(tokens)
+	s.SetTokenPriorities(priorities)
+	return nil
+}
+
+// ReloadTokensOnSignal перечитывает токены по SIGHUP (или переданным сигналам), пока не вызван stop
+func (s *Server) ReloadTokensOnSignal(signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, signals...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := s.ReloadTokens(); err != nil {
+					log.Printf("tokens reload failed: %s", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// reloadTokens - POST /admin/tokens/reload
+func (s *Server) reloadTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cfg.TokensFile == "" {
+		writeError(w, http.StatusNotImplemented, "tokens file is not configured")
+		return
+	}
+	if err := s.ReloadTokens(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package searchserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func writeTokens(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+}
+
+func newTokensHandler(t *testing.T) (*Server, string) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	writeTokens(t, path, `{"abc-def": "search", "admin-token": "admin"}`)
+	tokens, err := LoadTokens(path)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	users, _ := LoadDataset("../../dataset.xml")
+	return NewServer(users, ServerConfig{Tokens: tokens, TokensFile: path}), path
+}
+
+func TestLoadTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	writeTokens(t, path, `{"a": "search", "b": "admin"}`)
+	tokens, err := LoadTokens(path)
+	if err != nil || len(tokens) != 2 || tokens["a"] != ScopeSearch || tokens["b"] != ScopeAdmin {
+		t.Errorf("Error : unexpected tokens %v %v", tokens, err)
+	}
+
+	for _, content := range []string{`{"a": "root"}`, `{"": "search"}`, `not json`} {
+		writeTokens(t, path, content)
+		if _, err := LoadTokens(path); err == nil {
+			t.Errorf("Error : %s accepted", content)
+		}
+	}
+}
+
+func TestLoadTokenPriorities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	writeTokens(t, path, `{"a": "search", "b": {"scope": "admin", "priority": "high"}, "c": {"scope": "search", "priority": "low"}}`)
+	tokens, err := LoadTokens(path)
+	if err != nil || len(tokens) != 3 || tokens["b"] != ScopeAdmin || tokens["c"] != ScopeSearch {
+		t.Errorf("Error : unexpected tokens %v %v", tokens, err)
+	}
+	priorities, err := LoadTokenPriorities(path)
+	if err != nil || len(priorities) != 2 || priorities["b"] != PriorityHigh || priorities["c"] != PriorityLow {
+		t.Errorf("Error : unexpected priorities %v %v", priorities, err)
+	}
+
+	for _, content := range []string{`{"a": {"scope": "search", "priority": "urgent"}}`, `{"a": {"priority": "high"}}`, `{"a": 1}`} {
+		writeTokens(t, path, content)
+		if _, err := LoadTokens(path); err == nil {
+			t.Errorf("Error : %s accepted", content)
+		}
+	}
+}
+
+func TestReloadTokensEndpoint(t *testing.T) {
+	h, path := newTokensHandler(t)
+
+	writeTokens(t, path, `{"new-token": "search", "admin-token": "admin"}`)
+	w := doRequest(h, "POST", "/admin/tokens/reload", "", map[string]string{"AccessToken": adminToken})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Error : unexpected status %d", w.Code)
+	}
+
+	if w := doRequest(h, "GET", "/?limit=1", "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Error : old token still works: %d", w.Code)
+	}
+	if w := doRequest(h, "GET", "/?limit=1", "", map[string]string{"AccessToken": "new-token"}); w.Code != http.StatusOK {
+		t.Errorf("Error : new token rejected: %d", w.Code)
+	}
+}
+
+// битый файл не должен оставить сервер без токенов
+func TestReloadTokensKeepsOldOnError(t *testing.T) {
+	h, path := newTokensHandler(t)
+
+	writeTokens(t, path, `{"new-token": "superuser"}`)
+	w := doRequest(h, "POST", "/admin/tokens/reload", "", map[string]string{"AccessToken": adminToken})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+	if w := doRequest(h, "GET", "/?limit=1", "", nil); w.Code != http.StatusOK {
+		t.Errorf("Error : old token rejected: %d", w.Code)
+	}
+}
+
+func TestReloadTokensNotConfigured(t *testing.T) {
+	w := doRequest(newTestHandler(), "POST", "/admin/tokens/reload", "", map[string]string{"AccessToken": adminToken})
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Error : unexpected status %d", w.Code)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package searchserver
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloadTokensOnSignal(t *testing.T) {
+	h, path := newTokensHandler(t)
+	stop := h.ReloadTokensOnSignal(syscall.SIGUSR1)
+	defer stop()
+
+	writeTokens(t, path, `{"new-token": "search"}`)
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := h.tokenScope("new-token"); ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("Error : tokens were not reloaded")
+}
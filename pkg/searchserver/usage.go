@@ -0,0 +1,180 @@
+package searchserver
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// TokenQuota - суточная квота токена, сутки считаются по UTC. 0 - без ограничения
+type TokenQuota struct {
+	Requests int64
+	// сколько байт тел ответов можно отдать
+	Bytes int64
+}
+
+func (q TokenQuota) exceeded(day UsageCounters) bool {
+	return q.Requests > 0 && day.Requests >= q.Requests || q.Bytes > 0 && day.Bytes >= q.Bytes
+}
+
+// UsageCounters - сколько запросов принято и сколько байт ответов отдано
+type UsageCounters struct {
+	Requests int64
+	Bytes    int64
+}
+
+// TokenUsage - потребление одного токена в GET /admin/usage
+type TokenUsage struct {
+	// отпечаток токена, как в журнале аудита
+	Token string
+	Scope Scope
+	// с запуска сервера
+	Requests int64
+	Bytes    int64
+	// за текущие сутки
+	Today UsageCounters
+	// сколько запросов за сутки отклонено по квоте
+	Rejected int64
+	Quota    *TokenQuota `json:",omitempty"`
+}
+
+// UsageResponse - ответ GET /admin/usage
+type UsageResponse struct {
+	// текущие сутки, UTC
+	Day    string
+	Tokens []TokenUsage
+}
+
+type tokenUsage struct {
+	scope    Scope
+	total    UsageCounters
+	today    UsageCounters
+	rejected int64
+}
+
+// usageTracker считает запросы и отданные байты по токенам и следит за суточными квотами
+type usageTracker struct {
+	defaultQuota TokenQuota
+	quotas       map[string]TokenQuota
+	now          func() time.Time
+
+	mu     sync.Mutex
+	day    string
+	tokens map[string]*tokenUsage
+}
+
+func newUsageTracker(defaultQuota TokenQuota, quotas map[string]TokenQuota) *usageTracker {
+	return &usageTracker{
+		defaultQuota: defaultQuota,
+		quotas:       quotas,
+		now:          time.Now,
+		tokens:       map[string]*tokenUsage{},
+	}
+}
+
+// quota - квота токена: своя или по умолчанию. На admin-токены квота по умолчанию не действует
+func (u *usageTracker) quota(token string, scope Scope) (TokenQuota, bool) {
+	if quota, ok := u.quotas[token]; ok {
+		return quota, true
+	}
+	if scope == ScopeAdmin || u.defaultQuota == (TokenQuota{}) {
+		return TokenQuota{}, false
+	}
+	return u.defaultQuota, true
+}
+
+// entry возвращает счётчики токена, в новые сутки обнуляя суточные. Вызывается под mu
+func (u *usageTracker) entry(token string, scope Scope) *tokenUsage {
+	if day := u.now().UTC().Format("2006-01-02"); day != u.day {
+		u.day = day
+		for _, t := range u.tokens {
+			t.today, t.rejected = UsageCounters{}, 0
+		}
+	}
+	t, ok := u.tokens[token]
+	if !ok {
+		t = &tokenUsage{}
+		u.tokens[token] = t
+	}
+	t.scope = scope
+	return t
+}
+
+// admit засчитывает запрос токену, а если суточная квота исчерпана - отклоняет его
+func (u *usageTracker) admit(token string, scope Scope) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	t := u.entry(token, scope)
+	if quota, ok := u.quota(token, scope); ok && quota.exceeded(t.today) {
+		t.rejected++
+		return false
+	}
+	t.total.Requests++
+	t.today.Requests++
+	return true
+}
+
+// served добавляет токену байты отданного ответа
+func (u *usageTracker) served(token string, scope Scope, bytes int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	t := u.entry(token, scope)
+	t.total.Bytes += bytes
+	t.today.Bytes += bytes
+}
+
+// untilNextDay - сколько осталось до обнуления квот
+func (u *usageTracker) untilNextDay() time.Duration {
+	now := u.now().UTC()
+	return now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+}
+
+func (u *usageTracker) report() UsageResponse {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	resp := UsageResponse{Day: u.day, Tokens: make([]TokenUsage, 0, len(u.tokens))}
+	for token, t := range u.tokens {
+		usage := TokenUsage{
+			Token:    TokenFingerprint(token),
+			Scope:    t.scope,
+			Requests: t.total.Requests,
+			Bytes:    t.total.Bytes,
+			Today:    t.today,
+			Rejected: t.rejected,
+		}
+		if quota, ok := u.quota(token, t.scope); ok {
+			usage.Quota = &quota
+		}
+		resp.Tokens = append(resp.Tokens, usage)
+	}
+	sort.Slice(resp.Tokens, func(i, j int) bool { return resp.Tokens[i].Token < resp.Tokens[j].Token })
+	return resp
+}
+
+// metered выполняет запрос в счёт квоты токена и засчитывает ему отданные байты.
+// Исчерпавший суточную квоту токен получает 429 до конца суток
+func (s *Server) metered(w http.ResponseWriter, r *http.Request, token string, scope Scope, h http.HandlerFunc) {
+	if !s.usage.admit(token, scope) {
+		retry := int(s.usage.untilNextDay()/time.Second) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		writeError(w, http.StatusTooManyRequests, model.ErrorQuotaExceeded)
+		return
+	}
+	rec := &statusRecorder{ResponseWriter: w}
+	h(rec, r)
+	s.usage.served(token, scope, rec.bytes)
+}
+
+// usageReport - GET /admin/usage
+func (s *Server) usageReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.usage.report())
+}
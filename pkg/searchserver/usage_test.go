@@ -0,0 +1,87 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func usageOf(t *testing.T, s *Server, token string) TokenUsage {
+	rec := doRequest(s, "GET", "/admin/usage", "", map[string]string{"AccessToken": adminToken})
+	resp := UsageResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected usage %d %v", rec.Code, err)
+	}
+	for _, usage := range resp.Tokens {
+		if usage.Token == TokenFingerprint(token) {
+			return usage
+		}
+	}
+	return TokenUsage{}
+}
+
+func TestTokenUsage(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	s := NewServer(users, ServerConfig{Tokens: testServerConfig.Tokens})
+
+	var served int64
+	for _, target := range []string{"/?limit=3", "/?limit=1", "/users/1"} {
+		served += int64(doRequest(s, "GET", target, "", nil).Body.Len())
+	}
+	usage := usageOf(t, s, accessToken)
+	if usage.Requests != 3 || usage.Bytes != served || usage.Today.Requests != 3 || usage.Quota != nil {
+		t.Errorf("Error : unexpected usage %+v, served %d", usage, served)
+	}
+	if usage := usageOf(t, s, adminToken); usage.Requests != 2 || usage.Scope != ScopeAdmin {
+		t.Errorf("Error : unexpected admin usage %+v", usage)
+	}
+}
+
+func TestTokenQuota(t *testing.T) {
+	users, _ := LoadDataset("../../dataset.xml")
+	const bytesToken = "bytes-token"
+	s := NewServer(users, ServerConfig{
+		Tokens:       map[string]Scope{accessToken: ScopeSearch, adminToken: ScopeAdmin, bytesToken: ScopeSearch},
+		DefaultQuota: TokenQuota{Requests: 2},
+		TokenQuotas:  map[string]TokenQuota{bytesToken: {Bytes: 10}},
+	})
+	day := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	s.usage.now = func() time.Time { return day }
+
+	for i := 0; i < 2; i++ {
+		if rec := doRequest(s, "GET", "/?limit=1", "", nil); rec.Code != http.StatusOK {
+			t.Fatalf("Error : unexpected status %d", rec.Code)
+		}
+	}
+	rec := doRequest(s, "GET", "/?limit=1", "", nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "3601" {
+		t.Errorf("Error : quota not enforced, status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// квота по байтам: первый ответ больше квоты отдаётся целиком, следующий - уже нет
+	bytes := map[string]string{"AccessToken": bytesToken}
+	if rec := doRequest(s, "GET", "/?limit=1", "", bytes); rec.Code != http.StatusOK {
+		t.Errorf("Error : unexpected status %d", rec.Code)
+	}
+	if rec := doRequest(s, "GET", "/?limit=1", "", bytes); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Error : byte quota not enforced, status %d", rec.Code)
+	}
+
+	// admin-токен квота по умолчанию не касается
+	for i := 0; i < 3; i++ {
+		usageOf(t, s, adminToken)
+	}
+	usage := usageOf(t, s, accessToken)
+	if usage.Requests != 2 || usage.Rejected != 1 || usage.Quota == nil || usage.Quota.Requests != 2 {
+		t.Errorf("Error : unexpected usage %+v", usage)
+	}
+
+	// в новые сутки квота снова доступна, а счётчики с запуска сохраняются
+	day = day.Add(2 * time.Hour)
+	if rec := doRequest(s, "GET", "/?limit=1", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("Error : quota not reset, status %d", rec.Code)
+	}
+	if usage := usageOf(t, s, accessToken); usage.Requests != 3 || usage.Today.Requests != 1 || usage.Rejected != 0 {
+		t.Errorf("Error : unexpected usage %+v", usage)
+	}
+}
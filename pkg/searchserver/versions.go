@@ -0,0 +1,185 @@
+package searchserver
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// datasetVersion - датасет, по которому сейчас не ищут: подготовленный к переключению или прежний, для отката
+type datasetVersion struct {
+	users    []model.User
+	loadedAt time.Time
+	load     LoadStats
+}
+
+// datasetVersions - версии датасета рядом с текущим. Каждая держит свой срез пользователей,
+// так что подготовленная и прежняя версии занимают память, пока их не выбросят
+type datasetVersions struct {
+	mu       sync.Mutex
+	staged   *datasetVersion
+	previous *datasetVersion
+}
+
+// DatasetDiff - чем версия отличается от текущего датасета, по Id
+type DatasetDiff struct {
+	Added   int
+	Removed int
+	Changed int
+}
+
+// DatasetVersionInfo - одна версия в GET /admin/datasets
+type DatasetVersionInfo struct {
+	Rows     int
+	LoadedAt time.Time
+	// только у подготовленной и прежней версий
+	Diff *DatasetDiff `json:",omitempty"`
+}
+
+// DatasetVersionsResponse - ответ /admin/datasets
+type DatasetVersionsResponse struct {
+	Active   DatasetVersionInfo
+	Staged   *DatasetVersionInfo `json:",omitempty"`
+	Previous *DatasetVersionInfo `json:",omitempty"`
+}
+
+// diffVersions сравнивает версию с текущим датасетом. Версия записи не сравнивается:
+// после переключения она всё равно начнётся заново
+func diffVersions(active, other []model.User) DatasetDiff {
+	byID := make(map[int]model.User, len(active))
+	for _, u := range active {
+		byID[u.Id] = u
+	}
+	diff := DatasetDiff{}
+	for _, u := range other {
+		old, ok := byID[u.Id]
+		if !ok {
+			diff.Added++
+			continue
+		}
+		delete(byID, u.Id)
+		old.Version, u.Version = 0, 0
+		if old != u {
+			diff.Changed++
+		}
+	}
+	diff.Removed = len(byID)
+	return diff
+}
+
+func (v *datasetVersion) info(active []model.User) *DatasetVersionInfo {
+	if v == nil {
+		return nil
+	}
+	diff := diffVersions(active, v.users)
+	return &DatasetVersionInfo{Rows: len(v.users), LoadedAt: v.loadedAt, Diff: &diff}
+}
+
+// StageDataset кладёт users рядом с текущим датасетом, не трогая поиск. Прежняя
+// подготовленная версия выбрасывается
+func (s *Server) StageDataset(users []model.User, load LoadStats) {
+	s.versions.mu.Lock()
+	defer s.versions.mu.Unlock()
+	s.versions.staged = &datasetVersion{users: users, loadedAt: time.Now(), load: load}
+}
+
+// current - текущий датасет как версия, для отката
+func (s *Server) current() *datasetVersion {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &datasetVersion{users: s.users, loadedAt: s.loadedAt, load: s.loadStats}
+}
+
+// activate делает v текущим датасетом, как Reload, и возвращает прежний. Вызывается под versions.mu
+func (s *Server) activate(v *datasetVersion) *datasetVersion {
+	previous := s.current()
+	s.Reload(v.users)
+	s.SetLoadStats(v.load)
+	return previous
+}
+
+// SwitchDataset делает подготовленную версию текущей, а текущую оставляет для RollbackDataset.
+// false - подготовленной версии нет
+func (s *Server) SwitchDataset() bool {
+	s.versions.mu.Lock()
+	defer s.versions.mu.Unlock()
+	if s.versions.staged == nil {
+		return false
+	}
+	s.versions.previous = s.activate(s.versions.staged)
+	s.versions.staged = nil
+	return true
+}
+
+// RollbackDataset возвращает версию, которая была текущей до переключения. Заменённая
+// становится прежней, так что повторный откат снова переключает вперёд. false - отката нет
+func (s *Server) RollbackDataset() bool {
+	s.versions.mu.Lock()
+	defer s.versions.mu.Unlock()
+	if s.versions.previous == nil {
+		return false
+	}
+	s.versions.previous = s.activate(s.versions.previous)
+	return true
+}
+
+func (s *Server) datasetVersions() DatasetVersionsResponse {
+	s.versions.mu.Lock()
+	defer s.versions.mu.Unlock()
+	active := s.current()
+	return DatasetVersionsResponse{
+		Active:   DatasetVersionInfo{Rows: len(active.users), LoadedAt: active.loadedAt},
+		Staged:   s.versions.staged.info(active.users),
+		Previous: s.versions.previous.info(active.users),
+	}
+}
+
+// datasetsHandler - GET /admin/datasets, POST /admin/datasets/switch и /admin/datasets/rollback,
+// DELETE /admin/datasets/staged и /admin/datasets/previous
+func (s *Server) datasetsHandler(w http.ResponseWriter, r *http.Request) {
+	action := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/datasets"), "/")
+	method := http.MethodPost
+	switch action {
+	case "":
+		method = http.MethodGet
+	case "staged", "previous":
+		method = http.MethodDelete
+	case "switch", "rollback":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if len(s.cfg.Aggregate.URLs) > 0 {
+		writeError(w, http.StatusNotImplemented, "aggregator has no dataset")
+		return
+	}
+
+	ok := true
+	switch action {
+	case "switch":
+		ok = s.SwitchDataset()
+	case "rollback":
+		ok = s.RollbackDataset()
+	case "staged", "previous":
+		s.versions.mu.Lock()
+		if action == "staged" {
+			s.versions.staged = nil
+		} else {
+			s.versions.previous = nil
+		}
+		s.versions.mu.Unlock()
+	}
+	if !ok {
+		writeError(w, http.StatusConflict, model.ErrorNoDatasetVersion)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.datasetVersions())
+}
@@ -0,0 +1,71 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func datasetsCall(t *testing.T, h http.Handler, method, target string) (int, DatasetVersionsResponse) {
+	w := doRequest(h, method, target, "", map[string]string{"AccessToken": adminToken})
+	resp := DatasetVersionsResponse{}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Error : %v %s", err, w.Body.String())
+		}
+	}
+	return w.Code, resp
+}
+
+func TestDatasetStageSwitchRollback(t *testing.T) {
+	h := newTestHandler()
+	rows := len(h.snapshot())
+
+	// Id 1 меняется, 1000 - новый, остальные пропадают
+	staged := `<root>
+<row><id>1</id><first_name>Changed</first_name><age>30</age></row>
+<row><id>1000</id><first_name>New</first_name><age>40</age></row>
+</root>`
+	code, report := doImport(t, h, "/admin/import?stage=true", staged)
+	if code != http.StatusOK || !report.Staged || report.Applied {
+		t.Fatalf("Error : unexpected report %v %+v", code, report)
+	}
+	if len(h.snapshot()) != rows {
+		t.Fatalf("Error : staged dataset went live")
+	}
+
+	code, versions := datasetsCall(t, h, "GET", "/admin/datasets")
+	if code != http.StatusOK || versions.Staged == nil || versions.Staged.Rows != 2 || versions.Previous != nil {
+		t.Fatalf("Error : unexpected versions %v %+v", code, versions)
+	}
+	if diff := *versions.Staged.Diff; diff.Added != 1 || diff.Removed != rows-1 || diff.Changed != 1 {
+		t.Errorf("Error : unexpected diff %+v", diff)
+	}
+
+	code, versions = datasetsCall(t, h, "POST", "/admin/datasets/switch")
+	if code != http.StatusOK || versions.Active.Rows != 2 || versions.Staged != nil || versions.Previous == nil || versions.Previous.Rows != rows {
+		t.Fatalf("Error : unexpected versions after switch %v %+v", code, versions)
+	}
+	if u, ok := h.lookupUser(1000, ScopeSearch); !ok || strings.TrimSpace(u.Name) != "New" {
+		t.Errorf("Error : switched dataset not searched, %+v", u)
+	}
+	if code, _ := datasetsCall(t, h, "POST", "/admin/datasets/switch"); code != http.StatusConflict {
+		t.Errorf("Error : switch without staged version, status %d", code)
+	}
+
+	code, versions = datasetsCall(t, h, "POST", "/admin/datasets/rollback")
+	if code != http.StatusOK || versions.Active.Rows != rows || versions.Previous == nil || versions.Previous.Rows != 2 {
+		t.Fatalf("Error : unexpected versions after rollback %v %+v", code, versions)
+	}
+	if _, ok := h.lookupUser(1000, ScopeSearch); ok {
+		t.Errorf("Error : rolled back dataset still searched")
+	}
+
+	if code, versions = datasetsCall(t, h, "DELETE", "/admin/datasets/previous"); code != http.StatusOK || versions.Previous != nil {
+		t.Errorf("Error : previous version not dropped %v %+v", code, versions)
+	}
+	if code, _ := datasetsCall(t, h, "POST", "/admin/datasets/rollback"); code != http.StatusConflict {
+		t.Errorf("Error : rollback without previous version, status %d", code)
+	}
+}
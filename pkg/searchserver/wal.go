@@ -0,0 +1,162 @@
+package searchserver
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+const (
+	// версия формата контрольной точки журнала
+	walCheckpointVersion = 1
+
+	defaultWALCompactInterval = 10 * time.Minute
+)
+
+// walCheckpoint - датасет на момент последнего сжатия журнала
+type walCheckpoint struct {
+	Version int
+	Users   []model.User
+}
+
+// WAL - журнал правок датасета (JSON lines, одна replicationChange на строку). Правка попадает
+// в журнал до того, как станет видна поиску, так что после перезапуска её можно повторить
+// поверх исходного датасета. Сжатие пишет текущий датасет в контрольную точку
+// (WALCheckpointPath) и очищает журнал; контрольная точка заменяет исходный датасет целиком.
+//
+// Записи не синхронизируются с диском по одной: при сбое ОС можно потерять последние правки,
+// при падении процесса - нет
+type WAL struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// WALCheckpointPath - где лежит контрольная точка журнала path
+func WALCheckpointPath(path string) string {
+	return path + ".snapshot"
+}
+
+// OpenWAL открывает журнал правок на дозапись и возвращает датасет после всех правок:
+// контрольную точку, если она есть, иначе base, и поверх - записи журнала.
+// Обрезанная последняя строка (процесс упал посреди записи) отбрасывается
+func OpenWAL(path string, base []model.User) (*WAL, []model.User, error) {
+	users := base
+	if f, err := os.Open(WALCheckpointPath(path)); err == nil {
+		var cp walCheckpoint
+		err := gob.NewDecoder(f).Decode(&cp)
+		f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if cp.Version == walCheckpointVersion {
+			users = cp.Users
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	var changes []replicationChange
+	valid := int64(0)
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		var change replicationChange
+		if err := json.Unmarshal(line, &change); err != nil {
+			break
+		}
+		changes = append(changes, change)
+		valid += int64(len(line))
+	}
+	// хвост после последней целой записи дописывать нельзя - следующая запись склеится с ним
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if _, err := f.Seek(valid, 0); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if len(changes) > 0 {
+		log.Printf("wal: replayed %d changes from %s", len(changes), path)
+	}
+	return &WAL{path: path, file: f}, applyChanges(users, changes), nil
+}
+
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+func (w *WAL) append(changes ...replicationChange) error {
+	var buf []byte
+	for _, change := range changes {
+		line, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.file.Write(buf)
+	return err
+}
+
+// compact пишет users в контрольную точку и очищает журнал. Если процесс упадёт между
+// этими шагами, журнал повторится поверх контрольной точки, в которую он уже вошёл -
+// это безопасно, правки идемпотентны
+func (w *WAL) compact(users []model.User) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := writeGob(WALCheckpointPath(w.path), walCheckpoint{Version: walCheckpointVersion, Users: users}); err != nil {
+		return err
+	}
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := w.file.Seek(0, 0)
+	return err
+}
+
+// compactWAL сжимает журнал сервера. Под s.mu.RLock правки ждут, а поиск - нет
+func (s *Server) compactWAL() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.WAL.compact(s.users)
+}
+
+// compactLoop периодически сжимает журнал, пока не закроют done
+func (s *Server) compactLoop(interval time.Duration) {
+	defer s.walWG.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.walDone:
+			return
+		case <-ticker.C:
+			// в режиме обслуживания хранилище не трогаем
+			if s.maintenance.Load() {
+				continue
+			}
+			if err := s.compactWAL(); err != nil {
+				log.Printf("wal compaction: %s", err)
+			}
+		}
+	}
+}
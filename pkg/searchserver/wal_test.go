@@ -0,0 +1,84 @@
+package searchserver
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+// restartWAL имитирует перезапуск: журнал открывается заново поверх исходного датасета
+func restartWAL(t *testing.T, path string, base []model.User) (*Server, *WAL) {
+	wal, users, err := OpenWAL(path, base)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	cfg := testServerConfig
+	cfg.WAL = wal
+	return NewServer(users, cfg), wal
+}
+
+func TestWALReplay(t *testing.T) {
+	base, _ := LoadDataset("../../dataset.xml")
+	path := filepath.Join(t.TempDir(), "dataset.wal")
+	admin := map[string]string{"AccessToken": adminToken}
+
+	s, wal := restartWAL(t, path, base)
+	if w := doRequest(s, "PATCH", "/users/3", `{"Age": 99}`, admin); w.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+	if w := doRequest(s, "DELETE", "/users/5", "", admin); w.Code != http.StatusNoContent {
+		t.Fatalf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+	if w := doRequest(s, "POST", "/admin/purge", "", admin); w.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+	expected := s.snapshot()
+	s.Close()
+	wal.Close()
+
+	// процесс упал посреди записи
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"op": "put", "us`)
+	f.Close()
+
+	s, wal = restartWAL(t, path, base)
+	if got := s.snapshot(); !equalUsers(got, expected) {
+		t.Errorf("Error : replay mismatch, %d users, want %d", len(got), len(expected))
+	}
+
+	// после сжатия журнал пуст, а датасет берётся из контрольной точки
+	if err := s.compactWAL(); err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	if w := doRequest(s, "PATCH", "/users/0", `{"Age": 1}`, admin); w.Code != http.StatusOK {
+		t.Fatalf("Error : unexpected status %d %s", w.Code, w.Body)
+	}
+	expected = s.snapshot()
+	s.Close()
+	wal.Close()
+	if data, _ := os.ReadFile(path); len(data) == 0 || data[0] != '{' {
+		t.Errorf("Error : wal was not truncated before append %q", data)
+	}
+
+	s, wal = restartWAL(t, path, nil)
+	defer wal.Close()
+	defer s.Close()
+	if got := s.snapshot(); !equalUsers(got, expected) {
+		t.Errorf("Error : checkpoint mismatch, %d users, want %d", len(got), len(expected))
+	}
+}
+
+func equalUsers(lhs, rhs []model.User) bool {
+	if len(lhs) != len(rhs) {
+		return false
+	}
+	for i := range lhs {
+		if lhs[i] != rhs[i] {
+			return false
+		}
+	}
+	return true
+}
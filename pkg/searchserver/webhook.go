@@ -0,0 +1,186 @@
+package searchserver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"final_task_golang/pkg/model"
+)
+
+// события вебхуков. Записи создаются только через POST /users/bulk
+const (
+	EventUserCreated     = "user.created"
+	EventUserUpdated     = "user.updated"
+	EventUserDeleted     = "user.deleted"
+	EventUsersPurged     = "users.purged"
+	EventDatasetReloaded = "dataset.reloaded"
+)
+
+const (
+	// сколько событий ждёт отправки на один вебхук, лишние отбрасываются с записью в лог
+	webhookQueueSize        = 1024
+	webhookTimeout          = 5 * time.Second
+	defaultWebhookRetry     = time.Second
+	defaultWebhookAttempts  = 5
+	webhookSignatureHeader  = "X-Webhook-Signature"
+	webhookEventHeader      = "X-Webhook-Event"
+	webhookSignaturePrefix  = "sha256="
+	webhookDeliveryIDHeader = "X-Webhook-Delivery"
+)
+
+// WebhookConfig - куда сообщать об изменениях датасета. Тело запроса - WebhookEvent в json,
+// подпись - HMAC-SHA256 тела по Secret в заголовке X-Webhook-Signature: sha256=<hex>.
+// Доставка асинхронная и с повторами, порядок событий одного вебхука сохраняется
+type WebhookConfig struct {
+	URL    string
+	Secret string
+	// какие события отправлять, пусто - все
+	Events []string
+	// пауза перед первым повтором, дальше удваивается, 0 - defaultWebhookRetry
+	RetryInterval time.Duration
+	// сколько всего попыток доставки, 0 - defaultWebhookAttempts
+	MaxAttempts int
+}
+
+// WebhookEvent - тело запроса вебхука
+type WebhookEvent struct {
+	// номер события, одинаковый во всех повторах - по нему получатель отсеивает дубли
+	ID   uint64    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// запись после изменения, для user.*
+	User *model.User `json:"user,omitempty"`
+	// сколько записей в датасете после события, для users.purged и dataset.reloaded
+	Rows int `json:"rows,omitempty"`
+	// для alert.triggered, см. model.Alert
+	Alert *AlertNotice `json:"alert,omitempty"`
+}
+
+// SignWebhook - подпись тела вебхука, как её считает сервер
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhook struct {
+	cfg    WebhookConfig
+	events map[string]bool
+	client *http.Client
+	queue  chan WebhookEvent
+	// закрывается в close: оставшиеся события отправляются по разу, без повторов
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newWebhook(cfg WebhookConfig) *webhook {
+	h := &webhook{
+		cfg:    cfg,
+		client: &http.Client{Timeout: webhookTimeout},
+		queue:  make(chan WebhookEvent, webhookQueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if len(cfg.Events) > 0 {
+		h.events = make(map[string]bool, len(cfg.Events))
+		for _, e := range cfg.Events {
+			h.events[e] = true
+		}
+	}
+	go h.run()
+	return h
+}
+
+// enqueue вызывается под Server.mu, поэтому не ждёт ни сети, ни места в очереди
+func (h *webhook) enqueue(e WebhookEvent) {
+	if h.events != nil && !h.events[e.Type] {
+		return
+	}
+	select {
+	case h.queue <- e:
+	default:
+		log.Printf("webhook %s: queue is full, event %d %s dropped", model.Redact(h.cfg.URL), e.ID, e.Type)
+	}
+}
+
+// close дожидается отправки уже поставленных в очередь событий
+func (h *webhook) close() {
+	close(h.stop)
+	close(h.queue)
+	<-h.done
+}
+
+func (h *webhook) run() {
+	defer close(h.done)
+	for e := range h.queue {
+		h.deliver(e)
+	}
+}
+
+func (h *webhook) deliver(e WebhookEvent) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("webhook %s: %s", model.Redact(h.cfg.URL), model.Redact(err.Error()))
+		return
+	}
+	retry := durationOr(h.cfg.RetryInterval, defaultWebhookRetry)
+	attempts := intOr(h.cfg.MaxAttempts, defaultWebhookAttempts)
+	for attempt := 1; ; attempt++ {
+		err := h.post(e, body)
+		if err == nil {
+			return
+		}
+		if attempt == attempts {
+			log.Printf("webhook %s: event %d %s dropped after %d attempts: %s", model.Redact(h.cfg.URL), e.ID, e.Type, attempt, model.Redact(err.Error()))
+			return
+		}
+		select {
+		case <-h.stop:
+			log.Printf("webhook %s: event %d %s dropped on shutdown: %s", model.Redact(h.cfg.URL), e.ID, e.Type, model.Redact(err.Error()))
+			return
+		case <-time.After(retry):
+		}
+		retry *= 2
+	}
+}
+
+func (h *webhook) post(e WebhookEvent, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, h.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, e.Type)
+	req.Header.Set(webhookDeliveryIDHeader, fmt.Sprint(e.ID))
+	req.Header.Set(webhookSignatureHeader, SignWebhook(h.cfg.Secret, body))
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// emit рассылает событие по вебхукам, вызывается под s.mu
+func (s *Server) emit(e WebhookEvent) {
+	if len(s.webhooks) == 0 {
+		return
+	}
+	s.eventSeq++
+	e.ID, e.Time = s.eventSeq, time.Now().UTC()
+	for _, h := range s.webhooks {
+		h.enqueue(e)
+	}
+}
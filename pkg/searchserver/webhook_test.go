@@ -0,0 +1,76 @@
+package searchserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	var events []WebhookEvent
+	failures := 1
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Webhook-Signature") != SignWebhook("secret", body) {
+			t.Errorf("Error : bad signature %s", r.Header.Get("X-Webhook-Signature"))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// первая доставка падает, событие должно прийти повтором
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var e WebhookEvent
+		json.Unmarshal(body, &e)
+		events = append(events, e)
+	}))
+	defer receiver.Close()
+
+	users, _ := LoadDataset("../../dataset.xml")
+	cfg := testServerConfig
+	cfg.Webhooks = []WebhookConfig{{
+		URL:           receiver.URL,
+		Secret:        "secret",
+		Events:        []string{EventUserUpdated, EventUserDeleted, EventDatasetReloaded},
+		RetryInterval: time.Millisecond,
+	}}
+	s := NewServer(users, cfg)
+
+	admin := map[string]string{"AccessToken": adminToken}
+	doRequest(s, "PATCH", "/users/3", `{"Age": 99}`, admin)
+	doRequest(s, "DELETE", "/users/5", "", admin)
+	// users.purged не входит в фильтр
+	doRequest(s, "POST", "/admin/purge", "", admin)
+	s.Reload(users[:10])
+
+	// повтор после ошибки идёт в фоне, Close его бы отменил
+	deadline := time.Now().Add(5 * time.Second)
+	for received := 0; received < 3 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		mu.Lock()
+		received = len(events)
+		mu.Unlock()
+	}
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 3 {
+		t.Fatalf("Error : unexpected events %+v", events)
+	}
+	if e := events[0]; e.Type != EventUserUpdated || e.User == nil || e.User.Id != 3 || e.User.Age != 99 || e.ID != 1 {
+		t.Errorf("Error : unexpected event %+v", e)
+	}
+	if e := events[1]; e.Type != EventUserDeleted || e.User == nil || e.User.Id != 5 {
+		t.Errorf("Error : unexpected event %+v", e)
+	}
+	if e := events[2]; e.Type != EventDatasetReloaded || e.Rows != 10 {
+		t.Errorf("Error : unexpected event %+v", e)
+	}
+}
@@ -0,0 +1,4 @@
+// Package searchpb - сгенерированные из search.proto сообщения и gRPC-сервис поиска
+package searchpb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative searchpb/search.proto
@@ -0,0 +1,63 @@
+package searchtest
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Delay - задержка ответа: Fixed плюс случайная добавка от 0 до Jitter
+type Delay struct {
+	Fixed  time.Duration
+	Jitter time.Duration
+}
+
+// WithDelay задерживает ответы на запросы к path ("/" - поиск, "*" - любой путь).
+// Нужна, чтобы детерминированно проверять таймауты, повторы и hedging клиента
+func WithDelay(path string, d Delay) Option {
+	return func(s *Server) {
+		if s.delays == nil {
+			s.delays = map[string]Delay{}
+		}
+		s.delays[path] = d
+	}
+}
+
+// WithSeed задаёт зерно для случайной части задержек, по умолчанию 1
+func WithSeed(seed int64) Option {
+	return func(s *Server) {
+		s.rnd = rand.New(rand.NewSource(seed))
+	}
+}
+
+func (s *Server) delayFor(path string) time.Duration {
+	d, ok := s.delays[path]
+	if !ok {
+		if d, ok = s.delays["*"]; !ok {
+			return 0
+		}
+	}
+	delay := d.Fixed
+	if d.Jitter > 0 {
+		s.rndMu.Lock()
+		delay += time.Duration(s.rnd.Int63n(int64(d.Jitter)))
+		s.rndMu.Unlock()
+	}
+	return delay
+}
+
+// withLatency оборачивает обработчик задержкой, прерывается, если клиент ушёл раньше
+func (s *Server) withLatency(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d := s.delayFor(r.URL.Path); d > 0 {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,64 @@
+package searchtest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	s := NewServer(testUsers, WithDelay("/", Delay{Fixed: 100 * time.Millisecond}))
+	defer s.Close()
+
+	start := time.Now()
+	if status, _, _ := get(t, s, s.Token, ""); status != http.StatusOK {
+		t.Errorf("Error : unexpected status %d", status)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Error : response was not delayed: %s", elapsed)
+	}
+}
+
+func TestDelayOtherRoute(t *testing.T) {
+	s := NewServer(testUsers, WithDelay("/slow", Delay{Fixed: time.Second}))
+	defer s.Close()
+
+	start := time.Now()
+	get(t, s, s.Token, "")
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Error : unexpected delay %s", elapsed)
+	}
+}
+
+// клиент с таймаутом меньше задержки должен получить ошибку
+func TestDelayClientTimeout(t *testing.T) {
+	s := NewServer(testUsers, WithDelay("*", Delay{Fixed: time.Second}))
+	defer s.Close()
+
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	req, _ := http.NewRequest("GET", s.URL, nil)
+	req.Header.Set("AccessToken", s.Token)
+	if _, err := client.Do(req); err == nil {
+		t.Errorf("Error : expected timeout")
+	}
+}
+
+// одинаковое зерно - одинаковые случайные задержки
+func TestDelayJitterSeed(t *testing.T) {
+	delays := func() []time.Duration {
+		s := NewServer(nil, WithDelay("/", Delay{Jitter: time.Second}), WithSeed(7))
+		defer s.Close()
+		var d []time.Duration
+		for i := 0; i < 5; i++ {
+			d = append(d, s.delayFor("/"))
+		}
+		return d
+	}
+	first, second := delays(), delays()
+	for i := range first {
+		if first[i] != second[i] || first[i] >= time.Second {
+			t.Errorf("Error : %v != %v", first, second)
+			break
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package searchtest - встраиваемый в тесты сервер поиска пользователей.
+// Это настоящий searchserver.Server на httptest.Server с одним токеном и
+// управляемыми задержками, так что клиентов поиска можно тестировать без внешней системы:
+//
+//	srv := searchtest.NewServer([]searchtest.User{{Id: 1, Name: "Boyd"}})
+//	defer srv.Close()
+//	client := searchclient.NewSearchClient(srv.Token, srv.URL)
+package searchtest
+
+import (
+	"math/rand"
+	"net/http/httptest"
+	"sync"
+
+	"final_task_golang/pkg/model"
+	"final_task_golang/pkg/searchserver"
+)
+
+// DefaultToken - токен, который сервер принимает, если не задан другой
+const DefaultToken = "searchtest-token"
+
+const (
+	OrderByAsc  = model.OrderByAsc
+	OrderByAsIs = model.OrderByAsIs
+	OrderByDesc = model.OrderByDesc
+)
+
+// User в том виде, в каком его отдаёт сервер
+type User = model.User
+
+// Server - searchserver.Server поверх httptest.Server
+type Server struct {
+	*httptest.Server
+	// токен, с которым нужно ходить в сервер
+	Token string
+
+	delays map[string]Delay
+	rndMu  sync.Mutex
+	rnd    *rand.Rand
+}
+
+// Option настраивает сервер при создании
+type Option func(*Server)
+
+// WithToken задаёт принимаемый токен вместо DefaultToken
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.Token = token
+	}
+}
+
+// NewServer запускает сервер с данным набором пользователей, порядок users - порядок "как встретилось"
+func NewServer(users []User, opts ...Option) *Server {
+	s := &Server{
+		Token: DefaultToken,
+		rnd:   rand.New(rand.NewSource(1)),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	srv := searchserver.NewServer(append([]User(nil), users...), searchserver.ServerConfig{
+		Tokens: map[string]searchserver.Scope{s.Token: searchserver.ScopeSearch},
+	})
+	s.Server = httptest.NewServer(s.withLatency(srv))
+	return s
+}
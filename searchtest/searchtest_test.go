@@ -0,0 +1,64 @@
+package searchtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"final_task_golang/pkg/model"
+)
+
+var testUsers = []User{
+	{Id: 0, Name: "Boyd Wolf", Age: 22, About: "Nulla cillum"},
+	{Id: 1, Name: "Hilda Mayer", Age: 21, About: "Sit commodo"},
+	{Id: 2, Name: "Brooks Aguilar", Age: 25, About: "Velit ullamco"},
+	{Id: 3, Name: "Everett Dillard", Age: 27, About: "Sint nulla", Deleted: true},
+}
+
+func get(t *testing.T, s *Server, token, params string) (int, []User, string) {
+	req, _ := http.NewRequest("GET", s.URL+"?"+params, nil)
+	req.Header.Set("AccessToken", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error : %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		e := model.SearchErrorResponse{}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return resp.StatusCode, nil, e.Error
+	}
+	users := []User{}
+	json.NewDecoder(resp.Body).Decode(&users)
+	return resp.StatusCode, users, ""
+}
+
+func TestSearch(t *testing.T) {
+	s := NewServer(testUsers)
+	defer s.Close()
+
+	_, users, _ := get(t, s, s.Token, "order_field=Age&order_by=1&limit=2&offset=1")
+	if len(users) != 2 || users[0].Id != 0 || users[1].Id != 1 {
+		t.Errorf("Error : unexpected users %v", users)
+	}
+
+	_, users, _ = get(t, s, s.Token, "query=ll")
+	if len(users) != 2 || users[0].Id != 0 || users[1].Id != 2 {
+		t.Errorf("Error : unexpected users %v", users)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := NewServer(testUsers, WithToken("secret"))
+	defer s.Close()
+
+	if status, _, _ := get(t, s, DefaultToken, ""); status != http.StatusUnauthorized {
+		t.Errorf("Error : unexpected status %d", status)
+	}
+	if status, _, msg := get(t, s, "secret", "order_field=About&order_by=-1"); status != http.StatusBadRequest || msg != "ErrorBadOrderField" {
+		t.Errorf("Error : unexpected response %d %s", status, msg)
+	}
+	if status, _, msg := get(t, s, "secret", "limit=-1"); status != http.StatusBadRequest || msg != "ErrorBadLimit" {
+		t.Errorf("Error : unexpected response %d %s", status, msg)
+	}
+}